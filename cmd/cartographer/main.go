@@ -7,12 +7,17 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
+	"github.com/rand/cartographer/internal/api/graphql"
 	"github.com/rand/cartographer/internal/api/rest"
 	"github.com/rand/cartographer/internal/api/websocket"
 	"github.com/rand/cartographer/internal/beads"
+	"github.com/rand/cartographer/internal/claude"
+	"github.com/rand/cartographer/internal/cli"
+	"github.com/rand/cartographer/internal/domain"
 	"github.com/rand/cartographer/internal/storage"
 )
 
@@ -20,6 +25,9 @@ const (
 	defaultPort    = "8080"
 	defaultHost    = "127.0.0.1" // localhost only for security
 	defaultDataDir = "./data"
+
+	// How often expired work leases are released
+	leaseSweepInterval = 30 * time.Second
 )
 
 // App holds application state
@@ -39,19 +47,46 @@ func main() {
 		port = defaultPort
 	}
 
-	dataDir := os.Getenv("DATA_DIR")
-	if dataDir == "" {
-		dataDir = defaultDataDir
+	dataDir := getDataDir()
+
+	// Subcommands
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			os.Exit(runMigrate(dataDir, os.Args[2:], os.Stdout))
+		case "token":
+			os.Exit(runToken(dataDir, os.Args[2:], os.Stdout))
+		case "mcp":
+			os.Exit(runMCP(dataDir, port, os.Args[2:], os.Stdin, os.Stdout, os.Stderr))
+		case "project", "board", "task", "doc", "beads":
+			cfg := cli.Config{
+				DataDir:   dataDir,
+				ServerURL: "http://" + defaultHost + ":" + port,
+				Token:     os.Getenv("CARTOGRAPHER_TOKEN"),
+			}
+			os.Exit(cli.Run(cfg, os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+		}
 	}
 
 	// Initialize database
 	logger.Println("Initializing database...")
-	db, err := storage.New(dataDir)
+	db, err := storage.Open(dataDir)
 	if err != nil {
 		logger.Fatalf("Failed to initialize database: %v", err)
 	}
 	defer db.Close()
-	logger.Printf("Database initialized at %s", db.Path())
+
+	applied, err := db.Migrate()
+	for _, m := range applied {
+		logger.Printf("Applied migration %04d_%s", m.Version, m.Name)
+	}
+	if err != nil {
+		logger.Fatalf("Failed to migrate database: %v", err)
+	}
+	logger.Printf("Database initialized at %s (schema version %d)", db.Path(), storage.LatestSchemaVersion())
+	if !db.FullTextSearch() {
+		logger.Println("FTS5 not available (build with -tags sqlite_fts5); search falls back to substring matching")
+	}
 
 	// Initialize repositories
 	logger.Println("Initializing repositories...")
@@ -59,6 +94,12 @@ func main() {
 	boardRepo := storage.NewBoardRepository(db)
 	taskRepo := storage.NewTaskRepository(db)
 	documentRepo := storage.NewDocumentRepository(db)
+	diagramRepo := storage.NewDiagramRepository(db)
+	searchRepo := storage.NewSearchRepository(db)
+	tokenRepo := storage.NewAPITokenRepository(db)
+	auditRepo := storage.NewAuditRepository(db)
+	leaseRepo := storage.NewLeaseRepository(db)
+	sessionRecorder := claude.NewRecorder(storage.NewSessionRepository(db), taskRepo, boardRepo)
 
 	// Initialize Beads parser
 	logger.Println("Initializing Beads parser...")
@@ -67,8 +108,53 @@ func main() {
 	// Initialize WebSocket hub
 	logger.Println("Starting WebSocket hub...")
 	wsHub := websocket.NewHub(logger)
+	wsHub.SetBoardResolver(func(boardID string) string {
+		board, err := boardRepo.GetByID(boardID)
+		if err != nil {
+			return ""
+		}
+		return board.ProjectID
+	})
+	if persist, _ := strconv.ParseBool(os.Getenv("PERSIST_EVENTS")); persist {
+		if err := wsHub.SetEventStore(storage.NewEventRepository(db)); err != nil {
+			logger.Fatalf("Failed to load event log: %v", err)
+		}
+		logger.Printf("Persisting WebSocket events (stream %s at seq %d)", wsHub.Stream(), wsHub.LatestSeq())
+	}
 	go wsHub.Run()
 
+	// Watch the beads issues file and broadcast changes to it
+	beadsWatcher := beads.NewWatcher(beadsParser, beads.DefaultWatchInterval, logger)
+	beadsWatcher.OnChange(func(changes []beads.IssueChange) {
+		for _, change := range changes {
+			switch change.Action {
+			case beads.ChangeCreated:
+				wsHub.BroadcastBeadCreated(change.Issue.ID, change.Issue)
+			case beads.ChangeUpdated:
+				wsHub.BroadcastBeadUpdated(change.Issue.ID, change.Changes, change.Issue)
+			case beads.ChangeClosed:
+				wsHub.BroadcastBeadClosed(change.Issue.ID, change.Changes, change.Issue)
+			case beads.ChangeDeleted:
+				wsHub.BroadcastBeadDeleted(change.Issue.ID)
+			}
+		}
+	})
+
+	// Sync tasks imported from beads with the issues file in both directions
+	beadsSync := beads.NewSyncer(beadsWatcher, taskRepo, boardRepo, storage.NewBeadSyncRepository(db), logger)
+	beadsSync.OnTaskChange(func(change beads.TaskChange) {
+		wsHub.BroadcastTaskUpdated(change.Task.ID, change.Task.BoardID, change.Changes, change.Task)
+	})
+	beadsSync.OnConflict(func(state *domain.BeadSyncState) {
+		boardID := ""
+		if task, err := taskRepo.GetByID(state.TaskID); err == nil {
+			boardID = task.BoardID
+		}
+		wsHub.BroadcastBeadConflict(state.BeadID, state.TaskID, boardID, state.Conflict)
+	})
+	beadsWatcher.OnChange(beadsSync.HandleBeadChanges)
+	beadsWatcher.Start()
+
 	// Create application state
 	app := &App{
 		db:     db,
@@ -84,12 +170,47 @@ func main() {
 
 	// WebSocket endpoint
 	wsHandler := websocket.NewHandler(wsHub, logger)
+	wsHandler.SetProjectScope(func(r *http.Request) []string {
+		if token := rest.TokenFromContext(r.Context()); token != nil {
+			return token.ProjectIDs
+		}
+		return nil
+	})
 	mux.HandleFunc("/ws", wsHandler.HandleWebSocket)
 
 	// REST API endpoints
-	apiHandler := rest.NewAPIHandler(projectRepo, boardRepo, taskRepo, documentRepo, beadsParser, wsHub, logger)
+	apiHandler := rest.NewAPIHandler(rest.Config{
+		Projects:     projectRepo,
+		Boards:       boardRepo,
+		Tasks:        taskRepo,
+		Documents:    documentRepo,
+		Diagrams:     diagramRepo,
+		Search:       searchRepo,
+		APITokens:    tokenRepo,
+		Audit:        auditRepo,
+		Leases:       leaseRepo,
+		Sessions:     sessionRecorder,
+		BeadsWatcher: beadsWatcher,
+		BeadsSync:    beadsSync,
+		WSHub:        wsHub,
+		Logger:       logger,
+	})
 	apiHandler.Register(mux)
 
+	// Release the work of agents that stopped heartbeating
+	leaseSweep := time.NewTicker(leaseSweepInterval)
+	defer leaseSweep.Stop()
+	go func() {
+		for range leaseSweep.C {
+			apiHandler.ExpireLeases()
+		}
+	}()
+
+	// GraphQL endpoint; subscriptions run over the WebSocket connection
+	graphqlHandler := graphql.NewHandler(projectRepo, boardRepo, taskRepo, documentRepo, beadsWatcher, wsHub, logger)
+	wsHub.SetOperationRunner(graphqlHandler.RunOperation)
+	mux.Handle(graphql.Path, graphqlHandler)
+
 	// Static files - serve from web/static
 	fs := http.FileServer(http.Dir("web/static"))
 	mux.Handle("/static/", http.StripPrefix("/static/", fs))
@@ -99,8 +220,19 @@ func main() {
 
 	addr := fmt.Sprintf("%s:%s", defaultHost, port)
 
+	// Require API tokens unless explicitly disabled
+	var handler http.Handler = mux
+	if disabled, _ := strconv.ParseBool(os.Getenv("AUTH_DISABLED")); disabled {
+		logger.Println("WARNING: authentication is disabled; any local process or web page can use the API")
+	} else {
+		handler = rest.AuthMiddleware(tokenRepo, logger)(handler)
+		if tokens, err := tokenRepo.List(); err == nil && len(tokens) == 0 {
+			logger.Println("No API tokens exist yet; create one with `cartographer token create --name <you> --scope admin`")
+		}
+	}
+
 	// Wrap with middleware (CORS and logging)
-	handler := rest.LoggingMiddleware(logger)(rest.CORSMiddleware(mux))
+	handler = rest.LoggingMiddleware(logger)(rest.CORSMiddleware(handler))
 
 	server := &http.Server{
 		Addr:         addr,
@@ -131,6 +263,7 @@ func main() {
 	if err := server.Shutdown(ctx); err != nil {
 		logger.Fatalf("Server forced to shutdown: %v", err)
 	}
+	beadsWatcher.Stop()
 
 	logger.Println("Server stopped")
 }
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/rand/cartographer/internal/api/mcp"
+	"github.com/rand/cartographer/internal/api/rest"
+	"github.com/rand/cartographer/internal/api/websocket"
+	"github.com/rand/cartographer/internal/beads"
+	"github.com/rand/cartographer/internal/claude"
+	"github.com/rand/cartographer/internal/domain"
+	"github.com/rand/cartographer/internal/storage"
+)
+
+// runMCP implements `cartographer mcp`: a Model Context Protocol server on
+// stdin and stdout, working on the database directly and making task
+// changes through the REST API served in process, which writes them back
+// to the beads issues file. Task changes are relayed to the WebSocket
+// clients of the server listening on port, if one is running. Diagnostics
+// go to errOut, since out carries the protocol.
+func runMCP(dataDir, port string, args []string, in io.Reader, out, errOut io.Writer) int {
+	fs := flag.NewFlagSet("mcp", flag.ContinueOnError)
+	fs.SetOutput(errOut)
+	server := fs.String("server", "http://"+defaultHost+":"+port, "URL of the running server to broadcast task changes through")
+	token := fs.String("token", os.Getenv("CARTOGRAPHER_TOKEN"), "API token with write scope for the server (default $CARTOGRAPHER_TOKEN)")
+	noBroadcast := fs.Bool("no-broadcast", false, "don't relay task changes to a running server")
+	agent := fs.String("agent", "mcp", "agent identity changes are attributed to")
+	beadsDir := fs.String("beads", ".", "directory whose .beads/issues.jsonl holds the beads issues")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	logger := log.New(errOut, "[cartographer mcp] ", log.LstdFlags)
+
+	db, err := storage.Open(dataDir)
+	if err != nil {
+		fmt.Fprintf(errOut, "Failed to open database: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	if _, err := db.Migrate(); err != nil {
+		fmt.Fprintf(errOut, "Failed to migrate database: %v\n", err)
+		return 1
+	}
+
+	projects := storage.NewProjectRepository(db)
+	boards := storage.NewBoardRepository(db)
+	tasks := storage.NewTaskRepository(db)
+	documents := storage.NewDocumentRepository(db)
+	search := storage.NewSearchRepository(db)
+	watcher := beads.NewWatcher(beads.NewParser(*beadsDir), 0, logger)
+	syncer := beads.NewSyncer(watcher, tasks, boards, storage.NewBeadSyncRepository(db), logger)
+
+	// The API has no WebSocket clients of its own; the server below relays
+	// its task changes
+	api := rest.NewAPIHandler(rest.Config{
+		Projects:     projects,
+		Boards:       boards,
+		Tasks:        tasks,
+		Documents:    documents,
+		Diagrams:     storage.NewDiagramRepository(db),
+		Search:       search,
+		APITokens:    storage.NewAPITokenRepository(db),
+		Audit:        storage.NewAuditRepository(db),
+		Leases:       storage.NewLeaseRepository(db),
+		Sessions:     claude.NewRecorder(storage.NewSessionRepository(db), tasks, boards),
+		BeadsWatcher: watcher,
+		BeadsSync:    syncer,
+		Logger:       logger,
+	})
+	mux := http.NewServeMux()
+	api.Register(mux)
+
+	var hub mcp.Broadcaster
+	if !*noBroadcast {
+		hub = websocket.NewRelay(*server, *token)
+	}
+	srv := mcp.NewServer(projects, boards, tasks, documents, search, watcher,
+		rest.AsUser(domain.User{Type: domain.AssigneeAgent, ID: *agent}, mux), hub, logger)
+	if err := srv.Serve(in, out); err != nil {
+		logger.Printf("Error serving MCP: %v", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/rand/cartographer/internal/storage"
+)
+
+// runMigrate implements `cartographer migrate [status|up] [--dry-run]`
+func runMigrate(dataDir string, args []string, out io.Writer) int {
+	subcommand := "status"
+	if len(args) > 0 && args[0] != "" && args[0][0] != '-' {
+		subcommand = args[0]
+		args = args[1:]
+	}
+
+	fs := flag.NewFlagSet("migrate "+subcommand, flag.ContinueOnError)
+	fs.SetOutput(out)
+	dryRun := fs.Bool("dry-run", false, "show pending migrations without applying them")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	db, err := storage.Open(dataDir)
+	if err != nil {
+		fmt.Fprintf(out, "Failed to open database: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	report, err := db.MigrationStatus()
+	if err != nil {
+		fmt.Fprintf(out, "Failed to read migration status: %v\n", err)
+		return 1
+	}
+
+	switch subcommand {
+	case "status":
+		printMigrationReport(out, db.Path(), report)
+		if !report.UpToDate() {
+			return 1
+		}
+		return 0
+
+	case "up":
+		if *dryRun {
+			printMigrationReport(out, db.Path(), report)
+			return 0
+		}
+
+		applied, err := db.Migrate()
+		for _, m := range applied {
+			fmt.Fprintf(out, "Applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			fmt.Fprintf(out, "Migration failed: %v\n", err)
+			if errors.Is(err, storage.ErrSchemaTooNew) {
+				fmt.Fprintln(out, "Upgrade cartographer before using this database.")
+			}
+			return 1
+		}
+		if len(applied) == 0 {
+			fmt.Fprintln(out, "Database is up to date")
+		}
+		return 0
+
+	default:
+		fmt.Fprintf(out, "Unknown migrate subcommand %q (expected status or up)\n", subcommand)
+		return 2
+	}
+}
+
+// printMigrationReport writes a human-readable migration report
+func printMigrationReport(out io.Writer, path string, report *storage.MigrationReport) {
+	fmt.Fprintf(out, "Database:        %s\n", path)
+	fmt.Fprintf(out, "Current version: %d\n", report.CurrentVersion)
+	fmt.Fprintf(out, "Latest version:  %d\n", report.LatestVersion)
+
+	for _, a := range report.Applied {
+		fmt.Fprintf(out, "  [applied] %04d_%s (%s)\n", a.Version, a.Name, a.AppliedAt.Format("2006-01-02 15:04:05"))
+	}
+	for _, m := range report.Pending {
+		fmt.Fprintf(out, "  [pending] %04d_%s\n", m.Version, m.Name)
+	}
+	for _, a := range report.Unknown {
+		fmt.Fprintf(out, "  [unknown] %04d_%s (applied by a newer binary)\n", a.Version, a.Name)
+	}
+
+	if report.UpToDate() {
+		fmt.Fprintln(out, "Database is up to date")
+	}
+}
+
+// getDataDir returns the data directory from the environment or the default
+func getDataDir() string {
+	if dataDir := os.Getenv("DATA_DIR"); dataDir != "" {
+		return dataDir
+	}
+	return defaultDataDir
+}
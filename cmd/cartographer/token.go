@@ -0,0 +1,135 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/rand/cartographer/internal/domain"
+	"github.com/rand/cartographer/internal/storage"
+)
+
+// runToken implements `cartographer token create|list|revoke`
+func runToken(dataDir string, args []string, out io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprintln(out, "Usage: cartographer token create|list|revoke")
+		return 2
+	}
+	subcommand, args := args[0], args[1:]
+
+	fs := flag.NewFlagSet("token "+subcommand, flag.ContinueOnError)
+	fs.SetOutput(out)
+	name := fs.String("name", "", "label for the token")
+	scope := fs.String("scope", domain.ScopeRead, "read, write or admin")
+	user := fs.String("user", "", "identity changes are attributed to (defaults to the name)")
+	agent := fs.Bool("agent", false, "attribute changes to an agent rather than a human")
+	expires := fs.Duration("expires", 0, "lifetime, e.g. 720h (default never)")
+	var projects stringList
+	fs.Var(&projects, "project", "limit the token to a project ID (repeatable)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	db, err := storage.Open(dataDir)
+	if err != nil {
+		fmt.Fprintf(out, "Failed to open database: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	if _, err := db.Migrate(); err != nil {
+		fmt.Fprintf(out, "Failed to migrate database: %v\n", err)
+		return 1
+	}
+	tokens := storage.NewAPITokenRepository(db)
+
+	switch subcommand {
+	case "create":
+		if *name == "" {
+			fmt.Fprintln(out, "--name is required")
+			return 2
+		}
+		if !domain.ValidScope(*scope) {
+			fmt.Fprintf(out, "Unknown scope %q (expected %s)\n", *scope, strings.Join(domain.Scopes, ", "))
+			return 2
+		}
+
+		token := &domain.APIToken{
+			Name:       *name,
+			User:       domain.User{Type: "human", ID: *user},
+			Scope:      *scope,
+			ProjectIDs: projects,
+		}
+		if *agent {
+			token.User.Type = "agent"
+		}
+		if *expires > 0 {
+			at := time.Now().Add(*expires)
+			token.ExpiresAt = &at
+		}
+
+		secret, err := tokens.Create(token)
+		if err != nil {
+			fmt.Fprintf(out, "Failed to create token: %v\n", err)
+			return 1
+		}
+		fmt.Fprintf(out, "Created token %s (%s, %s)\n", token.ID, token.Name, token.Scope)
+		fmt.Fprintln(out, "Store this secret now; it is not shown again:")
+		fmt.Fprintln(out, secret)
+		return 0
+
+	case "list":
+		list, err := tokens.List()
+		if err != nil {
+			fmt.Fprintf(out, "Failed to list tokens: %v\n", err)
+			return 1
+		}
+		if len(list) == 0 {
+			fmt.Fprintln(out, "No tokens")
+		}
+		now := time.Now()
+		for _, token := range list {
+			state := "active"
+			if !token.Active(now) {
+				state = "inactive"
+			}
+			projectList := "all projects"
+			if token.Restricted() {
+				projectList = strings.Join(token.ProjectIDs, ",")
+			}
+			fmt.Fprintf(out, "%s  %-20s %-5s %s:%s  %s  [%s]\n",
+				token.ID, token.Name, token.Scope, token.User.Type, token.User.ID, projectList, state)
+		}
+		return 0
+
+	case "revoke":
+		if fs.NArg() != 1 {
+			fmt.Fprintln(out, "Usage: cartographer token revoke <id>")
+			return 2
+		}
+		if err := tokens.Revoke(fs.Arg(0)); err != nil {
+			fmt.Fprintf(out, "Failed to revoke token: %v\n", err)
+			return 1
+		}
+		fmt.Fprintf(out, "Revoked %s\n", fs.Arg(0))
+		return 0
+
+	default:
+		fmt.Fprintf(out, "Unknown token subcommand %q (expected create, list or revoke)\n", subcommand)
+		return 2
+	}
+}
+
+// stringList is a flag that can be given more than once
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
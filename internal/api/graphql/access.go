@@ -0,0 +1,79 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+
+	"github.com/rand/cartographer/internal/api/websocket"
+	"github.com/rand/cartographer/internal/domain"
+)
+
+// Errors resolvers report to clients, matching the REST API's
+var (
+	errProjectAccess = errors.New("Token does not grant access to this project")
+	errAllProjects   = errors.New("Token is limited to specific projects")
+	errInternal      = errors.New("Internal server error")
+)
+
+// access is what an operation may read: the projects its token is limited
+// to, or every project when empty. Beads aren't tied to a project, so like
+// the REST API only operations for every project may read them.
+type access struct {
+	projects []string
+}
+
+type contextKey int
+
+const accessContextKey contextKey = iota
+
+// tokenAccess returns the access a token grants; with authentication
+// disabled there is no token and every project is accessible
+func tokenAccess(token *domain.APIToken) access {
+	if token == nil {
+		return access{}
+	}
+	return access{projects: token.ProjectIDs}
+}
+
+func withAccess(ctx context.Context, a access) context.Context {
+	return context.WithValue(ctx, accessContextKey, a)
+}
+
+func accessFrom(ctx context.Context) access {
+	a, _ := ctx.Value(accessContextKey).(access)
+	return a
+}
+
+// restricted reports whether the operation is limited to some projects
+func (a access) restricted() bool {
+	return len(a.projects) > 0
+}
+
+// allowsProject reports whether the operation may read a project
+func (a access) allowsProject(projectID string) bool {
+	if !a.restricted() {
+		return true
+	}
+	for _, id := range a.projects {
+		if id == projectID {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsEvent reports whether the operation may see an event on the given
+// routing resources, as the hub decides for restricted clients
+func (a access) allowsEvent(resources []string) bool {
+	if !a.restricted() {
+		return true
+	}
+	for _, resource := range resources {
+		for _, projectID := range a.projects {
+			if resource == websocket.Resource(websocket.ResourceProject, projectID) {
+				return true
+			}
+		}
+	}
+	return false
+}
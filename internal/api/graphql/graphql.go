@@ -0,0 +1,182 @@
+// Package graphql serves a read-only GraphQL API over projects, boards,
+// tasks, documents and beads issues. Queries are served over HTTP;
+// subscriptions run over the WebSocket connection of the hub.
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	gql "github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/rand/cartographer/internal/api/rest"
+	"github.com/rand/cartographer/internal/api/websocket"
+	"github.com/rand/cartographer/internal/beads"
+	"github.com/rand/cartographer/internal/storage"
+)
+
+// Path is where the GraphQL endpoint is served
+const Path = "/api/graphql"
+
+// Handler serves GraphQL queries and runs subscriptions for the hub
+type Handler struct {
+	schema gql.Schema
+
+	projects     *storage.ProjectRepository
+	boards       *storage.BoardRepository
+	tasks        *storage.TaskRepository
+	documents    *storage.DocumentRepository
+	beadsWatcher *beads.Watcher
+	hub          *websocket.Hub
+	logger       *log.Logger
+}
+
+// NewHandler creates a GraphQL handler. Subscriptions are fed by the
+// hub's events.
+func NewHandler(
+	projects *storage.ProjectRepository,
+	boards *storage.BoardRepository,
+	tasks *storage.TaskRepository,
+	documents *storage.DocumentRepository,
+	beadsWatcher *beads.Watcher,
+	hub *websocket.Hub,
+	logger *log.Logger,
+) *Handler {
+	if logger == nil {
+		logger = log.Default()
+	}
+
+	h := &Handler{
+		projects:     projects,
+		boards:       boards,
+		tasks:        tasks,
+		documents:    documents,
+		beadsWatcher: beadsWatcher,
+		hub:          hub,
+		logger:       logger,
+	}
+	schema, err := gql.NewSchema(h.schemaConfig())
+	if err != nil {
+		// The schema is fixed, so it only fails to build on a bug
+		panic("graphql: invalid schema: " + err.Error())
+	}
+	h.schema = schema
+	return h
+}
+
+// request is a GraphQL request, from a POST body or GET query parameters
+type request struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+}
+
+// ServeHTTP runs a query. Errors in the query itself are reported in the
+// result's errors, as GraphQL clients expect; only malformed requests get
+// an error status.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req request
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		req.Query = query.Get("query")
+		req.OperationName = query.Get("operationName")
+		if variables := query.Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				writeError(w, "Invalid variables", http.StatusBadRequest)
+				return
+			}
+		}
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if req.Query == "" {
+		writeError(w, "query is required", http.StatusBadRequest)
+		return
+	}
+	if operationType(req.Query, req.OperationName) == ast.OperationTypeSubscription {
+		writeError(w, "Subscriptions run over the WebSocket connection at /ws", http.StatusBadRequest)
+		return
+	}
+
+	ctx := withAccess(r.Context(), tokenAccess(rest.TokenFromContext(r.Context())))
+	result := gql.Do(gql.Params{
+		Schema:         h.schema,
+		RequestString:  req.Query,
+		VariableValues: req.Variables,
+		OperationName:  req.OperationName,
+		Context:        ctx,
+	})
+	writeJSON(w, http.StatusOK, result)
+}
+
+// RunOperation runs an operation a WebSocket client started; it is the
+// hub's OperationRunner. A subscription yields a result for each matching
+// event until ctx is done, any other operation its one result.
+func (h *Handler) RunOperation(ctx context.Context, client *websocket.Client, req websocket.OperationRequest) <-chan interface{} {
+	params := gql.Params{
+		Schema:         h.schema,
+		RequestString:  req.Query,
+		VariableValues: req.Variables,
+		OperationName:  req.OperationName,
+		Context:        withAccess(ctx, access{projects: client.Projects()}),
+	}
+
+	results := make(chan interface{})
+	go func() {
+		defer close(results)
+		if operationType(req.Query, req.OperationName) != ast.OperationTypeSubscription {
+			results <- gql.Do(params)
+			return
+		}
+		for result := range gql.Subscribe(params) {
+			results <- result
+		}
+	}()
+	return results
+}
+
+// operationType returns the type of the operation a request runs: query,
+// mutation or subscription. It is empty if the query doesn't parse, which
+// running it reports.
+func operationType(query, operationName string) string {
+	doc, err := parser.Parse(parser.ParseParams{Source: query})
+	if err != nil {
+		return ""
+	}
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if operationName == "" || op.Name != nil && op.Name.Value == operationName {
+			return op.Operation
+		}
+	}
+	return ""
+}
+
+// writeJSON writes v as a JSON response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes a request error the way GraphQL results report them
+func writeError(w http.ResponseWriter, message string, status int) {
+	writeJSON(w, status, map[string]interface{}{
+		"errors": []map[string]string{{"message": message}},
+	})
+}
@@ -0,0 +1,281 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rand/cartographer/internal/api/websocket"
+	"github.com/rand/cartographer/internal/beads"
+	"github.com/rand/cartographer/internal/domain"
+	"github.com/rand/cartographer/internal/storage"
+	"github.com/rand/cartographer/internal/testutil"
+)
+
+// fixture is a project whose second task depends on a first task linked to
+// a bead and a document
+type fixture struct {
+	h      *Handler
+	hub    *websocket.Hub
+	board  *domain.Board
+	first  *domain.Task
+	second *domain.Task
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	dir := t.TempDir()
+	db := testutil.NewDB(t, dir)
+	testutil.WriteBeads(t, dir)
+	watcher := beads.NewWatcher(beads.NewParser(dir), time.Hour, nil)
+	if _, err := watcher.Refresh(); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+
+	projects := storage.NewProjectRepository(db)
+	boards := storage.NewBoardRepository(db)
+	tasks := storage.NewTaskRepository(db)
+	documents := storage.NewDocumentRepository(db)
+
+	project := &domain.Project{Name: "Graph", Path: "/tmp/graph"}
+	if err := projects.Create(project); err != nil {
+		t.Fatalf("failed to create project: %v", err)
+	}
+	board := &domain.Board{ProjectID: project.ID, Name: "Main", Columns: []domain.BoardColumn{{ID: "todo", Name: "To Do"}}}
+	if err := boards.Create(board); err != nil {
+		t.Fatalf("failed to create board: %v", err)
+	}
+	doc := &domain.Document{ProjectID: project.ID, Title: "Grammar", Path: "docs/grammar.md"}
+	if err := documents.Create(doc); err != nil {
+		t.Fatalf("failed to create document: %v", err)
+	}
+	first := &domain.Task{BoardID: board.ID, Title: "Write the parser", Status: "todo",
+		LinkedItems: []domain.LinkedItem{{Type: "bead", ID: "bd-1"}, {Type: "doc", ID: doc.ID}}}
+	if err := tasks.Create(first); err != nil {
+		t.Fatalf("failed to create task: %v", err)
+	}
+	second := &domain.Task{BoardID: board.ID, Title: "Render the tree", Status: "todo", Dependencies: []string{first.ID}}
+	if err := tasks.Create(second); err != nil {
+		t.Fatalf("failed to create task: %v", err)
+	}
+
+	hub := websocket.NewHub(log.New(io.Discard, "", 0))
+	go hub.Run()
+	t.Cleanup(hub.Shutdown)
+
+	h := NewHandler(projects, boards, tasks, documents, watcher, hub, log.New(io.Discard, "", 0))
+	return &fixture{h: h, hub: hub, board: board, first: first, second: second}
+}
+
+// result decodes a GraphQL response
+type result struct {
+	Data   map[string]interface{} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+func decodeResult(t *testing.T, v interface{}) result {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("failed to encode result: %v", err)
+	}
+	var r result
+	if err := json.Unmarshal(data, &r); err != nil {
+		t.Fatalf("invalid result %s: %v", data, err)
+	}
+	return r
+}
+
+// expectData checks a result has no errors and the data given as JSON
+func expectData(t *testing.T, r result, want string) {
+	t.Helper()
+	if len(r.Errors) > 0 {
+		t.Fatalf("unexpected errors: %+v", r.Errors)
+	}
+	var expected map[string]interface{}
+	if err := json.Unmarshal([]byte(want), &expected); err != nil {
+		t.Fatalf("invalid expected data: %v", err)
+	}
+	if !reflect.DeepEqual(r.Data, expected) {
+		got, _ := json.Marshal(r.Data)
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	f := newFixture(t)
+
+	post := func(query string) (*httptest.ResponseRecorder, result) {
+		body, _ := json.Marshal(request{Query: query})
+		rec := httptest.NewRecorder()
+		f.h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, Path, strings.NewReader(string(body))))
+		var r result
+		json.Unmarshal(rec.Body.Bytes(), &r)
+		return rec, r
+	}
+
+	// Task → dependencies → linked beads → their tasks' documents
+	rec, r := post(`{
+		task(id: "` + f.second.ID + `") {
+			title
+			board { name project { name } }
+			dependencies {
+				title
+				beads { id blocks { id } task { title } documents { title } }
+				documents { title }
+			}
+		}
+	}`)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("expected a JSON result, got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	expectData(t, r, `{"task": {
+		"title": "Render the tree",
+		"board": {"name": "Main", "project": {"name": "Graph"}},
+		"dependencies": [{
+			"title": "Write the parser",
+			"beads": [{"id": "bd-1", "blocks": [{"id": "bd-2"}], "task": {"title": "Write the parser"}, "documents": [{"title": "Grammar"}]}],
+			"documents": [{"title": "Grammar"}]
+		}]
+	}}`)
+
+	_, r = post(`{
+		board(id: "` + f.board.ID + `") { tasks(text: "tree") { title } }
+		readyBeads { id }
+		blockedBeads { issue { id } blockedBy { id } }
+		missing: task(id: "missing") { id }
+	}`)
+	expectData(t, r, `{
+		"board": {"tasks": [{"title": "Render the tree"}]},
+		"readyBeads": [{"id": "bd-1"}],
+		"blockedBeads": [{"issue": {"id": "bd-2"}, "blockedBy": [{"id": "bd-1"}]}],
+		"missing": null
+	}`)
+
+	// GET takes the query as a parameter
+	rec = httptest.NewRecorder()
+	f.h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, Path+"?query="+url.QueryEscape(`{ projects { name } }`), nil))
+	r = result{}
+	json.Unmarshal(rec.Body.Bytes(), &r)
+	expectData(t, r, `{"projects": [{"name": "Graph"}]}`)
+
+	if _, r := post(`{ task(id: "x") { nothing } }`); len(r.Errors) == 0 {
+		t.Error("expected an error for an unknown field")
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		status int
+	}{
+		{"subscription", http.MethodPost, `{"query": "subscription { taskChanged { taskId } }"}`, http.StatusBadRequest},
+		{"missing query", http.MethodPost, `{}`, http.StatusBadRequest},
+		{"invalid body", http.MethodPost, `{`, http.StatusBadRequest},
+		{"method", http.MethodPut, `{}`, http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		f.h.ServeHTTP(rec, httptest.NewRequest(tt.method, Path, strings.NewReader(tt.body)))
+		var r result
+		if err := json.Unmarshal(rec.Body.Bytes(), &r); err != nil || rec.Code != tt.status || len(r.Errors) != 1 {
+			t.Errorf("%s: expected %d with an error, got %d %s", tt.name, tt.status, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestOperationAccess(t *testing.T) {
+	f := newFixture(t)
+
+	client := websocket.NewClient(f.hub, nil, "restricted")
+	client.RestrictToProjects([]string{"other"})
+
+	results := f.h.RunOperation(context.Background(), client, websocket.OperationRequest{
+		Query: `{ projects { id } task(id: "` + f.first.ID + `") { id } beads { id } }`,
+	})
+	r := decodeResult(t, <-results)
+	if _, open := <-results; open {
+		t.Error("expected a query to send one result")
+	}
+
+	if projects, _ := r.Data["projects"].([]interface{}); len(projects) != 0 || r.Data["task"] != nil {
+		t.Errorf("expected nothing outside the client's projects, got %+v", r.Data)
+	}
+	var messages []string
+	for _, e := range r.Errors {
+		messages = append(messages, e.Message)
+	}
+	if want := []string{errProjectAccess.Error(), errAllProjects.Error()}; !reflect.DeepEqual(messages, want) {
+		t.Errorf("expected errors %q, got %q", want, messages)
+	}
+}
+
+func TestSubscription(t *testing.T) {
+	f := newFixture(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	results := f.h.RunOperation(ctx, websocket.NewClient(f.hub, nil, "client-1"), websocket.OperationRequest{
+		Query: `subscription Changes($board: ID) {
+			taskChanged(boardId: $board) { action taskId task { title dependencies { title } } }
+		}`,
+		Variables: map[string]interface{}{"board": f.board.ID},
+	})
+
+	// The subscription starts listening asynchronously, so events are
+	// broadcast until one arrives
+	var first interface{}
+	for first == nil {
+		f.hub.BroadcastTaskCreated("elsewhere", "other-board", nil)
+		f.hub.BroadcastTaskUpdated(f.second.ID, f.board.ID, nil, f.second)
+		select {
+		case first = <-results:
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	expectData(t, decodeResult(t, first), `{"taskChanged": {
+		"action": "updated",
+		"taskId": "`+f.second.ID+`",
+		"task": {"title": "Render the tree", "dependencies": [{"title": "Write the parser"}]}
+	}}`)
+
+	// A batch sends a change for each task on the board
+	batch := &websocket.TaskBatchEvent{}
+	batch.AddCreated(f.first.ID, f.board.ID, f.first)
+	batch.AddDeleted("gone", f.board.ID)
+	batch.AddDeleted("elsewhere", "other-board")
+	f.hub.BroadcastTaskBatch(batch)
+
+	var actions []string
+	timeout := time.After(time.Second)
+	for len(actions) < 2 {
+		select {
+		case v := <-results:
+			r := decodeResult(t, v)
+			change, _ := r.Data["taskChanged"].(map[string]interface{})
+			if change["taskId"] == f.second.ID {
+				continue // still broadcasting
+			}
+			actions = append(actions, change["action"].(string)+" "+change["taskId"].(string))
+		case <-timeout:
+			t.Fatalf("timed out waiting for the batch, got %v", actions)
+		}
+	}
+	if want := []string{"created " + f.first.ID, "deleted gone"}; !reflect.DeepEqual(actions, want) {
+		t.Errorf("expected %v, got %v", want, actions)
+	}
+
+	cancel()
+	for range results {
+	}
+}
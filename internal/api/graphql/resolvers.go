@@ -0,0 +1,392 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+
+	gql "github.com/graphql-go/graphql"
+	"github.com/rand/cartographer/internal/beads"
+	"github.com/rand/cartographer/internal/domain"
+	"github.com/rand/cartographer/internal/storage"
+	bd "github.com/steveyegge/beads"
+)
+
+// Lookups return nil without an error for resources that don't exist, so
+// they resolve to null, and an error for those the operation may not read.
+
+func (h *Handler) project(ctx context.Context, id string) (*domain.Project, error) {
+	if !accessFrom(ctx).allowsProject(id) {
+		return nil, errProjectAccess
+	}
+	project, err := h.projects.GetByID(id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, h.internal("getting project", err)
+	}
+	return project, nil
+}
+
+func (h *Handler) board(ctx context.Context, id string) (*domain.Board, error) {
+	board, err := h.boards.GetByID(id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, h.internal("getting board", err)
+	}
+	if !accessFrom(ctx).allowsProject(board.ProjectID) {
+		return nil, errProjectAccess
+	}
+	return board, nil
+}
+
+func (h *Handler) task(ctx context.Context, id string) (*domain.Task, error) {
+	task, err := h.tasks.GetByID(id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, h.internal("getting task", err)
+	}
+	if accessFrom(ctx).restricted() {
+		if board, err := h.board(ctx, task.BoardID); board == nil {
+			return nil, err
+		}
+	}
+	return task, nil
+}
+
+func (h *Handler) document(ctx context.Context, id string) (*domain.Document, error) {
+	doc, err := h.documents.GetByID(id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, h.internal("getting document", err)
+	}
+	if !accessFrom(ctx).allowsProject(doc.ProjectID) {
+		return nil, errProjectAccess
+	}
+	return doc, nil
+}
+
+// tasksByID looks up tasks, skipping those that no longer exist
+func (h *Handler) tasksByID(ctx context.Context, ids []string) ([]*domain.Task, error) {
+	var tasks []*domain.Task
+	for _, id := range ids {
+		task, err := h.task(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if task != nil {
+			tasks = append(tasks, task)
+		}
+	}
+	return tasks, nil
+}
+
+// linkedDocuments looks up the documents linked to a task
+func (h *Handler) linkedDocuments(ctx context.Context, task *domain.Task) ([]*domain.Document, error) {
+	var docs []*domain.Document
+	for _, item := range task.LinkedItems {
+		if item.Type != "doc" {
+			continue
+		}
+		doc, err := h.document(ctx, item.ID)
+		if err != nil {
+			return nil, err
+		}
+		if doc != nil {
+			docs = append(docs, doc)
+		}
+	}
+	return docs, nil
+}
+
+// beadIssues returns the issues of the beads file
+func (h *Handler) beadIssues(ctx context.Context) ([]*bd.Issue, error) {
+	if accessFrom(ctx).restricted() {
+		return nil, errAllProjects
+	}
+	issues, err := h.beadsWatcher.Issues()
+	if err != nil {
+		return nil, h.internal("reading beads issues", err)
+	}
+	return issues, nil
+}
+
+// beadsByID looks up issues, skipping those that no longer exist
+func (h *Handler) beadsByID(ctx context.Context, ids []string) ([]*bd.Issue, error) {
+	if _, err := h.beadIssues(ctx); err != nil {
+		return nil, err
+	}
+	var issues []*bd.Issue
+	for _, id := range ids {
+		if issue, ok := h.beadsWatcher.Issue(id); ok {
+			issues = append(issues, issue)
+		}
+	}
+	return issues, nil
+}
+
+// beadGraph returns the dependency graph of the beads issues
+func (h *Handler) beadGraph(ctx context.Context) (*beads.DependencyGraph, error) {
+	if accessFrom(ctx).restricted() {
+		return nil, errAllProjects
+	}
+	graph, err := h.beadsWatcher.Graph()
+	if err != nil {
+		return nil, h.internal("building dependency graph", err)
+	}
+	return graph, nil
+}
+
+// beadAnalyzer returns an analyzer of the beads issues with its dependency
+// graph built
+func (h *Handler) beadAnalyzer(ctx context.Context) (*beads.Analyzer, error) {
+	issues, err := h.beadIssues(ctx)
+	if err != nil {
+		return nil, err
+	}
+	analyzer := beads.NewAnalyzer(issues)
+	if _, err := analyzer.BuildDependencyGraph(); err != nil {
+		return nil, h.internal("building dependency graph", err)
+	}
+	return analyzer, nil
+}
+
+// internal logs an error that isn't the client's to see
+func (h *Handler) internal(what string, err error) error {
+	h.logger.Printf("Error %s: %v", what, err)
+	return errInternal
+}
+
+// Query
+
+func (h *Handler) resolveProjects(p gql.ResolveParams) (interface{}, error) {
+	projects, _, err := h.projects.Query(storage.ProjectQuery{IDs: accessFrom(p.Context).projects})
+	if err != nil {
+		return nil, h.internal("listing projects", err)
+	}
+	return projects, nil
+}
+
+func (h *Handler) resolveProject(p gql.ResolveParams) (interface{}, error) {
+	return nullable(h.project(p.Context, p.Args["id"].(string)))
+}
+
+func (h *Handler) resolveBoard(p gql.ResolveParams) (interface{}, error) {
+	return nullable(h.board(p.Context, p.Args["id"].(string)))
+}
+
+func (h *Handler) resolveTask(p gql.ResolveParams) (interface{}, error) {
+	return nullable(h.task(p.Context, p.Args["id"].(string)))
+}
+
+func (h *Handler) resolveTasks(p gql.ResolveParams) (interface{}, error) {
+	board, err := h.board(p.Context, p.Args["boardId"].(string))
+	if board == nil {
+		return nil, err
+	}
+	return h.queryTasks(board.ID, p.Args)
+}
+
+func (h *Handler) resolveDocument(p gql.ResolveParams) (interface{}, error) {
+	return nullable(h.document(p.Context, p.Args["id"].(string)))
+}
+
+func (h *Handler) resolveBeads(p gql.ResolveParams) (interface{}, error) {
+	issues, err := h.beadIssues(p.Context)
+	if err != nil {
+		return nil, err
+	}
+	status, _ := p.Args["status"].(string)
+	if status == "" {
+		return issues, nil
+	}
+	var matching []*bd.Issue
+	for _, issue := range issues {
+		if string(issue.Status) == status {
+			matching = append(matching, issue)
+		}
+	}
+	return matching, nil
+}
+
+func (h *Handler) resolveBead(p gql.ResolveParams) (interface{}, error) {
+	issues, err := h.beadsByID(p.Context, []string{p.Args["id"].(string)})
+	if err != nil || len(issues) == 0 {
+		return nil, err
+	}
+	return issues[0], nil
+}
+
+func (h *Handler) resolveReadyBeads(p gql.ResolveParams) (interface{}, error) {
+	analyzer, err := h.beadAnalyzer(p.Context)
+	if err != nil {
+		return nil, err
+	}
+	ready, err := analyzer.GetReadyIssues()
+	if err != nil {
+		return nil, h.internal("finding ready beads", err)
+	}
+	return ready, nil
+}
+
+func (h *Handler) resolveBlockedBeads(p gql.ResolveParams) (interface{}, error) {
+	analyzer, err := h.beadAnalyzer(p.Context)
+	if err != nil {
+		return nil, err
+	}
+	blocked, err := analyzer.GetBlockedIssues()
+	if err != nil {
+		return nil, h.internal("finding blocked beads", err)
+	}
+	return blocked, nil
+}
+
+// Relationships
+
+func (h *Handler) resolveProjectBoards(p gql.ResolveParams) (interface{}, error) {
+	boards, err := h.boards.ListByProject(p.Source.(*domain.Project).ID)
+	if err != nil {
+		return nil, h.internal("listing boards", err)
+	}
+	return boards, nil
+}
+
+func (h *Handler) resolveProjectDocuments(p gql.ResolveParams) (interface{}, error) {
+	docs, err := h.documents.ListByProject(p.Source.(*domain.Project).ID)
+	if err != nil {
+		return nil, h.internal("listing documents", err)
+	}
+	return docs, nil
+}
+
+func (h *Handler) resolveBoardProject(p gql.ResolveParams) (interface{}, error) {
+	return nullable(h.project(p.Context, p.Source.(*domain.Board).ProjectID))
+}
+
+func (h *Handler) resolveBoardTasks(p gql.ResolveParams) (interface{}, error) {
+	return h.queryTasks(p.Source.(*domain.Board).ID, p.Args)
+}
+
+// queryTasks lists a board's tasks matching the task filter arguments
+func (h *Handler) queryTasks(boardID string, args map[string]interface{}) (interface{}, error) {
+	q := storage.TaskQuery{BoardID: boardID}
+	if statuses, ok := args["status"].([]interface{}); ok {
+		for _, status := range statuses {
+			q.Status = append(q.Status, status.(string))
+		}
+	}
+	q.Assignee, _ = args["assignee"].(string)
+	q.Label, _ = args["label"].(string)
+	q.Text, _ = args["text"].(string)
+
+	tasks, _, err := h.tasks.Query(q)
+	if err != nil {
+		return nil, h.internal("listing tasks", err)
+	}
+	return tasks, nil
+}
+
+func (h *Handler) resolveTaskBoard(p gql.ResolveParams) (interface{}, error) {
+	return nullable(h.board(p.Context, p.Source.(*domain.Task).BoardID))
+}
+
+func (h *Handler) resolveTaskDependencies(p gql.ResolveParams) (interface{}, error) {
+	return h.tasksByID(p.Context, p.Source.(*domain.Task).Dependencies)
+}
+
+func (h *Handler) resolveTaskBlocks(p gql.ResolveParams) (interface{}, error) {
+	return h.tasksByID(p.Context, p.Source.(*domain.Task).Blocks)
+}
+
+func (h *Handler) resolveTaskRelated(p gql.ResolveParams) (interface{}, error) {
+	return h.tasksByID(p.Context, p.Source.(*domain.Task).Related)
+}
+
+func (h *Handler) resolveTaskBeads(p gql.ResolveParams) (interface{}, error) {
+	var ids []string
+	for _, item := range p.Source.(*domain.Task).LinkedItems {
+		if item.Type == "bead" {
+			ids = append(ids, item.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return []*bd.Issue{}, nil
+	}
+	return h.beadsByID(p.Context, ids)
+}
+
+func (h *Handler) resolveTaskDocuments(p gql.ResolveParams) (interface{}, error) {
+	return h.linkedDocuments(p.Context, p.Source.(*domain.Task))
+}
+
+func (h *Handler) resolveDocumentProject(p gql.ResolveParams) (interface{}, error) {
+	return nullable(h.project(p.Context, p.Source.(*domain.Document).ProjectID))
+}
+
+func (h *Handler) resolveBeadDependsOn(p gql.ResolveParams) (interface{}, error) {
+	return h.beadNeighbours(p, func(g *beads.DependencyGraph) map[string][]string { return g.DependsOn })
+}
+
+func (h *Handler) resolveBeadBlocks(p gql.ResolveParams) (interface{}, error) {
+	return h.beadNeighbours(p, func(g *beads.DependencyGraph) map[string][]string { return g.Blocks })
+}
+
+func (h *Handler) resolveBeadRelated(p gql.ResolveParams) (interface{}, error) {
+	return h.beadNeighbours(p, func(g *beads.DependencyGraph) map[string][]string { return g.Related })
+}
+
+// beadNeighbours looks up the issues next to an issue in one of the
+// dependency graph's adjacency lists
+func (h *Handler) beadNeighbours(p gql.ResolveParams, edges func(*beads.DependencyGraph) map[string][]string) (interface{}, error) {
+	graph, err := h.beadGraph(p.Context)
+	if err != nil {
+		return nil, err
+	}
+	return h.beadsByID(p.Context, edges(graph)[p.Source.(*bd.Issue).ID])
+}
+
+func (h *Handler) resolveBeadTask(p gql.ResolveParams) (interface{}, error) {
+	return nullable(h.beadTask(p.Context, p.Source.(*bd.Issue).ID))
+}
+
+func (h *Handler) resolveBeadDocuments(p gql.ResolveParams) (interface{}, error) {
+	task, err := h.beadTask(p.Context, p.Source.(*bd.Issue).ID)
+	if task == nil {
+		return []*domain.Document{}, err
+	}
+	return h.linkedDocuments(p.Context, task)
+}
+
+// beadTask returns the task linked to an issue, or nil if there is none
+func (h *Handler) beadTask(ctx context.Context, issueID string) (*domain.Task, error) {
+	task, err := h.tasks.FindByLinkedItem("bead", issueID)
+	if err != nil {
+		return nil, h.internal("finding bead task", err)
+	}
+	return task, nil
+}
+
+func (h *Handler) resolveBlockedBy(p gql.ResolveParams) (interface{}, error) {
+	return h.beadsByID(p.Context, p.Source.(beads.BlockedIssueInfo).BlockedBy)
+}
+
+// nullable returns a lookup's result so that a missing resource resolves
+// to null rather than a typed nil pointer
+func nullable[T any](v *T, err error) (interface{}, error) {
+	if v == nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// resolveSource resolves a subscription field to the value its
+// subscription sent
+func resolveSource(p gql.ResolveParams) (interface{}, error) {
+	return p.Source, nil
+}
@@ -0,0 +1,366 @@
+package graphql
+
+import (
+	gql "github.com/graphql-go/graphql"
+)
+
+// schemaConfig describes the schema. Fields without a resolver read the
+// struct field of the same name, ignoring case, so boardId reads
+// Task.BoardID.
+func (h *Handler) schemaConfig() gql.SchemaConfig {
+	var project, board, task, document, beadIssue *gql.Object
+
+	projectSettings := gql.NewObject(gql.ObjectConfig{
+		Name: "ProjectSettings",
+		Fields: gql.Fields{
+			"defaultBoard": &gql.Field{Type: gql.String},
+			"theme":        &gql.Field{Type: gql.String},
+		},
+	})
+	projectMetadata := gql.NewObject(gql.ObjectConfig{
+		Name: "ProjectMetadata",
+		Fields: gql.Fields{
+			"gitRemote":       &gql.Field{Type: gql.String},
+			"primaryLanguage": &gql.Field{Type: gql.String},
+			"framework":       &gql.Field{Type: gql.String},
+		},
+	})
+	project = gql.NewObject(gql.ObjectConfig{
+		Name: "Project",
+		Fields: gql.FieldsThunk(func() gql.Fields {
+			return gql.Fields{
+				"id":          &gql.Field{Type: gql.NewNonNull(gql.ID)},
+				"name":        &gql.Field{Type: gql.NewNonNull(gql.String)},
+				"description": &gql.Field{Type: gql.String},
+				"path":        &gql.Field{Type: gql.NewNonNull(gql.String)},
+				"type":        &gql.Field{Type: gql.String},
+				"createdAt":   &gql.Field{Type: gql.NewNonNull(gql.DateTime)},
+				"updatedAt":   &gql.Field{Type: gql.NewNonNull(gql.DateTime)},
+				"revision":    &gql.Field{Type: gql.NewNonNull(gql.Int)},
+				"settings":    &gql.Field{Type: projectSettings},
+				"metadata":    &gql.Field{Type: projectMetadata},
+				"boards":      &gql.Field{Type: listOf(board), Resolve: h.resolveProjectBoards},
+				"documents":   &gql.Field{Type: listOf(document), Resolve: h.resolveProjectDocuments},
+			}
+		}),
+	})
+
+	column := gql.NewObject(gql.ObjectConfig{
+		Name: "BoardColumn",
+		Fields: gql.Fields{
+			"id":       &gql.Field{Type: gql.NewNonNull(gql.ID)},
+			"name":     &gql.Field{Type: gql.NewNonNull(gql.String)},
+			"wipLimit": &gql.Field{Type: gql.Int},
+			"order":    &gql.Field{Type: gql.NewNonNull(gql.Int)},
+		},
+	})
+	board = gql.NewObject(gql.ObjectConfig{
+		Name: "Board",
+		Fields: gql.FieldsThunk(func() gql.Fields {
+			return gql.Fields{
+				"id":          &gql.Field{Type: gql.NewNonNull(gql.ID)},
+				"projectId":   &gql.Field{Type: gql.NewNonNull(gql.ID)},
+				"name":        &gql.Field{Type: gql.NewNonNull(gql.String)},
+				"description": &gql.Field{Type: gql.String},
+				"columns":     &gql.Field{Type: listOf(column)},
+				"wipPolicy":   &gql.Field{Type: gql.String},
+				"createdAt":   &gql.Field{Type: gql.NewNonNull(gql.DateTime)},
+				"updatedAt":   &gql.Field{Type: gql.NewNonNull(gql.DateTime)},
+				"revision":    &gql.Field{Type: gql.NewNonNull(gql.Int)},
+				"project":     &gql.Field{Type: project, Resolve: h.resolveBoardProject},
+				"tasks": &gql.Field{
+					Type:    listOf(task),
+					Args:    taskFilterArgs(),
+					Resolve: h.resolveBoardTasks,
+				},
+			}
+		}),
+	})
+
+	assignee := gql.NewObject(gql.ObjectConfig{
+		Name: "Assignee",
+		Fields: gql.Fields{
+			"type": &gql.Field{Type: gql.NewNonNull(gql.String)},
+			"id":   &gql.Field{Type: gql.NewNonNull(gql.ID)},
+			"name": &gql.Field{Type: gql.String},
+		},
+	})
+	user := gql.NewObject(gql.ObjectConfig{
+		Name: "User",
+		Fields: gql.Fields{
+			"type": &gql.Field{Type: gql.NewNonNull(gql.String)},
+			"id":   &gql.Field{Type: gql.NewNonNull(gql.ID)},
+		},
+	})
+	linkedItem := gql.NewObject(gql.ObjectConfig{
+		Name: "LinkedItem",
+		Fields: gql.Fields{
+			"type": &gql.Field{Type: gql.NewNonNull(gql.String)},
+			"id":   &gql.Field{Type: gql.NewNonNull(gql.ID)},
+			"path": &gql.Field{Type: gql.String},
+		},
+	})
+	checklistItem := gql.NewObject(gql.ObjectConfig{
+		Name: "ChecklistItem",
+		Fields: gql.Fields{
+			"id":        &gql.Field{Type: gql.NewNonNull(gql.ID)},
+			"text":      &gql.Field{Type: gql.NewNonNull(gql.String)},
+			"completed": &gql.Field{Type: gql.NewNonNull(gql.Boolean)},
+		},
+	})
+	activityEntry := gql.NewObject(gql.ObjectConfig{
+		Name: "ActivityEntry",
+		Fields: gql.Fields{
+			"type":      &gql.Field{Type: gql.NewNonNull(gql.String)},
+			"user":      &gql.Field{Type: gql.String},
+			"timestamp": &gql.Field{Type: gql.NewNonNull(gql.DateTime)},
+			"comment":   &gql.Field{Type: gql.String},
+		},
+	})
+	task = gql.NewObject(gql.ObjectConfig{
+		Name: "Task",
+		Fields: gql.FieldsThunk(func() gql.Fields {
+			return gql.Fields{
+				"id":          &gql.Field{Type: gql.NewNonNull(gql.ID)},
+				"boardId":     &gql.Field{Type: gql.NewNonNull(gql.ID)},
+				"title":       &gql.Field{Type: gql.NewNonNull(gql.String)},
+				"description": &gql.Field{Type: gql.String},
+				"status":      &gql.Field{Type: gql.NewNonNull(gql.String)},
+				"priority":    &gql.Field{Type: gql.String},
+				"assignee":    &gql.Field{Type: assignee},
+				"labels":      &gql.Field{Type: listOf(gql.String)},
+				"dueDate":     &gql.Field{Type: gql.DateTime},
+				"estimate":    &gql.Field{Type: gql.Float},
+				"actual":      &gql.Field{Type: gql.Float},
+				"linkedItems": &gql.Field{Type: listOf(linkedItem)},
+				"checklist":   &gql.Field{Type: listOf(checklistItem)},
+				"rank":        &gql.Field{Type: gql.NewNonNull(gql.Float)},
+				"createdAt":   &gql.Field{Type: gql.NewNonNull(gql.DateTime)},
+				"updatedAt":   &gql.Field{Type: gql.NewNonNull(gql.DateTime)},
+				"revision":    &gql.Field{Type: gql.NewNonNull(gql.Int)},
+				"createdBy":   &gql.Field{Type: user},
+				"activity":    &gql.Field{Type: listOf(activityEntry)},
+				"board":       &gql.Field{Type: board, Resolve: h.resolveTaskBoard},
+				"dependencies": &gql.Field{
+					Type:        listOf(task),
+					Description: "Tasks this task depends on",
+					Resolve:     h.resolveTaskDependencies,
+				},
+				"blocks": &gql.Field{
+					Type:        listOf(task),
+					Description: "Tasks this task blocks",
+					Resolve:     h.resolveTaskBlocks,
+				},
+				"related": &gql.Field{Type: listOf(task), Resolve: h.resolveTaskRelated},
+				"beads": &gql.Field{
+					Type:        listOf(beadIssue),
+					Description: "Beads issues linked to the task",
+					Resolve:     h.resolveTaskBeads,
+				},
+				"documents": &gql.Field{
+					Type:        listOf(document),
+					Description: "Documents linked to the task",
+					Resolve:     h.resolveTaskDocuments,
+				},
+			}
+		}),
+	})
+
+	document = gql.NewObject(gql.ObjectConfig{
+		Name: "Document",
+		Fields: gql.FieldsThunk(func() gql.Fields {
+			return gql.Fields{
+				"id":         &gql.Field{Type: gql.NewNonNull(gql.ID)},
+				"projectId":  &gql.Field{Type: gql.NewNonNull(gql.ID)},
+				"title":      &gql.Field{Type: gql.NewNonNull(gql.String)},
+				"content":    &gql.Field{Type: gql.String},
+				"path":       &gql.Field{Type: gql.String},
+				"tags":       &gql.Field{Type: listOf(gql.String)},
+				"linkedFrom": &gql.Field{Type: listOf(gql.String)},
+				"linksTo":    &gql.Field{Type: listOf(gql.String)},
+				"createdAt":  &gql.Field{Type: gql.NewNonNull(gql.DateTime)},
+				"updatedAt":  &gql.Field{Type: gql.NewNonNull(gql.DateTime)},
+				"revision":   &gql.Field{Type: gql.NewNonNull(gql.Int)},
+				"project":    &gql.Field{Type: project, Resolve: h.resolveDocumentProject},
+			}
+		}),
+	})
+
+	beadIssue = gql.NewObject(gql.ObjectConfig{
+		Name:        "BeadIssue",
+		Description: "An issue from the beads issues file",
+		Fields: gql.FieldsThunk(func() gql.Fields {
+			return gql.Fields{
+				"id":                 &gql.Field{Type: gql.NewNonNull(gql.ID)},
+				"title":              &gql.Field{Type: gql.NewNonNull(gql.String)},
+				"description":        &gql.Field{Type: gql.String},
+				"design":             &gql.Field{Type: gql.String},
+				"acceptanceCriteria": &gql.Field{Type: gql.String},
+				"notes":              &gql.Field{Type: gql.String},
+				"status":             &gql.Field{Type: gql.NewNonNull(gql.String)},
+				"priority":           &gql.Field{Type: gql.NewNonNull(gql.Int)},
+				"issueType":          &gql.Field{Type: gql.String},
+				"assignee":           &gql.Field{Type: gql.String},
+				"estimatedMinutes":   &gql.Field{Type: gql.Int},
+				"labels":             &gql.Field{Type: listOf(gql.String)},
+				"createdAt":          &gql.Field{Type: gql.NewNonNull(gql.DateTime)},
+				"updatedAt":          &gql.Field{Type: gql.NewNonNull(gql.DateTime)},
+				"closedAt":           &gql.Field{Type: gql.DateTime},
+				"dependsOn": &gql.Field{
+					Type:        listOf(beadIssue),
+					Description: "Issues this issue depends on",
+					Resolve:     h.resolveBeadDependsOn,
+				},
+				"blocks": &gql.Field{
+					Type:        listOf(beadIssue),
+					Description: "Issues that depend on this issue",
+					Resolve:     h.resolveBeadBlocks,
+				},
+				"related": &gql.Field{Type: listOf(beadIssue), Resolve: h.resolveBeadRelated},
+				"task": &gql.Field{
+					Type:        task,
+					Description: "The task imported from or synced with the issue",
+					Resolve:     h.resolveBeadTask,
+				},
+				"documents": &gql.Field{
+					Type:        listOf(document),
+					Description: "Documents linked to the issue's task",
+					Resolve:     h.resolveBeadDocuments,
+				},
+			}
+		}),
+	})
+	blockedBead := gql.NewObject(gql.ObjectConfig{
+		Name: "BlockedBead",
+		Fields: gql.Fields{
+			"issue":     &gql.Field{Type: gql.NewNonNull(beadIssue)},
+			"blockedBy": &gql.Field{Type: listOf(beadIssue), Resolve: h.resolveBlockedBy},
+		},
+	})
+
+	query := gql.NewObject(gql.ObjectConfig{
+		Name: "Query",
+		Fields: gql.Fields{
+			"projects": &gql.Field{Type: listOf(project), Resolve: h.resolveProjects},
+			"project":  &gql.Field{Type: project, Args: idArgs(), Resolve: h.resolveProject},
+			"board":    &gql.Field{Type: board, Args: idArgs(), Resolve: h.resolveBoard},
+			"task":     &gql.Field{Type: task, Args: idArgs(), Resolve: h.resolveTask},
+			"tasks": &gql.Field{
+				Type: listOf(task),
+				Args: func() gql.FieldConfigArgument {
+					args := taskFilterArgs()
+					args["boardId"] = &gql.ArgumentConfig{Type: gql.NewNonNull(gql.ID)}
+					return args
+				}(),
+				Resolve: h.resolveTasks,
+			},
+			"document": &gql.Field{Type: document, Args: idArgs(), Resolve: h.resolveDocument},
+			"beads": &gql.Field{
+				Type: listOf(beadIssue),
+				Args: gql.FieldConfigArgument{
+					"status": &gql.ArgumentConfig{Type: gql.String},
+				},
+				Resolve: h.resolveBeads,
+			},
+			"bead": &gql.Field{Type: beadIssue, Args: idArgs(), Resolve: h.resolveBead},
+			"readyBeads": &gql.Field{
+				Type:        listOf(beadIssue),
+				Description: "Open issues with no open dependencies",
+				Resolve:     h.resolveReadyBeads,
+			},
+			"blockedBeads": &gql.Field{
+				Type:        listOf(blockedBead),
+				Description: "Open issues waiting on open dependencies",
+				Resolve:     h.resolveBlockedBeads,
+			},
+		},
+	})
+
+	taskChange := gql.NewObject(gql.ObjectConfig{
+		Name: "TaskChange",
+		Fields: gql.Fields{
+			"action":  &gql.Field{Type: gql.NewNonNull(gql.String), Description: "created, updated, moved or deleted"},
+			"taskId":  &gql.Field{Type: gql.NewNonNull(gql.ID)},
+			"boardId": &gql.Field{Type: gql.NewNonNull(gql.ID)},
+			"seq":     &gql.Field{Type: gql.NewNonNull(gql.Int)},
+			"task":    &gql.Field{Type: task, Description: "The task after the change; null when deleted"},
+		},
+	})
+	beadChange := gql.NewObject(gql.ObjectConfig{
+		Name: "BeadChange",
+		Fields: gql.Fields{
+			"action":  &gql.Field{Type: gql.NewNonNull(gql.String), Description: "created, updated, closed or deleted"},
+			"issueId": &gql.Field{Type: gql.NewNonNull(gql.ID)},
+			"seq":     &gql.Field{Type: gql.NewNonNull(gql.Int)},
+			"issue":   &gql.Field{Type: beadIssue, Description: "The issue after the change; null when deleted"},
+		},
+	})
+	event := gql.NewObject(gql.ObjectConfig{
+		Name: "Event",
+		Fields: gql.Fields{
+			"seq":       &gql.Field{Type: gql.NewNonNull(gql.Int)},
+			"type":      &gql.Field{Type: gql.NewNonNull(gql.String)},
+			"resources": &gql.Field{Type: listOf(gql.String)},
+			"data":      &gql.Field{Type: gql.String, Description: "The event's data as JSON"},
+		},
+	})
+	subscription := gql.NewObject(gql.ObjectConfig{
+		Name: "Subscription",
+		Fields: gql.Fields{
+			"taskChanged": &gql.Field{
+				Type: gql.NewNonNull(taskChange),
+				Args: gql.FieldConfigArgument{
+					"boardId":   &gql.ArgumentConfig{Type: gql.ID},
+					"projectId": &gql.ArgumentConfig{Type: gql.ID},
+				},
+				Subscribe: h.subscribeTaskChanged,
+				Resolve:   resolveSource,
+			},
+			"beadChanged": &gql.Field{
+				Type: gql.NewNonNull(beadChange),
+				Args: gql.FieldConfigArgument{
+					"id": &gql.ArgumentConfig{Type: gql.ID},
+				},
+				Subscribe: h.subscribeBeadChanged,
+				Resolve:   resolveSource,
+			},
+			"event": &gql.Field{
+				Type:        gql.NewNonNull(event),
+				Description: "Every hub event, filtered like a WebSocket subscription",
+				Args: gql.FieldConfigArgument{
+					"resource": &gql.ArgumentConfig{Type: gql.String, Description: `Resource pattern, e.g. "board:*"`},
+					"types":    &gql.ArgumentConfig{Type: gql.NewList(gql.NewNonNull(gql.String)), Description: `Event type patterns, e.g. "task.*"`},
+				},
+				Subscribe: h.subscribeEvents,
+				Resolve:   resolveSource,
+			},
+		},
+	})
+
+	return gql.SchemaConfig{Query: query, Subscription: subscription}
+}
+
+// listOf is a list of non-null items. The list itself is nullable, so a
+// list the operation may not read is null with an error rather than
+// nulling its parent.
+func listOf(t gql.Type) gql.Type {
+	return gql.NewList(gql.NewNonNull(t))
+}
+
+// idArgs are the arguments of a lookup by ID
+func idArgs() gql.FieldConfigArgument {
+	return gql.FieldConfigArgument{
+		"id": &gql.ArgumentConfig{Type: gql.NewNonNull(gql.ID)},
+	}
+}
+
+// taskFilterArgs filter a board's tasks, like the REST API's list
+// parameters
+func taskFilterArgs() gql.FieldConfigArgument {
+	return gql.FieldConfigArgument{
+		"status":   &gql.ArgumentConfig{Type: gql.NewList(gql.NewNonNull(gql.String))},
+		"assignee": &gql.ArgumentConfig{Type: gql.String, Description: "Assignee ID or name"},
+		"label":    &gql.ArgumentConfig{Type: gql.String},
+		"text":     &gql.ArgumentConfig{Type: gql.String, Description: "Text in the title or description"},
+	}
+}
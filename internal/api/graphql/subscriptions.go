@@ -0,0 +1,226 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	gql "github.com/graphql-go/graphql"
+	"github.com/rand/cartographer/internal/api/websocket"
+	"github.com/rand/cartographer/internal/domain"
+	bd "github.com/steveyegge/beads"
+)
+
+// taskChange is a change to a task as taskChanged reports it
+type taskChange struct {
+	Action  string
+	TaskID  string
+	BoardID string
+	Seq     int
+	Task    *domain.Task
+}
+
+// beadChange is a change to a beads issue as beadChanged reports it
+type beadChange struct {
+	Action  string
+	IssueID string
+	Seq     int
+	Issue   *bd.Issue
+}
+
+// hubEvent is a hub event as the event subscription reports it
+type hubEvent struct {
+	Seq       int
+	Type      string
+	Resources []string
+	Data      string
+}
+
+// taskEvent is the part of a task event's data a taskChange reports
+type taskEvent struct {
+	TaskID  string       `json:"task_id"`
+	BoardID string       `json:"board_id"`
+	Task    *domain.Task `json:"task"`
+}
+
+// beadEvent is the part of a bead event's data a beadChange reports
+type beadEvent struct {
+	IssueID string    `json:"issue_id"`
+	Action  string    `json:"action"`
+	Issue   *bd.Issue `json:"issue"`
+}
+
+// subscribe feeds a subscription from the hub's events until ctx is done.
+// values maps each event the operation may see to the values to send for
+// it, if any.
+func (h *Handler) subscribe(ctx context.Context, values func(*domain.Event) []interface{}) chan interface{} {
+	a := accessFrom(ctx)
+	events := h.hub.Listen(ctx)
+
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		for e := range events {
+			if !a.allowsEvent(e.Resources) {
+				continue
+			}
+			for _, v := range values(e) {
+				select {
+				case out <- v:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out
+}
+
+// subscribeTaskChanged sends a taskChange for every task created, updated,
+// moved or deleted, on a board or in a project if given. A batch of task
+// operations sends one for each task it changed.
+func (h *Handler) subscribeTaskChanged(p gql.ResolveParams) (interface{}, error) {
+	ctx := p.Context
+	a := accessFrom(ctx)
+	boardID, _ := p.Args["boardId"].(string)
+	projectID, _ := p.Args["projectId"].(string)
+	if projectID != "" && !a.allowsProject(projectID) {
+		return nil, errProjectAccess
+	}
+
+	// A batch's event is routed by all its boards, so each change is
+	// checked against the board it was on
+	inScope := func(change *taskChange) bool {
+		if boardID != "" && change.BoardID != boardID {
+			return false
+		}
+		if projectID == "" && !a.restricted() {
+			return true
+		}
+		board, err := h.boards.GetByID(change.BoardID)
+		if err != nil {
+			return false
+		}
+		return (projectID == "" || board.ProjectID == projectID) && a.allowsProject(board.ProjectID)
+	}
+
+	return h.subscribe(ctx, func(e *domain.Event) []interface{} {
+		// Claims change who works on a task, not the task
+		if !strings.HasPrefix(e.Type, "task.") || isLeaseEvent(e.Type) {
+			return nil
+		}
+		var values []interface{}
+		for _, change := range h.taskChanges(e) {
+			if inScope(change) {
+				values = append(values, change)
+			}
+		}
+		return values
+	}), nil
+}
+
+// isLeaseEvent reports whether an event is a work queue claim or release
+func isLeaseEvent(eventType string) bool {
+	t := websocket.MessageType(eventType)
+	return t == websocket.MessageTypeTaskClaimed || t == websocket.MessageTypeTaskReleased
+}
+
+// taskChanges decodes the changes a task event reports
+func (h *Handler) taskChanges(e *domain.Event) []*taskChange {
+	var msg websocket.Message
+	if err := json.Unmarshal(e.Data, &msg); err != nil {
+		h.logger.Printf("Error decoding event %d: %v", e.Seq, err)
+		return nil
+	}
+
+	var changes []*taskChange
+	add := func(action string, events ...taskEvent) {
+		for _, te := range events {
+			changes = append(changes, &taskChange{
+				Action:  action,
+				TaskID:  te.TaskID,
+				BoardID: te.BoardID,
+				Seq:     int(e.Seq),
+				Task:    te.Task,
+			})
+		}
+	}
+
+	if websocket.MessageType(e.Type) == websocket.MessageTypeTaskBatch {
+		var batch struct {
+			Created []taskEvent `json:"created"`
+			Updated []taskEvent `json:"updated"`
+			Moved   []taskEvent `json:"moved"`
+			Deleted []taskEvent `json:"deleted"`
+		}
+		if err := json.Unmarshal(msg.Data, &batch); err != nil {
+			h.logger.Printf("Error decoding event %d: %v", e.Seq, err)
+			return nil
+		}
+		add("created", batch.Created...)
+		add("updated", batch.Updated...)
+		add("moved", batch.Moved...)
+		add("deleted", batch.Deleted...)
+		return changes
+	}
+
+	var te taskEvent
+	if err := json.Unmarshal(msg.Data, &te); err != nil {
+		h.logger.Printf("Error decoding event %d: %v", e.Seq, err)
+		return nil
+	}
+	add(strings.TrimPrefix(e.Type, "task."), te)
+	return changes
+}
+
+// subscribeBeadChanged sends a beadChange for every beads issue created,
+// updated, closed or deleted, or only for the given issue
+func (h *Handler) subscribeBeadChanged(p gql.ResolveParams) (interface{}, error) {
+	if accessFrom(p.Context).restricted() {
+		return nil, errAllProjects
+	}
+	issueID, _ := p.Args["id"].(string)
+
+	return h.subscribe(p.Context, func(e *domain.Event) []interface{} {
+		switch websocket.MessageType(e.Type) {
+		case websocket.MessageTypeBeadCreated, websocket.MessageTypeBeadUpdated,
+			websocket.MessageTypeBeadClosed, websocket.MessageTypeBeadDeleted:
+		default:
+			return nil
+		}
+
+		var msg websocket.Message
+		var be beadEvent
+		if json.Unmarshal(e.Data, &msg) != nil || json.Unmarshal(msg.Data, &be) != nil {
+			h.logger.Printf("Error decoding event %d", e.Seq)
+			return nil
+		}
+		if issueID != "" && be.IssueID != issueID {
+			return nil
+		}
+		return []interface{}{&beadChange{Action: be.Action, IssueID: be.IssueID, Seq: int(e.Seq), Issue: be.Issue}}
+	}), nil
+}
+
+// subscribeEvents sends every hub event matching a resource pattern and
+// event type patterns, as a WebSocket subscription would receive them
+func (h *Handler) subscribeEvents(p gql.ResolveParams) (interface{}, error) {
+	sub := websocket.Subscription{Resource: "*"}
+	if resource, _ := p.Args["resource"].(string); resource != "" {
+		sub.Resource = resource
+	}
+	if types, ok := p.Args["types"].([]interface{}); ok {
+		for _, t := range types {
+			sub.Events = append(sub.Events, t.(string))
+		}
+	}
+
+	return h.subscribe(p.Context, func(e *domain.Event) []interface{} {
+		if !sub.Matches(websocket.MessageType(e.Type), e.Resources) {
+			return nil
+		}
+		var msg websocket.Message
+		json.Unmarshal(e.Data, &msg)
+		return []interface{}{&hubEvent{Seq: int(e.Seq), Type: e.Type, Resources: e.Resources, Data: string(msg.Data)}}
+	}), nil
+}
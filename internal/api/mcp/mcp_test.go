@@ -0,0 +1,454 @@
+package mcp
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rand/cartographer/internal/api/rest"
+	"github.com/rand/cartographer/internal/beads"
+	"github.com/rand/cartographer/internal/domain"
+	"github.com/rand/cartographer/internal/storage"
+	"github.com/rand/cartographer/internal/testutil"
+	bd "github.com/steveyegge/beads"
+)
+
+// recorder is a Broadcaster recording the events it was given
+type recorder struct {
+	events []string
+}
+
+func (r *recorder) BroadcastTaskCreated(taskID, boardID string, task interface{}) error {
+	r.events = append(r.events, "created "+taskID)
+	return nil
+}
+
+func (r *recorder) BroadcastTaskUpdated(taskID, boardID string, changes map[string]interface{}, task interface{}) error {
+	r.events = append(r.events, "updated "+taskID)
+	return nil
+}
+
+func (r *recorder) BroadcastTaskMoved(taskID, boardID, from, to string, position int, task interface{}) error {
+	r.events = append(r.events, "moved "+taskID+" "+from+" "+to)
+	return nil
+}
+
+// fixture is a board whose second task depends on the first, with a
+// document and two beads issues, the second blocked by the first
+type fixture struct {
+	s      *Server
+	hub    *recorder
+	audit  *storage.AuditRepository
+	syncer *beads.Syncer
+	dir    string
+	board  *domain.Board
+	doc    *domain.Document
+	first  *domain.Task
+	second *domain.Task
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	dir := t.TempDir()
+	db := testutil.NewDB(t, dir)
+	testutil.WriteBeads(t, dir)
+
+	projects := storage.NewProjectRepository(db)
+	boards := storage.NewBoardRepository(db)
+	tasks := storage.NewTaskRepository(db)
+	documents := storage.NewDocumentRepository(db)
+	audit := storage.NewAuditRepository(db)
+
+	project := &domain.Project{Name: "Compiler", Path: "/tmp/compiler"}
+	if err := projects.Create(project); err != nil {
+		t.Fatalf("failed to create project: %v", err)
+	}
+	board := &domain.Board{ProjectID: project.ID, Name: "Main", WIPPolicy: domain.WIPPolicyEnforce, Columns: []domain.BoardColumn{
+		{ID: "todo", Name: "To Do"}, {ID: "in_progress", Name: "In Progress", WIPLimit: 1}, {ID: "done", Name: "Done"},
+	}}
+	if err := boards.Create(board); err != nil {
+		t.Fatalf("failed to create board: %v", err)
+	}
+	doc := &domain.Document{ProjectID: project.ID, Title: "Grammar", Path: "docs/grammar.md", Content: "# Grammar\n\nExpressions and statements"}
+	if err := documents.Create(doc); err != nil {
+		t.Fatalf("failed to create document: %v", err)
+	}
+	first := &domain.Task{BoardID: board.ID, Title: "Write the parser", Status: "todo"}
+	if err := tasks.Create(first); err != nil {
+		t.Fatalf("failed to create task: %v", err)
+	}
+	second := &domain.Task{BoardID: board.ID, Title: "Render the tree", Status: "todo", Priority: domain.PriorityUrgent, Dependencies: []string{first.ID}}
+	if err := tasks.Create(second); err != nil {
+		t.Fatalf("failed to create task: %v", err)
+	}
+
+	logger := log.New(io.Discard, "", 0)
+	watcher := beads.NewWatcher(beads.NewParser(dir), time.Hour, nil)
+	syncer := beads.NewSyncer(watcher, tasks, boards, storage.NewBeadSyncRepository(db), logger)
+	api := rest.NewAPIHandler(rest.Config{
+		Projects:     projects,
+		Boards:       boards,
+		Tasks:        tasks,
+		Documents:    documents,
+		Audit:        audit,
+		BeadsWatcher: watcher,
+		BeadsSync:    syncer,
+		Logger:       logger,
+	})
+	mux := http.NewServeMux()
+	api.Register(mux)
+
+	hub := &recorder{}
+	s := NewServer(projects, boards, tasks, documents, storage.NewSearchRepository(db), watcher,
+		rest.AsUser(domain.User{Type: domain.AssigneeAgent, ID: "agent-1"}, mux), hub, logger)
+	return &fixture{s: s, hub: hub, audit: audit, syncer: syncer, dir: dir, board: board, doc: doc, first: first, second: second}
+}
+
+// rpc sends one request and decodes the response's result into v,
+// returning the response's error if any
+func (f *fixture) rpc(t *testing.T, method string, params interface{}, v interface{}) *rpcError {
+	t.Helper()
+	line, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": method, "params": params})
+	resp := f.s.handle(line)
+	if resp == nil {
+		t.Fatalf("%s: expected a response", method)
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	data, _ := json.Marshal(resp.Result)
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatalf("%s: invalid result %s: %v", method, data, err)
+	}
+	return nil
+}
+
+// call calls a tool, decoding its result into v if it succeeded, and
+// returns the error it reported
+func (f *fixture) call(t *testing.T, name string, args interface{}, v interface{}) string {
+	t.Helper()
+	var result struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+		IsError bool `json:"isError"`
+	}
+	if err := f.rpc(t, "tools/call", map[string]interface{}{"name": name, "arguments": args}, &result); err != nil {
+		t.Fatalf("%s: unexpected error %d %s", name, err.Code, err.Message)
+	}
+	if len(result.Content) != 1 || result.Content[0].Type != "text" {
+		t.Fatalf("%s: expected one text content, got %+v", name, result.Content)
+	}
+	if result.IsError {
+		return result.Content[0].Text
+	}
+	if err := json.Unmarshal([]byte(result.Content[0].Text), v); err != nil {
+		t.Fatalf("%s: invalid result %s: %v", name, result.Content[0].Text, err)
+	}
+	return ""
+}
+
+func TestServe(t *testing.T) {
+	f := newFixture(t)
+
+	in := strings.Join([]string{
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26","capabilities":{},"clientInfo":{"name":"test","version":"0"}}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","id":"two","method":"tools/list"}`,
+		`{"jsonrpc":"2.0","id":3,"method":"nothing"}`,
+		`{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"nothing"}}`,
+		`not json`,
+		`{"jsonrpc":"2.0","id":5,"method":"ping"}`,
+	}, "\n")
+	var out bytes.Buffer
+	if err := f.s.Serve(strings.NewReader(in), &out); err != nil {
+		t.Fatalf("Serve failed: %v", err)
+	}
+
+	type serveResponse struct {
+		ID     json.RawMessage `json:"id"`
+		Result struct {
+			ProtocolVersion string `json:"protocolVersion"`
+			Tools           []tool `json:"tools"`
+		} `json:"result"`
+		Error *rpcError `json:"error"`
+	}
+	var responses []serveResponse
+	decoder := json.NewDecoder(&out)
+	for decoder.More() {
+		var resp serveResponse
+		if err := decoder.Decode(&resp); err != nil {
+			t.Fatalf("invalid response: %v", err)
+		}
+		responses = append(responses, resp)
+	}
+
+	var ids []string
+	for _, r := range responses {
+		ids = append(ids, string(r.ID))
+	}
+	if want := []string{"1", `"two"`, "3", "4", "null", "5"}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("expected responses to %v, got %v", want, ids)
+	}
+	if responses[0].Result.ProtocolVersion != "2025-03-26" {
+		t.Errorf("expected the client's protocol version, got %q", responses[0].Result.ProtocolVersion)
+	}
+	var names []string
+	for _, tool := range responses[1].Result.Tools {
+		names = append(names, tool.Name)
+	}
+	if want := []string{"list_ready_work", "create_task", "update_task", "move_task", "search", "read_document", "get_bead_graph"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("expected tools %v, got %v", want, names)
+	}
+	for i, code := range map[int]int{2: codeMethodNotFound, 3: codeInvalidParams, 4: codeParseError} {
+		if responses[i].Error == nil || responses[i].Error.Code != code {
+			t.Errorf("response %s: expected error %d, got %+v", ids[i], code, responses[i].Error)
+		}
+	}
+	if responses[5].Error != nil {
+		t.Errorf("expected ping to succeed, got %+v", responses[5].Error)
+	}
+}
+
+// readyWork is the result of list_ready_work
+type readyWork struct {
+	Tasks []*domain.Task `json:"tasks"`
+	Beads []*bd.Issue    `json:"beads"`
+}
+
+func (w readyWork) taskTitles() []string {
+	titles := []string{}
+	for _, task := range w.Tasks {
+		titles = append(titles, task.Title)
+	}
+	return titles
+}
+
+func TestTaskTools(t *testing.T) {
+	f := newFixture(t)
+
+	var work readyWork
+	f.call(t, "list_ready_work", map[string]string{"board_id": f.board.ID}, &work)
+	if want := []string{"Write the parser"}; !reflect.DeepEqual(work.taskTitles(), want) {
+		t.Errorf("expected ready tasks %v, got %v", want, work.taskTitles())
+	}
+	if len(work.Beads) != 1 || work.Beads[0].ID != "bd-1" {
+		t.Errorf("expected bead bd-1 to be ready, got %+v", work.Beads)
+	}
+
+	// A task without a status goes in the first column
+	var created domain.Task
+	msg := f.call(t, "create_task", map[string]interface{}{"board_id": f.board.ID, "title": "Type check", "priority": "high", "comment": "After parsing"}, &created)
+	if msg != "" {
+		t.Fatalf("create_task failed: %s", msg)
+	}
+	if created.Status != "todo" || created.CreatedBy == nil || created.CreatedBy.ID != "agent-1" || len(created.Activity) != 2 {
+		t.Errorf("unexpected task: %+v", created)
+	}
+
+	var updated domain.Task
+	if msg := f.call(t, "update_task", map[string]interface{}{"id": created.ID, "priority": "low", "labels": []string{"types"}}, &updated); msg != "" {
+		t.Fatalf("update_task failed: %s", msg)
+	}
+	if updated.Priority != "low" || !reflect.DeepEqual(updated.Labels, []string{"types"}) || updated.Title != "Type check" {
+		t.Errorf("unexpected update: %+v", updated)
+	}
+
+	var move domain.TaskMove
+	if msg := f.call(t, "move_task", map[string]interface{}{"id": f.first.ID, "column": "in_progress"}, &move); msg != "" {
+		t.Fatalf("move_task failed: %s", msg)
+	}
+	if move.From != "todo" || move.To != "in_progress" || move.Task.Status != "in_progress" {
+		t.Errorf("unexpected move: %+v", move)
+	}
+
+	// The board enforces WIP limits, and errors are reported as results
+	tests := []struct {
+		name string
+		args map[string]interface{}
+		want string
+	}{
+		{"move_task", map[string]interface{}{"id": created.ID, "column": "in_progress"}, "Column In Progress is at its WIP limit of 1"},
+		{"update_task", map[string]interface{}{"id": created.ID, "status": "in_progress"}, "Column In Progress is at its WIP limit of 1"},
+		{"update_task", map[string]interface{}{"id": created.ID, "board_id": "other"}, "Field board_id can't be changed"},
+		{"update_task", map[string]interface{}{"id": created.ID, "dependencies": []string{"missing"}}, "invalid dependencies[0]: no such task: missing"},
+		{"create_task", map[string]interface{}{"board_id": f.board.ID}, "invalid title: is required"},
+		{"move_task", map[string]interface{}{"id": "missing", "column": "done"}, "Task not found: missing"},
+		{"read_document", map[string]interface{}{"id": "missing"}, "Document not found: missing"},
+	}
+	for _, tt := range tests {
+		if msg := f.call(t, tt.name, tt.args, nil); msg != tt.want {
+			t.Errorf("%s %v: expected error %q, got %q", tt.name, tt.args, tt.want, msg)
+		}
+	}
+
+	// With its dependency done, the second task is ready, most pressing first
+	f.call(t, "move_task", map[string]interface{}{"id": f.first.ID, "column": "done"}, &move)
+	f.call(t, "list_ready_work", map[string]string{}, &work)
+	if want := []string{"Render the tree", "Type check"}; !reflect.DeepEqual(work.taskTitles(), want) {
+		t.Errorf("expected ready tasks %v, got %v", want, work.taskTitles())
+	}
+
+	want := []string{
+		"created " + created.ID,
+		"updated " + created.ID,
+		"moved " + f.first.ID + " todo in_progress",
+		"moved " + f.first.ID + " in_progress done",
+	}
+	if !reflect.DeepEqual(f.hub.events, want) {
+		t.Errorf("expected broadcasts %v, got %v", want, f.hub.events)
+	}
+	entries, err := f.audit.List(storage.AuditQuery{Entity: domain.EntityTask})
+	if err != nil {
+		t.Fatalf("failed to list audit log: %v", err)
+	}
+	if len(entries) != 4 || entries[0].User == nil || entries[0].User.ID != "agent-1" {
+		t.Errorf("expected 4 audit entries by the agent, got %+v", entries)
+	}
+}
+
+func TestTaskToolsSyncBeads(t *testing.T) {
+	f := newFixture(t)
+
+	issues, err := beads.NewParser(f.dir).ReadBeadsFromProject()
+	if err != nil {
+		t.Fatalf("ReadBeadsFromProject failed: %v", err)
+	}
+	if _, _, err := beads.ImportIntoBoard(issues, f.board, f.s.tasks, ""); err != nil {
+		t.Fatalf("ImportIntoBoard failed: %v", err)
+	}
+	if _, err := f.syncer.Sync(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	var task domain.Task
+	if msg := f.call(t, "update_task", map[string]interface{}{"id": "bd-1", "title": "Parse everything"}, &task); msg != "" {
+		t.Fatalf("update_task failed: %s", msg)
+	}
+	var move domain.TaskMove
+	if msg := f.call(t, "move_task", map[string]interface{}{"id": "bd-1", "column": "in_progress"}, &move); msg != "" {
+		t.Fatalf("move_task failed: %s", msg)
+	}
+
+	data, err := os.ReadFile(filepath.Join(f.dir, ".beads", "issues.jsonl"))
+	if err != nil {
+		t.Fatalf("failed to read issues: %v", err)
+	}
+	for _, want := range []string{`"title":"Parse everything"`, `"status":"in_progress"`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("expected the bead written back with %s, got %s", want, data)
+		}
+	}
+}
+
+func TestReadTools(t *testing.T) {
+	f := newFixture(t)
+
+	var results struct {
+		Total int                `json:"total"`
+		Hits  []domain.SearchHit `json:"hits"`
+	}
+	f.call(t, "search", map[string]interface{}{"query": "parser"}, &results)
+	types := map[string]bool{}
+	for _, hit := range results.Hits {
+		types[hit.Type] = true
+	}
+	if !types[domain.SearchTypeTask] || !types[domain.SearchTypeBead] {
+		t.Errorf("expected task and bead hits, got %+v", results.Hits)
+	}
+	if msg := f.call(t, "search", map[string]interface{}{"query": " "}, nil); msg != "query is required" {
+		t.Errorf("expected an error for an empty query, got %q", msg)
+	}
+
+	var doc domain.Document
+	f.call(t, "read_document", map[string]string{"id": f.doc.ID}, &doc)
+	if doc.Content != f.doc.Content {
+		t.Errorf("expected the document's content, got %q", doc.Content)
+	}
+
+	var graph struct {
+		Graph   beads.DependencyGraph `json:"graph"`
+		Blocked []blockedBead         `json:"blocked"`
+	}
+	f.call(t, "get_bead_graph", nil, &graph)
+	if want := []blockedBead{{ID: "bd-2", Title: "Renderer", BlockedBy: []string{"bd-1"}}}; !reflect.DeepEqual(graph.Blocked, want) {
+		t.Errorf("expected blocked beads %+v, got %+v", want, graph.Blocked)
+	}
+	if !reflect.DeepEqual(graph.Graph.DependsOn["bd-2"], []string{"bd-1"}) {
+		t.Errorf("unexpected graph: %+v", graph.Graph)
+	}
+}
+
+func TestResources(t *testing.T) {
+	f := newFixture(t)
+
+	var list struct {
+		Resources []resource `json:"resources"`
+	}
+	if err := f.rpc(t, "resources/list", nil, &list); err != nil {
+		t.Fatalf("resources/list failed: %v", err)
+	}
+	uris := map[string]bool{}
+	for _, r := range list.Resources {
+		uris[r.URI] = true
+	}
+	for _, uri := range []string{
+		"cartographer://projects",
+		"cartographer://projects/" + f.board.ProjectID + "/boards",
+		"cartographer://projects/" + f.board.ProjectID + "/docs",
+		"cartographer://boards/" + f.board.ID,
+		"cartographer://documents/" + f.doc.ID,
+	} {
+		if !uris[uri] {
+			t.Errorf("expected resource %s in %+v", uri, list.Resources)
+		}
+	}
+
+	read := func(uri string) (string, string, *rpcError) {
+		var result struct {
+			Contents []map[string]string `json:"contents"`
+		}
+		if err := f.rpc(t, "resources/read", map[string]string{"uri": uri}, &result); err != nil {
+			return "", "", err
+		}
+		if len(result.Contents) != 1 || result.Contents[0]["uri"] != uri {
+			t.Fatalf("expected the contents of %s, got %+v", uri, result.Contents)
+		}
+		return result.Contents[0]["text"], result.Contents[0]["mimeType"], nil
+	}
+
+	text, mimeType, _ := read("cartographer://documents/" + f.doc.ID)
+	if text != f.doc.Content || mimeType != "text/markdown" {
+		t.Errorf("expected the document as markdown, got %s %q", mimeType, text)
+	}
+
+	text, _, _ = read("cartographer://boards/" + f.board.ID)
+	var board struct {
+		Board *domain.Board  `json:"board"`
+		Tasks []*domain.Task `json:"tasks"`
+	}
+	if err := json.Unmarshal([]byte(text), &board); err != nil || board.Board.Name != "Main" || len(board.Tasks) != 2 {
+		t.Errorf("expected the board and its tasks, got %s", text)
+	}
+
+	text, _, _ = read("cartographer://projects/" + f.board.ProjectID + "/docs")
+	var docs []*domain.Document
+	if err := json.Unmarshal([]byte(text), &docs); err != nil || len(docs) != 1 || docs[0].Content != "" {
+		t.Errorf("expected the documents without content, got %s", text)
+	}
+
+	for _, uri := range []string{"cartographer://boards/missing", "cartographer://projects/missing/docs", "cartographer://nothing", "file:///etc/passwd"} {
+		if _, _, err := read(uri); err == nil || err.Code != codeResourceNotFound {
+			t.Errorf("%s: expected resource not found, got %+v", uri, err)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package mcp
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/rand/cartographer/internal/domain"
+	"github.com/rand/cartographer/internal/storage"
+)
+
+// resourceScheme prefixes the URIs of the server's resources
+const resourceScheme = "cartographer://"
+
+// resource describes a resource clients can read
+type resource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType"`
+}
+
+// resourceTemplate describes resources by a URI template
+type resourceTemplate struct {
+	URITemplate string `json:"uriTemplate"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	MimeType    string `json:"mimeType"`
+}
+
+var resourceTemplates = []resourceTemplate{
+	{resourceScheme + "projects/{id}/boards", "Project boards", "The boards of a project", "application/json"},
+	{resourceScheme + "projects/{id}/docs", "Project documents", "The documents of a project, without their content", "application/json"},
+	{resourceScheme + "boards/{id}", "Board", "A board and its tasks", "application/json"},
+	{resourceScheme + "documents/{id}", "Document", "A document's markdown content", "text/markdown"},
+}
+
+// listResources lists the projects, each project's boards and documents,
+// and each board and document
+func (s *Server) listResources() (interface{}, error) {
+	resources := []resource{{URI: resourceScheme + "projects", Name: "Projects", Description: "Every project", MimeType: "application/json"}}
+
+	projects, err := s.projects.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, project := range projects {
+		resources = append(resources,
+			resource{URI: resourceScheme + "projects/" + project.ID + "/boards", Name: project.Name + " boards", MimeType: "application/json"},
+			resource{URI: resourceScheme + "projects/" + project.ID + "/docs", Name: project.Name + " documents", MimeType: "application/json"},
+		)
+
+		boards, err := s.boards.ListByProject(project.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, board := range boards {
+			resources = append(resources, resource{URI: resourceScheme + "boards/" + board.ID, Name: board.Name, Description: "Board of " + project.Name, MimeType: "application/json"})
+		}
+
+		docs, err := s.documents.ListByProject(project.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, doc := range docs {
+			resources = append(resources, resource{URI: resourceScheme + "documents/" + doc.ID, Name: doc.Title, Description: doc.Path, MimeType: "text/markdown"})
+		}
+	}
+	return map[string]interface{}{"resources": resources}, nil
+}
+
+// readResource reads a resource by URI
+func (s *Server) readResource(params json.RawMessage) (interface{}, error) {
+	var p struct {
+		URI string `json:"uri"`
+	}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	text, mimeType, err := s.resourceText(p.URI)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, &rpcError{codeResourceNotFound, "Resource not found: " + p.URI}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"contents": []map[string]string{{"uri": p.URI, "mimeType": mimeType, "text": text}},
+	}, nil
+}
+
+// resourceText returns a resource's content and MIME type. Unknown URIs
+// return storage.ErrNotFound.
+func (s *Server) resourceText(uri string) (string, string, error) {
+	path, ok := strings.CutPrefix(uri, resourceScheme)
+	if !ok {
+		return "", "", storage.ErrNotFound
+	}
+	parts := strings.Split(path, "/")
+
+	var v interface{}
+	var err error
+	switch {
+	case len(parts) == 1 && parts[0] == "projects":
+		v, err = s.projects.List()
+	case len(parts) == 3 && parts[0] == "projects" && parts[2] == "boards":
+		if _, err = s.projects.GetByID(parts[1]); err == nil {
+			v, err = s.boards.ListByProject(parts[1])
+		}
+	case len(parts) == 3 && parts[0] == "projects" && parts[2] == "docs":
+		if _, err = s.projects.GetByID(parts[1]); err == nil {
+			v, err = s.documentList(parts[1])
+		}
+	case len(parts) == 2 && parts[0] == "boards":
+		v, err = s.boardWithTasks(parts[1])
+	case len(parts) == 2 && parts[0] == "documents":
+		doc, err := s.documents.GetByID(parts[1])
+		if err != nil {
+			return "", "", err
+		}
+		return doc.Content, "text/markdown", nil
+	default:
+		return "", "", storage.ErrNotFound
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", "", err
+	}
+	return string(data), "application/json", nil
+}
+
+// documentList returns a project's documents without their content, which
+// is read through each document's resource
+func (s *Server) documentList(projectID string) ([]*domain.Document, error) {
+	docs, err := s.documents.ListByProject(projectID)
+	if err != nil {
+		return nil, err
+	}
+	for _, doc := range docs {
+		doc.Content = ""
+		doc.Versions = nil
+	}
+	return docs, nil
+}
+
+// boardWithTasks returns a board along with its tasks
+func (s *Server) boardWithTasks(id string) (interface{}, error) {
+	board, err := s.boards.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	tasks, err := s.tasks.ListByBoard(id)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"board": board, "tasks": tasks}, nil
+}
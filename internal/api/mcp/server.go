@@ -0,0 +1,232 @@
+// Package mcp serves the Model Context Protocol over stdio, so agents can
+// plan with Cartographer as tools and resources rather than HTTP calls. It
+// reads the storage repositories and the beads issues file directly, and
+// makes task changes through the REST API served in process, so they get
+// the same validation, activity, audit log and beads sync. Task changes are
+// broadcast to a running server's WebSocket clients through a Broadcaster.
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/rand/cartographer/internal/beads"
+	"github.com/rand/cartographer/internal/storage"
+)
+
+// ProtocolVersion is the latest MCP revision the server speaks
+const ProtocolVersion = "2025-06-18"
+
+// protocolVersions lists the MCP revisions the server accepts, newest first
+var protocolVersions = []string{ProtocolVersion, "2025-03-26", "2024-11-05"}
+
+// serverVersion is reported to clients on initialization
+const serverVersion = "1.0.0"
+
+// Broadcaster sends task events to WebSocket clients: the hub itself, or a
+// websocket.Relay to the hub of a running server
+type Broadcaster interface {
+	BroadcastTaskCreated(taskID, boardID string, task interface{}) error
+	BroadcastTaskUpdated(taskID, boardID string, changes map[string]interface{}, task interface{}) error
+	BroadcastTaskMoved(taskID, boardID, from, to string, position int, task interface{}) error
+}
+
+// Server answers MCP requests from one client
+type Server struct {
+	projects     *storage.ProjectRepository
+	boards       *storage.BoardRepository
+	tasks        *storage.TaskRepository
+	documents    *storage.DocumentRepository
+	search       *storage.SearchRepository
+	beadsWatcher *beads.Watcher
+	api          http.Handler
+	hub          Broadcaster
+	logger       *log.Logger
+}
+
+// NewServer creates an MCP server. Task changes are made through api, the
+// REST API on the same database with requests attributed to the agent (see
+// rest.AsUser), and broadcast through hub if it isn't nil.
+func NewServer(
+	projects *storage.ProjectRepository,
+	boards *storage.BoardRepository,
+	tasks *storage.TaskRepository,
+	documents *storage.DocumentRepository,
+	search *storage.SearchRepository,
+	beadsWatcher *beads.Watcher,
+	api http.Handler,
+	hub Broadcaster,
+	logger *log.Logger,
+) *Server {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &Server{
+		projects:     projects,
+		boards:       boards,
+		tasks:        tasks,
+		documents:    documents,
+		search:       search,
+		beadsWatcher: beadsWatcher,
+		api:          api,
+		hub:          hub,
+		logger:       logger,
+	}
+}
+
+// JSON-RPC 2.0 error codes
+const (
+	codeParseError       = -32700
+	codeInvalidRequest   = -32600
+	codeMethodNotFound   = -32601
+	codeInvalidParams    = -32602
+	codeInternalError    = -32603
+	codeResourceNotFound = -32002
+)
+
+// request is a JSON-RPC request, or a notification if it has no ID
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// response is a JSON-RPC response, with either a result or an error
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+// Serve reads newline-delimited JSON-RPC messages from in and writes the
+// responses to out, one per line, until in ends
+func (s *Server) Serve(in io.Reader, out io.Writer) error {
+	reader := bufio.NewReader(in)
+	encoder := json.NewEncoder(out)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			if resp := s.handle(line); resp != nil {
+				if err := encoder.Encode(resp); err != nil {
+					return err
+				}
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// handle answers one message, returning nil for notifications
+func (s *Server) handle(line []byte) *response {
+	var req request
+	if err := json.Unmarshal(line, &req); err != nil {
+		return &response{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{codeParseError, "Parse error"}}
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		if req.ID == nil {
+			return nil
+		}
+		return &response{JSONRPC: "2.0", ID: req.ID, Error: &rpcError{codeInvalidRequest, "Invalid request"}}
+	}
+
+	result, err := s.call(req.Method, req.Params)
+	if req.ID == nil {
+		return nil
+	}
+	resp := &response{JSONRPC: "2.0", ID: req.ID, Result: result}
+	if err != nil {
+		var rpcErr *rpcError
+		if !errors.As(err, &rpcErr) {
+			s.logger.Printf("Error handling %s: %v", req.Method, err)
+			rpcErr = &rpcError{codeInternalError, "Internal error"}
+		}
+		resp.Result, resp.Error = nil, rpcErr
+	}
+	return resp
+}
+
+// call runs a method with its params
+func (s *Server) call(method string, params json.RawMessage) (interface{}, error) {
+	switch method {
+	case "initialize":
+		return s.initialize(params)
+	case "ping":
+		return struct{}{}, nil
+	case "tools/list":
+		return map[string]interface{}{"tools": tools}, nil
+	case "tools/call":
+		return s.callTool(params)
+	case "resources/list":
+		return s.listResources()
+	case "resources/templates/list":
+		return map[string]interface{}{"resourceTemplates": resourceTemplates}, nil
+	case "resources/read":
+		return s.readResource(params)
+	}
+	if strings.HasPrefix(method, "notifications/") {
+		return nil, nil
+	}
+	return nil, &rpcError{codeMethodNotFound, "Method not found: " + method}
+}
+
+// initialize agrees on a protocol revision: the client's if the server
+// speaks it, otherwise the latest
+func (s *Server) initialize(params json.RawMessage) (interface{}, error) {
+	var p struct {
+		ProtocolVersion string `json:"protocolVersion"`
+	}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	version := ProtocolVersion
+	for _, v := range protocolVersions {
+		if v == p.ProtocolVersion {
+			version = v
+		}
+	}
+	return map[string]interface{}{
+		"protocolVersion": version,
+		"capabilities": map[string]interface{}{
+			"tools":     map[string]interface{}{},
+			"resources": map[string]interface{}{},
+		},
+		"serverInfo": map[string]string{"name": "cartographer", "version": serverVersion},
+		"instructions": "Cartographer plans work as projects with kanban boards of tasks, documents and beads issues. " +
+			"Use list_ready_work to find unblocked tasks and beads, and move_task to move a task across its board's columns.",
+	}, nil
+}
+
+// decodeParams decodes a request's params into v, which absent params
+// leave unchanged
+func decodeParams(params json.RawMessage, v interface{}) error {
+	if len(params) == 0 || string(params) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return &rpcError{codeInvalidParams, "Invalid params: " + err.Error()}
+	}
+	return nil
+}
@@ -0,0 +1,598 @@
+package mcp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/rand/cartographer/internal/beads"
+	"github.com/rand/cartographer/internal/domain"
+	"github.com/rand/cartographer/internal/storage"
+	bd "github.com/steveyegge/beads"
+)
+
+// tool is a tool the server offers, run with the call's arguments
+type tool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	InputSchema map[string]interface{} `json:"inputSchema"`
+
+	run func(s *Server, args json.RawMessage) (interface{}, error)
+}
+
+// toolError is a failed tool call reported to the agent, like a 4xx from
+// the REST API
+type toolError struct {
+	message string
+}
+
+func (e *toolError) Error() string {
+	return e.message
+}
+
+func toolErrorf(format string, args ...interface{}) error {
+	return &toolError{fmt.Sprintf(format, args...)}
+}
+
+// object returns the input schema of an object with the given properties
+func object(required []string, properties map[string]interface{}) map[string]interface{} {
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// property returns the schema of a described property of a JSON type
+func property(typ, description string) map[string]interface{} {
+	return map[string]interface{}{"type": typ, "description": description}
+}
+
+// stringList returns the schema of a described list of strings
+func stringList(description string) map[string]interface{} {
+	return map[string]interface{}{"type": "array", "items": map[string]string{"type": "string"}, "description": description}
+}
+
+// taskFields are the task fields create_task and update_task set
+func taskFields() map[string]interface{} {
+	return map[string]interface{}{
+		"title":        property("string", "Title"),
+		"description":  property("string", "Description in markdown"),
+		"status":       property("string", "Column ID of the task's board"),
+		"priority":     map[string]interface{}{"type": "string", "enum": domain.Priorities},
+		"assignee":     object([]string{"id"}, map[string]interface{}{"type": map[string]interface{}{"type": "string", "enum": []string{domain.AssigneeHuman, domain.AssigneeAgent}}, "id": property("string", "Assignee ID"), "name": property("string", "Display name")}),
+		"labels":       stringList("Labels"),
+		"due_date":     map[string]interface{}{"type": "string", "format": "date-time"},
+		"estimate":     property("number", "Estimate in hours"),
+		"dependencies": stringList("IDs of tasks this task depends on"),
+		"blocks":       stringList("IDs of tasks this task blocks"),
+		"related":      stringList("IDs of related tasks"),
+		"comment":      property("string", "A comment to add to the task's activity"),
+	}
+}
+
+// withFields returns fields with more properties added
+func withFields(fields map[string]interface{}, more map[string]interface{}) map[string]interface{} {
+	for name, schema := range more {
+		fields[name] = schema
+	}
+	return fields
+}
+
+var tools = []tool{
+	{
+		Name: "list_ready_work",
+		Description: "List tasks that are ready to work on, most pressing first: not in a done column and with every task they depend on done. " +
+			"Covers a board, a project's boards, or every board. Also lists open beads issues with no open blockers.",
+		InputSchema: object(nil, map[string]interface{}{
+			"project_id": property("string", "Only tasks on this project's boards"),
+			"board_id":   property("string", "Only tasks on this board"),
+		}),
+		run: (*Server).listReadyWork,
+	},
+	{
+		Name:        "create_task",
+		Description: "Create a task on a board. Without a status it goes in the board's first column.",
+		InputSchema: object([]string{"board_id", "title"}, withFields(taskFields(), map[string]interface{}{
+			"board_id": property("string", "Board to create the task on"),
+		})),
+		run: (*Server).createTask,
+	},
+	{
+		Name:        "update_task",
+		Description: "Change a task's fields; fields left out are unchanged and null clears one. Changing the status moves the task to the bottom of that column.",
+		InputSchema: object([]string{"id"}, withFields(taskFields(), map[string]interface{}{
+			"id": property("string", "Task ID"),
+		})),
+		run: (*Server).updateTask,
+	},
+	{
+		Name:        "move_task",
+		Description: "Move a task to a position in a column of its board, subject to the board's WIP limits.",
+		InputSchema: object([]string{"id", "column"}, map[string]interface{}{
+			"id":       property("string", "Task ID"),
+			"column":   property("string", "Column ID to move to"),
+			"position": property("integer", "Index in the column, 0 being the top; the bottom if left out"),
+		}),
+		run: (*Server).moveTask,
+	},
+	{
+		Name:        "search",
+		Description: "Search tasks, documents, diagrams and beads issues, best matches first.",
+		InputSchema: object([]string{"query"}, map[string]interface{}{
+			"query":      property("string", "Words to search for"),
+			"types":      map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string", "enum": domain.SearchTypes}},
+			"project_id": property("string", "Only results in this project"),
+			"limit":      property("integer", "Maximum number of results (default 20)"),
+		}),
+		run: (*Server).searchAll,
+	},
+	{
+		Name:        "read_document",
+		Description: "Read a document, including its markdown content.",
+		InputSchema: object([]string{"id"}, map[string]interface{}{
+			"id": property("string", "Document ID"),
+		}),
+		run: (*Server).readDocument,
+	},
+	{
+		Name:        "get_bead_graph",
+		Description: "Get the dependency graph of the beads issues and the open issues blocked by others.",
+		InputSchema: object(nil, map[string]interface{}{}),
+		run:         (*Server).getBeadGraph,
+	},
+}
+
+// callTool runs a tool. Failures of the tool itself are results flagged as
+// errors, so the agent sees them; only unknown tools are protocol errors.
+func (s *Server) callTool(params json.RawMessage) (interface{}, error) {
+	var p struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	var t *tool
+	for i := range tools {
+		if tools[i].Name == p.Name {
+			t = &tools[i]
+		}
+	}
+	if t == nil {
+		return nil, &rpcError{codeInvalidParams, "Unknown tool: " + p.Name}
+	}
+	if len(p.Arguments) == 0 || string(p.Arguments) == "null" {
+		p.Arguments = json.RawMessage("{}")
+	}
+
+	result, err := t.run(s, p.Arguments)
+	if err != nil {
+		return toolResult(s.failure(p.Name, err), true), nil
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	return toolResult(string(data), false), nil
+}
+
+// toolResult is the result of a tool call, as text
+func toolResult(text string, isError bool) map[string]interface{} {
+	return map[string]interface{}{
+		"content": []map[string]string{{"type": "text", "text": text}},
+		"isError": isError,
+	}
+}
+
+// failure returns the message to report for a failed tool call, logging
+// errors that aren't the agent's
+func (s *Server) failure(name string, err error) string {
+	var te *toolError
+	var invalid *domain.ValidationError
+	switch {
+	case errors.As(err, &te), errors.As(err, &invalid):
+		return err.Error()
+	case errors.Is(err, storage.ErrRevisionConflict):
+		return "The task changed meanwhile; read it again and retry"
+	}
+	s.logger.Printf("Error running %s: %v", name, err)
+	return "Internal error"
+}
+
+// decodeArgs decodes a tool's arguments into v
+func decodeArgs(args json.RawMessage, v interface{}) error {
+	if err := json.Unmarshal(args, v); err != nil {
+		return toolErrorf("Invalid arguments: %v", err)
+	}
+	return nil
+}
+
+// listReadyWork lists the ready tasks of the boards in scope and the
+// ready beads issues
+func (s *Server) listReadyWork(args json.RawMessage) (interface{}, error) {
+	var p struct {
+		ProjectID string `json:"project_id"`
+		BoardID   string `json:"board_id"`
+	}
+	if err := decodeArgs(args, &p); err != nil {
+		return nil, err
+	}
+
+	boards, err := s.scopeBoards(p.ProjectID, p.BoardID)
+	if err != nil {
+		return nil, err
+	}
+	ready := []*domain.Task{}
+	for _, board := range boards {
+		tasks, err := s.tasks.ListByBoard(board.ID)
+		if err != nil {
+			return nil, err
+		}
+		found, err := beads.ReadyTasks(tasks)
+		if err != nil {
+			return nil, err
+		}
+		ready = append(ready, found...)
+	}
+	sort.SliceStable(ready, func(i, j int) bool {
+		return domain.PriorityRank(ready[i].Priority) > domain.PriorityRank(ready[j].Priority)
+	})
+
+	issues, err := s.beadIssues()
+	if err != nil {
+		return nil, err
+	}
+	analyzer := beads.NewAnalyzer(issues)
+	if _, err := analyzer.BuildDependencyGraph(); err != nil {
+		return nil, err
+	}
+	readyBeads, err := analyzer.GetReadyIssues()
+	if err != nil {
+		return nil, err
+	}
+	if readyBeads == nil {
+		readyBeads = []*bd.Issue{}
+	}
+	// Beads priorities run from 0, the most pressing
+	sort.SliceStable(readyBeads, func(i, j int) bool { return readyBeads[i].Priority < readyBeads[j].Priority })
+
+	return map[string]interface{}{"tasks": ready, "beads": readyBeads}, nil
+}
+
+// scopeBoards returns a board, a project's boards, or every board
+func (s *Server) scopeBoards(projectID, boardID string) ([]*domain.Board, error) {
+	if boardID != "" {
+		board, err := s.boards.GetByID(boardID)
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, toolErrorf("Board not found: %s", boardID)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if projectID != "" && board.ProjectID != projectID {
+			return nil, toolErrorf("Board %s is not in project %s", boardID, projectID)
+		}
+		return []*domain.Board{board}, nil
+	}
+
+	var projects []*domain.Project
+	if projectID != "" {
+		project, err := s.projects.GetByID(projectID)
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, toolErrorf("Project not found: %s", projectID)
+		}
+		if err != nil {
+			return nil, err
+		}
+		projects = append(projects, project)
+	} else {
+		var err error
+		if projects, err = s.projects.List(); err != nil {
+			return nil, err
+		}
+	}
+
+	var boards []*domain.Board
+	for _, project := range projects {
+		found, err := s.boards.ListByProject(project.ID)
+		if err != nil {
+			return nil, err
+		}
+		boards = append(boards, found...)
+	}
+	return boards, nil
+}
+
+// taskArgs are the arguments of create_task and update_task besides the
+// task's fields
+type taskArgs struct {
+	ID      string `json:"id"`
+	BoardID string `json:"board_id"`
+	Comment string `json:"comment"`
+}
+
+// createTask creates a task through POST /api/tasks
+func (s *Server) createTask(args json.RawMessage) (interface{}, error) {
+	var p taskArgs
+	var task domain.Task
+	if err := decodeArgs(args, &p); err != nil {
+		return nil, err
+	}
+	if err := decodeArgs(args, &task); err != nil {
+		return nil, err
+	}
+	// Only the fields an agent may set are kept
+	task = domain.Task{
+		BoardID:      p.BoardID,
+		Title:        task.Title,
+		Description:  task.Description,
+		Status:       task.Status,
+		Priority:     task.Priority,
+		Assignee:     task.Assignee,
+		Labels:       task.Labels,
+		DueDate:      task.DueDate,
+		Estimate:     task.Estimate,
+		Dependencies: task.Dependencies,
+		Blocks:       task.Blocks,
+		Related:      task.Related,
+	}
+	if task.Status == "" {
+		board, err := s.boards.GetByID(task.BoardID)
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return nil, err
+		}
+		if board != nil && len(board.Columns) > 0 {
+			task.Status = board.Columns[0].ID
+		}
+	}
+	if p.Comment != "" {
+		task.Activity = []domain.ActivityEntry{comment(p.Comment)}
+	}
+
+	var created domain.Task
+	if err := s.callAPI(http.MethodPost, "/api/tasks", "application/json", &task, &created); err != nil {
+		return nil, err
+	}
+	if s.hub != nil {
+		if err := s.hub.BroadcastTaskCreated(created.ID, created.BoardID, &created); err != nil {
+			s.logger.Printf("Error broadcasting task %s: %v", created.ID, err)
+		}
+	}
+	return &created, nil
+}
+
+// taskReadOnly are the task fields update_task can't change
+var taskReadOnly = []string{"board_id", "rank", "created_at", "updated_at", "revision", "created_by", "activity"}
+
+// updateTask applies the given fields to a task as a merge patch through
+// PATCH /api/tasks/{id}
+func (s *Server) updateTask(args json.RawMessage) (interface{}, error) {
+	var p taskArgs
+	var fields map[string]interface{}
+	if err := decodeArgs(args, &p); err != nil {
+		return nil, err
+	}
+	if err := decodeArgs(args, &fields); err != nil {
+		return nil, err
+	}
+	delete(fields, "id")
+	delete(fields, "comment")
+	for _, name := range taskReadOnly {
+		if _, ok := fields[name]; ok {
+			return nil, toolErrorf("Field %s can't be changed", name)
+		}
+	}
+	if p.Comment != "" {
+		fields["activity"] = []domain.ActivityEntry{comment(p.Comment)}
+	}
+
+	existing, err := s.getTask(p.ID)
+	if err != nil {
+		return nil, err
+	}
+	var task domain.Task
+	if err := s.callAPI(http.MethodPatch, "/api/tasks/"+url.PathEscape(p.ID), "application/merge-patch+json", fields, &task); err != nil {
+		return nil, err
+	}
+	if s.hub != nil && task.Revision != existing.Revision {
+		changes := domain.DiffTasks(existing, &task)
+		if err := s.hub.BroadcastTaskUpdated(task.ID, task.BoardID, changes.Values(), &task); err != nil {
+			s.logger.Printf("Error broadcasting task %s: %v", task.ID, err)
+		}
+	}
+	return &task, nil
+}
+
+// moveTask moves a task within its board through PATCH
+// /api/tasks/{id}/move
+func (s *Server) moveTask(args json.RawMessage) (interface{}, error) {
+	var p struct {
+		ID       string `json:"id"`
+		Column   string `json:"column"`
+		Position *int   `json:"position"`
+	}
+	if err := decodeArgs(args, &p); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.getTask(p.ID); err != nil {
+		return nil, err
+	}
+	body := map[string]interface{}{"column": p.Column, "position": p.Position}
+	var move domain.TaskMove
+	if err := s.callAPI(http.MethodPatch, "/api/tasks/"+url.PathEscape(p.ID)+"/move", "application/json", body, &move); err != nil {
+		return nil, err
+	}
+	if s.hub != nil {
+		task := move.Task
+		if err := s.hub.BroadcastTaskMoved(task.ID, task.BoardID, move.From, move.To, move.Position, task); err != nil {
+			s.logger.Printf("Error broadcasting task %s: %v", task.ID, err)
+		}
+	}
+	return &move, nil
+}
+
+// comment returns an activity entry adding a comment, which the API
+// attributes to the server's user
+func comment(text string) domain.ActivityEntry {
+	return domain.ActivityEntry{Type: domain.ActivityCommented, Comment: text}
+}
+
+// callAPI makes a request of the REST API served in process with body
+// encoded as JSON and decodes the response into v. Error responses are
+// returned as tool errors, with invalid fields as validation errors, except
+// for server errors.
+func (s *Server) callAPI(method, path, contentType string, body, v interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req := httptest.NewRequest(method, path, bytes.NewReader(data))
+	req.Header.Set("Content-Type", contentType)
+	rec := httptest.NewRecorder()
+	s.api.ServeHTTP(rec, req)
+
+	if rec.Code >= http.StatusBadRequest {
+		var resp struct {
+			Message     string              `json:"message"`
+			FieldErrors []domain.FieldError `json:"field_errors"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		switch {
+		case rec.Code >= http.StatusInternalServerError:
+			return fmt.Errorf("%s %s: %d %s", method, path, rec.Code, resp.Message)
+		case len(resp.FieldErrors) > 0:
+			return &domain.ValidationError{Fields: resp.FieldErrors}
+		default:
+			return &toolError{resp.Message}
+		}
+	}
+	return json.Unmarshal(rec.Body.Bytes(), v)
+}
+
+// searchAll searches the database and the beads issues as GET /api/search
+// does
+func (s *Server) searchAll(args json.RawMessage) (interface{}, error) {
+	var p struct {
+		Query     string   `json:"query"`
+		Types     []string `json:"types"`
+		ProjectID string   `json:"project_id"`
+		Limit     int      `json:"limit"`
+	}
+	if err := decodeArgs(args, &p); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(p.Query) == "" {
+		return nil, toolErrorf("query is required")
+	}
+	if p.Limit <= 0 {
+		p.Limit = 20
+	}
+
+	wantBeads := len(p.Types) == 0
+	var types []string
+	for _, t := range p.Types {
+		if t == domain.SearchTypeBead {
+			wantBeads = true
+		} else {
+			types = append(types, t)
+		}
+	}
+
+	var stored, beadHits []domain.SearchHit
+	if len(p.Types) == 0 || len(types) > 0 {
+		found, err := s.search.Search(p.Query, storage.SearchOptions{Types: types, ProjectID: p.ProjectID, Limit: p.Limit})
+		if err != nil {
+			return nil, err
+		}
+		stored = found
+	}
+	if wantBeads && p.ProjectID == "" {
+		if _, err := s.beadsWatcher.Refresh(); err != nil && !s.beadsWatcher.Missing() {
+			s.logger.Printf("Error reading beads issues: %v", err)
+		}
+		found, err := s.beadsWatcher.Search(p.Query, p.Limit)
+		if err != nil {
+			// Beads are supplementary; don't fail the whole search
+			s.logger.Printf("Error searching beads: %v", err)
+		}
+		beadHits = found
+	}
+
+	hits := domain.MergeSearchHits(p.Limit, stored, beadHits)
+	return map[string]interface{}{"query": p.Query, "total": len(hits), "hits": hits}, nil
+}
+
+func (s *Server) readDocument(args json.RawMessage) (interface{}, error) {
+	var p struct {
+		ID string `json:"id"`
+	}
+	if err := decodeArgs(args, &p); err != nil {
+		return nil, err
+	}
+	doc, err := s.documents.GetByID(p.ID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, toolErrorf("Document not found: %s", p.ID)
+	}
+	return doc, err
+}
+
+// blockedBead is an open beads issue and the open issues blocking it
+type blockedBead struct {
+	ID        string   `json:"id"`
+	Title     string   `json:"title"`
+	BlockedBy []string `json:"blocked_by"`
+}
+
+// getBeadGraph returns the beads dependency graph and the blocked issues
+func (s *Server) getBeadGraph(args json.RawMessage) (interface{}, error) {
+	issues, err := s.beadIssues()
+	if err != nil {
+		return nil, err
+	}
+	analyzer := beads.NewAnalyzer(issues)
+	graph, err := analyzer.BuildDependencyGraph()
+	if err != nil {
+		return nil, err
+	}
+	blocked, err := analyzer.GetBlockedIssues()
+	if err != nil {
+		return nil, err
+	}
+
+	result := []blockedBead{}
+	for _, info := range blocked {
+		result = append(result, blockedBead{ID: info.IssueID, Title: info.Issue.Title, BlockedBy: info.BlockedBy})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return map[string]interface{}{"graph": graph, "blocked": result}, nil
+}
+
+// beadIssues re-reads the beads issues file if it changed. A missing file
+// has no issues.
+func (s *Server) beadIssues() ([]*bd.Issue, error) {
+	_, err := s.beadsWatcher.Refresh()
+	if s.beadsWatcher.Missing() {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.beadsWatcher.Issues()
+}
+
+// getTask returns a task or a tool error if it doesn't exist
+func (s *Server) getTask(id string) (*domain.Task, error) {
+	task, err := s.tasks.GetByID(id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, toolErrorf("Task not found: %s", id)
+	}
+	return task, err
+}
@@ -0,0 +1,81 @@
+package rest
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rand/cartographer/internal/claude"
+	"github.com/rand/cartographer/internal/domain"
+	"github.com/rand/cartographer/internal/storage"
+	"github.com/rand/cartographer/internal/testutil"
+)
+
+// testAPI serves an APIHandler on every repository of a fresh database
+// holding a project and optionally one of its boards
+type testAPI struct {
+	tasks     *storage.TaskRepository
+	documents *storage.DocumentRepository
+	diagrams  *storage.DiagramRepository
+	audit     *storage.AuditRepository
+	leases    *storage.LeaseRepository
+	mux       *http.ServeMux
+}
+
+// newTestAPI creates project, and board in it if not nil, in a new
+// database, and registers an APIHandler on the database
+func newTestAPI(t *testing.T, project *domain.Project, board *domain.Board) *testAPI {
+	t.Helper()
+	db := testutil.NewDB(t, t.TempDir())
+
+	projects := storage.NewProjectRepository(db)
+	boards := storage.NewBoardRepository(db)
+	if err := projects.Create(project); err != nil {
+		t.Fatalf("failed to create project: %v", err)
+	}
+	if board != nil {
+		board.ProjectID = project.ID
+		if err := boards.Create(board); err != nil {
+			t.Fatalf("failed to create board: %v", err)
+		}
+	}
+
+	api := &testAPI{
+		tasks:     storage.NewTaskRepository(db),
+		documents: storage.NewDocumentRepository(db),
+		diagrams:  storage.NewDiagramRepository(db),
+		audit:     storage.NewAuditRepository(db),
+		leases:    storage.NewLeaseRepository(db),
+		mux:       http.NewServeMux(),
+	}
+	h := NewAPIHandler(Config{
+		Projects:  projects,
+		Boards:    boards,
+		Tasks:     api.tasks,
+		Documents: api.documents,
+		Diagrams:  api.diagrams,
+		Search:    storage.NewSearchRepository(db),
+		APITokens: storage.NewAPITokenRepository(db),
+		Audit:     api.audit,
+		Leases:    api.leases,
+		Sessions:  claude.NewRecorder(storage.NewSessionRepository(db), api.tasks, boards),
+		Logger:    log.New(io.Discard, "", 0),
+	})
+	h.Register(api.mux)
+	return api
+}
+
+// request serves a request, authenticated with token if it isn't nil
+func (api *testAPI) request(method, path, body string, token *domain.APIToken) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != nil {
+		req = req.WithContext(context.WithValue(req.Context(), tokenContextKey, token))
+	}
+	rec := httptest.NewRecorder()
+	api.mux.ServeHTTP(rec, req)
+	return rec
+}
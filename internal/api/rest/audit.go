@@ -0,0 +1,124 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rand/cartographer/internal/domain"
+	"github.com/rand/cartographer/internal/storage"
+)
+
+// recordAudit adds an entry for a mutation to the audit log. Failures are
+// logged rather than failing a request whose change already happened.
+func (h *APIHandler) recordAudit(r *http.Request, entity, entityID, projectID, action string, changes domain.Changes) {
+	if h.audit == nil {
+		return
+	}
+
+	entry := &domain.AuditEntry{
+		Entity:    entity,
+		EntityID:  entityID,
+		ProjectID: projectID,
+		Action:    action,
+		User:      requestUser(r),
+		Changes:   changes,
+	}
+	if err := h.audit.Record(entry); err != nil {
+		h.logger.Printf("Error recording audit entry for %s %s: %v", entity, entityID, err)
+	}
+}
+
+// handleAudit lists audit log entries.
+// ?entity is an entity type, optionally with an ID ("task" or "task:<id>"),
+// ?since an RFC 3339 time and ?limit caps the number of entries.
+func (h *APIHandler) handleAudit(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var q storage.AuditQuery
+
+	if entity := query.Get("entity"); entity != "" {
+		q.Entity, q.EntityID, _ = strings.Cut(entity, ":")
+		if !isAuditEntity(q.Entity) {
+			httpError(w, "Invalid entity: "+q.Entity, http.StatusBadRequest)
+			return
+		}
+	}
+	if since := query.Get("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			httpError(w, "Invalid since: expected an RFC 3339 time", http.StatusBadRequest)
+			return
+		}
+		q.Since = t
+	}
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			httpError(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		q.Limit = n
+	}
+
+	// Tokens limited to some projects only see their changes
+	if token := TokenFromContext(r.Context()); token != nil && token.Restricted() {
+		q.ProjectIDs = token.ProjectIDs
+	}
+
+	entries, err := h.audit.List(q)
+	if err != nil {
+		h.logger.Printf("Error listing audit log: %v", err)
+		httpError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.respondJSON(w, entries)
+}
+
+// isAuditEntity reports whether t is an audited entity type
+func isAuditEntity(t string) bool {
+	for _, entity := range domain.AuditEntities {
+		if t == entity {
+			return true
+		}
+	}
+	return false
+}
+
+// newComments returns the comments among the activity entries a request
+// adds after the first n, timestamped now if they aren't already. Other
+// activity is recorded by the server.
+func newComments(activity []domain.ActivityEntry, n int, now time.Time) []domain.ActivityEntry {
+	var comments []domain.ActivityEntry
+	for i := n; i < len(activity); i++ {
+		if activity[i].Type != domain.ActivityCommented {
+			continue
+		}
+		comment := activity[i]
+		if comment.Timestamp.IsZero() {
+			comment.Timestamp = now
+		}
+		comments = append(comments, comment)
+	}
+	return comments
+}
+
+// activityUser returns the user ID to record task activity under, or "" if
+// authentication is disabled
+func activityUser(r *http.Request) string {
+	if user := requestUser(r); user != nil {
+		return user.ID
+	}
+	return ""
+}
+
+// boardProjectID returns the project a board belongs to, or "" if it can't
+// be found
+func (h *APIHandler) boardProjectID(boardID string) string {
+	board, err := h.boards.GetByID(boardID)
+	if err != nil {
+		return ""
+	}
+	return board.ProjectID
+}
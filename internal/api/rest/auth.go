@@ -0,0 +1,324 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rand/cartographer/internal/domain"
+)
+
+// TokenStore looks up API tokens and records their use
+type TokenStore interface {
+	GetBySecret(secret string) (*domain.APIToken, error)
+	Touch(id string, at time.Time) error
+}
+
+type contextKey int
+
+const tokenContextKey contextKey = iota
+
+// TokenFromContext returns the token a request authenticated with, or nil
+// if authentication is disabled
+func TokenFromContext(ctx context.Context) *domain.APIToken {
+	token, _ := ctx.Value(tokenContextKey).(*domain.APIToken)
+	return token
+}
+
+// AuthMiddleware requires a bearer token with a sufficient scope on API and
+// WebSocket requests. Reads need the read scope, other methods write, and
+// token management admin. Browsers can't set headers on WebSocket requests,
+// so /ws also accepts the token as an access_token query parameter. Static
+// files, the index page, the health check and the OpenAPI document stay
+// public.
+func AuthMiddleware(tokens TokenStore, logger *log.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			isWebSocket := r.URL.Path == "/ws"
+			if !isWebSocket && !strings.HasPrefix(r.URL.Path, "/api/") || r.URL.Path == openAPIPath {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			secret := bearerToken(r)
+			if secret == "" && isWebSocket {
+				secret = r.URL.Query().Get("access_token")
+			}
+			if secret == "" {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="cartographer"`)
+				httpError(w, "Authentication required", http.StatusUnauthorized)
+				return
+			}
+
+			token, err := tokens.GetBySecret(secret)
+			if err != nil {
+				logger.Printf("Error looking up API token: %v", err)
+				httpError(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			now := time.Now()
+			if token == nil || !token.Active(now) {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="cartographer", error="invalid_token"`)
+				httpError(w, "Invalid or expired token", http.StatusUnauthorized)
+				return
+			}
+
+			scope := requiredScope(r)
+			if !token.Allows(scope) {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="cartographer", error="insufficient_scope", scope="`+scope+`"`)
+				httpError(w, "Token lacks the "+scope+" scope", http.StatusForbidden)
+				return
+			}
+
+			if err := tokens.Touch(token.ID, now); err != nil {
+				logger.Printf("Error recording API token use: %v", err)
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), tokenContextKey, token)))
+		})
+	}
+}
+
+// bearerToken returns the token from an "Authorization: Bearer" header
+func bearerToken(r *http.Request) string {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// requiredScope returns the scope a request needs. The GraphQL schema
+// has no mutations, so GraphQL queries only need read even when POSTed.
+func requiredScope(r *http.Request) string {
+	if r.URL.Path == "/api/tokens" || strings.HasPrefix(r.URL.Path, "/api/tokens/") {
+		return domain.ScopeAdmin
+	}
+	if r.URL.Path == "/api/graphql" {
+		return domain.ScopeRead
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return domain.ScopeRead
+	default:
+		return domain.ScopeWrite
+	}
+}
+
+// Project access checks. Tokens limited to some projects may only reach
+// resources in those projects; with authentication disabled every check
+// passes.
+
+// allowProject writes a 403 and returns false if the request's token may
+// not access the project
+func (h *APIHandler) allowProject(w http.ResponseWriter, r *http.Request, projectID string) bool {
+	token := TokenFromContext(r.Context())
+	if token == nil || token.AllowsProject(projectID) {
+		return true
+	}
+	httpError(w, "Token does not grant access to this project", http.StatusForbidden)
+	return false
+}
+
+// allowBoard checks access to the project a board belongs to
+func (h *APIHandler) allowBoard(w http.ResponseWriter, r *http.Request, boardID string) bool {
+	if token := TokenFromContext(r.Context()); token == nil || !token.Restricted() {
+		return true
+	}
+	board, err := h.boards.GetByID(boardID)
+	if err != nil {
+		httpError(w, "Board not found", http.StatusNotFound)
+		return false
+	}
+	return h.allowProject(w, r, board.ProjectID)
+}
+
+// allowTask checks access to the project a task's board belongs to
+func (h *APIHandler) allowTask(w http.ResponseWriter, r *http.Request, taskID string) bool {
+	if token := TokenFromContext(r.Context()); token == nil || !token.Restricted() {
+		return true
+	}
+	task, err := h.tasks.GetByID(taskID)
+	if err != nil {
+		httpError(w, "Task not found", http.StatusNotFound)
+		return false
+	}
+	return h.allowBoard(w, r, task.BoardID)
+}
+
+// allowDocument checks access to the project a document belongs to
+func (h *APIHandler) allowDocument(w http.ResponseWriter, r *http.Request, documentID string) bool {
+	if token := TokenFromContext(r.Context()); token == nil || !token.Restricted() {
+		return true
+	}
+	doc, err := h.documents.GetByID(documentID)
+	if err != nil {
+		httpError(w, "Document not found", http.StatusNotFound)
+		return false
+	}
+	return h.allowProject(w, r, doc.ProjectID)
+}
+
+// allowDiagram checks access to the project a diagram belongs to
+func (h *APIHandler) allowDiagram(w http.ResponseWriter, r *http.Request, diagramID string) bool {
+	if token := TokenFromContext(r.Context()); token == nil || !token.Restricted() {
+		return true
+	}
+	diagram, err := h.diagrams.GetByID(diagramID)
+	if err != nil {
+		httpError(w, "Diagram not found", http.StatusNotFound)
+		return false
+	}
+	return h.allowProject(w, r, diagram.ProjectID)
+}
+
+// allowAllProjects writes a 403 and returns false for tokens limited to
+// some projects, for endpoints that aren't tied to one project
+func (h *APIHandler) allowAllProjects(w http.ResponseWriter, r *http.Request) bool {
+	if token := TokenFromContext(r.Context()); token == nil || !token.Restricted() {
+		return true
+	}
+	httpError(w, "Token is limited to specific projects", http.StatusForbidden)
+	return false
+}
+
+// allProjectsOnly wraps a handler for endpoints that aren't tied to one
+// project, so tokens limited to some projects can't reach them
+func (h *APIHandler) allProjectsOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.allowAllProjects(w, r) {
+			next(w, r)
+		}
+	}
+}
+
+// AsUser attributes every request to user, with write scope on every
+// project, for the API served in process on an agent's behalf
+func AsUser(user domain.User, next http.Handler) http.Handler {
+	token := &domain.APIToken{Name: "in-process", User: user, Scope: domain.ScopeWrite}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), tokenContextKey, token)))
+	})
+}
+
+// requestUser returns the identity to attribute a request's changes to, or
+// nil if authentication is disabled
+func requestUser(r *http.Request) *domain.User {
+	token := TokenFromContext(r.Context())
+	if token == nil {
+		return nil
+	}
+	user := token.User
+	return &user
+}
+
+// attributeTask records the request's identity on a task being created
+// (existing is nil) or updated: as its creator, and as the author of any
+// activity entries the request adds. Clients can't set either themselves.
+func attributeTask(r *http.Request, task, existing *domain.Task) {
+	user := requestUser(r)
+	if user == nil {
+		return
+	}
+
+	added := 0
+	if existing == nil {
+		task.CreatedBy = user
+	} else {
+		task.CreatedBy = existing.CreatedBy
+		added = len(existing.Activity)
+	}
+
+	now := time.Now()
+	for i := added; i < len(task.Activity); i++ {
+		task.Activity[i].User = user.ID
+		if task.Activity[i].Timestamp.IsZero() {
+			task.Activity[i].Timestamp = now
+		}
+	}
+}
+
+// Token handlers
+
+// createTokenRequest is the body of POST /api/tokens
+type createTokenRequest struct {
+	Name       string      `json:"name"`
+	User       domain.User `json:"user"`
+	Scope      string      `json:"scope"`
+	ProjectIDs []string    `json:"project_ids"`
+	ExpiresIn  string      `json:"expires_in"` // Go duration, e.g. "720h"
+}
+
+// createTokenResponse returns a new token along with its secret, which is
+// never shown again
+type createTokenResponse struct {
+	*domain.APIToken
+	Token string `json:"token"`
+}
+
+func (h *APIHandler) listTokens(w http.ResponseWriter, r *http.Request) {
+	tokens, err := h.apiTokens.List()
+	if err != nil {
+		h.logger.Printf("Error listing API tokens: %v", err)
+		httpError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.respondJSON(w, tokens)
+}
+
+func (h *APIHandler) createToken(w http.ResponseWriter, r *http.Request) {
+	var req createTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Name == "" {
+		httpError(w, "name is required", http.StatusBadRequest)
+		return
+	}
+	if !domain.ValidScope(req.Scope) {
+		httpError(w, "scope must be one of "+strings.Join(domain.Scopes, ", "), http.StatusBadRequest)
+		return
+	}
+
+	token := &domain.APIToken{
+		Name:       req.Name,
+		User:       req.User,
+		Scope:      req.Scope,
+		ProjectIDs: req.ProjectIDs,
+	}
+	if req.ExpiresIn != "" {
+		d, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || d <= 0 {
+			httpError(w, "Invalid expires_in", http.StatusBadRequest)
+			return
+		}
+		expires := time.Now().Add(d)
+		token.ExpiresAt = &expires
+	}
+
+	secret, err := h.apiTokens.Create(token)
+	if err != nil {
+		h.logger.Printf("Error creating API token: %v", err)
+		httpError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	h.respondJSON(w, createTokenResponse{APIToken: token, Token: secret})
+}
+
+func (h *APIHandler) revokeToken(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if err := h.apiTokens.Revoke(id); err != nil {
+		h.logger.Printf("Error revoking API token: %v", err)
+		httpError(w, "Token not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
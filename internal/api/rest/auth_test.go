@@ -0,0 +1,131 @@
+package rest
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rand/cartographer/internal/domain"
+)
+
+// memoryTokenStore is an in-memory TokenStore keyed by secret
+type memoryTokenStore map[string]*domain.APIToken
+
+func (m memoryTokenStore) GetBySecret(secret string) (*domain.APIToken, error) {
+	return m[secret], nil
+}
+
+func (m memoryTokenStore) Touch(id string, at time.Time) error {
+	return nil
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	tokens := memoryTokenStore{
+		"reader":  {ID: "1", Scope: domain.ScopeRead, User: domain.User{Type: "human", ID: "alice"}},
+		"writer":  {ID: "2", Scope: domain.ScopeWrite},
+		"admin":   {ID: "3", Scope: domain.ScopeAdmin},
+		"expired": {ID: "4", Scope: domain.ScopeAdmin, ExpiresAt: &past},
+	}
+
+	var seen *domain.APIToken
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = TokenFromContext(r.Context())
+	})
+	handler := AuthMiddleware(tokens, log.New(io.Discard, "", 0))(next)
+
+	tests := []struct {
+		method, target, token string
+		want                  int
+	}{
+		{http.MethodGet, "/health", "", http.StatusOK},
+		{http.MethodGet, "/static/js/main.js", "", http.StatusOK},
+		{http.MethodGet, "/api/openapi.json", "", http.StatusOK},
+		{http.MethodGet, "/api/projects", "", http.StatusUnauthorized},
+		{http.MethodGet, "/api/projects", "wrong", http.StatusUnauthorized},
+		{http.MethodGet, "/api/projects", "expired", http.StatusUnauthorized},
+		{http.MethodGet, "/api/projects", "reader", http.StatusOK},
+		{http.MethodDelete, "/api/projects/1", "reader", http.StatusForbidden},
+		{http.MethodDelete, "/api/projects/1", "writer", http.StatusOK},
+		{http.MethodGet, "/api/tokens", "writer", http.StatusForbidden},
+		{http.MethodPost, "/api/tokens", "admin", http.StatusOK},
+		{http.MethodPost, "/api/graphql", "reader", http.StatusOK},
+		{http.MethodPost, "/api/events", "reader", http.StatusForbidden},
+		{http.MethodGet, "/api/work/leases", "reader", http.StatusOK},
+		{http.MethodPost, "/api/work/claim", "reader", http.StatusForbidden},
+		{http.MethodPost, "/api/work/claim", "writer", http.StatusOK},
+		{http.MethodGet, "/api/claude/session/1/history", "reader", http.StatusOK},
+		{http.MethodPost, "/api/claude/session/1/event", "reader", http.StatusForbidden},
+		{http.MethodGet, "/ws", "", http.StatusUnauthorized},
+		{http.MethodGet, "/ws?access_token=reader", "", http.StatusOK},
+		{http.MethodGet, "/api/projects?access_token=reader", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		seen = nil
+		req := httptest.NewRequest(tt.method, tt.target, nil)
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != tt.want {
+			t.Errorf("%s %s with %q: expected %d, got %d", tt.method, tt.target, tt.token, tt.want, rec.Code)
+		}
+		if rec.Code == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%s %s: expected a WWW-Authenticate header", tt.method, tt.target)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/projects", nil)
+	req.Header.Set("Authorization", "bearer reader")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if seen == nil || seen.User.ID != "alice" {
+		t.Errorf("Expected the token in the request context, got %+v", seen)
+	}
+}
+
+func TestAttributeTask(t *testing.T) {
+	token := &domain.APIToken{Scope: domain.ScopeWrite, User: domain.User{Type: "agent", ID: "bot"}}
+	req := httptest.NewRequest(http.MethodPost, "/api/tasks", nil)
+	req = req.WithContext(context.WithValue(req.Context(), tokenContextKey, token))
+
+	// Without a token nothing is attributed
+	unattributed := &domain.Task{Activity: []domain.ActivityEntry{{Type: "commented"}}}
+	attributeTask(httptest.NewRequest(http.MethodPost, "/api/tasks", nil), unattributed, nil)
+	if unattributed.CreatedBy != nil || unattributed.Activity[0].User != "" {
+		t.Errorf("Expected no attribution, got %+v", unattributed)
+	}
+
+	creator := &domain.User{Type: "human", ID: "alice"}
+	existing := &domain.Task{
+		CreatedBy: creator,
+		Activity:  []domain.ActivityEntry{{Type: "commented", User: "alice"}},
+	}
+	task := &domain.Task{
+		CreatedBy: &domain.User{Type: "human", ID: "mallory"},
+		Activity: []domain.ActivityEntry{
+			{Type: "commented", User: "alice"},
+			{Type: "commented", User: "mallory", Comment: "looks good"},
+		},
+	}
+
+	attributeTask(req, task, existing)
+	if task.CreatedBy != creator {
+		t.Errorf("Expected the creator kept, got %+v", task.CreatedBy)
+	}
+	if task.Activity[0].User != "alice" || task.Activity[1].User != "bot" || task.Activity[1].Timestamp.IsZero() {
+		t.Errorf("Expected only the new entry attributed to bot, got %+v", task.Activity)
+	}
+
+	created := &domain.Task{}
+	attributeTask(req, created, nil)
+	if created.CreatedBy == nil || created.CreatedBy.ID != "bot" || created.CreatedBy.Type != "agent" {
+		t.Errorf("Expected the task created by bot, got %+v", created.CreatedBy)
+	}
+}
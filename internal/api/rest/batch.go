@@ -0,0 +1,441 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rand/cartographer/internal/api/websocket"
+	"github.com/rand/cartographer/internal/domain"
+	"github.com/rand/cartographer/internal/storage"
+)
+
+// Batch modes
+const (
+	batchAtomic  = "atomic"  // every operation applies or none does
+	batchPartial = "partial" // each operation applies or fails on its own
+)
+
+// Batch operations
+const (
+	batchCreate = "create"
+	batchUpdate = "update"
+	batchDelete = "delete"
+	batchMove   = "move"
+)
+
+// maxBatchOperations bounds the number of operations in one batch
+const maxBatchOperations = 500
+
+// requestError is an error that maps to an HTTP status and a message for
+// the client
+type requestError struct {
+	status  int
+	message string
+}
+
+func (e *requestError) Error() string {
+	return e.message
+}
+
+// batchRequest is the body of POST /api/tasks/batch
+type batchRequest struct {
+	Mode       string           `json:"mode"` // atomic (the default) or partial
+	Operations []batchOperation `json:"operations"`
+}
+
+// batchOperation is one task operation of a batch. Its ID, and the tasks a
+// created or updated task depends on, blocks or relates to, may be the temp
+// ID of a task created earlier in the batch.
+type batchOperation struct {
+	Op       string          `json:"op"`                 // create, update, delete or move
+	TempID   string          `json:"temp_id,omitempty"`  // create: names the task for later operations
+	ID       string          `json:"id,omitempty"`       // update, delete, move
+	Revision int64           `json:"revision,omitempty"` // update, delete, move: the expected revision, as with If-Match
+	Task     json.RawMessage `json:"task,omitempty"`     // create: the task; update: a merge patch
+	Column   string          `json:"column,omitempty"`   // move
+	Position *int            `json:"position,omitempty"` // move
+}
+
+// batchResult is the outcome of one operation
+type batchResult struct {
+	Index       int                 `json:"index"`
+	Op          string              `json:"op"`
+	ID          string              `json:"id,omitempty"`
+	TempID      string              `json:"temp_id,omitempty"`
+	Status      int                 `json:"status"` // as for the operation made on its own
+	Error       string              `json:"error,omitempty"`
+	FieldErrors []domain.FieldError `json:"field_errors,omitempty"`
+	Task        *domain.Task        `json:"task,omitempty"`
+	Warnings    []string            `json:"warnings,omitempty"`
+}
+
+// batchResponse reports the outcome of a batch. A failed atomic batch
+// commits nothing and only reports the operation that failed.
+type batchResponse struct {
+	Mode      string        `json:"mode"`
+	Committed bool          `json:"committed"`
+	Results   []batchResult `json:"results"`
+}
+
+// batchEffect is a change made by an operation, audited, broadcast and
+// synced to beads once it is committed
+type batchEffect struct {
+	op      string
+	before  *domain.Task
+	task    *domain.Task
+	changes domain.Changes
+	move    *domain.TaskMove
+}
+
+// taskBatch applies the operations of one batch request
+type taskBatch struct {
+	h       *APIHandler
+	r       *http.Request
+	tempIDs map[string]string // temp ID to task ID
+	boards  map[string]*domain.Board
+	effects []*batchEffect
+}
+
+// handleTaskBatch applies a batch of task operations, all in one
+// transaction unless the mode is partial, and broadcasts their changes as a
+// single task.batch event
+func (h *APIHandler) handleTaskBatch(w http.ResponseWriter, r *http.Request) {
+	var req batchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	switch req.Mode {
+	case "":
+		req.Mode = batchAtomic
+	case batchAtomic, batchPartial:
+	default:
+		httpError(w, "Invalid mode: "+req.Mode, http.StatusBadRequest)
+		return
+	}
+	if len(req.Operations) == 0 {
+		httpError(w, "No operations", http.StatusBadRequest)
+		return
+	}
+	if len(req.Operations) > maxBatchOperations {
+		httpError(w, fmt.Sprintf("Too many operations, the limit is %d", maxBatchOperations), http.StatusBadRequest)
+		return
+	}
+	tempIDs := make(map[string]bool)
+	for _, op := range req.Operations {
+		if op.TempID == "" {
+			continue
+		}
+		if tempIDs[op.TempID] {
+			httpError(w, "Duplicate temp_id: "+op.TempID, http.StatusBadRequest)
+			return
+		}
+		tempIDs[op.TempID] = true
+	}
+
+	b := &taskBatch{
+		h:       h,
+		r:       r,
+		tempIDs: make(map[string]string),
+		boards:  make(map[string]*domain.Board),
+	}
+	resp := batchResponse{Mode: req.Mode, Results: []batchResult{}}
+
+	if req.Mode == batchPartial {
+		for i, op := range req.Operations {
+			var result batchResult
+			var effect *batchEffect
+			err := h.tasks.Transaction(func(tasks *storage.TaskRepository) error {
+				var err error
+				result, effect, err = b.apply(tasks, i, op)
+				return err
+			})
+			if err == nil && effect != nil {
+				b.effects = append(b.effects, effect)
+				resp.Committed = true
+			}
+			resp.Results = append(resp.Results, result)
+		}
+		b.finish()
+		h.respondJSON(w, resp)
+		return
+	}
+
+	var failed *batchResult
+	var effects []*batchEffect
+	err := h.tasks.Transaction(func(tasks *storage.TaskRepository) error {
+		for i, op := range req.Operations {
+			result, effect, err := b.apply(tasks, i, op)
+			if err != nil {
+				failed = &result
+				return err
+			}
+			resp.Results = append(resp.Results, result)
+			if effect != nil {
+				effects = append(effects, effect)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if failed == nil {
+			h.logger.Printf("Error committing task batch: %v", err)
+			httpError(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		resp.Results = []batchResult{*failed}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(failed.Status)
+		h.respondJSON(w, resp)
+		return
+	}
+
+	resp.Committed = true
+	b.effects = effects
+	b.finish()
+	h.respondJSON(w, resp)
+}
+
+// apply applies one operation in the transaction of tasks, returning its
+// result, the change to make known once it is committed, if any, and an
+// error if it failed
+func (b *taskBatch) apply(tasks *storage.TaskRepository, index int, op batchOperation) (batchResult, *batchEffect, error) {
+	result := batchResult{Index: index, Op: op.Op, ID: b.resolve(op.ID), TempID: op.TempID}
+
+	var effect *batchEffect
+	var err error
+	switch op.Op {
+	case batchCreate:
+		result.Status = http.StatusCreated
+		effect, err = b.create(tasks, op)
+	case batchUpdate:
+		result.Status = http.StatusOK
+		effect, err = b.update(tasks, op, &result)
+	case batchDelete:
+		result.Status = http.StatusNoContent
+		effect, err = b.delete(tasks, op)
+	case batchMove:
+		result.Status = http.StatusOK
+		effect, err = b.move(tasks, op, &result)
+	default:
+		err = &requestError{http.StatusBadRequest, "Unknown op: " + op.Op}
+	}
+
+	if err != nil {
+		status, resp, ok := errorStatus(err, op.Revision != 0)
+		if !ok {
+			b.h.logger.Printf("Error applying batch operation %d (%s): %v", index, op.Op, err)
+		}
+		result.Status, result.Error, result.FieldErrors = status, resp.Message, resp.FieldErrors
+		return result, nil, err
+	}
+
+	if effect != nil && effect.op != batchDelete {
+		result.ID = effect.task.ID
+		result.Task = effect.task
+	}
+	return result, effect, nil
+}
+
+func (b *taskBatch) create(tasks *storage.TaskRepository, op batchOperation) (*batchEffect, error) {
+	var task domain.Task
+	if err := json.Unmarshal(b.resolveTask(op.Task), &task); err != nil {
+		return nil, &requestError{http.StatusBadRequest, "Invalid task: " + err.Error()}
+	}
+	board, err := b.board(task.BoardID)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.h.validateTask(tasks, &task); err != nil {
+		return nil, err
+	}
+	if err := checkWIPLimit(tasks, board, task.Status); err != nil {
+		return nil, err
+	}
+
+	// Activity starts with the creation; clients may only add comments
+	now := time.Now()
+	created := domain.ActivityEntry{Type: domain.ActivityCreated, Timestamp: now}
+	task.Activity = append([]domain.ActivityEntry{created}, newComments(task.Activity, 0, now)...)
+	attributeTask(b.r, &task, nil)
+
+	if err := tasks.Create(&task); err != nil {
+		return nil, err
+	}
+	if op.TempID != "" {
+		b.tempIDs[op.TempID] = task.ID
+	}
+	return &batchEffect{op: batchCreate, task: &task}, nil
+}
+
+func (b *taskBatch) update(tasks *storage.TaskRepository, op batchOperation, result *batchResult) (*batchEffect, error) {
+	existing, board, err := b.task(tasks, op)
+	if err != nil {
+		return nil, err
+	}
+	task, changes, fields, reqErr := patchedTask(b.r, existing, b.resolveTask(op.Task))
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	if err := b.h.validateTask(tasks, task); err != nil {
+		return nil, err
+	}
+	if task.Status != existing.Status {
+		if err := checkWIPLimit(tasks, board, task.Status); err != nil {
+			return nil, err
+		}
+	}
+	if len(fields) == 0 {
+		result.ID, result.Task = existing.ID, existing
+		return nil, nil
+	}
+
+	task.Revision = op.Revision
+	if err := tasks.UpdateFieldsAppending(task, fields, task.Activity[len(existing.Activity):]); err != nil {
+		return nil, err
+	}
+	return &batchEffect{op: batchUpdate, before: existing, task: task, changes: changes}, nil
+}
+
+func (b *taskBatch) delete(tasks *storage.TaskRepository, op batchOperation) (*batchEffect, error) {
+	existing, _, err := b.task(tasks, op)
+	if err != nil {
+		return nil, err
+	}
+	if err := tasks.Delete(existing.ID); err != nil {
+		return nil, err
+	}
+	return &batchEffect{op: batchDelete, before: existing, task: existing}, nil
+}
+
+func (b *taskBatch) move(tasks *storage.TaskRepository, op batchOperation, result *batchResult) (*batchEffect, error) {
+	existing, board, err := b.task(tasks, op)
+	if err != nil {
+		return nil, err
+	}
+	move, changes, err := b.h.applyMove(b.r, tasks, board, existing, op.Column, op.Position, op.Revision)
+	if err != nil {
+		return nil, err
+	}
+	result.Warnings = move.Warnings
+	return &batchEffect{op: batchMove, before: existing, task: move.Task, changes: changes, move: move}, nil
+}
+
+// task returns the task an operation applies to and its board, checking
+// the request may access it and that it is at the expected revision
+func (b *taskBatch) task(tasks *storage.TaskRepository, op batchOperation) (*domain.Task, *domain.Board, error) {
+	if op.ID == "" {
+		return nil, nil, &requestError{http.StatusBadRequest, "Task ID required"}
+	}
+	task, err := tasks.GetByID(b.resolve(op.ID))
+	if err != nil {
+		return nil, nil, &requestError{http.StatusNotFound, "Task not found"}
+	}
+	board, err := b.board(task.BoardID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if op.Revision != 0 && op.Revision != task.Revision {
+		return nil, nil, &requestError{http.StatusPreconditionFailed, "Precondition failed: the resource has changed"}
+	}
+	return task, board, nil
+}
+
+// board returns the board with the given ID if the request may access it
+func (b *taskBatch) board(id string) (*domain.Board, error) {
+	if board, ok := b.boards[id]; ok {
+		return board, nil
+	}
+	board, err := b.h.boards.GetByID(id)
+	if err != nil {
+		return nil, &requestError{http.StatusNotFound, "Board not found"}
+	}
+	if token := TokenFromContext(b.r.Context()); token != nil && !token.AllowsProject(board.ProjectID) {
+		return nil, &requestError{http.StatusForbidden, "Token does not grant access to this project"}
+	}
+	b.boards[id] = board
+	return board, nil
+}
+
+// resolve returns the ID of the task created with a temp ID, or id itself
+// if it isn't one
+func (b *taskBatch) resolve(id string) string {
+	if taskID, ok := b.tempIDs[id]; ok {
+		return taskID
+	}
+	return id
+}
+
+// resolveTask replaces temp IDs in the task references of a task or merge
+// patch. Anything it can't make sense of is left for decoding to reject.
+func (b *taskBatch) resolveTask(raw json.RawMessage) json.RawMessage {
+	if len(b.tempIDs) == 0 {
+		return raw
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil || fields == nil {
+		return raw
+	}
+
+	for _, name := range []string{"dependencies", "blocks", "related"} {
+		var ids []string
+		if err := json.Unmarshal(fields[name], &ids); err != nil || ids == nil {
+			continue
+		}
+		for i, id := range ids {
+			ids[i] = b.resolve(id)
+		}
+		fields[name], _ = json.Marshal(ids)
+	}
+
+	resolved, err := json.Marshal(fields)
+	if err != nil {
+		return raw
+	}
+	return resolved
+}
+
+// finish audits the committed changes, writes edits back to beads and
+// broadcasts them as one event
+func (b *taskBatch) finish() {
+	h := b.h
+	event := &websocket.TaskBatchEvent{}
+	for _, effect := range b.effects {
+		task := effect.task
+		projectID := ""
+		if board, ok := b.boards[task.BoardID]; ok {
+			projectID = board.ProjectID
+		}
+
+		switch effect.op {
+		case batchCreate:
+			h.recordAudit(b.r, domain.EntityTask, task.ID, projectID, domain.AuditCreated, nil)
+			event.AddCreated(task.ID, task.BoardID, task)
+		case batchUpdate:
+			if len(effect.changes) > 0 {
+				h.recordAudit(b.r, domain.EntityTask, task.ID, projectID, domain.AuditUpdated, effect.changes)
+			}
+			event.AddUpdated(task.ID, task.BoardID, effect.changes.Values(), task)
+		case batchMove:
+			h.recordAudit(b.r, domain.EntityTask, task.ID, projectID, domain.AuditUpdated, moveChanges(effect.before, task, effect.changes))
+			event.AddMoved(task.ID, task.BoardID, effect.move.From, effect.move.To, effect.move.Position, task)
+		case batchDelete:
+			h.recordAudit(b.r, domain.EntityTask, task.ID, projectID, domain.AuditDeleted, nil)
+			event.AddDeleted(task.ID, task.BoardID)
+		}
+
+		// Write edits back to the bead the task was imported from
+		if h.beadsSync != nil && effect.op != batchCreate && effect.op != batchDelete && len(effect.changes) > 0 {
+			if _, err := h.beadsSync.PushTask(task.ID); err != nil {
+				h.logger.Printf("Error syncing task %s to beads: %v", task.ID, err)
+			}
+		}
+	}
+
+	// Broadcast the batch via WebSocket
+	if h.wsHub != nil && !event.Empty() {
+		h.wsHub.BroadcastTaskBatch(event)
+	}
+}
@@ -0,0 +1,93 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rand/cartographer/internal/domain"
+)
+
+func TestTaskBatch(t *testing.T) {
+	board := &domain.Board{
+		Name:      "Main",
+		WIPPolicy: domain.WIPPolicyEnforce,
+		Columns:   []domain.BoardColumn{{ID: "todo", Name: "To Do"}, {ID: "doing", Name: "Doing", WIPLimit: 1}},
+	}
+	api := newTestAPI(t, &domain.Project{Name: "Batch", Path: "/tmp/batch"}, board)
+
+	batch := func(body string) (int, batchResponse) {
+		body = strings.ReplaceAll(body, "BOARD", board.ID)
+		rec := httptest.NewRecorder()
+		api.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/tasks/batch", strings.NewReader(body)))
+		var resp batchResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("invalid response %q: %v", rec.Body.String(), err)
+		}
+		return rec.Code, resp
+	}
+
+	// Later operations can refer to tasks created earlier by temp ID
+	code, resp := batch(`{"operations": [
+		{"op": "create", "temp_id": "parent", "task": {"board_id": "BOARD", "title": "Parent", "status": "todo"}},
+		{"op": "create", "temp_id": "child", "task": {"board_id": "BOARD", "title": "Child", "status": "todo", "dependencies": ["parent"]}},
+		{"op": "update", "id": "parent", "task": {"blocks": ["child"]}},
+		{"op": "move", "id": "child", "column": "todo", "position": 0}
+	]}`)
+	if code != http.StatusOK || !resp.Committed || len(resp.Results) != 4 {
+		t.Fatalf("Expected a committed batch of 4, got %d %+v", code, resp)
+	}
+	parent, child := resp.Results[0].ID, resp.Results[1].ID
+	if got := resp.Results[1].Task.Dependencies; len(got) != 1 || got[0] != parent {
+		t.Errorf("Expected child to depend on %s, got %v", parent, got)
+	}
+	if got := resp.Results[2].Task.Blocks; len(got) != 1 || got[0] != child {
+		t.Errorf("Expected parent to block %s, got %v", child, got)
+	}
+	if resp.Results[3].Task.Rank >= resp.Results[2].Task.Rank {
+		t.Errorf("Expected child ranked above parent")
+	}
+
+	// An atomic batch that fails part way leaves nothing behind
+	code, resp = batch(`{"operations": [
+		{"op": "create", "task": {"board_id": "BOARD", "title": "Doomed", "status": "todo"}},
+		{"op": "move", "id": "` + parent + `", "column": "doing"},
+		{"op": "move", "id": "` + child + `", "column": "doing"}
+	]}`)
+	if code != http.StatusConflict || resp.Committed || len(resp.Results) != 1 || resp.Results[0].Index != 2 {
+		t.Fatalf("Expected the WIP limit to fail operation 2, got %d %+v", code, resp)
+	}
+	all, err := api.tasks.ListByBoard(board.ID)
+	if err != nil {
+		t.Fatalf("ListByBoard failed: %v", err)
+	}
+	if len(all) != 2 {
+		t.Errorf("Expected the failed batch to be rolled back, got %d tasks", len(all))
+	}
+	for _, task := range all {
+		if task.Status != "todo" {
+			t.Errorf("Expected %s still in todo, got %s", task.Title, task.Status)
+		}
+	}
+
+	// In partial mode each operation stands alone
+	code, resp = batch(`{"mode": "partial", "operations": [
+		{"op": "delete", "id": "missing"},
+		{"op": "update", "id": "` + child + `", "revision": 99, "task": {"title": "Stale"}},
+		{"op": "delete", "id": "` + child + `"}
+	]}`)
+	if code != http.StatusOK || !resp.Committed {
+		t.Fatalf("Expected a committed partial batch, got %d %+v", code, resp)
+	}
+	want := []int{http.StatusNotFound, http.StatusPreconditionFailed, http.StatusNoContent}
+	for i, result := range resp.Results {
+		if result.Status != want[i] {
+			t.Errorf("Operation %d: expected status %d, got %d (%s)", i, want[i], result.Status, result.Error)
+		}
+	}
+	if _, err := api.tasks.GetByID(child); err == nil {
+		t.Error("Expected child to be deleted")
+	}
+}
@@ -0,0 +1,73 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/rand/cartographer/internal/claude"
+	"github.com/rand/cartographer/internal/domain"
+)
+
+// DefaultBriefingBudget is the token budget of a project's context briefing
+// unless the request sets one
+const DefaultBriefingBudget = 2000
+
+// projectContext renders the briefing an agent starting a session reads:
+// Markdown by default, JSON with ?format=json. ?budget caps its estimated
+// tokens, dropping the lowest priority sections' items first.
+func (h *APIHandler) projectContext(w http.ResponseWriter, r *http.Request, id string) {
+	query := r.URL.Query()
+	budget := DefaultBriefingBudget
+	if v := query.Get("budget"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			httpError(w, "budget must be a positive number of tokens", http.StatusBadRequest)
+			return
+		}
+		budget = n
+	}
+	format := query.Get("format")
+	if format != "" && format != "markdown" && format != "json" {
+		httpError(w, "format must be markdown or json", http.StatusBadRequest)
+		return
+	}
+
+	project, err := h.projects.GetByID(id)
+	if err != nil {
+		h.respondError(w, r, "getting project", err)
+		return
+	}
+	boards, err := h.boards.ListByProject(id)
+	if err != nil {
+		h.respondError(w, r, "listing boards", err)
+		return
+	}
+	var tasks []*domain.Task
+	for _, board := range boards {
+		boardTasks, err := h.tasks.ListByBoard(board.ID)
+		if err != nil {
+			h.respondError(w, r, "listing tasks", err)
+			return
+		}
+		tasks = append(tasks, boardTasks...)
+	}
+	documents, err := h.documents.ListByProject(id)
+	if err != nil {
+		h.respondError(w, r, "listing documents", err)
+		return
+	}
+
+	briefing, err := claude.Brief(project, boards, tasks, documents)
+	if err != nil {
+		h.respondError(w, r, "building context briefing", err)
+		return
+	}
+	briefing.Fit(budget)
+
+	if format == "json" {
+		h.respondJSON(w, briefing)
+		return
+	}
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	w.Write([]byte(briefing.Markdown()))
+}
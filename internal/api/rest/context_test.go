@@ -0,0 +1,69 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rand/cartographer/internal/claude"
+	"github.com/rand/cartographer/internal/domain"
+)
+
+func TestProjectContext(t *testing.T) {
+	project := &domain.Project{Name: "Atlas", Path: "/tmp/atlas"}
+	board := &domain.Board{Name: "Main", Columns: []domain.BoardColumn{
+		{ID: "todo", Name: "To Do"}, {ID: "doing", Name: "Doing", Order: 1}, {ID: "done", Name: "Done", Order: 2},
+	}}
+	api := newTestAPI(t, project, board)
+	doc := &domain.Document{ProjectID: project.ID, Title: "Design", Path: "design.md", Content: "# Design"}
+	if err := api.documents.Create(doc); err != nil {
+		t.Fatalf("failed to create document: %v", err)
+	}
+	for _, task := range []*domain.Task{
+		{Title: "Lexer", Status: "doing", Priority: "high", LinkedItems: []domain.LinkedItem{{Type: "doc", ID: doc.ID}}},
+		{Title: "Parser", Status: "todo"},
+	} {
+		task.BoardID = board.ID
+		if err := api.tasks.Create(task); err != nil {
+			t.Fatalf("failed to create task: %v", err)
+		}
+	}
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		api.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+	path := "/api/projects/" + project.ID + "/context"
+
+	rec := get(path)
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/markdown") {
+		t.Fatalf("context: got %d %s %s", rec.Code, rec.Header().Get("Content-Type"), body)
+	}
+	for _, want := range []string{"# Atlas", "## In progress", "**Lexer**", "## Ready", "**Parser**", "## Documents", "**Design**"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected the briefing to contain %q, got\n%s", want, body)
+		}
+	}
+
+	rec = get(path + "?format=json&budget=40")
+	var briefing claude.Briefing
+	if err := json.Unmarshal(rec.Body.Bytes(), &briefing); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("JSON context: got %d %s", rec.Code, rec.Body.String())
+	}
+	if briefing.Project.Name != "Atlas" || len(briefing.Documents) != 0 || briefing.Omitted[claude.SectionDocuments] != 1 || briefing.Tokens > 40 {
+		t.Errorf("expected the documents dropped to fit 40 tokens, got %s", rec.Body.String())
+	}
+
+	for _, query := range []string{"?budget=0", "?budget=lots", "?format=html"} {
+		if rec := get(path + query); rec.Code != http.StatusBadRequest {
+			t.Errorf("context%s: expected 400, got %d", query, rec.Code)
+		}
+	}
+	if rec := get("/api/projects/missing/context"); rec.Code != http.StatusNotFound {
+		t.Errorf("context of a missing project: expected 404, got %d", rec.Code)
+	}
+}
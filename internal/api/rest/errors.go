@@ -0,0 +1,87 @@
+package rest
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/rand/cartographer/internal/domain"
+	"github.com/rand/cartographer/internal/storage"
+)
+
+// codeValidationFailed is the error code of requests with invalid fields.
+// Other codes name the status, e.g. not_found.
+const codeValidationFailed = "validation_failed"
+
+// errorResponse is the body of every error response
+type errorResponse struct {
+	Code        string              `json:"code"`
+	Message     string              `json:"message"`
+	FieldErrors []domain.FieldError `json:"field_errors,omitempty"`
+}
+
+// errorCode returns the error code for a status: its text in snake case
+func errorCode(status int) string {
+	return strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+}
+
+// httpError writes an error response with the given message and status,
+// like http.Error but in the JSON error envelope
+func httpError(w http.ResponseWriter, message string, status int) {
+	writeError(w, status, errorResponse{Code: errorCode(status), Message: message})
+}
+
+func writeError(w http.ResponseWriter, status int, resp errorResponse) {
+	w.Header().Del("Content-Length")
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// errorStatus maps an error from validation or storage to a status and an
+// error response. conditional tells whether the request expected a
+// revision, making a revision conflict a failed precondition. It returns
+// false for unexpected errors, which are internal server errors.
+func errorStatus(err error, conditional bool) (int, errorResponse, bool) {
+	var invalid *domain.ValidationError
+	var reqErr *requestError
+	status, message := 0, ""
+	switch {
+	case errors.As(err, &invalid):
+		return http.StatusUnprocessableEntity, errorResponse{
+			Code:        codeValidationFailed,
+			Message:     "Validation failed",
+			FieldErrors: invalid.Fields,
+		}, true
+	case errors.As(err, &reqErr):
+		status, message = reqErr.status, reqErr.message
+	case errors.Is(err, storage.ErrNotFound), errors.Is(err, sql.ErrNoRows):
+		status, message = http.StatusNotFound, "Not found"
+	case errors.Is(err, storage.ErrRevisionConflict) && conditional:
+		status, message = http.StatusPreconditionFailed, "Precondition failed: the resource has changed"
+	case errors.Is(err, storage.ErrRevisionConflict):
+		status, message = http.StatusConflict, "Conflict: the resource was modified concurrently, retry"
+	case errors.Is(err, storage.ErrWIPLimitExceeded):
+		status, message = http.StatusConflict, "Conflict: the column is at its WIP limit"
+	case storage.IsDuplicate(err):
+		status, message = http.StatusConflict, "Conflict: the resource already exists"
+	case storage.IsMissingReference(err):
+		status, message = http.StatusUnprocessableEntity, "Refers to a resource that doesn't exist"
+	default:
+		return http.StatusInternalServerError, errorResponse{Code: errorCode(http.StatusInternalServerError), Message: "Internal server error"}, false
+	}
+	return status, errorResponse{Code: errorCode(status), Message: message}, true
+}
+
+// respondError writes the error of a failed operation, logging it if it
+// was unexpected. what describes the operation, e.g. "creating task".
+func (h *APIHandler) respondError(w http.ResponseWriter, r *http.Request, what string, err error) {
+	status, resp, ok := errorStatus(err, hasIfMatch(r))
+	if !ok {
+		h.logger.Printf("Error %s: %v", what, err)
+	}
+	writeError(w, status, resp)
+}
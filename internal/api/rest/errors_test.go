@@ -0,0 +1,68 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rand/cartographer/internal/domain"
+)
+
+func TestErrorEnvelope(t *testing.T) {
+	board := &domain.Board{Name: "Main", Columns: []domain.BoardColumn{{ID: "todo", Name: "To Do"}}}
+	api := newTestAPI(t, &domain.Project{Name: "Errors", Path: "/tmp/errors"}, board)
+	diagram := &domain.Diagram{ProjectID: board.ProjectID, Name: "Flow", Type: "mermaid", Content: "graph TD; A-->B"}
+	if err := api.diagrams.Create(diagram); err != nil {
+		t.Fatalf("failed to create diagram: %v", err)
+	}
+
+	request := func(method, path, body string) (int, errorResponse) {
+		body = strings.ReplaceAll(body, "BOARD", board.ID)
+		path = strings.ReplaceAll(path, "DIAGRAM", diagram.ID)
+		rec := httptest.NewRecorder()
+		api.mux.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+			t.Fatalf("%s %s: expected a JSON error, got %q: %s", method, path, ct, rec.Body.String())
+		}
+		var resp errorResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s %s: invalid error %q: %v", method, path, rec.Body.String(), err)
+		}
+		return rec.Code, resp
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+		code   string
+		fields []string
+	}{
+		{"invalid JSON", http.MethodPost, "/api/tasks", `{`, http.StatusBadRequest, "bad_request", nil},
+		{"unknown task", http.MethodGet, "/api/tasks/missing", ``, http.StatusNotFound, "not_found", nil},
+		{"invalid task", http.MethodPost, "/api/tasks", `{"board_id": "BOARD", "status": "doing", "priority": "asap", "dependencies": ["missing"]}`, http.StatusUnprocessableEntity, codeValidationFailed, []string{"title", "status", "priority", "dependencies[0]"}},
+		{"unknown board", http.MethodPost, "/api/tasks", `{"board_id": "missing", "title": "Orphan"}`, http.StatusUnprocessableEntity, codeValidationFailed, []string{"board_id"}},
+		{"invalid board", http.MethodPost, "/api/boards", `{"project_id": "missing", "name": "B", "columns": [{"id": "a", "name": "A"}, {"id": "a", "name": "Again"}]}`, http.StatusUnprocessableEntity, codeValidationFailed, []string{"columns[1].id", "project_id"}},
+		{"invalid diagram", http.MethodPost, "/api/diagrams", `{"project_id": "missing", "type": "mermaid"}`, http.StatusUnprocessableEntity, codeValidationFailed, []string{"name", "content", "project_id"}},
+		{"invalid diagram update", http.MethodPut, "/api/diagrams/DIAGRAM", `{"name": "Flow"}`, http.StatusUnprocessableEntity, codeValidationFailed, []string{"type", "content"}},
+		{"method", http.MethodPost, "/api/tasks/missing", `{}`, http.StatusMethodNotAllowed, "method_not_allowed", nil},
+	}
+	for _, tt := range tests {
+		status, resp := request(tt.method, tt.path, tt.body)
+		if status != tt.status || resp.Code != tt.code || resp.Message == "" {
+			t.Errorf("%s: expected %d %s, got %d %+v", tt.name, tt.status, tt.code, status, resp)
+		}
+		var fields []string
+		for _, f := range resp.FieldErrors {
+			fields = append(fields, f.Field)
+		}
+		if !reflect.DeepEqual(fields, tt.fields) {
+			t.Errorf("%s: expected field errors on %v, got %+v", tt.name, tt.fields, resp.FieldErrors)
+		}
+	}
+}
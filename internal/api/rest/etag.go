@@ -0,0 +1,61 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// etag formats a revision as a strong entity tag
+func etag(revision int64) string {
+	return `"` + strconv.FormatInt(revision, 10) + `"`
+}
+
+// setETag sets the ETag header to a resource's revision
+func setETag(w http.ResponseWriter, revision int64) {
+	w.Header().Set("ETag", etag(revision))
+}
+
+// matchesIfMatch reports whether a resource at the given revision satisfies
+// the request's If-Match header, which it always does if there is none.
+// Weak tags never match, as If-Match uses strong comparison.
+func matchesIfMatch(r *http.Request, revision int64) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return true
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || tag == etag(revision) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch responds with 412 Precondition Failed if the resource's
+// revision doesn't satisfy the request's If-Match header
+func checkIfMatch(w http.ResponseWriter, r *http.Request, revision int64) bool {
+	if matchesIfMatch(r, revision) {
+		return true
+	}
+	setETag(w, revision)
+	httpError(w, "Precondition failed: the resource has changed", http.StatusPreconditionFailed)
+	return false
+}
+
+// hasIfMatch reports whether the request is conditional on a revision
+func hasIfMatch(r *http.Request) bool {
+	return r.Header.Get("If-Match") != ""
+}
+
+// revisionConflict responds to a write that lost a race with another one
+// made after the resource was read. The precondition failed if the request
+// had one; otherwise the client can simply retry.
+func revisionConflict(w http.ResponseWriter, r *http.Request) {
+	if hasIfMatch(r) {
+		httpError(w, "Precondition failed: the resource has changed", http.StatusPreconditionFailed)
+		return
+	}
+	httpError(w, "Conflict: the resource was modified concurrently, retry", http.StatusConflict)
+}
@@ -0,0 +1,39 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMatchesIfMatch(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{"", true},
+		{"*", true},
+		{`"3"`, true},
+		{`"2"`, false},
+		{`"1", "3"`, true},
+		{`W/"3"`, false},
+		{`3`, false},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPut, "/api/tasks/1", nil)
+		if tt.header != "" {
+			req.Header.Set("If-Match", tt.header)
+		}
+		if got := matchesIfMatch(req, 3); got != tt.want {
+			t.Errorf("If-Match %q at revision 3: expected %v, got %v", tt.header, tt.want, got)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPut, "/api/tasks/1", nil)
+	req.Header.Set("If-Match", `"2"`)
+	rec := httptest.NewRecorder()
+	if checkIfMatch(rec, req, 3) || rec.Code != http.StatusPreconditionFailed || rec.Header().Get("ETag") != `"3"` {
+		t.Errorf("Expected 412 with the current ETag, got %d %q", rec.Code, rec.Header().Get("ETag"))
+	}
+}
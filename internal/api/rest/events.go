@@ -0,0 +1,36 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/rand/cartographer/internal/api/websocket"
+)
+
+// relayEvent broadcasts a task event to WebSocket clients on behalf of a
+// process that changed the database itself, like `cartographer mcp`
+func (h *APIHandler) relayEvent(w http.ResponseWriter, r *http.Request) {
+	var msg websocket.Message
+	if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+		httpError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if h.wsHub == nil {
+		httpError(w, "No WebSocket hub", http.StatusServiceUnavailable)
+		return
+	}
+
+	err := h.wsHub.Relay(&msg)
+	if errors.Is(err, websocket.ErrNotRelayable) {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		h.logger.Printf("Error relaying event: %v", err)
+		httpError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -2,12 +2,18 @@ package rest
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/rand/cartographer/internal/api/websocket"
 	"github.com/rand/cartographer/internal/beads"
+	"github.com/rand/cartographer/internal/claude"
 	"github.com/rand/cartographer/internal/domain"
 	"github.com/rand/cartographer/internal/storage"
 )
@@ -18,129 +24,134 @@ type APIHandler struct {
 	boards       *storage.BoardRepository
 	tasks        *storage.TaskRepository
 	documents    *storage.DocumentRepository
-	beadsParser  *beads.Parser
+	diagrams     *storage.DiagramRepository
+	search       *storage.SearchRepository
+	apiTokens    *storage.APITokenRepository
+	audit        *storage.AuditRepository
+	leases       *storage.LeaseRepository
+	sessions     *claude.Recorder
+	beadsWatcher *beads.Watcher
+	beadsSync    *beads.Syncer
 	wsHub        *websocket.Hub
 	logger       *log.Logger
+	routes       []string // patterns registered by Register
+}
+
+// Config holds the repositories and services an APIHandler serves. Only
+// those of the endpoints in use are needed. Without a beads syncer or a
+// WebSocket hub changes aren't synced or broadcast, and the logger defaults
+// to log.Default.
+type Config struct {
+	Projects     *storage.ProjectRepository
+	Boards       *storage.BoardRepository
+	Tasks        *storage.TaskRepository
+	Documents    *storage.DocumentRepository
+	Diagrams     *storage.DiagramRepository
+	Search       *storage.SearchRepository
+	APITokens    *storage.APITokenRepository
+	Audit        *storage.AuditRepository
+	Leases       *storage.LeaseRepository
+	Sessions     *claude.Recorder
+	BeadsWatcher *beads.Watcher
+	BeadsSync    *beads.Syncer
+	WSHub        *websocket.Hub
+	Logger       *log.Logger
 }
 
 // NewAPIHandler creates a new API handler
-func NewAPIHandler(
-	projects *storage.ProjectRepository,
-	boards *storage.BoardRepository,
-	tasks *storage.TaskRepository,
-	documents *storage.DocumentRepository,
-	beadsParser *beads.Parser,
-	wsHub *websocket.Hub,
-	logger *log.Logger,
-) *APIHandler {
+func NewAPIHandler(cfg Config) *APIHandler {
+	if cfg.Logger == nil {
+		cfg.Logger = log.Default()
+	}
 	return &APIHandler{
-		projects:    projects,
-		boards:      boards,
-		tasks:       tasks,
-		documents:   documents,
-		beadsParser: beadsParser,
-		wsHub:       wsHub,
-		logger:      logger,
+		projects:     cfg.Projects,
+		boards:       cfg.Boards,
+		tasks:        cfg.Tasks,
+		documents:    cfg.Documents,
+		diagrams:     cfg.Diagrams,
+		search:       cfg.Search,
+		apiTokens:    cfg.APITokens,
+		audit:        cfg.Audit,
+		leases:       cfg.Leases,
+		sessions:     cfg.Sessions,
+		beadsWatcher: cfg.BeadsWatcher,
+		beadsSync:    cfg.BeadsSync,
+		wsHub:        cfg.WSHub,
+		logger:       cfg.Logger,
 	}
 }
 
-// Register registers all API routes
-func (h *APIHandler) Register(mux *http.ServeMux) {
-	// Projects
-	mux.HandleFunc("/api/projects", h.handleProjects)
-	mux.HandleFunc("/api/projects/", h.handleProject)
-
-	// Boards
-	mux.HandleFunc("/api/boards", h.handleBoards)
-	mux.HandleFunc("/api/boards/", h.handleBoard)
-
-	// Tasks
-	mux.HandleFunc("/api/tasks", h.handleTasks)
-	mux.HandleFunc("/api/tasks/", h.handleTask)
-
-	// Documents
-	mux.HandleFunc("/api/documents", h.handleDocuments)
-	mux.HandleFunc("/api/documents/", h.handleDocument)
-
-	// Beads
-	mux.HandleFunc("/api/beads/issues", h.handleBeadsIssues)
-	mux.HandleFunc("/api/beads/issues/", h.handleBeadsIssue)
-	mux.HandleFunc("/api/beads/graph", h.handleBeadsGraph)
-	mux.HandleFunc("/api/beads/stats", h.handleBeadsStats)
-}
-
 // Projects handlers
 
-func (h *APIHandler) handleProjects(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodGet:
-		h.listProjects(w, r)
-	case http.MethodPost:
-		h.createProject(w, r)
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+// listProjects lists projects, filtered by ?type, ?updated_since and ?q
+// and sorted and paged as in respondList
+func (h *APIHandler) listProjects(w http.ResponseWriter, r *http.Request) {
+	opts, ok := listOptions(w, r)
+	if !ok {
+		return
 	}
-}
-
-func (h *APIHandler) handleProject(w http.ResponseWriter, r *http.Request) {
-	id := strings.TrimPrefix(r.URL.Path, "/api/projects/")
-	if id == "" {
-		http.Error(w, "Project ID required", http.StatusBadRequest)
+	q := storage.ProjectQuery{
+		Type:        r.URL.Query().Get("type"),
+		Text:        strings.TrimSpace(r.URL.Query().Get("q")),
+		ListOptions: opts,
+	}
+	if q.UpdatedSince, ok = timeParam(w, r, "updated_since"); !ok {
 		return
 	}
 
-	switch r.Method {
-	case http.MethodGet:
-		h.getProject(w, r, id)
-	case http.MethodPut:
-		h.updateProject(w, r, id)
-	case http.MethodDelete:
-		h.deleteProject(w, r, id)
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	// Tokens limited to some projects only see those
+	if token := TokenFromContext(r.Context()); token != nil && token.Restricted() {
+		q.IDs = token.ProjectIDs
 	}
-}
 
-func (h *APIHandler) listProjects(w http.ResponseWriter, r *http.Request) {
-	projects, err := h.projects.List()
+	projects, next, err := h.projects.Query(q)
 	if err != nil {
-		h.logger.Printf("Error listing projects: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		h.listFailed(w, "projects", err)
 		return
 	}
+	if projects == nil {
+		projects = []*domain.Project{}
+	}
 
-	h.respondJSON(w, projects)
+	h.respondList(w, r, opts, projects, next)
 }
 
 func (h *APIHandler) getProject(w http.ResponseWriter, r *http.Request, id string) {
 	project, err := h.projects.GetByID(id)
 	if err != nil {
 		h.logger.Printf("Error getting project: %v", err)
-		http.Error(w, "Project not found", http.StatusNotFound)
+		httpError(w, "Project not found", http.StatusNotFound)
 		return
 	}
 
+	setETag(w, project.Revision)
 	h.respondJSON(w, project)
 }
 
 func (h *APIHandler) createProject(w http.ResponseWriter, r *http.Request) {
 	var project domain.Project
 	if err := json.NewDecoder(r.Body).Decode(&project); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		httpError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := project.Validate(); err != nil {
+		h.respondError(w, r, "validating project", err)
 		return
 	}
 
 	if err := h.projects.Create(&project); err != nil {
-		h.logger.Printf("Error creating project: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		h.respondError(w, r, "creating project", err)
 		return
 	}
 
+	h.recordAudit(r, domain.EntityProject, project.ID, project.ID, domain.AuditCreated, nil)
+
 	// Broadcast project creation via WebSocket
 	if h.wsHub != nil {
 		h.wsHub.BroadcastProjectCreated(project.ID, &project)
 	}
 
+	setETag(w, project.Revision)
 	w.WriteHeader(http.StatusCreated)
 	h.respondJSON(w, project)
 }
@@ -148,81 +159,126 @@ func (h *APIHandler) createProject(w http.ResponseWriter, r *http.Request) {
 func (h *APIHandler) updateProject(w http.ResponseWriter, r *http.Request, id string) {
 	var project domain.Project
 	if err := json.NewDecoder(r.Body).Decode(&project); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		httpError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	previous, err := h.projects.GetByID(id)
+	if err != nil {
+		httpError(w, "Project not found", http.StatusNotFound)
+		return
+	}
+	if !checkIfMatch(w, r, previous.Revision) {
 		return
 	}
 
 	project.ID = id
+	project.CreatedAt = previous.CreatedAt
+	project.Revision = previous.Revision
+	if err := project.Validate(); err != nil {
+		h.respondError(w, r, "validating project", err)
+		return
+	}
 	if err := h.projects.Update(&project); err != nil {
-		h.logger.Printf("Error updating project: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		h.respondError(w, r, "updating project", err)
 		return
 	}
 
+	changes := domain.DiffFields(previous, &project, projectReadOnly...)
+	h.recordAudit(r, domain.EntityProject, project.ID, project.ID, domain.AuditUpdated, changes)
+
 	// Broadcast project update via WebSocket
 	if h.wsHub != nil {
-		changes := map[string]interface{}{
-			"name":        project.Name,
-			"description": project.Description,
-		}
-		h.wsHub.BroadcastProjectUpdated(project.ID, changes, &project)
+		h.wsHub.BroadcastProjectUpdated(project.ID, changes.Values(), &project)
 	}
 
+	setETag(w, project.Revision)
 	h.respondJSON(w, project)
 }
 
 func (h *APIHandler) deleteProject(w http.ResponseWriter, r *http.Request, id string) {
+	if hasIfMatch(r) {
+		project, err := h.projects.GetByID(id)
+		if err != nil {
+			httpError(w, "Project not found", http.StatusNotFound)
+			return
+		}
+		if !checkIfMatch(w, r, project.Revision) {
+			return
+		}
+	}
+
 	if err := h.projects.Delete(id); err != nil {
-		h.logger.Printf("Error deleting project: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		h.respondError(w, r, "deleting project", err)
 		return
 	}
 
+	h.recordAudit(r, domain.EntityProject, id, id, domain.AuditDeleted, nil)
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
 // Boards handlers
 
-func (h *APIHandler) handleBoards(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodGet:
-		projectID := r.URL.Query().Get("project_id")
-		if projectID == "" {
-			http.Error(w, "project_id parameter required", http.StatusBadRequest)
-			return
-		}
-		h.listBoards(w, r, projectID)
-	case http.MethodPost:
-		h.createBoard(w, r)
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+// boardCriticalPath schedules a board's tasks by their estimates
+func (h *APIHandler) boardCriticalPath(w http.ResponseWriter, r *http.Request, id string) {
+	defaultMinutes, ok := defaultEstimate(w, r)
+	if !ok {
+		return
 	}
-}
 
-func (h *APIHandler) handleBoard(w http.ResponseWriter, r *http.Request) {
-	id := strings.TrimPrefix(r.URL.Path, "/api/boards/")
-	if id == "" {
-		http.Error(w, "Board ID required", http.StatusBadRequest)
+	if _, err := h.boards.GetByID(id); err != nil {
+		httpError(w, "Board not found", http.StatusNotFound)
+		return
+	}
+
+	tasks, err := h.tasks.ListByBoard(id)
+	if err != nil {
+		h.logger.Printf("Error listing tasks: %v", err)
+		httpError(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	switch r.Method {
-	case http.MethodGet:
-		h.getBoard(w, r, id)
-	case http.MethodPut:
-		h.updateBoard(w, r, id)
-	case http.MethodDelete:
-		h.deleteBoard(w, r, id)
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	schedule, err := beads.TaskSchedule(tasks, defaultMinutes)
+	h.respondSchedule(w, schedule, err)
+}
+
+// defaultEstimate reads the default_minutes query parameter, writing a 400
+// if it isn't a non-negative number
+func defaultEstimate(w http.ResponseWriter, r *http.Request) (int, bool) {
+	value := r.URL.Query().Get("default_minutes")
+	if value == "" {
+		return beads.DefaultEstimateMinutes, true
+	}
+	minutes, err := strconv.Atoi(value)
+	if err != nil || minutes < 0 {
+		httpError(w, "Invalid default_minutes", http.StatusBadRequest)
+		return 0, false
+	}
+	return minutes, true
+}
+
+// respondSchedule writes a schedule, or a 409 if the dependencies have a cycle
+func (h *APIHandler) respondSchedule(w http.ResponseWriter, schedule *beads.Schedule, err error) {
+	var cycle *beads.CycleError
+	if errors.As(err, &cycle) {
+		httpError(w, cycle.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		h.logger.Printf("Error computing critical path: %v", err)
+		httpError(w, "Internal server error", http.StatusInternalServerError)
+		return
 	}
+
+	h.respondJSON(w, schedule)
 }
 
 func (h *APIHandler) listBoards(w http.ResponseWriter, r *http.Request, projectID string) {
 	boards, err := h.boards.ListByProject(projectID)
 	if err != nil {
 		h.logger.Printf("Error listing boards: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		httpError(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
@@ -233,26 +289,39 @@ func (h *APIHandler) getBoard(w http.ResponseWriter, r *http.Request, id string)
 	board, err := h.boards.GetByID(id)
 	if err != nil {
 		h.logger.Printf("Error getting board: %v", err)
-		http.Error(w, "Board not found", http.StatusNotFound)
+		httpError(w, "Board not found", http.StatusNotFound)
 		return
 	}
 
+	setETag(w, board.Revision)
 	h.respondJSON(w, board)
 }
 
-func (h *APIHandler) createBoard(w http.ResponseWriter, r *http.Request) {
+func (h *APIHandler) createBoard(w http.ResponseWriter, r *http.Request, projectID string) {
 	var board domain.Board
 	if err := json.NewDecoder(r.Body).Decode(&board); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		httpError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if projectID != "" {
+		board.ProjectID = projectID
+	}
+	if !h.allowProject(w, r, board.ProjectID) {
+		return
+	}
+	if err := h.validateBoard(&board); err != nil {
+		h.respondError(w, r, "validating board", err)
 		return
 	}
 
 	if err := h.boards.Create(&board); err != nil {
-		h.logger.Printf("Error creating board: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		h.respondError(w, r, "creating board", err)
 		return
 	}
 
+	h.recordAudit(r, domain.EntityBoard, board.ID, board.ProjectID, domain.AuditCreated, nil)
+
+	setETag(w, board.Revision)
 	w.WriteHeader(http.StatusCreated)
 	h.respondJSON(w, board)
 }
@@ -260,117 +329,227 @@ func (h *APIHandler) createBoard(w http.ResponseWriter, r *http.Request) {
 func (h *APIHandler) updateBoard(w http.ResponseWriter, r *http.Request, id string) {
 	var board domain.Board
 	if err := json.NewDecoder(r.Body).Decode(&board); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		httpError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	previous, err := h.boards.GetByID(id)
+	if err != nil {
+		httpError(w, "Board not found", http.StatusNotFound)
+		return
+	}
+	if !checkIfMatch(w, r, previous.Revision) {
 		return
 	}
 
 	board.ID = id
+	board.ProjectID = previous.ProjectID
+	board.CreatedAt = previous.CreatedAt
+	board.Revision = previous.Revision
+	if err := board.Validate(); err != nil {
+		h.respondError(w, r, "validating board", err)
+		return
+	}
 	if err := h.boards.Update(&board); err != nil {
-		h.logger.Printf("Error updating board: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		h.respondError(w, r, "updating board", err)
 		return
 	}
 
+	changes := domain.DiffFields(previous, &board, boardReadOnly...)
+	h.recordAudit(r, domain.EntityBoard, board.ID, board.ProjectID, domain.AuditUpdated, changes)
+
 	// Broadcast board update via WebSocket
 	if h.wsHub != nil {
-		changes := map[string]interface{}{
-			"name":        board.Name,
-			"description": board.Description,
-			"columns":     board.Columns,
-		}
-		h.wsHub.BroadcastBoardUpdated(board.ID, board.ProjectID, changes, &board)
+		h.wsHub.BroadcastBoardUpdated(board.ID, board.ProjectID, changes.Values(), &board)
 	}
 
+	setETag(w, board.Revision)
 	h.respondJSON(w, board)
 }
 
 func (h *APIHandler) deleteBoard(w http.ResponseWriter, r *http.Request, id string) {
+	board, err := h.boards.GetByID(id)
+	if err != nil {
+		httpError(w, "Board not found", http.StatusNotFound)
+		return
+	}
+	if !checkIfMatch(w, r, board.Revision) {
+		return
+	}
+
 	if err := h.boards.Delete(id); err != nil {
-		h.logger.Printf("Error deleting board: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		h.respondError(w, r, "deleting board", err)
 		return
 	}
 
+	h.recordAudit(r, domain.EntityBoard, id, board.ProjectID, domain.AuditDeleted, nil)
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// Tasks handlers
+// importBeads upserts the board's project beads as tasks in one transaction
+func (h *APIHandler) importBeads(w http.ResponseWriter, r *http.Request, id string) {
+	board, err := h.boards.GetByID(id)
+	if err != nil {
+		httpError(w, "Board not found", http.StatusNotFound)
+		return
+	}
 
-func (h *APIHandler) handleTasks(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodGet:
-		boardID := r.URL.Query().Get("board_id")
-		if boardID == "" {
-			http.Error(w, "board_id parameter required", http.StatusBadRequest)
-			return
-		}
-		h.listTasks(w, r, boardID)
-	case http.MethodPost:
-		h.createTask(w, r)
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	project, err := h.projects.GetByID(board.ProjectID)
+	if err != nil {
+		h.logger.Printf("Error getting project for board %s: %v", id, err)
+		httpError(w, "Project not found", http.StatusNotFound)
+		return
+	}
+
+	parser := beads.NewParser(project.Path)
+	if _, err := os.Stat(parser.Path()); os.IsNotExist(err) {
+		httpError(w, "Project has no .beads/issues.jsonl", http.StatusNotFound)
+		return
+	}
+	issues, err := parser.ReadBeadsFromProject()
+	if err != nil {
+		httpError(w, "Invalid beads file: "+err.Error(), http.StatusUnprocessableEntity)
+		return
 	}
-}
 
-func (h *APIHandler) handleTask(w http.ResponseWriter, r *http.Request) {
-	id := strings.TrimPrefix(r.URL.Path, "/api/tasks/")
-	if id == "" {
-		http.Error(w, "Task ID required", http.StatusBadRequest)
+	var report *beads.ImportReport
+	var imported []beads.ImportedTask
+	err = h.tasks.Transaction(func(tasks *storage.TaskRepository) error {
+		var err error
+		report, imported, err = beads.ImportIntoBoard(issues, board, tasks, activityUser(r))
+		return err
+	})
+	if err != nil {
+		h.logger.Printf("Error importing beads into board %s: %v", id, err)
+		httpError(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	switch r.Method {
-	case http.MethodGet:
-		h.getTask(w, r, id)
-	case http.MethodPut:
-		h.updateTask(w, r, id)
-	case http.MethodDelete:
-		h.deleteTask(w, r, id)
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	for _, imp := range imported {
+		if imp.Created {
+			h.recordAudit(r, domain.EntityTask, imp.Task.ID, board.ProjectID, domain.AuditCreated, nil)
+		} else {
+			h.recordAudit(r, domain.EntityTask, imp.Task.ID, board.ProjectID, domain.AuditUpdated, imp.Changes)
+		}
 	}
+	if h.wsHub != nil {
+		for _, imp := range imported {
+			if imp.Created {
+				h.wsHub.BroadcastTaskCreated(imp.Task.ID, imp.Task.BoardID, imp.Task)
+			} else {
+				h.wsHub.BroadcastTaskUpdated(imp.Task.ID, imp.Task.BoardID, imp.Changes.Values(), imp.Task)
+			}
+		}
+	}
+
+	// Start tracking the imported tasks if the server syncs this file
+	if h.beadsSync != nil && samePath(h.beadsSync.Path(), parser.Path()) {
+		if _, err := h.beadsSync.Sync(); err != nil {
+			h.logger.Printf("Error syncing imported beads: %v", err)
+		}
+	}
+
+	h.respondJSON(w, report)
 }
 
+// samePath reports whether two paths name the same file
+func samePath(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
+
+// Tasks handlers
+
+// listTasks lists the tasks of a board, filtered by ?status, ?priority
+// (both comma-separated), ?assignee, ?label, ?due_before, ?updated_since
+// and ?q and sorted and paged as in respondList
 func (h *APIHandler) listTasks(w http.ResponseWriter, r *http.Request, boardID string) {
-	tasks, err := h.tasks.ListByBoard(boardID)
+	opts, ok := listOptions(w, r)
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+	q := storage.TaskQuery{
+		BoardID:     boardID,
+		Status:      listParam(r, "status"),
+		Priority:    listParam(r, "priority"),
+		Assignee:    query.Get("assignee"),
+		Label:       query.Get("label"),
+		Text:        strings.TrimSpace(query.Get("q")),
+		ListOptions: opts,
+	}
+	if q.DueBefore, ok = timeParam(w, r, "due_before"); !ok {
+		return
+	}
+	if q.UpdatedSince, ok = timeParam(w, r, "updated_since"); !ok {
+		return
+	}
+
+	tasks, next, err := h.tasks.Query(q)
 	if err != nil {
-		h.logger.Printf("Error listing tasks: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		h.listFailed(w, "tasks", err)
 		return
 	}
+	if tasks == nil {
+		tasks = []*domain.Task{}
+	}
 
-	h.respondJSON(w, tasks)
+	h.respondList(w, r, opts, tasks, next)
 }
 
 func (h *APIHandler) getTask(w http.ResponseWriter, r *http.Request, id string) {
 	task, err := h.tasks.GetByID(id)
 	if err != nil {
 		h.logger.Printf("Error getting task: %v", err)
-		http.Error(w, "Task not found", http.StatusNotFound)
+		httpError(w, "Task not found", http.StatusNotFound)
 		return
 	}
 
+	setETag(w, task.Revision)
 	h.respondJSON(w, task)
 }
 
-func (h *APIHandler) createTask(w http.ResponseWriter, r *http.Request) {
+func (h *APIHandler) createTask(w http.ResponseWriter, r *http.Request, boardID string) {
 	var task domain.Task
 	if err := json.NewDecoder(r.Body).Decode(&task); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		httpError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if boardID != "" {
+		task.BoardID = boardID
+	}
+	if !h.allowBoard(w, r, task.BoardID) {
 		return
 	}
+	if err := h.validateTask(h.tasks, &task); err != nil {
+		h.respondError(w, r, "validating task", err)
+		return
+	}
+	if !h.allowWIPLimit(w, task.BoardID, task.Status) {
+		return
+	}
+
+	// Activity starts with the creation; clients may only add comments
+	now := time.Now()
+	created := domain.ActivityEntry{Type: domain.ActivityCreated, Timestamp: now}
+	task.Activity = append([]domain.ActivityEntry{created}, newComments(task.Activity, 0, now)...)
+	attributeTask(r, &task, nil)
 
 	if err := h.tasks.Create(&task); err != nil {
-		h.logger.Printf("Error creating task: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		h.respondError(w, r, "creating task", err)
 		return
 	}
 
+	h.recordAudit(r, domain.EntityTask, task.ID, h.boardProjectID(task.BoardID), domain.AuditCreated, nil)
+
 	// Broadcast task creation via WebSocket
 	if h.wsHub != nil {
 		h.wsHub.BroadcastTaskCreated(task.ID, task.BoardID, &task)
 	}
 
+	setETag(w, task.Revision)
 	w.WriteHeader(http.StatusCreated)
 	h.respondJSON(w, task)
 }
@@ -378,28 +557,60 @@ func (h *APIHandler) createTask(w http.ResponseWriter, r *http.Request) {
 func (h *APIHandler) updateTask(w http.ResponseWriter, r *http.Request, id string) {
 	var task domain.Task
 	if err := json.NewDecoder(r.Body).Decode(&task); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		httpError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	existing, err := h.tasks.GetByID(id)
+	if err != nil {
+		httpError(w, "Task not found", http.StatusNotFound)
+		return
+	}
+	if !checkIfMatch(w, r, existing.Revision) {
 		return
 	}
 
 	task.ID = id
+	task.BoardID = existing.BoardID
+	task.CreatedAt = existing.CreatedAt
+	task.Revision = existing.Revision
+	if err := h.validateTask(h.tasks, &task); err != nil {
+		h.respondError(w, r, "validating task", err)
+		return
+	}
+	if task.Status != existing.Status && !h.allowWIPLimit(w, existing.BoardID, task.Status) {
+		return
+	}
+
+	// The server keeps the activity history: a request can only add
+	// comments, and its field changes are recorded below
+	now := time.Now()
+	comments := newComments(task.Activity, len(existing.Activity), now)
+	task.Activity = append(append([]domain.ActivityEntry{}, existing.Activity...), comments...)
+	attributeTask(r, &task, existing)
+
+	changes := domain.RecordTaskUpdate(existing, &task, activityUser(r), now)
+
 	if err := h.tasks.Update(&task); err != nil {
-		h.logger.Printf("Error updating task: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		h.respondError(w, r, "updating task", err)
 		return
 	}
 
+	h.recordAudit(r, domain.EntityTask, task.ID, h.boardProjectID(task.BoardID), domain.AuditUpdated, changes)
+
 	// Broadcast task update via WebSocket
 	if h.wsHub != nil {
-		changes := map[string]interface{}{
-			"title":       task.Title,
-			"description": task.Description,
-			"status":      task.Status,
-			"priority":    task.Priority,
+		h.wsHub.BroadcastTaskUpdated(task.ID, task.BoardID, changes.Values(), &task)
+	}
+
+	// Write the edit back to the bead the task was imported from
+	if h.beadsSync != nil {
+		if _, err := h.beadsSync.PushTask(task.ID); err != nil {
+			h.logger.Printf("Error syncing task %s to beads: %v", task.ID, err)
 		}
-		h.wsHub.BroadcastTaskUpdated(task.ID, task.BoardID, changes, &task)
 	}
 
+	setETag(w, task.Revision)
 	h.respondJSON(w, task)
 }
 
@@ -408,16 +619,20 @@ func (h *APIHandler) deleteTask(w http.ResponseWriter, r *http.Request, id strin
 	task, err := h.tasks.GetByID(id)
 	if err != nil {
 		h.logger.Printf("Error getting task for deletion: %v", err)
-		http.Error(w, "Task not found", http.StatusNotFound)
+		httpError(w, "Task not found", http.StatusNotFound)
+		return
+	}
+	if !checkIfMatch(w, r, task.Revision) {
 		return
 	}
 
 	if err := h.tasks.Delete(id); err != nil {
-		h.logger.Printf("Error deleting task: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		h.respondError(w, r, "deleting task", err)
 		return
 	}
 
+	h.recordAudit(r, domain.EntityTask, task.ID, h.boardProjectID(task.BoardID), domain.AuditDeleted, nil)
+
 	// Broadcast task deletion via WebSocket
 	if h.wsHub != nil {
 		h.wsHub.BroadcastTaskDeleted(task.ID, task.BoardID)
@@ -428,76 +643,72 @@ func (h *APIHandler) deleteTask(w http.ResponseWriter, r *http.Request, id strin
 
 // Documents handlers
 
-func (h *APIHandler) handleDocuments(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodGet:
-		projectID := r.URL.Query().Get("project_id")
-		if projectID == "" {
-			http.Error(w, "project_id parameter required", http.StatusBadRequest)
-			return
-		}
-		h.listDocuments(w, r, projectID)
-	case http.MethodPost:
-		h.createDocument(w, r)
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-	}
-}
-
-func (h *APIHandler) handleDocument(w http.ResponseWriter, r *http.Request) {
-	id := strings.TrimPrefix(r.URL.Path, "/api/documents/")
-	if id == "" {
-		http.Error(w, "Document ID required", http.StatusBadRequest)
+// listDocuments lists the documents of a project, filtered by ?tag,
+// ?updated_since and ?q and sorted and paged as in respondList
+func (h *APIHandler) listDocuments(w http.ResponseWriter, r *http.Request, projectID string) {
+	opts, ok := listOptions(w, r)
+	if !ok {
 		return
 	}
-
-	switch r.Method {
-	case http.MethodGet:
-		h.getDocument(w, r, id)
-	case http.MethodPut:
-		h.updateDocument(w, r, id)
-	case http.MethodDelete:
-		h.deleteDocument(w, r, id)
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	q := storage.DocumentQuery{
+		ProjectID:   projectID,
+		Tag:         r.URL.Query().Get("tag"),
+		Text:        strings.TrimSpace(r.URL.Query().Get("q")),
+		ListOptions: opts,
+	}
+	if q.UpdatedSince, ok = timeParam(w, r, "updated_since"); !ok {
+		return
 	}
-}
 
-func (h *APIHandler) listDocuments(w http.ResponseWriter, r *http.Request, projectID string) {
-	documents, err := h.documents.ListByProject(projectID)
+	documents, next, err := h.documents.Query(q)
 	if err != nil {
-		h.logger.Printf("Error listing documents: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		h.listFailed(w, "documents", err)
 		return
 	}
+	if documents == nil {
+		documents = []*domain.Document{}
+	}
 
-	h.respondJSON(w, documents)
+	h.respondList(w, r, opts, documents, next)
 }
 
 func (h *APIHandler) getDocument(w http.ResponseWriter, r *http.Request, id string) {
 	document, err := h.documents.GetByID(id)
 	if err != nil {
 		h.logger.Printf("Error getting document: %v", err)
-		http.Error(w, "Document not found", http.StatusNotFound)
+		httpError(w, "Document not found", http.StatusNotFound)
 		return
 	}
 
+	setETag(w, document.Revision)
 	h.respondJSON(w, document)
 }
 
-func (h *APIHandler) createDocument(w http.ResponseWriter, r *http.Request) {
+func (h *APIHandler) createDocument(w http.ResponseWriter, r *http.Request, projectID string) {
 	var document domain.Document
 	if err := json.NewDecoder(r.Body).Decode(&document); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		httpError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if projectID != "" {
+		document.ProjectID = projectID
+	}
+	if !h.allowProject(w, r, document.ProjectID) {
+		return
+	}
+	if err := h.validateDocument(&document); err != nil {
+		h.respondError(w, r, "validating document", err)
 		return
 	}
 
 	if err := h.documents.Create(&document); err != nil {
-		h.logger.Printf("Error creating document: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		h.respondError(w, r, "creating document", err)
 		return
 	}
 
+	h.recordAudit(r, domain.EntityDocument, document.ID, document.ProjectID, domain.AuditCreated, nil)
+
+	setETag(w, document.Revision)
 	w.WriteHeader(http.StatusCreated)
 	h.respondJSON(w, document)
 }
@@ -505,42 +716,273 @@ func (h *APIHandler) createDocument(w http.ResponseWriter, r *http.Request) {
 func (h *APIHandler) updateDocument(w http.ResponseWriter, r *http.Request, id string) {
 	var document domain.Document
 	if err := json.NewDecoder(r.Body).Decode(&document); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		httpError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	previous, err := h.documents.GetByID(id)
+	if err != nil {
+		httpError(w, "Document not found", http.StatusNotFound)
+		return
+	}
+	if !checkIfMatch(w, r, previous.Revision) {
 		return
 	}
 
 	document.ID = id
+	document.ProjectID = previous.ProjectID
+	document.CreatedAt = previous.CreatedAt
+	document.Revision = previous.Revision
+	if err := document.Validate(); err != nil {
+		h.respondError(w, r, "validating document", err)
+		return
+	}
 	if err := h.documents.Update(&document); err != nil {
-		h.logger.Printf("Error updating document: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		h.respondError(w, r, "updating document", err)
 		return
 	}
 
+	changes := domain.DiffFields(previous, &document, documentReadOnly...)
+	h.recordAudit(r, domain.EntityDocument, id, previous.ProjectID, domain.AuditUpdated, changes)
+
+	setETag(w, document.Revision)
+
 	h.respondJSON(w, document)
 }
 
 func (h *APIHandler) deleteDocument(w http.ResponseWriter, r *http.Request, id string) {
+	document, err := h.documents.GetByID(id)
+	if err != nil {
+		httpError(w, "Document not found", http.StatusNotFound)
+		return
+	}
+	if !checkIfMatch(w, r, document.Revision) {
+		return
+	}
+
 	if err := h.documents.Delete(id); err != nil {
-		h.logger.Printf("Error deleting document: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		h.respondError(w, r, "deleting document", err)
+		return
+	}
+
+	h.recordAudit(r, domain.EntityDocument, id, document.ProjectID, domain.AuditDeleted, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Diagrams handlers
+
+func (h *APIHandler) listDiagrams(w http.ResponseWriter, r *http.Request, projectID string) {
+	diagrams, err := h.diagrams.ListByProject(projectID)
+	if err != nil {
+		h.logger.Printf("Error listing diagrams: %v", err)
+		httpError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.respondJSON(w, diagrams)
+}
+
+func (h *APIHandler) getDiagram(w http.ResponseWriter, r *http.Request, id string) {
+	diagram, err := h.diagrams.GetByID(id)
+	if err != nil {
+		h.logger.Printf("Error getting diagram: %v", err)
+		httpError(w, "Diagram not found", http.StatusNotFound)
+		return
+	}
+
+	setETag(w, diagram.Revision)
+	h.respondJSON(w, diagram)
+}
+
+func (h *APIHandler) createDiagram(w http.ResponseWriter, r *http.Request, projectID string) {
+	var diagram domain.Diagram
+	if err := json.NewDecoder(r.Body).Decode(&diagram); err != nil {
+		httpError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if projectID != "" {
+		diagram.ProjectID = projectID
+	}
+	if !h.allowProject(w, r, diagram.ProjectID) {
+		return
+	}
+	if err := h.validateDiagram(&diagram); err != nil {
+		h.respondError(w, r, "validating diagram", err)
+		return
+	}
+
+	if err := h.diagrams.Create(&diagram); err != nil {
+		h.respondError(w, r, "creating diagram", err)
+		return
+	}
+
+	h.recordAudit(r, domain.EntityDiagram, diagram.ID, diagram.ProjectID, domain.AuditCreated, nil)
+
+	// Broadcast diagram creation via WebSocket
+	if h.wsHub != nil {
+		h.wsHub.BroadcastDiagramCreated(diagram.ID, diagram.ProjectID, &diagram)
+	}
+
+	setETag(w, diagram.Revision)
+	w.WriteHeader(http.StatusCreated)
+	h.respondJSON(w, diagram)
+}
+
+func (h *APIHandler) updateDiagram(w http.ResponseWriter, r *http.Request, id string) {
+	var diagram domain.Diagram
+	if err := json.NewDecoder(r.Body).Decode(&diagram); err != nil {
+		httpError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Get the current diagram so the broadcast only carries real changes
+	previous, err := h.diagrams.GetByID(id)
+	if err != nil {
+		h.logger.Printf("Error getting diagram for update: %v", err)
+		httpError(w, "Diagram not found", http.StatusNotFound)
+		return
+	}
+	if !checkIfMatch(w, r, previous.Revision) {
+		return
+	}
+
+	diagram.ID = id
+	diagram.ProjectID = previous.ProjectID
+	diagram.CreatedAt = previous.CreatedAt
+	diagram.Revision = previous.Revision
+	if err := diagram.Validate(); err != nil {
+		h.respondError(w, r, "validating diagram", err)
+		return
+	}
+	if err := h.diagrams.Update(&diagram); err != nil {
+		h.respondError(w, r, "updating diagram", err)
+		return
+	}
+
+	changes := domain.DiffFields(previous, &diagram, "id", "project_id", "created_at", "updated_at", "revision", "versions")
+	h.recordAudit(r, domain.EntityDiagram, diagram.ID, previous.ProjectID, domain.AuditUpdated, changes)
+
+	// Broadcast diagram update via WebSocket
+	if h.wsHub != nil {
+		h.wsHub.BroadcastDiagramUpdated(diagram.ID, diagram.ProjectID, diagram.CurrentVersion(), changes.Values(), &diagram)
+	}
+
+	setETag(w, diagram.Revision)
+	h.respondJSON(w, diagram)
+}
+
+func (h *APIHandler) deleteDiagram(w http.ResponseWriter, r *http.Request, id string) {
+	// Get diagram to find project_id for WebSocket broadcast
+	diagram, err := h.diagrams.GetByID(id)
+	if err != nil {
+		h.logger.Printf("Error getting diagram for deletion: %v", err)
+		httpError(w, "Diagram not found", http.StatusNotFound)
+		return
+	}
+	if !checkIfMatch(w, r, diagram.Revision) {
+		return
+	}
+
+	if err := h.diagrams.Delete(id); err != nil {
+		h.respondError(w, r, "deleting diagram", err)
 		return
 	}
 
+	h.recordAudit(r, domain.EntityDiagram, diagram.ID, diagram.ProjectID, domain.AuditDeleted, nil)
+
+	// Broadcast diagram deletion via WebSocket
+	if h.wsHub != nil {
+		h.wsHub.BroadcastDiagramDeleted(diagram.ID, diagram.ProjectID)
+	}
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
+func (h *APIHandler) listDiagramVersions(w http.ResponseWriter, r *http.Request, id string) {
+	diagram, err := h.diagrams.GetByID(id)
+	if err != nil {
+		h.logger.Printf("Error getting diagram: %v", err)
+		httpError(w, "Diagram not found", http.StatusNotFound)
+		return
+	}
+
+	// Include the live content as the newest version
+	versions := append([]domain.DiagramVersion{}, diagram.Versions...)
+	versions = append(versions, domain.DiagramVersion{
+		Version:   diagram.CurrentVersion(),
+		Timestamp: diagram.UpdatedAt,
+		Content:   diagram.Content,
+	})
+
+	h.respondJSON(w, versions)
+}
+
+// diffDiagram returns a line diff between two versions of a diagram.
+// ?from defaults to the previous version and ?to to the current one.
+func (h *APIHandler) diffDiagram(w http.ResponseWriter, r *http.Request, id string) {
+	diagram, err := h.diagrams.GetByID(id)
+	if err != nil {
+		h.logger.Printf("Error getting diagram: %v", err)
+		httpError(w, "Diagram not found", http.StatusNotFound)
+		return
+	}
+
+	to := diagram.CurrentVersion()
+	from := to - 1
+	query := r.URL.Query()
+	if v := query.Get("from"); v != "" {
+		if from, err = strconv.Atoi(v); err != nil {
+			httpError(w, "Invalid from version", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := query.Get("to"); v != "" {
+		if to, err = strconv.Atoi(v); err != nil {
+			httpError(w, "Invalid to version", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Version 0 is the empty diagram, so ?from=0 shows the whole first version
+	var fromContent string
+	if from != 0 {
+		var ok bool
+		if fromContent, ok = diagram.ContentAt(from); !ok {
+			httpError(w, "Version not found", http.StatusNotFound)
+			return
+		}
+	}
+	toContent, ok := diagram.ContentAt(to)
+	if !ok {
+		httpError(w, "Version not found", http.StatusNotFound)
+		return
+	}
+
+	diff := domain.DiffLines(fromContent, toContent)
+	h.respondJSON(w, map[string]interface{}{
+		"diagram_id": diagram.ID,
+		"from":       from,
+		"to":         to,
+		"additions":  diff.Additions,
+		"deletions":  diff.Deletions,
+		"lines":      diff.Lines,
+	})
+}
+
 // Beads handlers
 
 func (h *APIHandler) handleBeadsIssues(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	// Without an issues file there are no issues
+	if h.beadsWatcher.Missing() {
+		h.respondJSON(w, []interface{}{})
 		return
 	}
 
-	issues, err := h.beadsParser.ReadBeadsFromProject()
+	issues, err := h.beadsWatcher.Issues()
 	if err != nil {
 		h.logger.Printf("Error reading beads issues: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		httpError(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
@@ -548,73 +990,231 @@ func (h *APIHandler) handleBeadsIssues(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *APIHandler) handleBeadsIssue(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	id := r.PathValue("id")
+
+	if _, err := h.beadsWatcher.Issues(); err != nil && !h.beadsWatcher.Missing() {
+		h.logger.Printf("Error reading beads issues: %v", err)
+		httpError(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	id := strings.TrimPrefix(r.URL.Path, "/api/beads/issues/")
-	if id == "" {
-		http.Error(w, "Issue ID required", http.StatusBadRequest)
+	issue, found := h.beadsWatcher.Issue(id)
+	if !found {
+		httpError(w, "Issue not found", http.StatusNotFound)
 		return
 	}
 
-	issues, err := h.beadsParser.ReadBeadsFromProject()
+	h.respondJSON(w, issue)
+}
+
+func (h *APIHandler) handleBeadsGraph(w http.ResponseWriter, r *http.Request) {
+	graph, err := h.beadsWatcher.Graph()
 	if err != nil {
-		h.logger.Printf("Error reading beads issues: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		h.logger.Printf("Error building dependency graph: %v", err)
+		httpError(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	analyzer := beads.NewAnalyzer(issues)
-	issue, found := analyzer.GetIssueByID(id)
-	if !found {
-		http.Error(w, "Issue not found", http.StatusNotFound)
+	h.respondJSON(w, graph)
+}
+
+func (h *APIHandler) handleBeadsStats(w http.ResponseWriter, r *http.Request) {
+	issues, err := h.beadsWatcher.Issues()
+	if err != nil {
+		h.logger.Printf("Error reading beads issues: %v", err)
+		httpError(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	h.respondJSON(w, issue)
+	stats := beads.GetBeadsStatistics(issues)
+	h.respondJSON(w, stats)
 }
 
-func (h *APIHandler) handleBeadsGraph(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+// handleBeadsCriticalPath schedules open issues by their estimates:
+// GET /api/beads/critical-path?default_minutes=60
+func (h *APIHandler) handleBeadsCriticalPath(w http.ResponseWriter, r *http.Request) {
+	defaultMinutes, ok := defaultEstimate(w, r)
+	if !ok {
 		return
 	}
 
-	issues, err := h.beadsParser.ReadBeadsFromProject()
+	issues, err := h.beadsWatcher.Issues()
 	if err != nil {
 		h.logger.Printf("Error reading beads issues: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		httpError(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	analyzer := beads.NewAnalyzer(issues)
-	graph, err := analyzer.BuildDependencyGraph()
+	schedule, err := beads.NewAnalyzer(issues).CriticalPath(defaultMinutes)
+	h.respondSchedule(w, schedule, err)
+}
+
+// handleBeadsSync syncs every task linked to a bead with the issues file
+func (h *APIHandler) handleBeadsSync(w http.ResponseWriter, r *http.Request) {
+	report, err := h.beadsSync.Sync()
 	if err != nil {
-		h.logger.Printf("Error building dependency graph: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		h.logger.Printf("Error syncing beads: %v", err)
+		httpError(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	h.respondJSON(w, graph)
+	h.respondJSON(w, report)
 }
 
-func (h *APIHandler) handleBeadsStats(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+func (h *APIHandler) handleBeadsConflicts(w http.ResponseWriter, r *http.Request) {
+	conflicts, err := h.beadsSync.Conflicts()
+	if err != nil {
+		h.logger.Printf("Error listing beads sync conflicts: %v", err)
+		httpError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.respondJSON(w, conflicts)
+}
+
+// handleBeadsConflict resolves a conflict by keeping the bead's or the
+// task's values: POST /api/beads/sync/conflicts/{bead_id} {"keep":"bead"}
+func (h *APIHandler) handleBeadsConflict(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	var req struct {
+		Keep string `json:"keep"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Keep != beads.KeepBead && req.Keep != beads.KeepTask {
+		httpError(w, `keep must be "bead" or "task"`, http.StatusBadRequest)
 		return
 	}
 
-	issues, err := h.beadsParser.ReadBeadsFromProject()
+	report, err := h.beadsSync.Resolve(id, req.Keep)
+	if err == beads.ErrNoConflict {
+		httpError(w, "Conflict not found", http.StatusNotFound)
+		return
+	}
 	if err != nil {
-		h.logger.Printf("Error reading beads issues: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		h.logger.Printf("Error resolving beads sync conflict: %v", err)
+		httpError(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	stats := beads.GetBeadsStatistics(issues)
-	h.respondJSON(w, stats)
+	h.respondJSON(w, report)
+}
+
+// Search handlers
+
+// maxSearchLimit caps the number of hits a single search can return
+const maxSearchLimit = 100
+
+func (h *APIHandler) handleSearch(w http.ResponseWriter, r *http.Request) {
+	var types []string
+	if v := r.URL.Query().Get("types"); v != "" {
+		for _, t := range strings.Split(v, ",") {
+			t = strings.TrimSpace(t)
+			if !isSearchType(t) {
+				httpError(w, "Unknown search type: "+t, http.StatusBadRequest)
+				return
+			}
+			types = append(types, t)
+		}
+	}
+
+	h.runSearch(w, r, types)
+}
+
+func (h *APIHandler) handleDocumentSearch(w http.ResponseWriter, r *http.Request) {
+	h.runSearch(w, r, []string{domain.SearchTypeDocument})
+}
+
+// runSearch searches SQLite-backed entities and the beads index, merging
+// the results by score
+func (h *APIHandler) runSearch(w http.ResponseWriter, r *http.Request, types []string) {
+	query := r.URL.Query()
+	q := strings.TrimSpace(query.Get("q"))
+	if q == "" {
+		httpError(w, "q parameter required", http.StatusBadRequest)
+		return
+	}
+
+	limit := 20
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			httpError(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+
+	// Tokens limited to some projects search one of them, without beads
+	restricted := false
+	if token := TokenFromContext(r.Context()); token != nil && token.Restricted() {
+		if query.Get("project_id") == "" {
+			httpError(w, "project_id parameter required", http.StatusBadRequest)
+			return
+		}
+		if !h.allowProject(w, r, query.Get("project_id")) {
+			return
+		}
+		restricted = true
+	}
+
+	wantBeads := len(types) == 0
+	var storageTypes []string
+	for _, t := range types {
+		if t == domain.SearchTypeBead {
+			wantBeads = true
+		} else {
+			storageTypes = append(storageTypes, t)
+		}
+	}
+
+	var stored, beadHits []domain.SearchHit
+	if len(types) == 0 || len(storageTypes) > 0 {
+		found, err := h.search.Search(q, storage.SearchOptions{
+			Types:     storageTypes,
+			ProjectID: query.Get("project_id"),
+			Limit:     limit,
+		})
+		if err != nil {
+			h.logger.Printf("Error searching: %v", err)
+			httpError(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		stored = found
+	}
+
+	if wantBeads && !restricted {
+		found, err := h.beadsWatcher.Search(q, limit)
+		if err != nil {
+			// Beads are supplementary; don't fail the whole search
+			h.logger.Printf("Error searching beads: %v", err)
+		}
+		beadHits = found
+	}
+
+	hits := domain.MergeSearchHits(limit, stored, beadHits)
+
+	h.respondJSON(w, map[string]interface{}{
+		"query": q,
+		"total": len(hits),
+		"hits":  hits,
+	})
+}
+
+// isSearchType reports whether t is a searchable entity type
+func isSearchType(t string) bool {
+	for _, known := range domain.SearchTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
 }
 
 // Helper methods
@@ -623,7 +1223,7 @@ func (h *APIHandler) respondJSON(w http.ResponseWriter, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(data); err != nil {
 		h.logger.Printf("Error encoding JSON response: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		httpError(w, "Internal server error", http.StatusInternalServerError)
 	}
 }
 
@@ -631,8 +1231,9 @@ func (h *APIHandler) respondJSON(w http.ResponseWriter, data interface{}) {
 func CORSMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, If-Match")
+		w.Header().Set("Access-Control-Expose-Headers", "ETag, Link")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -0,0 +1,58 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rand/cartographer/internal/domain"
+	"github.com/rand/cartographer/internal/storage"
+)
+
+func TestImportBeadsAudit(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".beads"), 0755); err != nil {
+		t.Fatalf("failed to create .beads: %v", err)
+	}
+	writeBead := func(title string) {
+		line := `{"id":"bd-1","title":"` + title + `","status":"open","priority":2,"issue_type":"task","created_at":"2025-01-01T00:00:00Z","updated_at":"2025-01-01T00:00:00Z"}` + "\n"
+		if err := os.WriteFile(filepath.Join(dir, ".beads", "issues.jsonl"), []byte(line), 0644); err != nil {
+			t.Fatalf("failed to write beads: %v", err)
+		}
+	}
+
+	board := &domain.Board{Name: "Main", Columns: []domain.BoardColumn{{ID: "todo", Name: "To Do"}}}
+	api := newTestAPI(t, &domain.Project{Name: "Import", Path: dir}, board)
+
+	importBeads := func() {
+		rec := httptest.NewRecorder()
+		api.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/boards/"+board.ID+"/import/beads", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("import: got %d %s", rec.Code, rec.Body.String())
+		}
+	}
+
+	writeBead("Parse config")
+	importBeads()
+	writeBead("Parse YAML config")
+	importBeads()
+
+	entries, err := api.audit.List(storage.AuditQuery{Entity: domain.EntityTask, EntityID: "bd-1"})
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("expected the creation and update audited, got %+v (%v)", entries, err)
+	}
+	actions := map[string]bool{entries[0].Action: true, entries[1].Action: true}
+	if !actions[domain.AuditCreated] || !actions[domain.AuditUpdated] {
+		t.Errorf("expected created and updated entries, got %+v", entries)
+	}
+
+	task, err := api.tasks.GetByID("bd-1")
+	if err != nil {
+		t.Fatalf("GetByID failed: %v", err)
+	}
+	if n := len(task.Activity); n != 2 || task.Activity[0].Type != domain.ActivityCreated || task.Activity[1].Type != domain.ActivityUpdated {
+		t.Errorf("expected the creation and update in the activity, got %+v", task.Activity)
+	}
+}
@@ -0,0 +1,105 @@
+package rest
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rand/cartographer/internal/storage"
+)
+
+// defaultPageSize is the page size of a list paged by ?cursor alone
+const defaultPageSize = 50
+
+// listPage is the response to a paged list request
+type listPage struct {
+	Items      interface{} `json:"items"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+}
+
+// listOptions reads the ?sort, ?limit and ?cursor parameters of a list
+// request, writing a 400 if the limit isn't a positive number
+func listOptions(w http.ResponseWriter, r *http.Request) (storage.ListOptions, bool) {
+	query := r.URL.Query()
+	opts := storage.ListOptions{Sort: query.Get("sort"), Cursor: query.Get("cursor")}
+
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			httpError(w, "Invalid limit", http.StatusBadRequest)
+			return opts, false
+		}
+		opts.Limit = n
+	} else if opts.Cursor != "" {
+		opts.Limit = defaultPageSize
+	}
+	if opts.Limit > storage.MaxListLimit {
+		opts.Limit = storage.MaxListLimit
+	}
+	return opts, true
+}
+
+// timeParam reads a query parameter holding an RFC 3339 time or a date,
+// writing a 400 if it is neither. It returns nil if the parameter is unset.
+func timeParam(w http.ResponseWriter, r *http.Request, name string) (*time.Time, bool) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return nil, true
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		t, err = time.Parse(time.DateOnly, v)
+	}
+	if err != nil {
+		httpError(w, "Invalid "+name+": expected an RFC 3339 time or a date", http.StatusBadRequest)
+		return nil, false
+	}
+	return &t, true
+}
+
+// listParam reads a comma-separated query parameter
+func listParam(r *http.Request, name string) []string {
+	var values []string
+	for _, v := range strings.Split(r.URL.Query().Get(name), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// respondList writes the items of a list request. Paged requests, those
+// with a limit or cursor, get a listPage and a Link header to the next
+// page; others get the items alone.
+func (h *APIHandler) respondList(w http.ResponseWriter, r *http.Request, opts storage.ListOptions, items interface{}, next string) {
+	if opts.Limit == 0 {
+		h.respondJSON(w, items)
+		return
+	}
+
+	if next != "" {
+		u := *r.URL
+		query := u.Query()
+		query.Set("cursor", next)
+		u.RawQuery = query.Encode()
+		w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, u.String()))
+	}
+	h.respondJSON(w, listPage{Items: items, NextCursor: next})
+}
+
+// listFailed writes the error of a list query: a 400 for a bad sort or
+// cursor, a 500 otherwise
+func (h *APIHandler) listFailed(w http.ResponseWriter, what string, err error) {
+	switch {
+	case errors.Is(err, storage.ErrInvalidSort):
+		httpError(w, "Invalid sort", http.StatusBadRequest)
+	case errors.Is(err, storage.ErrInvalidCursor):
+		httpError(w, "Invalid cursor", http.StatusBadRequest)
+	default:
+		h.logger.Printf("Error listing %s: %v", what, err)
+		httpError(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
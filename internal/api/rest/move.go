@@ -0,0 +1,176 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rand/cartographer/internal/domain"
+	"github.com/rand/cartographer/internal/storage"
+)
+
+// moveRequest is the body of a task move: the column to move to and the
+// position in it, 0 being the top. Without a position the task goes to the
+// bottom.
+type moveRequest struct {
+	Column   string `json:"column"`
+	Position *int   `json:"position"`
+}
+
+// moveTask moves a task to a position in a column of its board, applying
+// the board's WIP limit policy if it enters a new column
+func (h *APIHandler) moveTask(w http.ResponseWriter, r *http.Request, id string) {
+	var req moveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	existing, err := h.tasks.GetByID(id)
+	if err != nil {
+		httpError(w, "Task not found", http.StatusNotFound)
+		return
+	}
+	if !checkIfMatch(w, r, existing.Revision) {
+		return
+	}
+
+	board, err := h.boards.GetByID(existing.BoardID)
+	if err != nil {
+		h.logger.Printf("Error getting board for task move: %v", err)
+		httpError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Without If-Match the move applies whatever else changed meanwhile
+	var revision int64
+	if hasIfMatch(r) {
+		revision = existing.Revision
+	}
+	move, changes, err := h.applyMove(r, h.tasks, board, existing, req.Column, req.Position, revision)
+	if err != nil {
+		h.respondError(w, r, "moving task", err)
+		return
+	}
+	task := move.Task
+
+	h.recordAudit(r, domain.EntityTask, task.ID, board.ProjectID, domain.AuditUpdated, moveChanges(existing, task, changes))
+
+	// Broadcast task move via WebSocket
+	if h.wsHub != nil {
+		h.wsHub.BroadcastTaskMoved(task.ID, task.BoardID, move.From, move.To, move.Position, task)
+	}
+
+	// Write the new status back to the bead the task was imported from
+	if h.beadsSync != nil && len(changes) > 0 {
+		if _, err := h.beadsSync.PushTask(task.ID); err != nil {
+			h.logger.Printf("Error syncing task %s to beads: %v", task.ID, err)
+		}
+	}
+
+	setETag(w, task.Revision)
+	h.respondJSON(w, move)
+}
+
+// applyMove moves a task of board to position in the column with the given
+// ID, "" meaning its current one, expecting it at revision (0 for any). It
+// returns the move and the changes to the task's fields, recorded in its
+// activity if it changed column.
+func (h *APIHandler) applyMove(r *http.Request, tasks *storage.TaskRepository, board *domain.Board, existing *domain.Task, columnID string, position *int, revision int64) (*domain.TaskMove, domain.Changes, error) {
+	if columnID == "" {
+		columnID = existing.Status
+	}
+	column := board.Column(columnID)
+	if column == nil {
+		return nil, nil, &requestError{http.StatusBadRequest, "Unknown column: " + columnID}
+	}
+	index := -1
+	if position != nil {
+		index = *position
+	}
+
+	task := *existing
+	task.Status = column.ID
+	task.Activity = append([]domain.ActivityEntry{}, existing.Activity...)
+	task.Revision = revision
+	changes := domain.RecordTaskUpdate(existing, &task, activityUser(r), time.Now())
+
+	limit := 0
+	if board.EnforcesWIPLimits() {
+		limit = column.WIPLimit
+	}
+	index, size, err := tasks.Move(&task, task.Activity[len(existing.Activity):], index, limit)
+	if errors.Is(err, storage.ErrWIPLimitExceeded) {
+		return nil, nil, wipLimitError(column)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	move := &domain.TaskMove{Task: &task, From: existing.Status, To: task.Status, Position: index}
+	if task.Status != existing.Status && column.OverWIPLimit(size) {
+		move.Warnings = append(move.Warnings, column.WIPWarning(size))
+	}
+	return move, changes, nil
+}
+
+// moveChanges returns the changes to audit for a move: the changed fields
+// and the rank
+func moveChanges(before, after *domain.Task, changes domain.Changes) domain.Changes {
+	audited := domain.Changes{"rank": {From: before.Rank, To: after.Rank}}
+	for name, change := range changes {
+		audited[name] = change
+	}
+	return audited
+}
+
+// allowWIPLimit reports whether a task may enter the column of its board
+// given by status, responding with 409 if the board enforces WIP limits and
+// the column is full
+func (h *APIHandler) allowWIPLimit(w http.ResponseWriter, boardID, status string) bool {
+	board, err := h.boards.GetByID(boardID)
+	if err != nil {
+		return true
+	}
+
+	err = checkWIPLimit(h.tasks, board, status)
+	var reqErr *requestError
+	if errors.As(err, &reqErr) {
+		httpError(w, reqErr.message, reqErr.status)
+		return false
+	}
+	if err != nil {
+		h.logger.Printf("Error counting tasks in column %s: %v", status, err)
+		httpError(w, "Internal server error", http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
+
+// checkWIPLimit returns an error if the board enforces WIP limits and its
+// column given by status is full
+func checkWIPLimit(tasks *storage.TaskRepository, board *domain.Board, status string) error {
+	if !board.EnforcesWIPLimits() {
+		return nil
+	}
+	column := board.Column(status)
+	if column == nil || column.WIPLimit <= 0 {
+		return nil
+	}
+
+	n, err := tasks.CountInColumn(board.ID, status)
+	if err != nil {
+		return err
+	}
+	if n >= column.WIPLimit {
+		return wipLimitError(column)
+	}
+	return nil
+}
+
+// wipLimitError reports a column that is at its WIP limit
+func wipLimitError(column *domain.BoardColumn) error {
+	return &requestError{http.StatusConflict, fmt.Sprintf("Column %s is at its WIP limit of %d", column.Name, column.WIPLimit)}
+}
@@ -0,0 +1,24 @@
+package rest
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// openAPIPath is where the OpenAPI document is served. It is public, like
+// the health check, so clients can discover the API before they have a
+// token.
+const openAPIPath = "/api/openapi.json"
+
+// openAPISpec is the OpenAPI 3 document describing every route in
+// Register. It is maintained by hand; TestOpenAPISpec fails if a route or
+// a field of a domain type is missing from it.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// handleOpenAPI serves the OpenAPI document
+func (h *APIHandler) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPISpec)
+}
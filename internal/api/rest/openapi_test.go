@@ -0,0 +1,196 @@
+package rest
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rand/cartographer/internal/beads"
+	"github.com/rand/cartographer/internal/domain"
+)
+
+// openAPIDoc is the part of an OpenAPI document the spec test checks
+type openAPIDoc struct {
+	OpenAPI    string                                `json:"openapi"`
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components map[string]map[string]json.RawMessage `json:"components"`
+}
+
+type openAPISchema struct {
+	Ref        string                     `json:"$ref"`
+	Properties map[string]json.RawMessage `json:"properties"`
+	AllOf      []openAPISchema            `json:"allOf"`
+}
+
+// properties returns the names of a schema's properties, including those
+// of the schemas it refers to or is composed of
+func (s openAPISchema) properties(doc *openAPIDoc) map[string]bool {
+	names := make(map[string]bool)
+	if s.Ref != "" {
+		var target openAPISchema
+		json.Unmarshal(doc.Components["schemas"][strings.TrimPrefix(s.Ref, "#/components/schemas/")], &target)
+		return target.properties(doc)
+	}
+	for name := range s.Properties {
+		names[name] = true
+	}
+	for _, part := range s.AllOf {
+		for name := range part.properties(doc) {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+func TestOpenAPISpec(t *testing.T) {
+	h := NewAPIHandler(Config{Logger: log.New(io.Discard, "", 0)})
+	mux := http.NewServeMux()
+	h.Register(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("expected the JSON document, got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	var doc openAPIDoc
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid OpenAPI document: %v", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Errorf("expected OpenAPI 3, got %q", doc.OpenAPI)
+	}
+
+	// Every registered route is documented, and every documented one is
+	// registered
+	registered := make(map[string]bool)
+	for _, pattern := range h.routes {
+		method, path, _ := strings.Cut(pattern, " ")
+		registered[strings.ToLower(method)+" "+path] = true
+		if _, ok := doc.Paths[path][strings.ToLower(method)]; !ok {
+			t.Errorf("route %s is missing from the spec", pattern)
+		}
+	}
+	for path, item := range doc.Paths {
+		for method := range item {
+			if method != "parameters" && !registered[method+" "+path] {
+				t.Errorf("spec documents %s %s, which isn't a route", strings.ToUpper(method), path)
+			}
+		}
+	}
+
+	// Every $ref points at a component
+	var refs func(v interface{})
+	refs = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if ref, ok := v["$ref"].(string); ok {
+				kind, name, _ := strings.Cut(strings.TrimPrefix(ref, "#/components/"), "/")
+				if _, ok := doc.Components[kind][name]; !ok {
+					t.Errorf("unresolved $ref %s", ref)
+				}
+			}
+			for _, child := range v {
+				refs(child)
+			}
+		case []interface{}:
+			for _, child := range v {
+				refs(child)
+			}
+		}
+	}
+	var raw interface{}
+	json.Unmarshal(rec.Body.Bytes(), &raw)
+	refs(raw)
+
+	// Schemas have exactly the JSON fields of the types they describe
+	types := map[string]interface{}{
+		"Project":             domain.Project{},
+		"ProjectSettings":     domain.ProjectSettings{},
+		"ProjectMetadata":     domain.ProjectMetadata{},
+		"Board":               domain.Board{},
+		"BoardColumn":         domain.BoardColumn{},
+		"Task":                domain.Task{},
+		"Assignee":            domain.Assignee{},
+		"LinkedItem":          domain.LinkedItem{},
+		"ChecklistItem":       domain.ChecklistItem{},
+		"User":                domain.User{},
+		"ActivityEntry":       domain.ActivityEntry{},
+		"Document":            domain.Document{},
+		"DocumentVersion":     domain.DocumentVersion{},
+		"Diagram":             domain.Diagram{},
+		"DiagramVersion":      domain.DiagramVersion{},
+		"DiffLine":            domain.DiffLine{},
+		"TaskMove":            domain.TaskMove{},
+		"SearchHit":           domain.SearchHit{},
+		"AuditEntry":          domain.AuditEntry{},
+		"FieldChange":         domain.FieldChange{},
+		"FieldError":          domain.FieldError{},
+		"APIToken":            domain.APIToken{},
+		"BeadFields":          domain.BeadFields{},
+		"BeadSyncState":       domain.BeadSyncState{},
+		"BeadSyncConflict":    domain.BeadSyncConflict{},
+		"Schedule":            beads.Schedule{},
+		"ScheduleEntry":       beads.ScheduleEntry{},
+		"SyncReport":          beads.SyncReport{},
+		"ImportReport":        beads.ImportReport{},
+		"ImportSkip":          beads.ImportSkip{},
+		"Error":               errorResponse{},
+		"MoveRequest":         moveRequest{},
+		"BatchRequest":        batchRequest{},
+		"BatchOperation":      batchOperation{},
+		"BatchResult":         batchResult{},
+		"BatchResponse":       batchResponse{},
+		"CreateTokenRequest":  createTokenRequest{},
+		"CreateTokenResponse": createTokenResponse{},
+	}
+	for name, v := range types {
+		raw, ok := doc.Components["schemas"][name]
+		if !ok {
+			t.Errorf("schema %s is missing", name)
+			continue
+		}
+		var schema openAPISchema
+		if err := json.Unmarshal(raw, &schema); err != nil {
+			t.Fatalf("invalid schema %s: %v", name, err)
+		}
+		documented := schema.properties(&doc)
+		for _, field := range jsonFields(reflect.TypeOf(v)) {
+			if !documented[field] {
+				t.Errorf("field %s of %T is missing from schema %s", field, v, name)
+			}
+			delete(documented, field)
+		}
+		for field := range documented {
+			t.Errorf("schema %s documents %s, which %T doesn't have", name, field, v)
+		}
+	}
+}
+
+// jsonFields returns the names of the JSON fields of a struct type,
+// including those of embedded structs
+func jsonFields(t reflect.Type) []string {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		switch {
+		case name == "-":
+		case f.Anonymous && name == "":
+			names = append(names, jsonFields(f.Type)...)
+		case f.IsExported():
+			if name == "" {
+				name = f.Name
+			}
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,337 @@
+package rest
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/rand/cartographer/internal/domain"
+)
+
+// mergePatchType is the media type of RFC 7396 JSON merge patches
+const mergePatchType = "application/merge-patch+json"
+
+// maxPatchSize bounds the size of a merge patch body
+const maxPatchSize = 1 << 20
+
+// readMergePatch reads a merge patch request body, responding with an error
+// if it isn't one
+func readMergePatch(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil || (mediaType != mergePatchType && mediaType != "application/json") {
+			w.Header().Set("Accept-Patch", mergePatchType)
+			httpError(w, "Unsupported patch type, use "+mergePatchType, http.StatusUnsupportedMediaType)
+			return nil, false
+		}
+	}
+
+	patch, err := io.ReadAll(io.LimitReader(r.Body, maxPatchSize))
+	if err != nil {
+		httpError(w, "Invalid request body", http.StatusBadRequest)
+		return nil, false
+	}
+	if _, err := domain.PatchFields(patch); err != nil {
+		httpError(w, "Invalid merge patch: "+err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	return patch, true
+}
+
+// applyMergePatch patches target, a copy of before, and responds with an
+// error if the patch is invalid or changes one of the read-only fields
+func applyMergePatch(w http.ResponseWriter, patch []byte, before, target interface{}, readOnly []string) bool {
+	if err := mergePatch(patch, before, target, readOnly); err != nil {
+		httpError(w, err.message, err.status)
+		return false
+	}
+	return true
+}
+
+// mergePatch patches target, a copy of before, returning an error if the
+// patch is invalid or changes one of the read-only fields
+func mergePatch(patch []byte, before, target interface{}, readOnly []string) *requestError {
+	if err := domain.ApplyMergePatch(target, patch); err != nil {
+		return &requestError{http.StatusBadRequest, "Invalid merge patch: " + err.Error()}
+	}
+
+	changes := domain.DiffFields(before, target)
+	for _, field := range readOnly {
+		if _, changed := changes[field]; changed {
+			return &requestError{http.StatusBadRequest, fmt.Sprintf("Field %s is read-only", field)}
+		}
+	}
+	return nil
+}
+
+// patchesField reports whether a merge patch sets or removes field
+func patchesField(patch []byte, field string) bool {
+	fields, _ := domain.PatchFields(patch)
+	for _, name := range fields {
+		if name == field {
+			return true
+		}
+	}
+	return false
+}
+
+// changedFields returns the names of the changed fields in a stable order
+func changedFields(changes domain.Changes) []string {
+	fields := make([]string, 0, len(changes))
+	for name := range changes {
+		fields = append(fields, name)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// Read-only fields of each patchable resource
+var (
+	projectReadOnly  = []string{"id", "created_at", "updated_at", "revision"}
+	boardReadOnly    = []string{"id", "project_id", "created_at", "updated_at", "revision"}
+	taskReadOnly     = []string{"id", "board_id", "rank", "created_at", "updated_at", "revision", "created_by"}
+	documentReadOnly = []string{"id", "project_id", "created_at", "updated_at", "revision", "versions"}
+)
+
+func (h *APIHandler) patchProject(w http.ResponseWriter, r *http.Request, id string) {
+	patch, ok := readMergePatch(w, r)
+	if !ok {
+		return
+	}
+
+	previous, err := h.projects.GetByID(id)
+	if err != nil {
+		httpError(w, "Project not found", http.StatusNotFound)
+		return
+	}
+	if !checkIfMatch(w, r, previous.Revision) {
+		return
+	}
+	project := *previous
+	if !applyMergePatch(w, patch, previous, &project, projectReadOnly) {
+		return
+	}
+	if err := project.Validate(); err != nil {
+		h.respondError(w, r, "validating project", err)
+		return
+	}
+
+	changes := domain.DiffFields(previous, &project, projectReadOnly...)
+	if len(changes) == 0 {
+		setETag(w, previous.Revision)
+		h.respondJSON(w, previous)
+		return
+	}
+
+	// Without If-Match only the changed fields are written, so concurrent
+	// patches of different fields both apply
+	if !hasIfMatch(r) {
+		project.Revision = 0
+	}
+	if err := h.projects.UpdateFields(&project, changedFields(changes)); err != nil {
+		h.respondError(w, r, "patching project", err)
+		return
+	}
+
+	h.recordAudit(r, domain.EntityProject, project.ID, project.ID, domain.AuditUpdated, changes)
+
+	// Broadcast project update via WebSocket
+	if h.wsHub != nil {
+		h.wsHub.BroadcastProjectUpdated(project.ID, changes.Values(), &project)
+	}
+
+	setETag(w, project.Revision)
+	h.respondJSON(w, project)
+}
+
+func (h *APIHandler) patchBoard(w http.ResponseWriter, r *http.Request, id string) {
+	patch, ok := readMergePatch(w, r)
+	if !ok {
+		return
+	}
+
+	previous, err := h.boards.GetByID(id)
+	if err != nil {
+		httpError(w, "Board not found", http.StatusNotFound)
+		return
+	}
+	if !checkIfMatch(w, r, previous.Revision) {
+		return
+	}
+	board := *previous
+	if !applyMergePatch(w, patch, previous, &board, boardReadOnly) {
+		return
+	}
+	if err := board.Validate(); err != nil {
+		h.respondError(w, r, "validating board", err)
+		return
+	}
+
+	changes := domain.DiffFields(previous, &board, boardReadOnly...)
+	if len(changes) == 0 {
+		setETag(w, previous.Revision)
+		h.respondJSON(w, previous)
+		return
+	}
+
+	// Without If-Match only the changed fields are written, so concurrent
+	// patches of different fields both apply
+	if !hasIfMatch(r) {
+		board.Revision = 0
+	}
+	if err := h.boards.UpdateFields(&board, changedFields(changes)); err != nil {
+		h.respondError(w, r, "patching board", err)
+		return
+	}
+
+	h.recordAudit(r, domain.EntityBoard, board.ID, board.ProjectID, domain.AuditUpdated, changes)
+
+	// Broadcast board update via WebSocket
+	if h.wsHub != nil {
+		h.wsHub.BroadcastBoardUpdated(board.ID, board.ProjectID, changes.Values(), &board)
+	}
+
+	setETag(w, board.Revision)
+	h.respondJSON(w, board)
+}
+
+func (h *APIHandler) patchTask(w http.ResponseWriter, r *http.Request, id string) {
+	patch, ok := readMergePatch(w, r)
+	if !ok {
+		return
+	}
+
+	existing, err := h.tasks.GetByID(id)
+	if err != nil {
+		httpError(w, "Task not found", http.StatusNotFound)
+		return
+	}
+	if !checkIfMatch(w, r, existing.Revision) {
+		return
+	}
+	task, changes, fields, reqErr := patchedTask(r, existing, patch)
+	if reqErr != nil {
+		httpError(w, reqErr.message, reqErr.status)
+		return
+	}
+	if err := h.validateTask(h.tasks, task); err != nil {
+		h.respondError(w, r, "validating task", err)
+		return
+	}
+	if task.Status != existing.Status && !h.allowWIPLimit(w, task.BoardID, task.Status) {
+		return
+	}
+	if len(fields) == 0 {
+		setETag(w, existing.Revision)
+		h.respondJSON(w, existing)
+		return
+	}
+
+	// Without If-Match only the changed fields are written and the activity
+	// is appended to, so concurrent patches of different fields both apply
+	if !hasIfMatch(r) {
+		task.Revision = 0
+	}
+
+	if err := h.tasks.UpdateFieldsAppending(task, fields, task.Activity[len(existing.Activity):]); err != nil {
+		h.respondError(w, r, "patching task", err)
+		return
+	}
+
+	if len(changes) > 0 {
+		h.recordAudit(r, domain.EntityTask, task.ID, h.boardProjectID(task.BoardID), domain.AuditUpdated, changes)
+	}
+
+	// Broadcast task update via WebSocket
+	if h.wsHub != nil {
+		h.wsHub.BroadcastTaskUpdated(task.ID, task.BoardID, changes.Values(), task)
+	}
+
+	// Write the edit back to the bead the task was imported from
+	if h.beadsSync != nil && len(changes) > 0 {
+		if _, err := h.beadsSync.PushTask(task.ID); err != nil {
+			h.logger.Printf("Error syncing task %s to beads: %v", task.ID, err)
+		}
+	}
+
+	setETag(w, task.Revision)
+	h.respondJSON(w, task)
+}
+
+// patchedTask applies a merge patch to a copy of a task, recording the
+// changes in its activity. It returns the patched task, the changed fields
+// and the fields to write, which include the activity if entries were added
+// to it. The added entries follow the existing ones in the task's activity,
+// to be appended with UpdateFieldsAppending.
+func patchedTask(r *http.Request, existing *domain.Task, patch []byte) (*domain.Task, domain.Changes, []string, *requestError) {
+	task := *existing
+	if err := mergePatch(patch, existing, &task, taskReadOnly); err != nil {
+		return nil, nil, nil, err
+	}
+
+	// The activity is server-owned, so rather than replacing it a patch's
+	// activity lists comments to add
+	now := time.Now()
+	var comments []domain.ActivityEntry
+	if patchesField(patch, "activity") {
+		comments = newComments(task.Activity, 0, now)
+	}
+	task.Activity = append(append([]domain.ActivityEntry{}, existing.Activity...), comments...)
+	attributeTask(r, &task, existing)
+
+	changes := domain.RecordTaskUpdate(existing, &task, activityUser(r), now)
+	fields := changedFields(changes)
+	if len(task.Activity) != len(existing.Activity) {
+		fields = append(fields, "activity")
+	}
+	return &task, changes, fields, nil
+}
+
+func (h *APIHandler) patchDocument(w http.ResponseWriter, r *http.Request, id string) {
+	patch, ok := readMergePatch(w, r)
+	if !ok {
+		return
+	}
+
+	previous, err := h.documents.GetByID(id)
+	if err != nil {
+		httpError(w, "Document not found", http.StatusNotFound)
+		return
+	}
+	if !checkIfMatch(w, r, previous.Revision) {
+		return
+	}
+	document := *previous
+	if !applyMergePatch(w, patch, previous, &document, documentReadOnly) {
+		return
+	}
+	if err := document.Validate(); err != nil {
+		h.respondError(w, r, "validating document", err)
+		return
+	}
+
+	changes := domain.DiffFields(previous, &document, documentReadOnly...)
+	if len(changes) == 0 {
+		setETag(w, previous.Revision)
+		h.respondJSON(w, previous)
+		return
+	}
+
+	// Without If-Match only the changed fields are written, so concurrent
+	// patches of different fields both apply
+	if !hasIfMatch(r) {
+		document.Revision = 0
+	}
+	if err := h.documents.UpdateFields(&document, changedFields(changes)); err != nil {
+		h.respondError(w, r, "patching document", err)
+		return
+	}
+
+	h.recordAudit(r, domain.EntityDocument, document.ID, document.ProjectID, domain.AuditUpdated, changes)
+
+	setETag(w, document.Revision)
+	h.respondJSON(w, document)
+}
@@ -0,0 +1,61 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rand/cartographer/internal/domain"
+)
+
+func TestInterleavedTaskPatches(t *testing.T) {
+	board := &domain.Board{Name: "Main", Columns: []domain.BoardColumn{{ID: "todo", Name: "To Do"}}}
+	tasks := newTestAPI(t, &domain.Project{Name: "Patch", Path: "/tmp/patch"}, board).tasks
+	existing := &domain.Task{BoardID: board.ID, Title: "Parser", Status: "todo", Priority: "low"}
+	if err := tasks.Create(existing); err != nil {
+		t.Fatalf("failed to create task: %v", err)
+	}
+
+	// Both patches read the task before either writes, as concurrent
+	// requests without If-Match do
+	r := httptest.NewRequest(http.MethodPatch, "/api/tasks/"+existing.ID, nil)
+	type write struct {
+		task   *domain.Task
+		fields []string
+	}
+	var writes []write
+	for _, patch := range []string{
+		`{"title": "Lexer"}`,
+		`{"priority": "high", "activity": [{"type": "commented", "comment": "Bumped"}]}`,
+	} {
+		task, _, fields, reqErr := patchedTask(r, existing, []byte(patch))
+		if reqErr != nil {
+			t.Fatalf("patch %s: %s", patch, reqErr.message)
+		}
+		task.Revision = 0
+		writes = append(writes, write{task, fields})
+	}
+	for _, w := range writes {
+		if err := tasks.UpdateFieldsAppending(w.task, w.fields, w.task.Activity[len(existing.Activity):]); err != nil {
+			t.Fatalf("UpdateFieldsAppending failed: %v", err)
+		}
+	}
+
+	found, err := tasks.GetByID(existing.ID)
+	if err != nil {
+		t.Fatalf("GetByID failed: %v", err)
+	}
+	if found.Title != "Lexer" || found.Priority != "high" {
+		t.Errorf("expected both edits kept, got %+v", found)
+	}
+	var types []string
+	for _, entry := range found.Activity {
+		types = append(types, entry.Type)
+	}
+	if len(found.Activity) != len(existing.Activity)+3 {
+		t.Fatalf("expected both patches' activity kept, got %v", types)
+	}
+	if len(writes[1].task.Activity) != len(found.Activity) {
+		t.Errorf("expected the last writer to see the stored activity, got %d entries", len(writes[1].task.Activity))
+	}
+}
@@ -0,0 +1,208 @@
+package rest
+
+import (
+	"net/http"
+
+	"github.com/rand/cartographer/internal/api/websocket"
+)
+
+// Register registers all API routes. Routes match a method and a path, so
+// a request for a known path with another method gets 405 Method Not
+// Allowed and an Allow header listing the methods it has.
+func (h *APIHandler) Register(mux *http.ServeMux) {
+	api := &routeMux{ServeMux: http.NewServeMux()}
+
+	// Projects
+	api.HandleFunc("GET /api/projects", h.listProjects)
+	api.HandleFunc("POST /api/projects", h.allProjectsOnly(h.createProject))
+	api.HandleFunc("GET /api/projects/{id}", withID(h.allowProject, h.getProject))
+	api.HandleFunc("PUT /api/projects/{id}", withID(h.allowProject, h.updateProject))
+	api.HandleFunc("PATCH /api/projects/{id}", withID(h.allowProject, h.patchProject))
+	api.HandleFunc("DELETE /api/projects/{id}", withID(h.allowProject, h.deleteProject))
+	api.HandleFunc("GET /api/projects/{id}/context", withID(h.allowProject, h.projectContext))
+
+	// Boards
+	api.HandleFunc("GET /api/boards", withParam("project_id", h.allowProject, h.listBoards))
+	api.HandleFunc("POST /api/boards", withBody(h.createBoard))
+	api.HandleFunc("GET /api/projects/{id}/boards", withID(h.allowProject, h.listBoards))
+	api.HandleFunc("POST /api/projects/{id}/boards", withParent(h.createBoard))
+	api.HandleFunc("GET /api/boards/{id}", withID(h.allowBoard, h.getBoard))
+	api.HandleFunc("PUT /api/boards/{id}", withID(h.allowBoard, h.updateBoard))
+	api.HandleFunc("PATCH /api/boards/{id}", withID(h.allowBoard, h.patchBoard))
+	api.HandleFunc("DELETE /api/boards/{id}", withID(h.allowBoard, h.deleteBoard))
+	api.HandleFunc("POST /api/boards/{id}/import/beads", withID(h.allowBoard, h.importBeads))
+	api.HandleFunc("GET /api/boards/{id}/critical-path", withID(h.allowBoard, h.boardCriticalPath))
+
+	// Tasks
+	api.HandleFunc("GET /api/tasks", withParam("board_id", h.allowBoard, h.listTasks))
+	api.HandleFunc("POST /api/tasks", withBody(h.createTask))
+	api.HandleFunc("GET /api/boards/{id}/tasks", withID(h.allowBoard, h.listTasks))
+	api.HandleFunc("POST /api/boards/{id}/tasks", withParent(h.createTask))
+	api.HandleFunc("POST /api/tasks/batch", h.handleTaskBatch)
+	api.HandleFunc("GET /api/tasks/{id}", withID(h.allowTask, h.getTask))
+	api.HandleFunc("PUT /api/tasks/{id}", withID(h.allowTask, h.updateTask))
+	api.HandleFunc("PATCH /api/tasks/{id}", withID(h.allowTask, h.patchTask))
+	api.HandleFunc("DELETE /api/tasks/{id}", withID(h.allowTask, h.deleteTask))
+	api.HandleFunc("PATCH /api/tasks/{id}/move", withID(h.allowTask, h.moveTask))
+
+	// Documents
+	api.HandleFunc("GET /api/documents", withParam("project_id", h.allowProject, h.listDocuments))
+	api.HandleFunc("POST /api/documents", withBody(h.createDocument))
+	api.HandleFunc("GET /api/projects/{id}/docs", withID(h.allowProject, h.listDocuments))
+	api.HandleFunc("POST /api/projects/{id}/docs", withParent(h.createDocument))
+	api.HandleFunc("GET /api/documents/search", h.handleDocumentSearch)
+	api.HandleFunc("GET /api/documents/{id}", withID(h.allowDocument, h.getDocument))
+	api.HandleFunc("PUT /api/documents/{id}", withID(h.allowDocument, h.updateDocument))
+	api.HandleFunc("PATCH /api/documents/{id}", withID(h.allowDocument, h.patchDocument))
+	api.HandleFunc("DELETE /api/documents/{id}", withID(h.allowDocument, h.deleteDocument))
+
+	// Diagrams
+	api.HandleFunc("GET /api/diagrams", withParam("project_id", h.allowProject, h.listDiagrams))
+	api.HandleFunc("POST /api/diagrams", withBody(h.createDiagram))
+	api.HandleFunc("GET /api/projects/{id}/diagrams", withID(h.allowProject, h.listDiagrams))
+	api.HandleFunc("POST /api/projects/{id}/diagrams", withParent(h.createDiagram))
+	api.HandleFunc("GET /api/diagrams/{id}", withID(h.allowDiagram, h.getDiagram))
+	api.HandleFunc("PUT /api/diagrams/{id}", withID(h.allowDiagram, h.updateDiagram))
+	api.HandleFunc("DELETE /api/diagrams/{id}", withID(h.allowDiagram, h.deleteDiagram))
+	api.HandleFunc("GET /api/diagrams/{id}/versions", withID(h.allowDiagram, h.listDiagramVersions))
+	api.HandleFunc("GET /api/diagrams/{id}/diff", withID(h.allowDiagram, h.diffDiagram))
+
+	// Beads come from the server's own issues file rather than a project
+	api.HandleFunc("GET /api/beads/issues", h.allProjectsOnly(h.handleBeadsIssues))
+	api.HandleFunc("GET /api/beads/issues/{id}", h.allProjectsOnly(h.handleBeadsIssue))
+	api.HandleFunc("GET /api/beads/graph", h.allProjectsOnly(h.handleBeadsGraph))
+	api.HandleFunc("GET /api/beads/stats", h.allProjectsOnly(h.handleBeadsStats))
+	api.HandleFunc("GET /api/beads/critical-path", h.allProjectsOnly(h.handleBeadsCriticalPath))
+	api.HandleFunc("POST /api/beads/sync", h.allProjectsOnly(h.handleBeadsSync))
+	api.HandleFunc("GET /api/beads/sync/conflicts", h.allProjectsOnly(h.handleBeadsConflicts))
+	api.HandleFunc("POST /api/beads/sync/conflicts/{id}", h.allProjectsOnly(h.handleBeadsConflict))
+
+	// Search
+	api.HandleFunc("GET /api/search", h.handleSearch)
+
+	// API tokens, managed only by admin tokens for every project, so a
+	// token can't mint one broader than itself
+	api.HandleFunc("GET /api/tokens", h.allProjectsOnly(h.listTokens))
+	api.HandleFunc("POST /api/tokens", h.allProjectsOnly(h.createToken))
+	api.HandleFunc("DELETE /api/tokens/{id}", h.allProjectsOnly(h.revokeToken))
+
+	// Audit log
+	api.HandleFunc("GET /api/audit", h.handleAudit)
+
+	// Work queue: agents claim ready work and heartbeat to keep it
+	api.HandleFunc("POST /api/work/claim", h.claimWork)
+	api.HandleFunc("GET /api/work/leases", h.listLeases)
+	api.HandleFunc("POST /api/work/leases/{id}/heartbeat", withID(h.allowLease, h.heartbeatLease))
+	api.HandleFunc("DELETE /api/work/leases/{id}", withID(h.allowLease, h.releaseLease))
+
+	// Claude sessions: what an agent did, for the next session to resume
+	api.HandleFunc("POST /api/claude/session/start", h.startSession)
+	api.HandleFunc("GET /api/claude/sessions", withParam("project_id", h.allowProject, h.listSessions))
+	api.HandleFunc("POST /api/claude/session/{id}/event", withID(h.allowSession, h.recordSessionEvent))
+	api.HandleFunc("GET /api/claude/session/{id}/history", withID(h.allowSession, h.sessionHistory))
+
+	// Events from other processes, like `cartographer mcp`, to broadcast
+	api.HandleFunc("POST "+websocket.RelayPath, h.allProjectsOnly(h.relayEvent))
+
+	// API description
+	api.HandleFunc("GET "+openAPIPath, h.handleOpenAPI)
+
+	h.routes = api.patterns
+	mux.Handle("/api/", routeErrors(api.ServeMux))
+}
+
+// routeMux is a ServeMux that remembers its patterns, so they can be
+// checked against the OpenAPI document
+type routeMux struct {
+	*http.ServeMux
+	patterns []string
+}
+
+func (m *routeMux) HandleFunc(pattern string, handler http.HandlerFunc) {
+	m.ServeMux.HandleFunc(pattern, handler)
+	m.patterns = append(m.patterns, pattern)
+}
+
+// idHandler handles a request for the resource with the given ID, or for
+// the resources nested in it
+type idHandler func(w http.ResponseWriter, r *http.Request, id string)
+
+// accessCheck writes a 403 and returns false if the request may not access
+// the resource with the given ID, like allowProject
+type accessCheck func(w http.ResponseWriter, r *http.Request, id string) bool
+
+// withID adapts h to a route with an {id} wildcard, if allow lets the
+// request access the resource with that ID
+func withID(allow accessCheck, h idHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		if allow(w, r, id) {
+			h(w, r, id)
+		}
+	}
+}
+
+// withParam adapts h to a flat route naming the parent resource in the
+// required query parameter name, as in GET /api/boards?project_id=
+func withParam(name string, allow accessCheck, h idHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.URL.Query().Get(name)
+		if id == "" {
+			httpError(w, name+" parameter required", http.StatusBadRequest)
+			return
+		}
+		if allow(w, r, id) {
+			h(w, r, id)
+		}
+	}
+}
+
+// withParent adapts the create handler of a nested resource to its nested
+// route, where the path names the parent. The handler checks access to the
+// parent itself.
+func withParent(h idHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		h(w, r, r.PathValue("id"))
+	}
+}
+
+// withBody adapts the create handler of a nested resource to its flat
+// route, where the body names the parent
+func withBody(h idHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		h(w, r, "")
+	}
+}
+
+// routeErrors serves mux, writing the 404 and 405 responses the mux makes
+// itself, for requests no route matches, in the JSON error envelope. A 405
+// keeps the Allow header set by the mux.
+func routeErrors(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, pattern := mux.Handler(r); pattern == "" {
+			w = &routeErrorWriter{ResponseWriter: w}
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// routeErrorWriter replaces the plain text error written by the mux
+type routeErrorWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *routeErrorWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	writeError(w.ResponseWriter, status, errorResponse{Code: errorCode(status), Message: http.StatusText(status)})
+}
+
+func (w *routeErrorWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return len(b), nil
+}
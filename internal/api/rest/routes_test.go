@@ -0,0 +1,82 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rand/cartographer/internal/domain"
+)
+
+func TestRoutes(t *testing.T) {
+	project := &domain.Project{Name: "Routes", Path: "/tmp/routes"}
+	api := newTestAPI(t, project, nil)
+
+	request := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		api.mux.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+
+	// Nested create takes the parent from the path, overriding the body
+	rec := request(http.MethodPost, "/api/projects/"+project.ID+"/boards", `{"project_id": "other", "name": "Main", "columns": [{"id": "todo", "name": "To Do"}]}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("nested board create: expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var board domain.Board
+	if err := json.Unmarshal(rec.Body.Bytes(), &board); err != nil {
+		t.Fatalf("invalid board: %v", err)
+	}
+	if board.ProjectID != project.ID {
+		t.Errorf("expected board in project %s, got %s", project.ID, board.ProjectID)
+	}
+
+	rec = request(http.MethodPost, "/api/boards/"+board.ID+"/tasks", `{"title": "Nested", "status": "todo"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("nested task create: expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	for _, path := range []string{"/api/boards/" + board.ID + "/tasks", "/api/tasks?board_id=" + board.ID} {
+		rec = request(http.MethodGet, path, "")
+		var tasks []domain.Task
+		if err := json.Unmarshal(rec.Body.Bytes(), &tasks); err != nil || len(tasks) != 1 || tasks[0].Title != "Nested" {
+			t.Errorf("GET %s: expected the nested task, got %d %s", path, rec.Code, rec.Body.String())
+		}
+	}
+
+	rec = request(http.MethodGet, "/api/projects/"+project.ID+"/boards", "")
+	var boards []domain.Board
+	if err := json.Unmarshal(rec.Body.Bytes(), &boards); err != nil || len(boards) != 1 {
+		t.Errorf("expected the project's board, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		status int
+		allow  string
+	}{
+		{"extra segment", http.MethodGet, "/api/tasks/abc/anything", http.StatusNotFound, ""},
+		{"unknown route", http.MethodGet, "/api/nothing", http.StatusNotFound, ""},
+		{"missing parent", http.MethodGet, "/api/tasks", http.StatusBadRequest, ""},
+		{"item method", http.MethodPost, "/api/projects/" + project.ID, http.StatusMethodNotAllowed, "DELETE, GET, HEAD, PATCH, PUT"},
+		{"sub-resource method", http.MethodGet, "/api/tasks/abc/move", http.StatusMethodNotAllowed, "PATCH"},
+		{"collection method", http.MethodDelete, "/api/projects/" + project.ID + "/docs", http.StatusMethodNotAllowed, "GET, HEAD, POST"},
+	}
+	for _, tt := range tests {
+		rec := request(tt.method, tt.path, "")
+		if rec.Code != tt.status {
+			t.Errorf("%s: expected %d, got %d: %s", tt.name, tt.status, rec.Code, rec.Body.String())
+		}
+		if allow := rec.Header().Get("Allow"); allow != tt.allow {
+			t.Errorf("%s: expected Allow %q, got %q", tt.name, tt.allow, allow)
+		}
+		var resp errorResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.Code == "" {
+			t.Errorf("%s: expected a JSON error, got %q", tt.name, rec.Body.String())
+		}
+	}
+}
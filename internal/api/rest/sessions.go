@@ -0,0 +1,143 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/rand/cartographer/internal/domain"
+)
+
+// defaultSessionAgent names the agent of sessions started without
+// authentication or an agent
+const defaultSessionAgent = "claude"
+
+// startSessionRequest starts a Claude session on a project
+type startSessionRequest struct {
+	ProjectID  string `json:"project_id"`
+	BoardID    string `json:"board_id,omitempty"` // where tasks from tagged events go
+	Agent      string `json:"agent,omitempty"`    // agent ID when authentication is disabled
+	Title      string `json:"title,omitempty"`
+	PreviousID string `json:"previous_session_id,omitempty"`
+}
+
+// startSession starts a session. It resumes the session it names, or else
+// the agent's latest session on the project.
+func (h *APIHandler) startSession(w http.ResponseWriter, r *http.Request) {
+	var req startSessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.ProjectID == "" {
+		httpError(w, "project_id is required", http.StatusBadRequest)
+		return
+	}
+	if !h.allowProject(w, r, req.ProjectID) {
+		return
+	}
+	if _, err := h.projects.GetByID(req.ProjectID); err != nil {
+		h.respondError(w, r, "getting project", err)
+		return
+	}
+	if req.Agent == "" {
+		req.Agent = defaultSessionAgent
+	}
+	agent, _ := requestAgent(r, req.Agent)
+
+	session := &domain.Session{
+		ProjectID:  req.ProjectID,
+		BoardID:    req.BoardID,
+		Agent:      agent,
+		Title:      req.Title,
+		PreviousID: req.PreviousID,
+	}
+	if err := h.sessions.Start(session); err != nil {
+		h.respondError(w, r, "starting session", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	h.respondJSON(w, session)
+}
+
+// listSessions lists a project's sessions, newest first
+func (h *APIHandler) listSessions(w http.ResponseWriter, r *http.Request, projectID string) {
+	sessions, err := h.sessions.Sessions(projectID)
+	if err != nil {
+		h.respondError(w, r, "listing sessions", err)
+		return
+	}
+	h.respondJSON(w, sessions)
+}
+
+// recordSessionEvent records an event of a session. With authentication
+// enabled only the session's agent can record its events.
+func (h *APIHandler) recordSessionEvent(w http.ResponseWriter, r *http.Request, id string) {
+	var event domain.SessionEvent
+	if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+		httpError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	event.SessionID = id
+
+	if user := requestUser(r); user != nil {
+		session, err := h.sessions.Session(id)
+		if err != nil {
+			h.respondError(w, r, "getting session", err)
+			return
+		}
+		if session.Agent != *user {
+			httpError(w, "Session belongs to another agent", http.StatusForbidden)
+			return
+		}
+	}
+
+	recorded, err := h.sessions.Record(&event)
+	if err != nil {
+		h.respondError(w, r, "recording session event", err)
+		return
+	}
+
+	if task := recorded.Created; task != nil {
+		h.recordAudit(r, domain.EntityTask, task.ID, h.boardProjectID(task.BoardID), domain.AuditCreated, nil)
+		if h.wsHub != nil {
+			h.wsHub.BroadcastTaskCreated(task.ID, task.BoardID, task)
+		}
+	}
+	for _, task := range recorded.Linked {
+		// Linking appends one item
+		items := task.LinkedItems
+		changes := domain.Changes{"linked_items": {From: items[:len(items)-1], To: items}}
+		h.recordAudit(r, domain.EntityTask, task.ID, h.boardProjectID(task.BoardID), domain.AuditUpdated, changes)
+		if h.wsHub != nil {
+			h.wsHub.BroadcastTaskUpdated(task.ID, task.BoardID, changes.Values(), task)
+		}
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	h.respondJSON(w, recorded)
+}
+
+// sessionHistory returns a session's events with the files, tasks and notes
+// the next session resumes from
+func (h *APIHandler) sessionHistory(w http.ResponseWriter, r *http.Request, id string) {
+	history, err := h.sessions.History(id)
+	if err != nil {
+		h.respondError(w, r, "getting session history", err)
+		return
+	}
+	h.respondJSON(w, history)
+}
+
+// allowSession checks access to the project a session belongs to
+func (h *APIHandler) allowSession(w http.ResponseWriter, r *http.Request, sessionID string) bool {
+	if token := TokenFromContext(r.Context()); token == nil || !token.Restricted() {
+		return true
+	}
+	session, err := h.sessions.Session(sessionID)
+	if err != nil {
+		httpError(w, "Session not found", http.StatusNotFound)
+		return false
+	}
+	return h.allowProject(w, r, session.ProjectID)
+}
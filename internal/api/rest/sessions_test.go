@@ -0,0 +1,82 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/rand/cartographer/internal/claude"
+	"github.com/rand/cartographer/internal/domain"
+	"github.com/rand/cartographer/internal/storage"
+)
+
+func TestClaudeSessions(t *testing.T) {
+	project := &domain.Project{Name: "Sessions", Path: "/tmp/sessions"}
+	board := &domain.Board{Name: "Main", Columns: []domain.BoardColumn{{ID: "todo", Name: "To Do"}}}
+	api := newTestAPI(t, project, board)
+	task := &domain.Task{BoardID: board.ID, Title: "Parser", Status: "todo"}
+	if err := api.tasks.Create(task); err != nil {
+		t.Fatalf("failed to create task: %v", err)
+	}
+
+	if rec := api.request(http.MethodPost, "/api/claude/session/start", `{}`, nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("start without a project: expected 400, got %d", rec.Code)
+	}
+	if rec := api.request(http.MethodPost, "/api/claude/session/start", `{"project_id": "missing"}`, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("start on a missing project: expected 404, got %d", rec.Code)
+	}
+
+	rec := api.request(http.MethodPost, "/api/claude/session/start", `{"project_id": "`+project.ID+`", "title": "Parser work"}`, nil)
+	var session domain.Session
+	json.Unmarshal(rec.Body.Bytes(), &session)
+	if rec.Code != http.StatusCreated || session.Agent.ID != defaultSessionAgent {
+		t.Fatalf("start: got %d %s", rec.Code, rec.Body.String())
+	}
+	path := "/api/claude/session/" + session.ID
+
+	// Only the session's agent can record its events
+	other := &domain.APIToken{Scope: domain.ScopeWrite, User: domain.User{Type: "agent", ID: "other"}}
+	if rec := api.request(http.MethodPost, path+"/event", `{"type": "note", "text": "hi"}`, other); rec.Code != http.StatusForbidden {
+		t.Errorf("event by another agent: expected 403, got %d", rec.Code)
+	}
+	restricted := &domain.APIToken{Scope: domain.ScopeWrite, ProjectIDs: []string{"elsewhere"}}
+	if rec := api.request(http.MethodGet, path+"/history", "", restricted); rec.Code != http.StatusForbidden {
+		t.Errorf("history with a token for another project: expected 403, got %d", rec.Code)
+	}
+	if rec := api.request(http.MethodPost, path+"/event", `{"type": "tool_call"}`, nil); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("tool call without a tool: expected 422, got %d", rec.Code)
+	}
+
+	rec = api.request(http.MethodPost, path+"/event",
+		`{"type": "tool_call", "tool": "Edit", "files": ["parser.go"], "links": [{"type": "task", "id": "`+task.ID+`"}]}`, nil)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("tool call event: got %d %s", rec.Code, rec.Body.String())
+	}
+	entries, err := api.audit.List(storage.AuditQuery{Entity: domain.EntityTask, EntityID: task.ID})
+	if err != nil || len(entries) != 1 || entries[0].Action != domain.AuditUpdated {
+		t.Errorf("expected the link audited as a task update, got %+v (%v)", entries, err)
+	}
+
+	rec = api.request(http.MethodPost, path+"/event", `{"type": "note", "text": "Handle comments", "tags": ["task"]}`, nil)
+	var recorded claude.Recorded
+	json.Unmarshal(rec.Body.Bytes(), &recorded)
+	if rec.Code != http.StatusCreated || recorded.Created == nil || recorded.Event.Seq != 2 {
+		t.Fatalf("tagged event: got %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = api.request(http.MethodGet, path+"/history", "", nil)
+	var history claude.History
+	json.Unmarshal(rec.Body.Bytes(), &history)
+	if rec.Code != http.StatusOK || len(history.Events) != 2 || len(history.Tasks) != 2 || len(history.Files) != 1 {
+		t.Errorf("history: got %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = api.request(http.MethodGet, "/api/claude/sessions?project_id="+project.ID, "", nil)
+	var sessions []domain.Session
+	if err := json.Unmarshal(rec.Body.Bytes(), &sessions); err != nil || len(sessions) != 1 || sessions[0].EventCount != 2 {
+		t.Errorf("list sessions: got %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := api.request(http.MethodGet, "/api/claude/session/missing/history", "", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("history of a missing session: expected 404, got %d", rec.Code)
+	}
+}
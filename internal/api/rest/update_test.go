@@ -0,0 +1,43 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/rand/cartographer/internal/domain"
+)
+
+func TestPutKeepsProjectAndCreation(t *testing.T) {
+	project := &domain.Project{Name: "Diagrams", Path: "/tmp/diagrams"}
+	api := newTestAPI(t, project, nil)
+
+	for _, resource := range []struct {
+		collection, items, create, update string
+	}{
+		{"diagrams", "diagrams", `{"name": "Flow", "type": "mermaid", "content": "graph TD; A-->B"}`, `{"name": "Flow", "type": "mermaid", "content": "graph TD; A-->C"}`},
+		{"docs", "documents", `{"title": "Design", "content": "# Design"}`, `{"title": "Design", "content": "# Design v2"}`},
+	} {
+		var created struct {
+			ID        string    `json:"id"`
+			ProjectID string    `json:"project_id"`
+			CreatedAt time.Time `json:"created_at"`
+		}
+		rec := api.request(http.MethodPost, "/api/projects/"+project.ID+"/"+resource.collection, resource.create, nil)
+		if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil || rec.Code != http.StatusCreated {
+			t.Fatalf("create %s: got %d %s", resource.items, rec.Code, rec.Body.String())
+		}
+
+		// The body names neither the project nor the creation time
+		updated := created
+		updated.ProjectID, updated.CreatedAt = "", time.Time{}
+		rec = api.request(http.MethodPut, "/api/"+resource.items+"/"+created.ID, resource.update, nil)
+		if err := json.Unmarshal(rec.Body.Bytes(), &updated); err != nil || rec.Code != http.StatusOK {
+			t.Fatalf("update %s: got %d %s", resource.items, rec.Code, rec.Body.String())
+		}
+		if updated.ProjectID != project.ID || !updated.CreatedAt.Equal(created.CreatedAt) {
+			t.Errorf("update %s: expected project %s created at %v, got %+v", resource.items, project.ID, created.CreatedAt, updated)
+		}
+	}
+}
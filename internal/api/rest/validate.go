@@ -0,0 +1,95 @@
+package rest
+
+import (
+	"errors"
+
+	"github.com/rand/cartographer/internal/domain"
+	"github.com/rand/cartographer/internal/storage"
+)
+
+// fieldErrors returns the validation error in err to add more fields to,
+// or an empty one
+func fieldErrors(err error) *domain.ValidationError {
+	var invalid *domain.ValidationError
+	if errors.As(err, &invalid) {
+		return invalid
+	}
+	return &domain.ValidationError{}
+}
+
+// validateProjectRef adds a field error if the project doesn't exist
+func (h *APIHandler) validateProjectRef(v *domain.ValidationError, projectID string) error {
+	if projectID == "" {
+		return nil
+	}
+	_, err := h.projects.GetByID(projectID)
+	if errors.Is(err, storage.ErrNotFound) {
+		v.Add("project_id", "no such project: %s", projectID)
+		return nil
+	}
+	return err
+}
+
+// validateBoard checks a board and that its project exists
+func (h *APIHandler) validateBoard(board *domain.Board) error {
+	v := fieldErrors(board.Validate())
+	if err := h.validateProjectRef(v, board.ProjectID); err != nil {
+		return err
+	}
+	return v.Err()
+}
+
+// validateDocument checks a document and that its project exists
+func (h *APIHandler) validateDocument(document *domain.Document) error {
+	v := fieldErrors(document.Validate())
+	if err := h.validateProjectRef(v, document.ProjectID); err != nil {
+		return err
+	}
+	return v.Err()
+}
+
+// validateDiagram checks a diagram and that its project exists
+func (h *APIHandler) validateDiagram(diagram *domain.Diagram) error {
+	v := fieldErrors(diagram.Validate())
+	if err := h.validateProjectRef(v, diagram.ProjectID); err != nil {
+		return err
+	}
+	return v.Err()
+}
+
+// validateTask checks a task against its board, and that its board and the
+// tasks it refers to exist. tasks is the repository to look them up in,
+// which may be bound to a transaction that created some of them.
+func (h *APIHandler) validateTask(tasks *storage.TaskRepository, task *domain.Task) error {
+	var board *domain.Board
+	var lookupErr error
+	if task.BoardID != "" {
+		board, lookupErr = h.boards.GetByID(task.BoardID)
+		if errors.Is(lookupErr, storage.ErrNotFound) {
+			lookupErr = nil
+		}
+		if lookupErr != nil {
+			return lookupErr
+		}
+	}
+
+	v := fieldErrors(task.Validate(board))
+	if task.BoardID != "" && board == nil {
+		v.Add("board_id", "no such board: %s", task.BoardID)
+	}
+	task.EachReference(func(field, id string) {
+		if lookupErr != nil || id == task.ID {
+			return
+		}
+		_, err := tasks.GetByID(id)
+		if errors.Is(err, storage.ErrNotFound) {
+			v.Add(field, "no such task: %s", id)
+		} else if err != nil {
+			lookupErr = err
+		}
+	})
+	if lookupErr != nil {
+		return lookupErr
+	}
+	return v.Err()
+}
@@ -0,0 +1,367 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/rand/cartographer/internal/beads"
+	"github.com/rand/cartographer/internal/domain"
+	"github.com/rand/cartographer/internal/storage"
+	bd "github.com/steveyegge/beads"
+)
+
+// Lease durations an agent can ask for
+const (
+	DefaultLeaseDuration = 5 * time.Minute
+	MaxLeaseDuration     = time.Hour
+)
+
+// claimRequest asks for the next piece of ready work. Without a board or
+// project it covers every board the token can access, and beads issues.
+type claimRequest struct {
+	Agent        string `json:"agent,omitempty"` // agent ID when authentication is disabled
+	ProjectID    string `json:"project_id,omitempty"`
+	BoardID      string `json:"board_id,omitempty"`
+	Kind         string `json:"kind,omitempty"` // task or bead, both by default
+	LeaseSeconds int    `json:"lease_seconds,omitempty"`
+}
+
+// heartbeatRequest extends a lease
+type heartbeatRequest struct {
+	LeaseSeconds int `json:"lease_seconds,omitempty"`
+}
+
+// claimResponse is a new lease with the task or beads issue it covers
+type claimResponse struct {
+	Lease *domain.Lease `json:"lease"`
+	Task  *domain.Task  `json:"task,omitempty"`
+	Issue *bd.Issue     `json:"issue,omitempty"`
+}
+
+// workItem is a task or beads issue an agent could claim
+type workItem struct {
+	task     *domain.Task
+	issue    *bd.Issue
+	priority int // as in domain.PriorityRank
+}
+
+// lease returns an unsaved lease on the item for an agent
+func (item *workItem) lease(agent domain.User) *domain.Lease {
+	if item.issue != nil {
+		return &domain.Lease{Kind: domain.WorkBead, ItemID: item.issue.ID, Agent: agent}
+	}
+	return &domain.Lease{Kind: domain.WorkTask, ItemID: item.task.ID, BoardID: item.task.BoardID, Agent: agent}
+}
+
+// claimWork leases the highest priority ready task or beads issue that no
+// other agent holds, answering 201 with the lease or 204 if there is none. Ties go to tasks in
+// board order, then to issues in file order.
+func (h *APIHandler) claimWork(w http.ResponseWriter, r *http.Request) {
+	var req claimRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		httpError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	agent, ok := requestAgent(r, req.Agent)
+	if !ok {
+		httpError(w, "agent is required", http.StatusBadRequest)
+		return
+	}
+	if req.Kind != "" && req.Kind != domain.WorkTask && req.Kind != domain.WorkBead {
+		httpError(w, "kind must be task or bead", http.StatusBadRequest)
+		return
+	}
+	duration, ok := leaseDuration(w, req.LeaseSeconds)
+	if !ok {
+		return
+	}
+	if req.BoardID != "" && !h.allowBoard(w, r, req.BoardID) {
+		return
+	}
+	if req.ProjectID != "" && !h.allowProject(w, r, req.ProjectID) {
+		return
+	}
+
+	// Free the work of agents that stopped heartbeating first
+	h.ExpireLeases()
+
+	items, err := h.readyWork(r, req, agent)
+	var reqErr *requestError
+	if errors.As(err, &reqErr) {
+		httpError(w, reqErr.message, reqErr.status)
+		return
+	}
+	if err != nil {
+		h.logger.Printf("Error finding ready work: %v", err)
+		httpError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	for _, item := range items {
+		lease := item.lease(agent)
+		err := h.leases.Create(lease, now, duration)
+		if storage.IsDuplicate(err) {
+			// Claimed by another agent since readyWork looked
+			continue
+		}
+		if err != nil {
+			h.logger.Printf("Error creating lease: %v", err)
+			httpError(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		if h.wsHub != nil {
+			h.wsHub.BroadcastTaskClaimed(lease)
+		}
+		w.WriteHeader(http.StatusCreated)
+		h.respondJSON(w, claimResponse{Lease: lease, Task: item.task, Issue: item.issue})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// readyWork returns the unleased ready work in a claim's scope that isn't
+// assigned to anyone but the agent, highest priority first
+func (h *APIHandler) readyWork(r *http.Request, req claimRequest, agent domain.User) ([]*workItem, error) {
+	active, err := h.leases.ListActive(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	leased := make(map[[2]string]bool, len(active))
+	for _, lease := range active {
+		leased[[2]string{lease.Kind, lease.ItemID}] = true
+	}
+
+	var items []*workItem
+	if req.Kind != domain.WorkBead {
+		boards, err := h.claimBoards(r, req)
+		if err != nil {
+			return nil, err
+		}
+		for _, board := range boards {
+			tasks, err := h.tasks.ListByBoard(board.ID)
+			if err != nil {
+				return nil, err
+			}
+			ready, err := beads.ReadyTasks(tasks)
+			if err != nil {
+				return nil, err
+			}
+			for _, task := range ready {
+				if leased[[2]string{domain.WorkTask, task.ID}] {
+					continue
+				}
+				if a := task.Assignee; a != nil && (a.Type != agent.Type || a.ID != agent.ID) {
+					continue
+				}
+				items = append(items, &workItem{task: task, priority: domain.PriorityRank(task.Priority)})
+			}
+		}
+	}
+
+	// Beads issues aren't in any project, so only unscoped claims by
+	// tokens for every project see them
+	token := TokenFromContext(r.Context())
+	unscoped := req.BoardID == "" && req.ProjectID == "" && (token == nil || !token.Restricted())
+	if req.Kind != domain.WorkTask && unscoped && h.beadsWatcher != nil && !h.beadsWatcher.Missing() {
+		issues, err := h.beadsWatcher.Issues()
+		if err != nil {
+			return nil, err
+		}
+		ready, err := beads.NewAnalyzer(issues).GetReadyIssues()
+		if err != nil {
+			return nil, err
+		}
+		for _, issue := range ready {
+			if leased[[2]string{domain.WorkBead, issue.ID}] {
+				continue
+			}
+			if issue.Assignee != "" && issue.Assignee != agent.ID {
+				continue
+			}
+			// Issues imported onto a board are claimed as their task
+			linked, err := h.tasks.FindByLinkedItem("bead", issue.ID)
+			if err != nil {
+				return nil, err
+			}
+			if linked != nil {
+				continue
+			}
+			items = append(items, &workItem{issue: issue, priority: domain.PriorityRank(beads.TaskPriority(issue.Priority))})
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].priority > items[j].priority
+	})
+	return items, nil
+}
+
+// claimBoards returns the boards a claim covers
+func (h *APIHandler) claimBoards(r *http.Request, req claimRequest) ([]*domain.Board, error) {
+	if req.BoardID != "" {
+		board, err := h.boards.GetByID(req.BoardID)
+		if err != nil {
+			return nil, &requestError{http.StatusNotFound, "Board not found"}
+		}
+		return []*domain.Board{board}, nil
+	}
+	if req.ProjectID != "" {
+		if _, err := h.projects.GetByID(req.ProjectID); err != nil {
+			return nil, &requestError{http.StatusNotFound, "Project not found"}
+		}
+		return h.boards.ListByProject(req.ProjectID)
+	}
+
+	projects, err := h.projects.List()
+	if err != nil {
+		return nil, err
+	}
+	token := TokenFromContext(r.Context())
+	var boards []*domain.Board
+	for _, project := range projects {
+		if token != nil && !token.AllowsProject(project.ID) {
+			continue
+		}
+		projectBoards, err := h.boards.ListByProject(project.ID)
+		if err != nil {
+			return nil, err
+		}
+		boards = append(boards, projectBoards...)
+	}
+	return boards, nil
+}
+
+// listLeases lists the active leases. Tokens limited to some projects only
+// see leases on tasks in those projects.
+func (h *APIHandler) listLeases(w http.ResponseWriter, r *http.Request) {
+	leases, err := h.leases.ListActive(time.Now())
+	if err != nil {
+		h.logger.Printf("Error listing leases: %v", err)
+		httpError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if token := TokenFromContext(r.Context()); token != nil && token.Restricted() {
+		visible := []*domain.Lease{}
+		for _, lease := range leases {
+			if lease.Kind != domain.WorkTask {
+				continue
+			}
+			if board, err := h.boards.GetByID(lease.BoardID); err == nil && token.AllowsProject(board.ProjectID) {
+				visible = append(visible, lease)
+			}
+		}
+		leases = visible
+	}
+
+	h.respondJSON(w, leases)
+}
+
+// heartbeatLease extends a lease to expire lease_seconds from now, or the
+// default duration
+func (h *APIHandler) heartbeatLease(w http.ResponseWriter, r *http.Request, id string) {
+	var req heartbeatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		httpError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	duration, ok := leaseDuration(w, req.LeaseSeconds)
+	if !ok {
+		return
+	}
+
+	lease, err := h.leases.Heartbeat(id, time.Now(), duration)
+	h.respondLease(w, lease, err)
+}
+
+// releaseLease ends a lease so its work can be claimed again
+func (h *APIHandler) releaseLease(w http.ResponseWriter, r *http.Request, id string) {
+	lease, err := h.leases.Release(id, domain.LeaseReleased, time.Now())
+	if err == nil && h.wsHub != nil {
+		h.wsHub.BroadcastTaskReleased(lease)
+	}
+	h.respondLease(w, lease, err)
+}
+
+func (h *APIHandler) respondLease(w http.ResponseWriter, lease *domain.Lease, err error) {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		httpError(w, "Lease not found", http.StatusNotFound)
+	case errors.Is(err, storage.ErrLeaseEnded):
+		httpError(w, "Lease has expired or been released", http.StatusConflict)
+	case err != nil:
+		h.logger.Printf("Error updating lease: %v", err)
+		httpError(w, "Internal server error", http.StatusInternalServerError)
+	default:
+		h.respondJSON(w, lease)
+	}
+}
+
+// allowLease lets only the agent holding a lease extend or release it. With
+// authentication disabled, knowing the lease's ID is enough.
+func (h *APIHandler) allowLease(w http.ResponseWriter, r *http.Request, id string) bool {
+	user := requestUser(r)
+	if user == nil {
+		return true
+	}
+	lease, err := h.leases.Get(id)
+	if err != nil {
+		httpError(w, "Lease not found", http.StatusNotFound)
+		return false
+	}
+	if lease.Agent != *user {
+		httpError(w, "Lease is held by another agent", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// ExpireLeases releases the leases whose agents stopped heartbeating and
+// broadcasts their release. The server calls it periodically; claims call
+// it too, so expired work is claimable right away.
+func (h *APIHandler) ExpireLeases() {
+	expired, err := h.leases.ExpireLeases(time.Now())
+	if err != nil {
+		h.logger.Printf("Error expiring leases: %v", err)
+		return
+	}
+	for _, lease := range expired {
+		h.logger.Printf("Lease %s of %s on %s %s expired", lease.ID, lease.Agent.ID, lease.Kind, lease.ItemID)
+		if h.wsHub != nil {
+			h.wsHub.BroadcastTaskReleased(lease)
+		}
+	}
+}
+
+// requestAgent returns the identity of an agent making a request: the
+// token's user, or with authentication disabled the agent it names
+func requestAgent(r *http.Request, name string) (domain.User, bool) {
+	if user := requestUser(r); user != nil {
+		return *user, true
+	}
+	if name == "" {
+		return domain.User{}, false
+	}
+	return domain.User{Type: domain.AssigneeAgent, ID: name}, true
+}
+
+// leaseDuration converts a requested lease length, writing a 400 and
+// returning false if it is out of range
+func leaseDuration(w http.ResponseWriter, seconds int) (time.Duration, bool) {
+	if seconds == 0 {
+		return DefaultLeaseDuration, true
+	}
+	d := time.Duration(seconds) * time.Second
+	if seconds < 0 || d > MaxLeaseDuration {
+		httpError(w, "lease_seconds must be between 1 and 3600", http.StatusBadRequest)
+		return 0, false
+	}
+	return d, true
+}
@@ -0,0 +1,125 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rand/cartographer/internal/domain"
+)
+
+func TestWorkQueue(t *testing.T) {
+	board := &domain.Board{Name: "Main", Columns: []domain.BoardColumn{
+		{ID: "todo", Name: "To Do"}, {ID: "done", Name: "Done", Order: 1},
+	}}
+	project := &domain.Project{Name: "Work", Path: "/tmp/work"}
+	api := newTestAPI(t, project, board)
+	create := func(task *domain.Task) *domain.Task {
+		task.BoardID = board.ID
+		if task.Status == "" {
+			task.Status = "todo"
+		}
+		if err := api.tasks.Create(task); err != nil {
+			t.Fatalf("failed to create task: %v", err)
+		}
+		return task
+	}
+	blocked := create(&domain.Task{Title: "Blocked", Priority: "urgent"})
+	low := create(&domain.Task{Title: "Low", Priority: "low"})
+	high := create(&domain.Task{Title: "High", Priority: "high"})
+	create(&domain.Task{Title: "Blocker", Priority: "medium", Blocks: []string{blocked.ID}})
+	create(&domain.Task{Title: "Assigned", Priority: "urgent", Assignee: &domain.Assignee{Type: "human", ID: "alice"}})
+	create(&domain.Task{Title: "Done", Priority: "urgent", Status: "done"})
+
+	// An agent that stopped heartbeating an hour ago holds the low task
+	stale := &domain.Lease{Kind: domain.WorkTask, ItemID: low.ID, BoardID: board.ID, Agent: domain.User{Type: "agent", ID: "gone"}}
+	if err := api.leases.Create(stale, time.Now().Add(-time.Hour), time.Minute); err != nil {
+		t.Fatalf("failed to create lease: %v", err)
+	}
+
+	claim := func(body string) (int, claimResponse) {
+		rec := api.request(http.MethodPost, "/api/work/claim", body, nil)
+		var resp claimResponse
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec.Code, resp
+	}
+
+	if code, _ := claim(`{}`); code != http.StatusBadRequest {
+		t.Errorf("claim without an agent: expected 400, got %d", code)
+	}
+	if code, _ := claim(`{"agent": "bot", "lease_seconds": 7200}`); code != http.StatusBadRequest {
+		t.Errorf("claim of a two hour lease: expected 400, got %d", code)
+	}
+	if code, _ := claim(`{"agent": "bot", "board_id": "missing"}`); code != http.StatusNotFound {
+		t.Errorf("claim on a missing board: expected 404, got %d", code)
+	}
+
+	// Highest priority first, skipping blocked, assigned and done tasks;
+	// the stale lease is released so the low task comes last
+	var claimed []string
+	var first claimResponse
+	for i := 0; i < 4; i++ {
+		code, resp := claim(`{"agent": "bot", "board_id": "` + board.ID + `"}`)
+		if code == http.StatusNoContent {
+			break
+		}
+		if code != http.StatusCreated || resp.Task == nil || resp.Lease.ItemID != resp.Task.ID {
+			t.Fatalf("claim %d: got %d %+v", i, code, resp)
+		}
+		if resp.Lease.Agent != (domain.User{Type: "agent", ID: "bot"}) {
+			t.Errorf("claim %d: leased to %+v", i, resp.Lease.Agent)
+		}
+		if i == 0 {
+			first = resp
+		}
+		claimed = append(claimed, resp.Task.Title)
+	}
+	if got, want := strings.Join(claimed, ","), "High,Blocker,Low"; got != want {
+		t.Errorf("claimed %s, want %s", got, want)
+	}
+	if expired, err := api.leases.Get(stale.ID); err != nil || expired.Reason != domain.LeaseExpired {
+		t.Errorf("stale lease %+v (%v), want it expired", expired, err)
+	}
+
+	rec := api.request(http.MethodGet, "/api/work/leases", "", nil)
+	var active []domain.Lease
+	if err := json.Unmarshal(rec.Body.Bytes(), &active); err != nil || len(active) != 3 {
+		t.Errorf("expected 3 active leases, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	// Only the agent holding a lease may extend or release it
+	path := "/api/work/leases/" + first.Lease.ID
+	other := &domain.APIToken{Scope: domain.ScopeWrite, User: domain.User{Type: "agent", ID: "other"}}
+	if rec := api.request(http.MethodPost, path+"/heartbeat", "", other); rec.Code != http.StatusForbidden {
+		t.Errorf("heartbeat by another agent: expected 403, got %d", rec.Code)
+	}
+	rec = api.request(http.MethodPost, path+"/heartbeat", `{"lease_seconds": 600}`, nil)
+	var extended domain.Lease
+	json.Unmarshal(rec.Body.Bytes(), &extended)
+	if rec.Code != http.StatusOK || !extended.ExpiresAt.After(first.Lease.ExpiresAt) {
+		t.Errorf("heartbeat: got %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := api.request(http.MethodPost, "/api/work/leases/"+stale.ID+"/heartbeat", "", nil); rec.Code != http.StatusConflict {
+		t.Errorf("heartbeat of an expired lease: expected 409, got %d", rec.Code)
+	}
+
+	if rec := api.request(http.MethodDelete, path, "", nil); rec.Code != http.StatusOK {
+		t.Errorf("release: expected 200, got %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := api.request(http.MethodDelete, path, "", nil); rec.Code != http.StatusConflict {
+		t.Errorf("second release: expected 409, got %d", rec.Code)
+	}
+	if rec := api.request(http.MethodDelete, "/api/work/leases/missing", "", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("release of a missing lease: expected 404, got %d", rec.Code)
+	}
+
+	// Released work can be claimed again
+	if code, resp := claim(`{"agent": "bot2", "project_id": "` + project.ID + `"}`); code != http.StatusCreated || resp.Task == nil || resp.Task.ID != high.ID {
+		t.Errorf("claim after release: got %d %+v", code, resp)
+	}
+	if code, _ := claim(`{"agent": "bot2", "kind": "bead"}`); code != http.StatusNoContent {
+		t.Errorf("claim of a bead without a beads file: expected 204, got %d", code)
+	}
+}
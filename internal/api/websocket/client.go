@@ -1,8 +1,11 @@
 package websocket
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -30,24 +33,46 @@ type Client struct {
 	// WebSocket connection
 	conn *websocket.Conn
 
-	// Buffered channel of outbound messages
+	// Buffered channel of outbound messages. It is never closed, since the
+	// client's GraphQL operations may still send to it after the hub drops
+	// the client; done is closed instead.
 	send chan []byte
 
+	// Closed when the hub drops the client, ending WritePump
+	done      chan struct{}
+	closeOnce sync.Once
+
 	// Client ID for tracking
 	id string
 
 	// Optional filters for this client (e.g., specific project IDs)
 	filters map[string]string
+
+	// Resources and events this client subscribed to. A client without
+	// subscriptions receives every event.
+	subscriptions []Subscription
+	subMu         sync.RWMutex
+
+	// Projects this client may receive events about; empty for every
+	// project. Set before the client is registered.
+	projects []string
+
+	// GraphQL operations the client started, by ID
+	operations  map[string]context.CancelFunc
+	opMu        sync.Mutex
+	operationWG sync.WaitGroup
 }
 
 // NewClient creates a new WebSocket client
 func NewClient(hub *Hub, conn *websocket.Conn, id string) *Client {
 	return &Client{
-		hub:     hub,
-		conn:    conn,
-		send:    make(chan []byte, 256),
-		id:      id,
-		filters: make(map[string]string),
+		hub:        hub,
+		conn:       conn,
+		send:       make(chan []byte, 256),
+		done:       make(chan struct{}),
+		id:         id,
+		filters:    make(map[string]string),
+		operations: make(map[string]context.CancelFunc),
 	}
 }
 
@@ -62,6 +87,96 @@ func (c *Client) GetFilter(key string) (string, bool) {
 	return value, exists
 }
 
+// RestrictToProjects limits the client to events routed to one of the
+// given projects, whatever it subscribes to
+func (c *Client) RestrictToProjects(projectIDs []string) {
+	c.projects = projectIDs
+}
+
+// Projects returns the projects the client may receive events about, or
+// nil for every project
+func (c *Client) Projects() []string {
+	return c.projects
+}
+
+// ID returns the client's unique identifier
+func (c *Client) ID() string {
+	return c.id
+}
+
+// Subscribe adds a subscription to events on a resource. Resource and
+// event patterns are globs; no events means every event type.
+func (c *Client) Subscribe(resource string, events []string) error {
+	if resource == "" {
+		return fmt.Errorf("resource is required")
+	}
+	if err := validatePatterns(resource, events); err != nil {
+		return err
+	}
+
+	c.subMu.Lock()
+	defer c.subMu.Unlock()
+	c.subscriptions = addSubscription(c.subscriptions, Subscription{Resource: resource, Events: events})
+	return nil
+}
+
+// Unsubscribe removes event patterns from a resource subscription, or the
+// whole subscription when no events are given. An empty resource removes
+// every subscription.
+func (c *Client) Unsubscribe(resource string, events []string) {
+	c.subMu.Lock()
+	defer c.subMu.Unlock()
+	c.subscriptions = removeSubscription(c.subscriptions, resource, events)
+}
+
+// Subscriptions returns a copy of the client's current subscriptions
+func (c *Client) Subscriptions() []Subscription {
+	c.subMu.RLock()
+	defer c.subMu.RUnlock()
+
+	subs := make([]Subscription, len(c.subscriptions))
+	for i, s := range c.subscriptions {
+		subs[i] = Subscription{Resource: s.Resource, Events: append([]string{}, s.Events...)}
+	}
+	return subs
+}
+
+// wants reports whether an event should be delivered to this client
+func (c *Client) wants(msgType MessageType, resources []string) bool {
+	if !c.allowsResources(resources) {
+		return false
+	}
+
+	c.subMu.RLock()
+	defer c.subMu.RUnlock()
+
+	if len(c.subscriptions) == 0 {
+		return true
+	}
+	for _, s := range c.subscriptions {
+		if s.Matches(msgType, resources) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsResources reports whether a client restricted to some projects may
+// see an event about resources
+func (c *Client) allowsResources(resources []string) bool {
+	if len(c.projects) == 0 {
+		return true
+	}
+	for _, resource := range resources {
+		for _, projectID := range c.projects {
+			if resource == Resource(ResourceProject, projectID) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // ReadPump pumps messages from the WebSocket connection to the hub
 //
 // The application runs ReadPump in a per-connection goroutine. The application
@@ -69,6 +184,7 @@ func (c *Client) GetFilter(key string) (string, bool) {
 // reads from this goroutine.
 func (c *Client) ReadPump() {
 	defer func() {
+		c.stopOperations()
 		c.hub.unregister <- c
 		c.conn.Close()
 	}()
@@ -108,14 +224,14 @@ func (c *Client) WritePump() {
 
 	for {
 		select {
-		case message, ok := <-c.send:
+		case <-c.done:
+			// Hub dropped the client
 			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
-			if !ok {
-				// Hub closed the channel
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
-				return
-			}
+			c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+			return
 
+		case message := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
 			w, err := c.conn.NextWriter(websocket.TextMessage)
 			if err != nil {
 				return
@@ -160,6 +276,34 @@ func (c *Client) handleIncomingMessage(data []byte) {
 		// Pong received, update read deadline
 		c.conn.SetReadDeadline(time.Now().Add(pongWait))
 
+	case MessageTypeSubscribe, MessageTypeUnsubscribe, MessageTypeListSubscriptions:
+		var req SubscriptionRequest
+		if err := json.Unmarshal(data, &req); err != nil {
+			c.sendError("INVALID_MESSAGE", "Invalid subscription request", err.Error())
+			return
+		}
+		c.handleSubscriptionRequest(req)
+
+	case MessageTypeResume:
+		var req ResumeRequest
+		if err := json.Unmarshal(data, &req); err != nil {
+			c.sendError("INVALID_MESSAGE", "Invalid resume request", err.Error())
+			return
+		}
+		c.hub.requestResume(c, req)
+
+	case MessageTypeGraphQLStart, MessageTypeGraphQLStop:
+		var req OperationRequest
+		if err := json.Unmarshal(data, &req); err != nil {
+			c.sendError("INVALID_MESSAGE", "Invalid operation request", err.Error())
+			return
+		}
+		if req.Type == MessageTypeGraphQLStart {
+			c.startOperation(req)
+		} else {
+			c.stopOperation(req.ID)
+		}
+
 	default:
 		// For now, we don't handle other incoming message types
 		// This could be extended to support client-initiated actions
@@ -167,6 +311,54 @@ func (c *Client) handleIncomingMessage(data []byte) {
 	}
 }
 
+// handleSubscriptionRequest applies a subscription request and acknowledges
+// it with the client's resulting subscriptions
+func (c *Client) handleSubscriptionRequest(req SubscriptionRequest) {
+	ackType := MessageTypeSubscriptions
+
+	switch req.Type {
+	case MessageTypeSubscribe:
+		if err := c.Subscribe(req.Resource, req.Events); err != nil {
+			c.sendError("INVALID_SUBSCRIPTION", "Invalid subscription", err.Error())
+			return
+		}
+		ackType = MessageTypeSubscribed
+
+	case MessageTypeUnsubscribe:
+		if err := validatePatterns(req.Resource, req.Events); err != nil {
+			c.sendError("INVALID_SUBSCRIPTION", "Invalid subscription", err.Error())
+			return
+		}
+		c.Unsubscribe(req.Resource, req.Events)
+		ackType = MessageTypeUnsubscribed
+	}
+
+	ack, err := NewSubscriptionMessage(ackType, req, c.id, c.Subscriptions())
+	if err != nil {
+		log.Printf("error creating subscription acknowledgement: %v", err)
+		return
+	}
+	c.sendMessage(ack)
+}
+
+// sendMessage sends a control message to this client only
+func (c *Client) sendMessage(msg *Message) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("error marshaling %s message: %v", msg.Type, err)
+		return
+	}
+
+	select {
+	case <-c.done:
+		// Client was dropped, nobody reads its messages
+	case c.send <- data:
+	default:
+		// Channel is full, skip this message
+		log.Printf("client %s send channel full, dropping %s message", c.id, msg.Type)
+	}
+}
+
 // sendError sends an error message to the client
 func (c *Client) sendError(code, message, details string) {
 	errMsg := NewErrorMessage(code, message, details)
@@ -212,7 +404,13 @@ func (c *Client) Send(message []byte) {
 	default:
 		// Channel is full, drop this message and close the connection
 		log.Printf("client %s send channel full, closing connection", c.id)
-		close(c.send)
+		c.close()
 		c.hub.unregister <- c
 	}
 }
+
+// close marks the client dropped, ending its WritePump, which closes the
+// connection. It is safe to call more than once.
+func (c *Client) close() {
+	c.closeOnce.Do(func() { close(c.done) })
+}
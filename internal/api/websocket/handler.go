@@ -27,6 +27,10 @@ var (
 type Handler struct {
 	hub    *Hub
 	logger *log.Logger
+
+	// Returns the projects a request may receive events about, or nil for
+	// every project
+	projectScope func(r *http.Request) []string
 }
 
 // NewHandler creates a new WebSocket handler
@@ -61,16 +65,22 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	// Create new client
 	client := NewClient(h.hub, conn, clientID)
+	if h.projectScope != nil {
+		client.RestrictToProjects(h.projectScope(r))
+	}
 
-	// Extract optional filters from query parameters
+	// Extract optional filters from query parameters; these subscribe the
+	// client to every event on the project or board
 	// Example: /ws?project_id=abc123
 	query := r.URL.Query()
 	if projectID := query.Get("project_id"); projectID != "" {
 		client.SetFilter("project_id", projectID)
+		client.Subscribe(Resource(ResourceProject, projectID), nil)
 		h.logger.Printf("Client %s subscribed to project %s", clientID, projectID)
 	}
 	if boardID := query.Get("board_id"); boardID != "" {
 		client.SetFilter("board_id", boardID)
+		client.Subscribe(Resource(ResourceBoard, boardID), nil)
 		h.logger.Printf("Client %s subscribed to board %s", clientID, boardID)
 	}
 
@@ -86,6 +96,12 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	client.ReadPump()
 }
 
+// SetProjectScope sets the function used to limit a connection to the
+// projects its credentials grant access to
+func (h *Handler) SetProjectScope(fn func(r *http.Request) []string) {
+	h.projectScope = fn
+}
+
 // HandleWebSocket is a convenience wrapper for http.HandleFunc
 func (h *Handler) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 	h.ServeHTTP(w, r)
@@ -1,9 +1,16 @@
 package websocket
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"log"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rand/cartographer/internal/domain"
 )
 
 // Hub maintains the set of active clients and broadcasts messages to the clients
@@ -11,8 +18,8 @@ type Hub struct {
 	// Registered clients
 	clients map[*Client]bool
 
-	// Inbound messages from clients
-	broadcast chan []byte
+	// Outbound events to route to subscribed clients
+	broadcast chan *envelope
 
 	// Register requests from clients
 	register chan *Client
@@ -28,6 +35,34 @@ type Hub struct {
 
 	// Shutdown channel
 	shutdown chan struct{}
+
+	// Resolves a board to its project so board-scoped events also reach
+	// project subscribers
+	boardProject func(boardID string) string
+
+	// Resume requests from clients
+	resume chan resumeRequest
+
+	// Event sequencing and replay. stream identifies the sequence: it
+	// changes on restart unless events are persisted to store.
+	stream    string
+	seq       atomic.Uint64
+	replay    *replayBuffer
+	store     EventStore
+	sinceTrim int
+
+	// In-process listeners to broadcast events
+	listeners map[chan *domain.Event]bool
+	listenMu  sync.Mutex
+
+	// Runs the GraphQL operations clients start over their connection
+	operations OperationRunner
+}
+
+// envelope is a message along with the resources it is routed by
+type envelope struct {
+	msg       Message
+	resources []string
 }
 
 // NewHub creates a new Hub instance
@@ -37,12 +72,15 @@ func NewHub(logger *log.Logger) *Hub {
 	}
 
 	return &Hub{
-		broadcast:  make(chan []byte, 256),
+		broadcast:  make(chan *envelope, 256),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
 		clients:    make(map[*Client]bool),
 		logger:     logger,
 		shutdown:   make(chan struct{}),
+		resume:     make(chan resumeRequest),
+		stream:     uuid.New().String(),
+		replay:     newReplayBuffer(DefaultReplayWindow),
 	}
 }
 
@@ -58,18 +96,22 @@ func (h *Hub) Run() {
 			h.clients[client] = true
 			h.mu.Unlock()
 			h.logger.Printf("Client %s registered (total: %d)", client.id, len(h.clients))
+			h.greet(client)
 
 		case client := <-h.unregister:
 			h.mu.Lock()
 			if _, ok := h.clients[client]; ok {
 				delete(h.clients, client)
-				close(client.send)
+				client.close()
 				h.logger.Printf("Client %s unregistered (total: %d)", client.id, len(h.clients))
 			}
 			h.mu.Unlock()
 
-		case message := <-h.broadcast:
-			h.broadcastToAll(message)
+		case env := <-h.broadcast:
+			h.deliver(env)
+
+		case req := <-h.resume:
+			h.handleResume(req)
 
 		case <-h.shutdown:
 			h.logger.Println("WebSocket hub shutting down...")
@@ -79,33 +121,132 @@ func (h *Hub) Run() {
 	}
 }
 
-// broadcastToAll sends a message to all registered clients
-func (h *Hub) broadcastToAll(message []byte) {
-	h.mu.RLock()
-	defer h.mu.RUnlock()
+// greet tells a newly registered client where the event stream stands,
+// so it can resume from there after a later disconnect
+func (h *Hub) greet(client *Client) {
+	msg, err := NewMessage(MessageTypeConnected, ConnectedEvent{
+		ClientID:  client.id,
+		Stream:    h.stream,
+		LatestSeq: h.seq.Load(),
+	})
+	if err != nil {
+		h.logger.Printf("error creating connected message: %v", err)
+		return
+	}
+	client.sendMessage(msg)
+}
+
+// deliver assigns the next sequence number to a message, records it for
+// replay and sends it to every registered client subscribed to it
+func (h *Hub) deliver(env *envelope) {
+	msg := env.msg
+	msg.Seq = h.seq.Load() + 1
+
+	data, err := json.Marshal(&msg)
+	if err != nil {
+		h.logger.Printf("error marshaling message: %v", err)
+		return
+	}
+
+	event := &domain.Event{
+		Seq:       msg.Seq,
+		Type:      string(msg.Type),
+		Resources: env.resources,
+		Data:      data,
+		CreatedAt: msg.Timestamp,
+	}
+	h.record(event)
+	h.seq.Store(msg.Seq)
+	h.notify(event)
 
+	var stalled []*Client
+
+	h.mu.RLock()
 	for client := range h.clients {
+		if !client.wants(msg.Type, env.resources) {
+			continue
+		}
 		select {
-		case client.send <- message:
+		case client.send <- data:
 		default:
-			// Client's send buffer is full, close and unregister
+			stalled = append(stalled, client)
+		}
+	}
+	h.mu.RUnlock()
+
+	if len(stalled) == 0 {
+		return
+	}
+
+	// Client's send buffer is full, close and unregister
+	h.mu.Lock()
+	for _, client := range stalled {
+		if _, ok := h.clients[client]; ok {
 			h.logger.Printf("Client %s send buffer full, closing", client.id)
-			close(client.send)
+			client.close()
 			delete(h.clients, client)
 		}
 	}
+	h.mu.Unlock()
 }
 
-// BroadcastMessage broadcasts a message to all clients
-func (h *Hub) BroadcastMessage(msg *Message) error {
-	data, err := json.Marshal(msg)
-	if err != nil {
-		h.logger.Printf("error marshaling message: %v", err)
-		return err
+// Listen returns a channel receiving every event the hub broadcasts until
+// ctx is done, when it is closed. Listeners see events whatever the
+// clients subscribed to, so they must apply their own access rules. Events
+// a listener has no room for are dropped.
+func (h *Hub) Listen(ctx context.Context) <-chan *domain.Event {
+	ch := make(chan *domain.Event, 64)
+
+	h.listenMu.Lock()
+	if h.listeners == nil {
+		h.listeners = make(map[chan *domain.Event]bool)
 	}
+	h.listeners[ch] = true
+	h.listenMu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		h.listenMu.Lock()
+		delete(h.listeners, ch)
+		close(ch)
+		h.listenMu.Unlock()
+	}()
+	return ch
+}
+
+// notify passes an event to the in-process listeners
+func (h *Hub) notify(event *domain.Event) {
+	h.listenMu.Lock()
+	defer h.listenMu.Unlock()
+
+	for ch := range h.listeners {
+		select {
+		case ch <- event:
+		default:
+			h.logger.Printf("Listener falling behind, event %d dropped", event.Seq)
+		}
+	}
+}
+
+// SetBoardResolver sets the function used to look up a board's project, so
+// that task and board events reach clients subscribed to the project.
+// It must be called before the hub starts broadcasting.
+func (h *Hub) SetBoardResolver(fn func(boardID string) string) {
+	h.boardProject = fn
+}
 
+// BroadcastMessage broadcasts a message to all clients. Clients with
+// subscriptions only receive it through a wildcard ("*") resource.
+func (h *Hub) BroadcastMessage(msg *Message) error {
+	return h.BroadcastToResources(msg)
+}
+
+// BroadcastToResources broadcasts a message about the given resources
+// (e.g. "project:123") to clients subscribed to any of them. The hub
+// assigns the message's sequence number.
+func (h *Hub) BroadcastToResources(msg *Message, resources ...string) error {
 	select {
-	case h.broadcast <- data:
+	case h.broadcast <- &envelope{msg: *msg, resources: resources}:
 		return nil
 	default:
 		h.logger.Println("broadcast channel full, message dropped")
@@ -113,6 +254,24 @@ func (h *Hub) BroadcastMessage(msg *Message) error {
 	}
 }
 
+// boardResources returns the routing resources for an event on a board
+func (h *Hub) boardResources(boardID, projectID string) []string {
+	if projectID == "" && h.boardProject != nil && boardID != "" {
+		projectID = h.boardProject(boardID)
+	}
+
+	resources := []string{Resource(ResourceBoard, boardID)}
+	if projectID != "" {
+		resources = append(resources, Resource(ResourceProject, projectID))
+	}
+	return resources
+}
+
+// taskResources returns the routing resources for an event on a task
+func (h *Hub) taskResources(taskID, boardID string) []string {
+	return append([]string{Resource(ResourceTask, taskID)}, h.boardResources(boardID, "")...)
+}
+
 // BroadcastToFiltered broadcasts a message to clients matching filters
 func (h *Hub) BroadcastToFiltered(msg *Message, filterKey, filterValue string) error {
 	data, err := json.Marshal(msg)
@@ -146,7 +305,7 @@ func (h *Hub) BroadcastTaskCreated(taskID, boardID string, task interface{}) err
 	if err != nil {
 		return err
 	}
-	return h.BroadcastMessage(msg)
+	return h.BroadcastToResources(msg, h.taskResources(taskID, boardID)...)
 }
 
 // BroadcastTaskUpdated broadcasts a task updated event
@@ -155,7 +314,7 @@ func (h *Hub) BroadcastTaskUpdated(taskID, boardID string, changes map[string]in
 	if err != nil {
 		return err
 	}
-	return h.BroadcastMessage(msg)
+	return h.BroadcastToResources(msg, h.taskResources(taskID, boardID)...)
 }
 
 // BroadcastTaskDeleted broadcasts a task deleted event
@@ -164,7 +323,101 @@ func (h *Hub) BroadcastTaskDeleted(taskID, boardID string) error {
 	if err != nil {
 		return err
 	}
-	return h.BroadcastMessage(msg)
+	return h.BroadcastToResources(msg, h.taskResources(taskID, boardID)...)
+}
+
+// BroadcastTaskMoved broadcasts a task moved event
+func (h *Hub) BroadcastTaskMoved(taskID, boardID, from, to string, position int, task interface{}) error {
+	msg, err := NewTaskMovedMessage(taskID, boardID, from, to, position, task)
+	if err != nil {
+		return err
+	}
+	return h.BroadcastToResources(msg, h.taskResources(taskID, boardID)...)
+}
+
+// BroadcastTaskBatch broadcasts the changes of a batch of task operations
+// as one event to subscribers of any of the tasks and boards involved
+func (h *Hub) BroadcastTaskBatch(batch *TaskBatchEvent) error {
+	msg, err := NewMessage(MessageTypeTaskBatch, batch)
+	if err != nil {
+		return err
+	}
+	return h.BroadcastToResources(msg, h.batchResources(batch)...)
+}
+
+// batchResources returns the routing resources for a batch's event: every
+// task it changed and their boards
+func (h *Hub) batchResources(batch *TaskBatchEvent) []string {
+	var resources []string
+	seen := make(map[string]bool)
+	add := func(resource string) {
+		if !seen[resource] {
+			seen[resource] = true
+			resources = append(resources, resource)
+		}
+	}
+	boards := make(map[string]bool)
+	for _, ids := range batch.tasks() {
+		add(Resource(ResourceTask, ids[0]))
+		if !boards[ids[1]] {
+			boards[ids[1]] = true
+			for _, resource := range h.boardResources(ids[1], "") {
+				add(resource)
+			}
+		}
+	}
+	return resources
+}
+
+// Relay broadcasts a task event made by another process, such as the MCP
+// server, routing it by the task and board its data names as the
+// Broadcast methods would. Other event types return ErrNotRelayable.
+func (h *Hub) Relay(msg *Message) error {
+	var resources []string
+	switch msg.Type {
+	case MessageTypeTaskCreated, MessageTypeTaskUpdated, MessageTypeTaskDeleted, MessageTypeTaskMoved:
+		var event TaskEvent
+		if err := json.Unmarshal(msg.Data, &event); err != nil || event.TaskID == "" {
+			return fmt.Errorf("%w: %s needs a task_id", ErrNotRelayable, msg.Type)
+		}
+		resources = h.taskResources(event.TaskID, event.BoardID)
+	case MessageTypeTaskBatch:
+		var batch TaskBatchEvent
+		if err := json.Unmarshal(msg.Data, &batch); err != nil || batch.Empty() {
+			return fmt.Errorf("%w: %s needs a non-empty batch", ErrNotRelayable, msg.Type)
+		}
+		resources = h.batchResources(&batch)
+	default:
+		return fmt.Errorf("%w: %s", ErrNotRelayable, msg.Type)
+	}
+
+	relayed := &Message{Type: msg.Type, Timestamp: msg.Timestamp, Data: msg.Data}
+	if relayed.Timestamp.IsZero() {
+		relayed.Timestamp = time.Now()
+	}
+	return h.BroadcastToResources(relayed, resources...)
+}
+
+// BroadcastTaskClaimed broadcasts that an agent claimed a task or beads issue
+func (h *Hub) BroadcastTaskClaimed(lease *domain.Lease) error {
+	return h.broadcastLease(MessageTypeTaskClaimed, lease)
+}
+
+// BroadcastTaskReleased broadcasts that an agent's lease on a task or beads
+// issue was released or expired
+func (h *Hub) BroadcastTaskReleased(lease *domain.Lease) error {
+	return h.broadcastLease(MessageTypeTaskReleased, lease)
+}
+
+func (h *Hub) broadcastLease(msgType MessageType, lease *domain.Lease) error {
+	msg, err := NewLeaseMessage(msgType, lease)
+	if err != nil {
+		return err
+	}
+	if lease.Kind == domain.WorkBead {
+		return h.BroadcastToResources(msg, Resource(ResourceBead, lease.ItemID))
+	}
+	return h.BroadcastToResources(msg, h.taskResources(lease.ItemID, lease.BoardID)...)
 }
 
 // BroadcastProjectCreated broadcasts a project created event
@@ -173,7 +426,7 @@ func (h *Hub) BroadcastProjectCreated(projectID string, project interface{}) err
 	if err != nil {
 		return err
 	}
-	return h.BroadcastMessage(msg)
+	return h.BroadcastToResources(msg, Resource(ResourceProject, projectID))
 }
 
 // BroadcastProjectUpdated broadcasts a project updated event
@@ -182,7 +435,7 @@ func (h *Hub) BroadcastProjectUpdated(projectID string, changes map[string]inter
 	if err != nil {
 		return err
 	}
-	return h.BroadcastMessage(msg)
+	return h.BroadcastToResources(msg, Resource(ResourceProject, projectID))
 }
 
 // BroadcastBoardUpdated broadcasts a board updated event
@@ -191,7 +444,81 @@ func (h *Hub) BroadcastBoardUpdated(boardID, projectID string, changes map[strin
 	if err != nil {
 		return err
 	}
-	return h.BroadcastMessage(msg)
+	return h.BroadcastToResources(msg, h.boardResources(boardID, projectID)...)
+}
+
+// BroadcastDiagramCreated broadcasts a diagram created event
+func (h *Hub) BroadcastDiagramCreated(diagramID, projectID string, diagram interface{}) error {
+	msg, err := NewDiagramCreatedMessage(diagramID, projectID, diagram)
+	if err != nil {
+		return err
+	}
+	return h.BroadcastToResources(msg, Resource(ResourceDiagram, diagramID), Resource(ResourceProject, projectID))
+}
+
+// BroadcastDiagramUpdated broadcasts a diagram updated event
+func (h *Hub) BroadcastDiagramUpdated(diagramID, projectID string, version int, changes map[string]interface{}, diagram interface{}) error {
+	msg, err := NewDiagramUpdatedMessage(diagramID, projectID, version, changes, diagram)
+	if err != nil {
+		return err
+	}
+	return h.BroadcastToResources(msg, Resource(ResourceDiagram, diagramID), Resource(ResourceProject, projectID))
+}
+
+// BroadcastDiagramDeleted broadcasts a diagram deleted event
+func (h *Hub) BroadcastDiagramDeleted(diagramID, projectID string) error {
+	msg, err := NewDiagramDeletedMessage(diagramID, projectID)
+	if err != nil {
+		return err
+	}
+	return h.BroadcastToResources(msg, Resource(ResourceDiagram, diagramID), Resource(ResourceProject, projectID))
+}
+
+// BroadcastBeadCreated broadcasts a bead created event
+func (h *Hub) BroadcastBeadCreated(issueID string, issue interface{}) error {
+	msg, err := NewBeadCreatedMessage(issueID, issue)
+	if err != nil {
+		return err
+	}
+	return h.BroadcastToResources(msg, Resource(ResourceBead, issueID))
+}
+
+// BroadcastBeadUpdated broadcasts a bead updated event
+func (h *Hub) BroadcastBeadUpdated(issueID string, changes map[string]interface{}, issue interface{}) error {
+	msg, err := NewBeadUpdatedMessage(issueID, changes, issue)
+	if err != nil {
+		return err
+	}
+	return h.BroadcastToResources(msg, Resource(ResourceBead, issueID))
+}
+
+// BroadcastBeadClosed broadcasts a bead closed event
+func (h *Hub) BroadcastBeadClosed(issueID string, changes map[string]interface{}, issue interface{}) error {
+	msg, err := NewBeadClosedMessage(issueID, changes, issue)
+	if err != nil {
+		return err
+	}
+	return h.BroadcastToResources(msg, Resource(ResourceBead, issueID))
+}
+
+// BroadcastBeadDeleted broadcasts a bead deleted event
+func (h *Hub) BroadcastBeadDeleted(issueID string) error {
+	msg, err := NewBeadDeletedMessage(issueID)
+	if err != nil {
+		return err
+	}
+	return h.BroadcastToResources(msg, Resource(ResourceBead, issueID))
+}
+
+// BroadcastBeadConflict broadcasts a bead sync conflict to subscribers of
+// the bead and of the task it is synced with
+func (h *Hub) BroadcastBeadConflict(issueID, taskID, boardID string, conflict interface{}) error {
+	msg, err := NewBeadConflictMessage(issueID, taskID, boardID, conflict)
+	if err != nil {
+		return err
+	}
+	resources := append([]string{Resource(ResourceBead, issueID)}, h.taskResources(taskID, boardID)...)
+	return h.BroadcastToResources(msg, resources...)
 }
 
 // RegisterClient registers a new client with the hub
@@ -204,6 +531,16 @@ func (h *Hub) UnregisterClient(client *Client) {
 	h.unregister <- client
 }
 
+// Stream returns the identifier of the hub's event sequence
+func (h *Hub) Stream() string {
+	return h.stream
+}
+
+// LatestSeq returns the sequence number of the most recent broadcast event
+func (h *Hub) LatestSeq() uint64 {
+	return h.seq.Load()
+}
+
 // ClientCount returns the current number of connected clients
 func (h *Hub) ClientCount() int {
 	h.mu.RLock()
@@ -222,12 +559,24 @@ func (h *Hub) closeAllClients() {
 	defer h.mu.Unlock()
 
 	for client := range h.clients {
-		close(client.send)
+		client.close()
 		delete(h.clients, client)
 	}
 	h.logger.Println("All WebSocket clients closed")
 }
 
+// Subscriptions returns each connected client's subscriptions keyed by client ID
+func (h *Hub) Subscriptions() map[string][]Subscription {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	subs := make(map[string][]Subscription, len(h.clients))
+	for client := range h.clients {
+		subs[client.id] = client.Subscriptions()
+	}
+	return subs
+}
+
 // GetClients returns a snapshot of current clients (thread-safe)
 func (h *Hub) GetClients() []*Client {
 	h.mu.RLock()
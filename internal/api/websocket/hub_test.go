@@ -1,10 +1,20 @@
 package websocket
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/rand/cartographer/internal/domain"
 )
 
 func TestNewHub(t *testing.T) {
@@ -89,6 +99,28 @@ func TestHubBroadcast(t *testing.T) {
 	}
 }
 
+func TestEventRevision(t *testing.T) {
+	msg, err := NewTaskUpdatedMessage("task-1", "board-1", map[string]interface{}{"status": "done"}, &domain.Task{ID: "task-1", Revision: 4})
+	if err != nil {
+		t.Fatalf("NewTaskUpdatedMessage failed: %v", err)
+	}
+	var event TaskEvent
+	if err := json.Unmarshal(msg.Data, &event); err != nil {
+		t.Fatalf("Failed to decode event: %v", err)
+	}
+	if event.Revision != 4 {
+		t.Errorf("Expected revision 4, got %d", event.Revision)
+	}
+
+	// Payloads without a revision leave it out
+	var task *domain.Task
+	for _, payload := range []interface{}{nil, task, map[string]interface{}{"title": "Test"}} {
+		if revision := revisionOf(payload); revision != 0 {
+			t.Errorf("Expected no revision for %#v, got %d", payload, revision)
+		}
+	}
+}
+
 func TestMessageTypes(t *testing.T) {
 	// Test task created message
 	msg, err := NewTaskCreatedMessage("task-1", "board-1", map[string]interface{}{"title": "Test"})
@@ -144,9 +176,550 @@ func TestMessageTypes(t *testing.T) {
 		t.Errorf("Expected type %s, got %s", MessageTypeBoardUpdated, msg.Type)
 	}
 
+	// Test diagram messages
+	msg, err = NewDiagramCreatedMessage("diagram-1", "proj-1", map[string]interface{}{"name": "Flow"})
+	if err != nil {
+		t.Errorf("NewDiagramCreatedMessage failed: %v", err)
+	}
+	if msg.Type != MessageTypeDiagramCreated {
+		t.Errorf("Expected type %s, got %s", MessageTypeDiagramCreated, msg.Type)
+	}
+
+	msg, err = NewDiagramUpdatedMessage("diagram-1", "proj-1", 2, map[string]interface{}{"content": "graph TD"}, nil)
+	if err != nil {
+		t.Errorf("NewDiagramUpdatedMessage failed: %v", err)
+	}
+	if msg.Type != MessageTypeDiagramUpdated {
+		t.Errorf("Expected type %s, got %s", MessageTypeDiagramUpdated, msg.Type)
+	}
+
+	msg, err = NewDiagramDeletedMessage("diagram-1", "proj-1")
+	if err != nil {
+		t.Errorf("NewDiagramDeletedMessage failed: %v", err)
+	}
+	if msg.Type != MessageTypeDiagramDeleted {
+		t.Errorf("Expected type %s, got %s", MessageTypeDiagramDeleted, msg.Type)
+	}
+
+	// Test bead messages
+	msg, err = NewBeadCreatedMessage("bd-1", map[string]interface{}{"title": "Bead"})
+	if err != nil {
+		t.Errorf("NewBeadCreatedMessage failed: %v", err)
+	}
+	if msg.Type != MessageTypeBeadCreated {
+		t.Errorf("Expected type %s, got %s", MessageTypeBeadCreated, msg.Type)
+	}
+
+	msg, err = NewBeadUpdatedMessage("bd-1", map[string]interface{}{"title": "Renamed"}, nil)
+	if err != nil {
+		t.Errorf("NewBeadUpdatedMessage failed: %v", err)
+	}
+	if msg.Type != MessageTypeBeadUpdated {
+		t.Errorf("Expected type %s, got %s", MessageTypeBeadUpdated, msg.Type)
+	}
+
+	msg, err = NewBeadClosedMessage("bd-1", map[string]interface{}{"status": "closed"}, nil)
+	if err != nil {
+		t.Errorf("NewBeadClosedMessage failed: %v", err)
+	}
+	if msg.Type != MessageTypeBeadClosed {
+		t.Errorf("Expected type %s, got %s", MessageTypeBeadClosed, msg.Type)
+	}
+
+	msg, err = NewBeadDeletedMessage("bd-1")
+	if err != nil {
+		t.Errorf("NewBeadDeletedMessage failed: %v", err)
+	}
+	if msg.Type != MessageTypeBeadDeleted {
+		t.Errorf("Expected type %s, got %s", MessageTypeBeadDeleted, msg.Type)
+	}
+
+	// Test lease messages
+	msg, err = NewLeaseMessage(MessageTypeTaskReleased, &domain.Lease{ID: "lease-1", Kind: domain.WorkBead, ItemID: "bd-1"})
+	if err != nil {
+		t.Errorf("NewLeaseMessage failed: %v", err)
+	}
+	var lease LeaseEvent
+	json.Unmarshal(msg.Data, &lease)
+	if msg.Type != MessageTypeTaskReleased || lease.Action != "released" || lease.IssueID != "bd-1" || lease.TaskID != "" {
+		t.Errorf("Unexpected lease message %s: %+v", msg.Type, lease)
+	}
+
 	// Test error message
 	errMsg := NewErrorMessage("TEST_ERROR", "Test error message", "Details here")
 	if errMsg.Type != MessageTypeError {
 		t.Errorf("Expected type %s, got %s", MessageTypeError, errMsg.Type)
 	}
 }
+
+func TestSubscriptionMatches(t *testing.T) {
+	tests := []struct {
+		sub       Subscription
+		msgType   MessageType
+		resources []string
+		want      bool
+	}{
+		{Subscription{Resource: "project:p1"}, MessageTypeTaskCreated, []string{"task:t1", "board:b1", "project:p1"}, true},
+		{Subscription{Resource: "project:p1", Events: []string{"task.*"}}, MessageTypeTaskUpdated, []string{"project:p1"}, true},
+		{Subscription{Resource: "project:p1", Events: []string{"task.*"}}, MessageTypeBoardUpdated, []string{"project:p1"}, false},
+		{Subscription{Resource: "project:p1"}, MessageTypeTaskCreated, []string{"project:p2"}, false},
+		{Subscription{Resource: "board:*", Events: []string{"task.created"}}, MessageTypeTaskCreated, []string{"board:b9"}, true},
+		{Subscription{Resource: "*"}, MessageTypeProjectCreated, nil, true},
+		{Subscription{Resource: "project:*"}, MessageTypeProjectCreated, nil, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.sub.Matches(tt.msgType, tt.resources); got != tt.want {
+			t.Errorf("%+v.Matches(%s, %v) = %v, want %v", tt.sub, tt.msgType, tt.resources, got, tt.want)
+		}
+	}
+}
+
+func TestClientSubscriptions(t *testing.T) {
+	client := NewClient(nil, nil, "client-1")
+
+	if err := client.Subscribe("", nil); err == nil {
+		t.Error("Expected error subscribing without a resource")
+	}
+	if err := client.Subscribe("project:p1", []string{"task.["}); err == nil {
+		t.Error("Expected error for malformed event pattern")
+	}
+
+	client.Subscribe("project:p1", []string{"task.*"})
+	client.Subscribe("project:p1", []string{"board.*", "task.*"})
+	client.Subscribe("board:b1", nil)
+
+	subs := client.Subscriptions()
+	if len(subs) != 2 {
+		t.Fatalf("Expected 2 subscriptions, got %+v", subs)
+	}
+	if len(subs[0].Events) != 2 {
+		t.Errorf("Expected merged event patterns, got %v", subs[0].Events)
+	}
+
+	client.Unsubscribe("project:p1", []string{"task.*"})
+	subs = client.Subscriptions()
+	if len(subs) != 2 || len(subs[0].Events) != 1 || subs[0].Events[0] != "board.*" {
+		t.Errorf("Expected task.* removed, got %+v", subs)
+	}
+
+	client.Unsubscribe("board:b1", nil)
+	if subs = client.Subscriptions(); len(subs) != 1 {
+		t.Errorf("Expected board subscription removed, got %+v", subs)
+	}
+
+	client.Unsubscribe("", nil)
+	if subs = client.Subscriptions(); len(subs) != 0 {
+		t.Errorf("Expected all subscriptions removed, got %+v", subs)
+	}
+}
+
+func TestHubRoutesBySubscription(t *testing.T) {
+	logger := log.New(os.Stdout, "[test] ", log.LstdFlags)
+	hub := NewHub(logger)
+	hub.SetBoardResolver(func(boardID string) string {
+		if boardID == "board-1" {
+			return "proj-1"
+		}
+		return "proj-2"
+	})
+
+	go hub.Run()
+	defer hub.Shutdown()
+
+	everything := NewClient(hub, nil, "everything")
+	project1 := NewClient(hub, nil, "project-1")
+	project1.Subscribe("project:proj-1", []string{"task.*"})
+	board2 := NewClient(hub, nil, "board-2")
+	board2.Subscribe("board:board-2", nil)
+	// A client limited to proj-2 sees nothing else, even subscribed to it
+	restricted := NewClient(hub, nil, "restricted")
+	restricted.RestrictToProjects([]string{"proj-2"})
+	restricted.Subscribe("board:board-1", nil)
+	restricted.Subscribe("project:proj-2", nil)
+
+	for _, c := range []*Client{everything, project1, board2, restricted} {
+		hub.RegisterClient(c)
+	}
+
+	hub.BroadcastTaskCreated("task-1", "board-1", nil)
+	hub.BroadcastTaskCreated("task-2", "board-2", nil)
+	hub.BroadcastBoardUpdated("board-1", "proj-1", nil, nil)
+
+	expectTypes(t, everything, MessageTypeConnected, MessageTypeTaskCreated, MessageTypeTaskCreated, MessageTypeBoardUpdated)
+	expectTypes(t, project1, MessageTypeConnected, MessageTypeTaskCreated)
+	expectTypes(t, board2, MessageTypeConnected, MessageTypeTaskCreated)
+	expectTypes(t, restricted, MessageTypeConnected, MessageTypeTaskCreated)
+
+	subs := hub.Subscriptions()
+	if len(subs["project-1"]) != 1 || len(subs["everything"]) != 0 {
+		t.Errorf("Unexpected hub subscriptions: %+v", subs)
+	}
+}
+
+func TestSubscriptionRequestAcknowledged(t *testing.T) {
+	client := NewClient(nil, nil, "client-1")
+
+	client.handleIncomingMessage([]byte(`{"type":"subscribe","id":"req-1","resource":"project:p1","events":["task.*"]}`))
+	ack := readMessage(t, client)
+	if ack.Type != MessageTypeSubscribed {
+		t.Fatalf("Expected %s, got %s", MessageTypeSubscribed, ack.Type)
+	}
+	var event SubscriptionEvent
+	if err := json.Unmarshal(ack.Data, &event); err != nil {
+		t.Fatalf("Failed to decode ack: %v", err)
+	}
+	if event.ID != "req-1" || event.ClientID != "client-1" || len(event.Subscriptions) != 1 {
+		t.Errorf("Unexpected ack: %+v", event)
+	}
+
+	client.handleIncomingMessage([]byte(`{"type":"list_subscriptions"}`))
+	if msg := readMessage(t, client); msg.Type != MessageTypeSubscriptions {
+		t.Errorf("Expected %s, got %s", MessageTypeSubscriptions, msg.Type)
+	}
+
+	client.handleIncomingMessage([]byte(`{"type":"subscribe","resource":""}`))
+	if msg := readMessage(t, client); msg.Type != MessageTypeError {
+		t.Errorf("Expected %s, got %s", MessageTypeError, msg.Type)
+	}
+
+	client.handleIncomingMessage([]byte(`{"type":"unsubscribe","resource":"project:p1"}`))
+	if msg := readMessage(t, client); msg.Type != MessageTypeUnsubscribed {
+		t.Errorf("Expected %s, got %s", MessageTypeUnsubscribed, msg.Type)
+	}
+	if subs := client.Subscriptions(); len(subs) != 0 {
+		t.Errorf("Expected no subscriptions, got %+v", subs)
+	}
+}
+
+func TestHubResume(t *testing.T) {
+	logger := log.New(os.Stdout, "[test] ", log.LstdFlags)
+	hub := NewHub(logger)
+	hub.replay = newReplayBuffer(4)
+
+	go hub.Run()
+	defer hub.Shutdown()
+
+	client := NewClient(hub, nil, "client-1")
+	hub.RegisterClient(client)
+
+	var connected ConnectedEvent
+	decodeData(t, readMessage(t, client), MessageTypeConnected, &connected)
+	if connected.Stream != hub.Stream() || connected.LatestSeq != 0 {
+		t.Fatalf("Unexpected connected event: %+v", connected)
+	}
+
+	for i := 1; i <= 6; i++ {
+		hub.BroadcastTaskDeleted(fmt.Sprintf("task-%d", i), "board-1")
+		if msg := readMessage(t, client); msg.Seq != uint64(i) {
+			t.Fatalf("Expected seq %d, got %d", i, msg.Seq)
+		}
+	}
+
+	// Events 4-6 are still buffered
+	client.handleIncomingMessage([]byte(fmt.Sprintf(`{"type":"resume","stream":%q,"after":3}`, hub.Stream())))
+	var resumed ResumeEvent
+	decodeData(t, readMessage(t, client), MessageTypeResumed, &resumed)
+	if len(resumed.Events) != 3 || resumed.LatestSeq != 6 {
+		t.Fatalf("Expected 3 replayed events up to 6, got %+v", resumed)
+	}
+	var first Message
+	json.Unmarshal(resumed.Events[0], &first)
+	if first.Seq != 4 || first.Type != MessageTypeTaskDeleted {
+		t.Errorf("Expected replay to start at seq 4, got %d %s", first.Seq, first.Type)
+	}
+
+	// Replay respects subscriptions
+	client.Subscribe("board:other", nil)
+	client.handleIncomingMessage([]byte(`{"type":"resume","after":3}`))
+	var filtered ResumeEvent
+	decodeData(t, readMessage(t, client), MessageTypeResumed, &filtered)
+	if len(filtered.Events) != 0 {
+		t.Errorf("Expected no replayed events outside subscriptions, got %d", len(filtered.Events))
+	}
+	client.Unsubscribe("", nil)
+
+	tests := []struct {
+		request string
+		reason  string
+	}{
+		{`{"type":"resume","after":1}`, ResyncGapTooLarge},
+		{`{"type":"resume","after":7}`, ResyncUnknownSequence},
+		{`{"type":"resume","stream":"other","after":5}`, ResyncStreamChanged},
+	}
+	for _, tt := range tests {
+		client.handleIncomingMessage([]byte(tt.request))
+		var resync ResumeEvent
+		decodeData(t, readMessage(t, client), MessageTypeResyncRequired, &resync)
+		if resync.Reason != tt.reason || resync.LatestSeq != 6 || resync.OldestSeq != 3 {
+			t.Errorf("%s: unexpected resync reply %+v", tt.request, resync)
+		}
+	}
+}
+
+// memoryEventStore is an in-memory EventStore
+type memoryEventStore struct {
+	events []*domain.Event
+}
+
+func (s *memoryEventStore) EventStreamID() (string, error) { return "persisted", nil }
+
+func (s *memoryEventStore) AppendEvent(event *domain.Event) error {
+	s.events = append(s.events, event)
+	return nil
+}
+
+func (s *memoryEventStore) RecentEvents(limit int) ([]*domain.Event, error) {
+	if len(s.events) > limit {
+		return s.events[len(s.events)-limit:], nil
+	}
+	return s.events, nil
+}
+
+func (s *memoryEventStore) TrimEvents(keep int) error {
+	if len(s.events) > keep {
+		s.events = s.events[len(s.events)-keep:]
+	}
+	return nil
+}
+
+func TestHubEventStoreContinuesSequence(t *testing.T) {
+	logger := log.New(os.Stdout, "[test] ", log.LstdFlags)
+	store := &memoryEventStore{}
+
+	first := NewHub(logger)
+	if err := first.SetEventStore(store); err != nil {
+		t.Fatalf("SetEventStore failed: %v", err)
+	}
+	go first.Run()
+	first.BroadcastTaskDeleted("task-1", "board-1")
+	first.BroadcastTaskDeleted("task-2", "board-1")
+	deadline := time.Now().Add(time.Second)
+	for first.LatestSeq() < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	first.Shutdown()
+
+	// A restarted hub continues the same stream
+	second := NewHub(logger)
+	if err := second.SetEventStore(store); err != nil {
+		t.Fatalf("SetEventStore failed: %v", err)
+	}
+	if second.Stream() != "persisted" || second.LatestSeq() != 2 {
+		t.Fatalf("Expected stream persisted at seq 2, got %s at %d", second.Stream(), second.LatestSeq())
+	}
+
+	go second.Run()
+	defer second.Shutdown()
+
+	client := NewClient(second, nil, "client-1")
+	second.RegisterClient(client)
+	readMessage(t, client) // connected
+
+	client.handleIncomingMessage([]byte(`{"type":"resume","stream":"persisted","after":1}`))
+	var resumed ResumeEvent
+	decodeData(t, readMessage(t, client), MessageTypeResumed, &resumed)
+	if len(resumed.Events) != 1 {
+		t.Errorf("Expected 1 replayed event, got %d", len(resumed.Events))
+	}
+}
+
+func TestHubListen(t *testing.T) {
+	logger := log.New(os.Stdout, "[test] ", log.LstdFlags)
+	hub := NewHub(logger)
+
+	go hub.Run()
+	defer hub.Shutdown()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := hub.Listen(ctx)
+
+	hub.BroadcastTaskDeleted("task-1", "board-1")
+	select {
+	case event := <-events:
+		if event.Seq != 1 || event.Type != string(MessageTypeTaskDeleted) {
+			t.Errorf("Unexpected event: %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for the event")
+	}
+
+	cancel()
+	select {
+	case _, open := <-events:
+		if open {
+			t.Error("Expected the channel to close")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for the channel to close")
+	}
+}
+
+func TestClientOperations(t *testing.T) {
+	hub := NewHub(nil)
+	client := NewClient(hub, nil, "client-1")
+
+	client.handleIncomingMessage([]byte(`{"type":"graphql.start","id":"1","query":"{ projects { id } }"}`))
+	if msg := readMessage(t, client); msg.Type != MessageTypeError {
+		t.Errorf("Expected %s without a runner, got %s", MessageTypeError, msg.Type)
+	}
+
+	results := make(chan interface{})
+	hub.SetOperationRunner(func(ctx context.Context, c *Client, req OperationRequest) <-chan interface{} {
+		go func() {
+			defer close(results)
+			results <- req.Query
+			<-ctx.Done()
+		}()
+		return results
+	})
+
+	client.handleIncomingMessage([]byte(`{"type":"graphql.start","id":"1","query":"subscription { taskChanged { taskId } }"}`))
+	var data OperationEvent
+	decodeData(t, readMessage(t, client), MessageTypeGraphQLData, &data)
+	if data.ID != "1" || data.Payload != "subscription { taskChanged { taskId } }" {
+		t.Errorf("Unexpected data: %+v", data)
+	}
+
+	client.handleIncomingMessage([]byte(`{"type":"graphql.start","id":"1","query":"{ projects { id } }"}`))
+	if msg := readMessage(t, client); msg.Type != MessageTypeError {
+		t.Errorf("Expected %s for a duplicate ID, got %s", MessageTypeError, msg.Type)
+	}
+
+	client.handleIncomingMessage([]byte(`{"type":"graphql.stop","id":"1"}`))
+	var complete OperationEvent
+	decodeData(t, readMessage(t, client), MessageTypeGraphQLComplete, &complete)
+	if complete.ID != "1" {
+		t.Errorf("Unexpected completion: %+v", complete)
+	}
+	client.stopOperations()
+}
+
+func TestSlowClientWithOperation(t *testing.T) {
+	hub := NewHub(log.New(io.Discard, "", 0))
+	go hub.Run()
+	defer hub.Shutdown()
+
+	// A subscription producing results faster than the client reads them
+	hub.SetOperationRunner(func(ctx context.Context, c *Client, req OperationRequest) <-chan interface{} {
+		results := make(chan interface{})
+		go func() {
+			defer close(results)
+			for i := 0; ; i++ {
+				select {
+				case results <- i:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		return results
+	})
+
+	client := NewClient(hub, nil, "slow")
+	hub.RegisterClient(client)
+	client.handleIncomingMessage([]byte(`{"type":"graphql.start","id":"1","query":"subscription { taskChanged { taskId } }"}`))
+	for len(client.send) < cap(client.send) {
+		time.Sleep(time.Millisecond)
+	}
+
+	// The hub drops the client while the operation still sends to it
+	if err := hub.BroadcastTaskDeleted("task-1", "board-1"); err != nil {
+		t.Fatalf("BroadcastTaskDeleted failed: %v", err)
+	}
+	select {
+	case <-client.done:
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for the hub to drop the client")
+	}
+	time.Sleep(10 * time.Millisecond)
+	client.stopOperations()
+}
+
+func TestHubRelay(t *testing.T) {
+	hub := NewHub(log.New(os.Stdout, "[test] ", log.LstdFlags))
+	hub.SetBoardResolver(func(boardID string) string { return "proj-1" })
+
+	go hub.Run()
+	defer hub.Shutdown()
+
+	project := NewClient(hub, nil, "project-1")
+	project.Subscribe("project:proj-1", nil)
+	hub.RegisterClient(project)
+	expectTypes(t, project, MessageTypeConnected)
+
+	// A relay posts events to a server that hands them to its hub
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg Message
+		json.NewDecoder(r.Body).Decode(&msg)
+		if err := hub.Relay(&msg); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"code":"bad_request","message":%q}`, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	relay := NewRelay(server.URL, "")
+
+	if err := relay.BroadcastTaskMoved("task-1", "board-1", "todo", "done", 0, nil); err != nil {
+		t.Fatalf("Relay failed: %v", err)
+	}
+	var moved TaskMovedEvent
+	msg := readMessage(t, project)
+	decodeData(t, msg, MessageTypeTaskMoved, &moved)
+	if msg.Seq == 0 || moved.TaskID != "task-1" || moved.To != "done" {
+		t.Errorf("Unexpected relayed event: %+v %+v", msg, moved)
+	}
+
+	if err := relay.BroadcastTaskCreated("", "board-1", nil); err == nil || !strings.Contains(err.Error(), "needs a task_id") {
+		t.Errorf("Expected an error for a task event without a task, got %v", err)
+	}
+	projectMsg, _ := NewProjectCreatedMessage("proj-1", nil)
+	if err := hub.Relay(projectMsg); !errors.Is(err, ErrNotRelayable) {
+		t.Errorf("Expected ErrNotRelayable for %s, got %v", projectMsg.Type, err)
+	}
+	expectTypes(t, project)
+}
+
+// decodeData checks a message's type and decodes its data
+func decodeData(t *testing.T, msg *Message, want MessageType, v interface{}) {
+	t.Helper()
+	if msg.Type != want {
+		t.Fatalf("Expected %s, got %s", want, msg.Type)
+	}
+	if err := json.Unmarshal(msg.Data, v); err != nil {
+		t.Fatalf("Failed to decode %s data: %v", msg.Type, err)
+	}
+}
+
+// readMessage reads the next message queued for a client
+func readMessage(t *testing.T, c *Client) *Message {
+	t.Helper()
+	select {
+	case data := <-c.send:
+		var msg Message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			t.Fatalf("Failed to decode message: %v", err)
+		}
+		return &msg
+	case <-time.After(time.Second):
+		t.Fatalf("Timed out waiting for message to %s", c.id)
+		return nil
+	}
+}
+
+// expectTypes checks a client received exactly the given message types
+func expectTypes(t *testing.T, c *Client, types ...MessageType) {
+	t.Helper()
+	for _, want := range types {
+		if msg := readMessage(t, c); msg.Type != want {
+			t.Errorf("Client %s: expected %s, got %s", c.id, want, msg.Type)
+		}
+	}
+	select {
+	case data := <-c.send:
+		t.Errorf("Client %s: unexpected message %s", c.id, data)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -2,7 +2,10 @@ package websocket
 
 import (
 	"encoding/json"
+	"reflect"
 	"time"
+
+	"github.com/rand/cartographer/internal/domain"
 )
 
 // MessageType represents the type of WebSocket message
@@ -13,6 +16,12 @@ const (
 	MessageTypeTaskCreated MessageType = "task.created"
 	MessageTypeTaskUpdated MessageType = "task.updated"
 	MessageTypeTaskDeleted MessageType = "task.deleted"
+	MessageTypeTaskMoved   MessageType = "task.moved"
+	MessageTypeTaskBatch   MessageType = "task.batch"
+
+	// Work queue events, for tasks and beads issues claimed by agents
+	MessageTypeTaskClaimed  MessageType = "task.claimed"
+	MessageTypeTaskReleased MessageType = "task.released"
 
 	// Project events
 	MessageTypeProjectCreated MessageType = "project.created"
@@ -21,15 +30,51 @@ const (
 	// Board events
 	MessageTypeBoardUpdated MessageType = "board.updated"
 
+	// Diagram events
+	MessageTypeDiagramCreated MessageType = "diagram.created"
+	MessageTypeDiagramUpdated MessageType = "diagram.updated"
+	MessageTypeDiagramDeleted MessageType = "diagram.deleted"
+
+	// Bead events (changes to .beads/issues.jsonl)
+	MessageTypeBeadCreated MessageType = "bead.created"
+	MessageTypeBeadUpdated MessageType = "bead.updated"
+	MessageTypeBeadClosed  MessageType = "bead.closed"
+	MessageTypeBeadDeleted MessageType = "bead.deleted"
+	// A bead and the task synced with it were edited differently
+	MessageTypeBeadConflict MessageType = "bead.conflict"
+
 	// Connection events
 	MessageTypePing MessageType = "ping"
 	MessageTypePong MessageType = "pong"
 	MessageTypeError MessageType = "error"
+
+	// Subscription requests (client → server)
+	MessageTypeSubscribe         MessageType = "subscribe"
+	MessageTypeUnsubscribe       MessageType = "unsubscribe"
+	MessageTypeListSubscriptions MessageType = "list_subscriptions"
+
+	// Subscription acknowledgements (server → client)
+	MessageTypeSubscribed    MessageType = "subscribed"
+	MessageTypeUnsubscribed  MessageType = "unsubscribed"
+	MessageTypeSubscriptions MessageType = "subscriptions"
+
+	// Session resumption
+	MessageTypeConnected      MessageType = "connected"       // server → client on connect
+	MessageTypeResume         MessageType = "resume"          // client → server
+	MessageTypeResumed        MessageType = "resumed"         // server → client, carries missed events
+	MessageTypeResyncRequired MessageType = "resync_required" // server → client, events unavailable
+
+	// GraphQL subscriptions run over the connection
+	MessageTypeGraphQLStart    MessageType = "graphql.start"    // client → server
+	MessageTypeGraphQLStop     MessageType = "graphql.stop"     // client → server
+	MessageTypeGraphQLData     MessageType = "graphql.data"     // server → client, one result
+	MessageTypeGraphQLComplete MessageType = "graphql.complete" // server → client, the operation ended
 )
 
 // Message represents a WebSocket message envelope
 type Message struct {
 	Type      MessageType     `json:"type"`
+	Seq       uint64          `json:"seq,omitempty"` // set on broadcast events, increasing per hub stream
 	Timestamp time.Time       `json:"timestamp"`
 	Data      json.RawMessage `json:"data,omitempty"`
 	Error     string          `json:"error,omitempty"`
@@ -40,14 +85,113 @@ type TaskEvent struct {
 	TaskID    string                 `json:"task_id"`
 	BoardID   string                 `json:"board_id"`
 	Action    string                 `json:"action"` // created, updated, deleted, moved
+	Revision  int64                  `json:"revision,omitempty"`
 	Changes   map[string]interface{} `json:"changes,omitempty"`
 	Task      interface{}            `json:"task,omitempty"` // Full task object for created/updated
 }
 
+// TaskMovedEvent reports a task moved to another column or to another
+// position in its column
+type TaskMovedEvent struct {
+	TaskID   string      `json:"task_id"`
+	BoardID  string      `json:"board_id"`
+	Revision int64       `json:"revision,omitempty"`
+	From     string      `json:"from"`     // column the task left
+	To       string      `json:"to"`       // column the task is now in
+	Position int         `json:"position"` // index in the column, 0 at the top
+	Task     interface{} `json:"task,omitempty"`
+}
+
+// TaskBatchEvent reports every change made by a batch of task operations in
+// one event, each described as in its own event
+type TaskBatchEvent struct {
+	Created []TaskEvent      `json:"created,omitempty"`
+	Updated []TaskEvent      `json:"updated,omitempty"`
+	Moved   []TaskMovedEvent `json:"moved,omitempty"`
+	Deleted []TaskEvent      `json:"deleted,omitempty"`
+}
+
+// AddCreated adds a created task to the batch
+func (e *TaskBatchEvent) AddCreated(taskID, boardID string, task interface{}) {
+	e.Created = append(e.Created, TaskEvent{
+		TaskID:   taskID,
+		BoardID:  boardID,
+		Action:   "created",
+		Revision: revisionOf(task),
+		Task:     task,
+	})
+}
+
+// AddUpdated adds an updated task to the batch
+func (e *TaskBatchEvent) AddUpdated(taskID, boardID string, changes map[string]interface{}, task interface{}) {
+	e.Updated = append(e.Updated, TaskEvent{
+		TaskID:   taskID,
+		BoardID:  boardID,
+		Action:   "updated",
+		Revision: revisionOf(task),
+		Changes:  changes,
+		Task:     task,
+	})
+}
+
+// AddMoved adds a moved task to the batch
+func (e *TaskBatchEvent) AddMoved(taskID, boardID, from, to string, position int, task interface{}) {
+	e.Moved = append(e.Moved, TaskMovedEvent{
+		TaskID:   taskID,
+		BoardID:  boardID,
+		Revision: revisionOf(task),
+		From:     from,
+		To:       to,
+		Position: position,
+		Task:     task,
+	})
+}
+
+// AddDeleted adds a deleted task to the batch
+func (e *TaskBatchEvent) AddDeleted(taskID, boardID string) {
+	e.Deleted = append(e.Deleted, TaskEvent{
+		TaskID:  taskID,
+		BoardID: boardID,
+		Action:  "deleted",
+	})
+}
+
+// Empty reports whether the batch changed nothing
+func (e *TaskBatchEvent) Empty() bool {
+	return len(e.Created)+len(e.Updated)+len(e.Moved)+len(e.Deleted) == 0
+}
+
+// tasks returns the task and board ID of every change in the batch
+func (e *TaskBatchEvent) tasks() [][2]string {
+	var ids [][2]string
+	for _, events := range [][]TaskEvent{e.Created, e.Updated, e.Deleted} {
+		for _, event := range events {
+			ids = append(ids, [2]string{event.TaskID, event.BoardID})
+		}
+	}
+	for _, event := range e.Moved {
+		ids = append(ids, [2]string{event.TaskID, event.BoardID})
+	}
+	return ids
+}
+
+// LeaseEvent reports an agent's lease on a task or beads issue starting or
+// ending
+type LeaseEvent struct {
+	LeaseID string        `json:"lease_id"`
+	Kind    string        `json:"kind"`               // task, bead
+	TaskID  string        `json:"task_id,omitempty"`  // for a task
+	IssueID string        `json:"issue_id,omitempty"` // for a beads issue
+	BoardID string        `json:"board_id,omitempty"`
+	Action  string        `json:"action"` // claimed, released
+	Lease   *domain.Lease `json:"lease"`
+}
+
 // ProjectEvent represents project-related events
 type ProjectEvent struct {
 	ProjectID string                 `json:"project_id"`
 	Action    string                 `json:"action"` // created, updated
+	Revision  int64                  `json:"revision,omitempty"`
 	Changes   map[string]interface{} `json:"changes,omitempty"`
 	Project   interface{}            `json:"project,omitempty"` // Full project object
 }
@@ -57,10 +201,122 @@ type BoardEvent struct {
 	BoardID   string                 `json:"board_id"`
 	ProjectID string                 `json:"project_id"`
 	Action    string                 `json:"action"` // updated, reordered
+	Revision  int64                  `json:"revision,omitempty"`
 	Changes   map[string]interface{} `json:"changes,omitempty"`
 	Board     interface{}            `json:"board,omitempty"` // Full board object
 }
 
+// DiagramEvent represents diagram-related events
+type DiagramEvent struct {
+	DiagramID string                 `json:"diagram_id"`
+	ProjectID string                 `json:"project_id"`
+	Action    string                 `json:"action"` // created, updated, deleted
+	Version   int                    `json:"version,omitempty"`
+	Revision  int64                  `json:"revision,omitempty"`
+	Changes   map[string]interface{} `json:"changes,omitempty"`
+	Diagram   interface{}            `json:"diagram,omitempty"` // Full diagram object
+}
+
+// BeadEvent represents changes to beads issues
+type BeadEvent struct {
+	IssueID string                 `json:"issue_id"`
+	Action  string                 `json:"action"` // created, updated, closed, deleted
+	Changes map[string]interface{} `json:"changes,omitempty"`
+	Issue   interface{}            `json:"issue,omitempty"` // Full issue object
+}
+
+// BeadConflictEvent reports fields edited differently on a bead and the
+// task synced with it
+type BeadConflictEvent struct {
+	IssueID  string      `json:"issue_id"`
+	TaskID   string      `json:"task_id"`
+	BoardID  string      `json:"board_id,omitempty"`
+	Conflict interface{} `json:"conflict"`
+}
+
+// revisioned is implemented by resources that carry a revision. Events
+// report it so clients can tell whether their copy is behind.
+type revisioned interface {
+	CurrentRevision() int64
+}
+
+// revisionOf returns a resource's revision, or 0 if it has none
+func revisionOf(resource interface{}) int64 {
+	r, ok := resource.(revisioned)
+	if !ok {
+		return 0
+	}
+	if v := reflect.ValueOf(resource); v.Kind() == reflect.Ptr && v.IsNil() {
+		return 0
+	}
+	return r.CurrentRevision()
+}
+
+// SubscriptionRequest is a subscribe, unsubscribe or list_subscriptions
+// message sent by a client. Fields sit at the top level of the message,
+// e.g. {"type":"subscribe","resource":"project:123","events":["task.*"]}.
+type SubscriptionRequest struct {
+	Type     MessageType `json:"type"`
+	ID       string      `json:"id,omitempty"` // echoed in the acknowledgement
+	Resource string      `json:"resource,omitempty"`
+	Events   []string    `json:"events,omitempty"`
+}
+
+// SubscriptionEvent acknowledges a subscription request and reports the
+// client's subscriptions after it was applied
+type SubscriptionEvent struct {
+	ID            string         `json:"id,omitempty"`
+	ClientID      string         `json:"client_id"`
+	Resource      string         `json:"resource,omitempty"`
+	Events        []string       `json:"events,omitempty"`
+	Subscriptions []Subscription `json:"subscriptions"`
+}
+
+// ConnectedEvent greets a new client with the position of the event stream
+type ConnectedEvent struct {
+	ClientID  string `json:"client_id"`
+	Stream    string `json:"stream"`
+	LatestSeq uint64 `json:"latest_seq"`
+}
+
+// ResumeRequest asks for every event after a sequence number, e.g.
+// {"type":"resume","stream":"…","after":42}
+type ResumeRequest struct {
+	Type   MessageType `json:"type"`
+	Stream string      `json:"stream,omitempty"` // from the connected message; checked when set
+	After  uint64      `json:"after"`
+}
+
+// ResumeEvent answers a resume request. Events holds the missed messages
+// (filtered by the client's subscriptions) in sequence order; for
+// resync_required it is empty and Reason says why.
+type ResumeEvent struct {
+	Stream    string            `json:"stream"`
+	After     uint64            `json:"after"`
+	LatestSeq uint64            `json:"latest_seq"`
+	OldestSeq uint64            `json:"oldest_seq,omitempty"`
+	Events    []json.RawMessage `json:"events,omitempty"`
+	Reason    string            `json:"reason,omitempty"`
+}
+
+// OperationRequest starts or stops a GraphQL subscription, e.g.
+// {"type":"graphql.start","id":"1","query":"subscription { taskChanged { taskId } }"}.
+// The client picks the ID, which tags the operation's results.
+type OperationRequest struct {
+	Type          MessageType            `json:"type"`
+	ID            string                 `json:"id"`
+	Query         string                 `json:"query,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+	OperationName string                 `json:"operation_name,omitempty"`
+}
+
+// OperationEvent carries a result of a GraphQL subscription, or reports
+// that it ended
+type OperationEvent struct {
+	ID      string      `json:"id"`
+	Payload interface{} `json:"payload,omitempty"`
+}
+
 // ErrorEvent represents error messages
 type ErrorEvent struct {
 	Code    string `json:"code"`
@@ -104,13 +360,78 @@ func NewErrorMessage(code, message, details string) *Message {
 	}
 }
 
+// NewBeadCreatedMessage creates a bead created message
+func NewBeadCreatedMessage(issueID string, issue interface{}) (*Message, error) {
+	event := BeadEvent{
+		IssueID: issueID,
+		Action:  "created",
+		Issue:   issue,
+	}
+	return NewMessage(MessageTypeBeadCreated, event)
+}
+
+// NewBeadUpdatedMessage creates a bead updated message
+func NewBeadUpdatedMessage(issueID string, changes map[string]interface{}, issue interface{}) (*Message, error) {
+	event := BeadEvent{
+		IssueID: issueID,
+		Action:  "updated",
+		Changes: changes,
+		Issue:   issue,
+	}
+	return NewMessage(MessageTypeBeadUpdated, event)
+}
+
+// NewBeadClosedMessage creates a bead closed message
+func NewBeadClosedMessage(issueID string, changes map[string]interface{}, issue interface{}) (*Message, error) {
+	event := BeadEvent{
+		IssueID: issueID,
+		Action:  "closed",
+		Changes: changes,
+		Issue:   issue,
+	}
+	return NewMessage(MessageTypeBeadClosed, event)
+}
+
+// NewBeadDeletedMessage creates a bead deleted message
+func NewBeadDeletedMessage(issueID string) (*Message, error) {
+	event := BeadEvent{
+		IssueID: issueID,
+		Action:  "deleted",
+	}
+	return NewMessage(MessageTypeBeadDeleted, event)
+}
+
+// NewBeadConflictMessage creates a bead sync conflict message
+func NewBeadConflictMessage(issueID, taskID, boardID string, conflict interface{}) (*Message, error) {
+	event := BeadConflictEvent{
+		IssueID:  issueID,
+		TaskID:   taskID,
+		BoardID:  boardID,
+		Conflict: conflict,
+	}
+	return NewMessage(MessageTypeBeadConflict, event)
+}
+
+// NewSubscriptionMessage creates a subscription acknowledgement message
+func NewSubscriptionMessage(msgType MessageType, req SubscriptionRequest, clientID string, subs []Subscription) (*Message, error) {
+	event := SubscriptionEvent{
+		ID:            req.ID,
+		ClientID:      clientID,
+		Resource:      req.Resource,
+		Events:        req.Events,
+		Subscriptions: subs,
+	}
+	return NewMessage(msgType, event)
+}
+
 // NewTaskCreatedMessage creates a task created message
 func NewTaskCreatedMessage(taskID, boardID string, task interface{}) (*Message, error) {
 	event := TaskEvent{
 		TaskID:  taskID,
 		BoardID: boardID,
-		Action:  "created",
-		Task:    task,
+		Action:   "created",
+		Revision: revisionOf(task),
+		Task:     task,
 	}
 	return NewMessage(MessageTypeTaskCreated, event)
 }
@@ -120,9 +441,10 @@ func NewTaskUpdatedMessage(taskID, boardID string, changes map[string]interface{
 	event := TaskEvent{
 		TaskID:  taskID,
 		BoardID: boardID,
-		Action:  "updated",
-		Changes: changes,
-		Task:    task,
+		Action:   "updated",
+		Revision: revisionOf(task),
+		Changes:  changes,
+		Task:     task,
 	}
 	return NewMessage(MessageTypeTaskUpdated, event)
 }
@@ -137,11 +459,46 @@ func NewTaskDeletedMessage(taskID, boardID string) (*Message, error) {
 	return NewMessage(MessageTypeTaskDeleted, event)
 }
 
+// NewTaskMovedMessage creates a task moved message
+func NewTaskMovedMessage(taskID, boardID, from, to string, position int, task interface{}) (*Message, error) {
+	event := TaskMovedEvent{
+		TaskID:   taskID,
+		BoardID:  boardID,
+		Revision: revisionOf(task),
+		From:     from,
+		To:       to,
+		Position: position,
+		Task:     task,
+	}
+	return NewMessage(MessageTypeTaskMoved, event)
+}
+
+// NewLeaseMessage creates a task claimed or released message for a lease
+func NewLeaseMessage(msgType MessageType, lease *domain.Lease) (*Message, error) {
+	event := LeaseEvent{
+		LeaseID: lease.ID,
+		Kind:    lease.Kind,
+		BoardID: lease.BoardID,
+		Action:  "claimed",
+		Lease:   lease,
+	}
+	if msgType == MessageTypeTaskReleased {
+		event.Action = "released"
+	}
+	if lease.Kind == domain.WorkBead {
+		event.IssueID = lease.ItemID
+	} else {
+		event.TaskID = lease.ItemID
+	}
+	return NewMessage(msgType, event)
+}
+
 // NewProjectCreatedMessage creates a project created message
 func NewProjectCreatedMessage(projectID string, project interface{}) (*Message, error) {
 	event := ProjectEvent{
 		ProjectID: projectID,
 		Action:    "created",
+		Revision:  revisionOf(project),
 		Project:   project,
 	}
 	return NewMessage(MessageTypeProjectCreated, event)
@@ -152,6 +509,7 @@ func NewProjectUpdatedMessage(projectID string, changes map[string]interface{},
 	event := ProjectEvent{
 		ProjectID: projectID,
 		Action:    "updated",
+		Revision:  revisionOf(project),
 		Changes:   changes,
 		Project:   project,
 	}
@@ -164,8 +522,46 @@ func NewBoardUpdatedMessage(boardID, projectID string, changes map[string]interf
 		BoardID:   boardID,
 		ProjectID: projectID,
 		Action:    "updated",
+		Revision:  revisionOf(board),
 		Changes:   changes,
 		Board:     board,
 	}
 	return NewMessage(MessageTypeBoardUpdated, event)
 }
+
+// NewDiagramCreatedMessage creates a diagram created message
+func NewDiagramCreatedMessage(diagramID, projectID string, diagram interface{}) (*Message, error) {
+	event := DiagramEvent{
+		DiagramID: diagramID,
+		ProjectID: projectID,
+		Action:    "created",
+		Version:   1,
+		Revision:  revisionOf(diagram),
+		Diagram:   diagram,
+	}
+	return NewMessage(MessageTypeDiagramCreated, event)
+}
+
+// NewDiagramUpdatedMessage creates a diagram updated message
+func NewDiagramUpdatedMessage(diagramID, projectID string, version int, changes map[string]interface{}, diagram interface{}) (*Message, error) {
+	event := DiagramEvent{
+		DiagramID: diagramID,
+		ProjectID: projectID,
+		Action:    "updated",
+		Version:   version,
+		Revision:  revisionOf(diagram),
+		Changes:   changes,
+		Diagram:   diagram,
+	}
+	return NewMessage(MessageTypeDiagramUpdated, event)
+}
+
+// NewDiagramDeletedMessage creates a diagram deleted message
+func NewDiagramDeletedMessage(diagramID, projectID string) (*Message, error) {
+	event := DiagramEvent{
+		DiagramID: diagramID,
+		ProjectID: projectID,
+		Action:    "deleted",
+	}
+	return NewMessage(MessageTypeDiagramDeleted, event)
+}
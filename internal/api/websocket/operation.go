@@ -0,0 +1,89 @@
+package websocket
+
+import (
+	"context"
+	"log"
+)
+
+// OperationRunner runs a GraphQL operation a client started over its
+// connection. It returns the operation's results, closing the channel when
+// the operation ends or ctx is done.
+type OperationRunner func(ctx context.Context, client *Client, req OperationRequest) <-chan interface{}
+
+// SetOperationRunner sets the function that runs the GraphQL operations
+// clients start. Without one, clients get an error when they try. It must
+// be called before clients connect.
+func (h *Hub) SetOperationRunner(fn OperationRunner) {
+	h.operations = fn
+}
+
+// startOperation runs an operation, sending each of its results to the
+// client as a graphql.data message and graphql.complete when it ends
+func (c *Client) startOperation(req OperationRequest) {
+	if c.hub.operations == nil {
+		c.sendError("UNSUPPORTED", "GraphQL operations are not supported", "")
+		return
+	}
+	if req.ID == "" || req.Query == "" {
+		c.sendError("INVALID_OPERATION", "Invalid operation", "id and query are required")
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.opMu.Lock()
+	if _, running := c.operations[req.ID]; running {
+		c.opMu.Unlock()
+		cancel()
+		c.sendError("INVALID_OPERATION", "Invalid operation", "operation "+req.ID+" is already running")
+		return
+	}
+	c.operations[req.ID] = cancel
+	c.operationWG.Add(1)
+	c.opMu.Unlock()
+
+	results := c.hub.operations(ctx, c, req)
+	go func() {
+		defer c.operationWG.Done()
+		for result := range results {
+			c.sendOperationMessage(MessageTypeGraphQLData, req.ID, result)
+		}
+
+		c.opMu.Lock()
+		delete(c.operations, req.ID)
+		c.opMu.Unlock()
+		cancel()
+		c.sendOperationMessage(MessageTypeGraphQLComplete, req.ID, nil)
+	}()
+}
+
+// stopOperation stops an operation; it completes once its last result is
+// sent
+func (c *Client) stopOperation(id string) {
+	c.opMu.Lock()
+	cancel, ok := c.operations[id]
+	c.opMu.Unlock()
+	if ok {
+		cancel()
+	}
+}
+
+// stopOperations stops every operation and waits for them to end, so none
+// outlives the connection
+func (c *Client) stopOperations() {
+	c.opMu.Lock()
+	for _, cancel := range c.operations {
+		cancel()
+	}
+	c.opMu.Unlock()
+	c.operationWG.Wait()
+}
+
+// sendOperationMessage sends a message about an operation to the client
+func (c *Client) sendOperationMessage(msgType MessageType, id string, payload interface{}) {
+	msg, err := NewMessage(msgType, OperationEvent{ID: id, Payload: payload})
+	if err != nil {
+		log.Printf("error creating %s message: %v", msgType, err)
+		return
+	}
+	c.sendMessage(msg)
+}
@@ -0,0 +1,92 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// RelayPath is the server's endpoint for relaying events to its hub
+const RelayPath = "/api/events"
+
+// ErrNotRelayable is returned by Hub.Relay for events other processes may
+// not broadcast, or that don't name what they're about
+var ErrNotRelayable = errors.New("event can't be relayed")
+
+// Relay broadcasts task events to the hub of a running server, for
+// processes that change the database without one, like the MCP server. It
+// has the hub's task Broadcast methods.
+type Relay struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+// NewRelay creates a relay to the server at serverURL, authenticating with
+// an API token with write scope for every project ("" if the server has
+// authentication disabled)
+func NewRelay(serverURL, token string) *Relay {
+	return &Relay{
+		url:    strings.TrimSuffix(serverURL, "/") + RelayPath,
+		token:  token,
+		client: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// BroadcastTaskCreated relays a task created event
+func (r *Relay) BroadcastTaskCreated(taskID, boardID string, task interface{}) error {
+	return r.send(NewTaskCreatedMessage(taskID, boardID, task))
+}
+
+// BroadcastTaskUpdated relays a task updated event
+func (r *Relay) BroadcastTaskUpdated(taskID, boardID string, changes map[string]interface{}, task interface{}) error {
+	return r.send(NewTaskUpdatedMessage(taskID, boardID, changes, task))
+}
+
+// BroadcastTaskDeleted relays a task deleted event
+func (r *Relay) BroadcastTaskDeleted(taskID, boardID string) error {
+	return r.send(NewTaskDeletedMessage(taskID, boardID))
+}
+
+// BroadcastTaskMoved relays a task moved event
+func (r *Relay) BroadcastTaskMoved(taskID, boardID, from, to string, position int, task interface{}) error {
+	return r.send(NewTaskMovedMessage(taskID, boardID, from, to, position, task))
+}
+
+// send posts a message to the server, returning the error it responds with
+func (r *Relay) send(msg *Message, err error) error {
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, r.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r.token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.token)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("relaying %s: %w", msg.Type, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		var failure struct {
+			Message string `json:"message"`
+		}
+		json.NewDecoder(resp.Body).Decode(&failure)
+		return fmt.Errorf("relaying %s: %s: %s", msg.Type, resp.Status, failure.Message)
+	}
+	return nil
+}
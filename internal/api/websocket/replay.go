@@ -0,0 +1,176 @@
+package websocket
+
+import (
+	"fmt"
+
+	"github.com/rand/cartographer/internal/domain"
+)
+
+// DefaultReplayWindow is how many recent events a hub keeps for clients
+// resuming after a disconnect. Clients further behind must resync.
+const DefaultReplayWindow = 1024
+
+// Reasons given in resync_required replies
+const (
+	ResyncStreamChanged   = "stream_changed"   // server restarted without a persistent event store
+	ResyncUnknownSequence = "unknown_sequence" // client is ahead of the server
+	ResyncGapTooLarge     = "gap_too_large"    // missed events are no longer buffered
+)
+
+// EventStore persists broadcast events so that sequence numbers and the
+// replay window survive server restarts
+type EventStore interface {
+	EventStreamID() (string, error)
+	AppendEvent(event *domain.Event) error
+	RecentEvents(limit int) ([]*domain.Event, error)
+	TrimEvents(keep int) error
+}
+
+// replayBuffer is a fixed-size ring of the most recent events
+type replayBuffer struct {
+	events []*domain.Event
+	start  int
+	count  int
+}
+
+func newReplayBuffer(size int) *replayBuffer {
+	return &replayBuffer{events: make([]*domain.Event, size)}
+}
+
+// add appends an event, evicting the oldest when full
+func (b *replayBuffer) add(event *domain.Event) {
+	if len(b.events) == 0 {
+		return
+	}
+	if b.count < len(b.events) {
+		b.events[(b.start+b.count)%len(b.events)] = event
+		b.count++
+		return
+	}
+	b.events[b.start] = event
+	b.start = (b.start + 1) % len(b.events)
+}
+
+// oldestSeq returns the sequence number of the oldest buffered event, or 0
+func (b *replayBuffer) oldestSeq() uint64 {
+	if b.count == 0 {
+		return 0
+	}
+	return b.events[b.start].Seq
+}
+
+// after returns buffered events with a sequence number greater than seq
+func (b *replayBuffer) after(seq uint64) []*domain.Event {
+	var events []*domain.Event
+	for i := 0; i < b.count; i++ {
+		event := b.events[(b.start+i)%len(b.events)]
+		if event.Seq > seq {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// resumeRequest is a client's resume message queued for the hub loop
+type resumeRequest struct {
+	client *Client
+	stream string
+	after  uint64
+}
+
+// SetEventStore persists events to store and continues its sequence and
+// stream, restoring the replay window from it. It must be called before Run.
+func (h *Hub) SetEventStore(store EventStore) error {
+	stream, err := store.EventStreamID()
+	if err != nil {
+		return fmt.Errorf("failed to load event stream: %w", err)
+	}
+
+	events, err := store.RecentEvents(len(h.replay.events))
+	if err != nil {
+		return fmt.Errorf("failed to load recent events: %w", err)
+	}
+
+	h.store = store
+	h.stream = stream
+	h.replay = newReplayBuffer(len(h.replay.events))
+	for _, event := range events {
+		h.replay.add(event)
+		h.seq.Store(event.Seq)
+	}
+
+	return nil
+}
+
+// record appends a sequenced event to the replay window and event store
+func (h *Hub) record(event *domain.Event) {
+	h.replay.add(event)
+
+	if h.store == nil {
+		return
+	}
+	if err := h.store.AppendEvent(event); err != nil {
+		h.logger.Printf("Error persisting event %d: %v", event.Seq, err)
+		return
+	}
+
+	// Trim in batches rather than on every event
+	h.sinceTrim++
+	if h.sinceTrim >= len(h.replay.events)/8 {
+		h.sinceTrim = 0
+		if err := h.store.TrimEvents(len(h.replay.events)); err != nil {
+			h.logger.Printf("Error trimming event log: %v", err)
+		}
+	}
+}
+
+// handleResume replies to a resume request with the events the client
+// missed, or resync_required if they are not available
+func (h *Hub) handleResume(req resumeRequest) {
+	if _, ok := h.clients[req.client]; !ok {
+		return
+	}
+
+	latest := h.seq.Load()
+	reply := ResumeEvent{
+		Stream:    h.stream,
+		After:     req.after,
+		LatestSeq: latest,
+		OldestSeq: h.replay.oldestSeq(),
+	}
+
+	switch {
+	case req.stream != "" && req.stream != h.stream:
+		reply.Reason = ResyncStreamChanged
+	case req.after > latest:
+		reply.Reason = ResyncUnknownSequence
+	case req.after < latest && (reply.OldestSeq == 0 || req.after+1 < reply.OldestSeq):
+		reply.Reason = ResyncGapTooLarge
+	}
+
+	msgType := MessageTypeResyncRequired
+	if reply.Reason == "" {
+		msgType = MessageTypeResumed
+		for _, event := range h.replay.after(req.after) {
+			if req.client.wants(MessageType(event.Type), event.Resources) {
+				reply.Events = append(reply.Events, event.Data)
+			}
+		}
+	}
+
+	msg, err := NewMessage(msgType, reply)
+	if err != nil {
+		h.logger.Printf("error creating %s message: %v", msgType, err)
+		return
+	}
+	req.client.sendMessage(msg)
+}
+
+// requestResume queues a resume request for the hub loop, which serializes
+// it with live broadcasts so no event is skipped between replay and live
+func (h *Hub) requestResume(client *Client, req ResumeRequest) {
+	select {
+	case h.resume <- resumeRequest{client: client, stream: req.Stream, after: req.After}:
+	case <-h.shutdown:
+	}
+}
@@ -0,0 +1,164 @@
+package websocket
+
+import (
+	"fmt"
+	"path"
+)
+
+// Resource prefixes used to route events to subscribed clients.
+// A resource is "<kind>:<id>", e.g. "project:123" or "board:abc".
+const (
+	ResourceProject = "project"
+	ResourceBoard   = "board"
+	ResourceTask    = "task"
+	ResourceDiagram = "diagram"
+	ResourceBead    = "bead"
+)
+
+// Subscription is a client's interest in events on a resource. Both the
+// resource and the event types are glob patterns ("project:*", "task.*").
+// An empty Events list means every event type.
+type Subscription struct {
+	Resource string   `json:"resource"`
+	Events   []string `json:"events"`
+}
+
+// Resource formats a routing resource, e.g. Resource(ResourceProject, "123")
+func Resource(kind, id string) string {
+	return kind + ":" + id
+}
+
+// validatePatterns checks the resource and event globs are well formed
+func validatePatterns(resource string, events []string) error {
+	if _, err := path.Match(resource, ""); err != nil {
+		return fmt.Errorf("invalid resource pattern %q", resource)
+	}
+	for _, event := range events {
+		if event == "" {
+			return fmt.Errorf("empty event pattern")
+		}
+		if _, err := path.Match(event, ""); err != nil {
+			return fmt.Errorf("invalid event pattern %q", event)
+		}
+	}
+	return nil
+}
+
+// matchesEvent reports whether the event type matches any of the
+// subscription's event patterns
+func (s Subscription) matchesEvent(msgType MessageType) bool {
+	if len(s.Events) == 0 {
+		return true
+	}
+	for _, pattern := range s.Events {
+		if ok, _ := path.Match(pattern, string(msgType)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesResource reports whether the subscription's resource pattern
+// matches any of the event's resources. Events that carry no resources
+// only reach wildcard ("*") subscriptions.
+func (s Subscription) matchesResource(resources []string) bool {
+	if s.Resource == "*" {
+		return true
+	}
+	for _, resource := range resources {
+		if ok, _ := path.Match(s.Resource, resource); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches reports whether an event of the given type on the given
+// resources should be delivered under this subscription
+func (s Subscription) Matches(msgType MessageType, resources []string) bool {
+	return s.matchesEvent(msgType) && s.matchesResource(resources)
+}
+
+// addSubscription merges a subscription into the list. Subscribing again to
+// the same resource widens its event patterns; an empty pattern list
+// (every event) absorbs any explicit patterns.
+func addSubscription(subs []Subscription, sub Subscription) []Subscription {
+	for i := range subs {
+		if subs[i].Resource != sub.Resource {
+			continue
+		}
+		if len(subs[i].Events) == 0 || len(sub.Events) == 0 {
+			subs[i].Events = nil
+			return subs
+		}
+		subs[i].Events = appendMissing(subs[i].Events, sub.Events)
+		return subs
+	}
+	sub.Events = appendMissing(nil, sub.Events)
+	return append(subs, sub)
+}
+
+// removeSubscription removes event patterns from the subscription on a
+// resource, dropping it entirely when no events are given or none remain.
+// Patterns cannot be subtracted from a subscription to every event, so
+// that is only dropped as a whole. An empty resource clears every
+// subscription.
+func removeSubscription(subs []Subscription, resource string, events []string) []Subscription {
+	if resource == "" {
+		return nil
+	}
+
+	result := subs[:0]
+	for _, s := range subs {
+		if s.Resource == resource {
+			if len(events) == 0 {
+				continue
+			}
+			if len(s.Events) == 0 {
+				result = append(result, s)
+				continue
+			}
+			s.Events = removeAll(s.Events, events)
+			if len(s.Events) == 0 {
+				continue
+			}
+		}
+		result = append(result, s)
+	}
+	return result
+}
+
+// appendMissing appends the values not already present in list
+func appendMissing(list, values []string) []string {
+	for _, v := range values {
+		found := false
+		for _, existing := range list {
+			if existing == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// removeAll returns list without any of the given values
+func removeAll(list, values []string) []string {
+	result := make([]string, 0, len(list))
+	for _, v := range list {
+		keep := true
+		for _, remove := range values {
+			if v == remove {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			result = append(result, v)
+		}
+	}
+	return result
+}
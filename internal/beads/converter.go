@@ -3,7 +3,10 @@ package beads
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 	"time"
+	"unicode"
 
 	"github.com/rand/cartographer/internal/domain"
 	"github.com/steveyegge/beads"
@@ -16,6 +19,10 @@ type Converter struct {
 
 	// BoardID is the Cartographer board ID to associate converted tasks with
 	BoardID string
+
+	// Columns maps bead statuses to the board's column IDs. Statuses not
+	// in the map use the default todo/in_progress/blocked/done columns.
+	Columns map[beads.Status]string
 }
 
 // NewConverter creates a new converter for the given project and board
@@ -26,6 +33,24 @@ func NewConverter(projectID, boardID string) *Converter {
 	}
 }
 
+// NewBoardConverter creates a converter that places tasks in the board's
+// own columns
+func NewBoardConverter(board *domain.Board) *Converter {
+	return &Converter{
+		ProjectID: board.ProjectID,
+		BoardID:   board.ID,
+		Columns:   MapColumns(board.Columns),
+	}
+}
+
+// column returns the column for a bead status
+func (c *Converter) column(status beads.Status) string {
+	if column, ok := c.Columns[status]; ok {
+		return column
+	}
+	return convertStatus(status)
+}
+
 // ConvertBeadToTask converts a Beads issue to a Cartographer task
 // This maps the Beads issue structure to our domain model
 func (c *Converter) ConvertBeadToTask(bead *beads.Issue) (*domain.Task, error) {
@@ -43,7 +68,7 @@ func (c *Converter) ConvertBeadToTask(bead *beads.Issue) (*domain.Task, error) {
 		BoardID:     c.BoardID,
 		Title:       bead.Title,
 		Description: buildDescription(bead),
-		Status:      convertStatus(bead.Status),
+		Status:      c.column(bead.Status),
 		Priority:    convertPriority(bead.Priority),
 		Labels:      bead.Labels,
 		CreatedAt:   bead.CreatedAt,
@@ -166,6 +191,90 @@ func convertStatus(beadStatus beads.Status) string {
 	}
 }
 
+// columnAliases lists normalized column IDs and names that stand for each
+// bead status
+var columnAliases = map[string]beads.Status{
+	"todo":       beads.StatusOpen,
+	"open":       beads.StatusOpen,
+	"backlog":    beads.StatusOpen,
+	"new":        beads.StatusOpen,
+	"ready":      beads.StatusOpen,
+	"inprogress": beads.StatusInProgress,
+	"doing":      beads.StatusInProgress,
+	"wip":        beads.StatusInProgress,
+	"started":    beads.StatusInProgress,
+	"active":     beads.StatusInProgress,
+	"blocked":    beads.StatusBlocked,
+	"onhold":     beads.StatusBlocked,
+	"waiting":    beads.StatusBlocked,
+	"done":       beads.StatusClosed,
+	"closed":     beads.StatusClosed,
+	"complete":   beads.StatusClosed,
+	"completed":  beads.StatusClosed,
+	"finished":   beads.StatusClosed,
+}
+
+// ColumnStatus returns the bead status a board column stands for, judged by
+// its ID or name, e.g. "inprogress", "in_progress" or "In Progress"
+func ColumnStatus(column string) (beads.Status, bool) {
+	var normalized strings.Builder
+	for _, r := range strings.ToLower(column) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			normalized.WriteRune(r)
+		}
+	}
+	status, ok := columnAliases[normalized.String()]
+	return status, ok
+}
+
+// MapColumns maps each bead status to a board column, matching columns by
+// ID and then by name. Statuses no column stands for fall back by position:
+// open to the first column, closed to the last, in_progress to the second
+// and blocked to wherever in_progress went.
+func MapColumns(columns []domain.BoardColumn) map[beads.Status]string {
+	mapping := make(map[beads.Status]string)
+	if len(columns) == 0 {
+		return mapping
+	}
+
+	ordered := append([]domain.BoardColumn(nil), columns...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Order < ordered[j].Order
+	})
+
+	for _, byName := range []bool{false, true} {
+		for _, column := range ordered {
+			key := column.ID
+			if byName {
+				key = column.Name
+			}
+			if status, ok := ColumnStatus(key); ok {
+				if _, taken := mapping[status]; !taken {
+					mapping[status] = column.ID
+				}
+			}
+		}
+	}
+
+	if _, ok := mapping[beads.StatusOpen]; !ok {
+		mapping[beads.StatusOpen] = ordered[0].ID
+	}
+	if _, ok := mapping[beads.StatusClosed]; !ok {
+		mapping[beads.StatusClosed] = ordered[len(ordered)-1].ID
+	}
+	if _, ok := mapping[beads.StatusInProgress]; !ok {
+		mapping[beads.StatusInProgress] = ordered[0].ID
+		if len(ordered) > 2 {
+			mapping[beads.StatusInProgress] = ordered[1].ID
+		}
+	}
+	if _, ok := mapping[beads.StatusBlocked]; !ok {
+		mapping[beads.StatusBlocked] = mapping[beads.StatusInProgress]
+	}
+
+	return mapping
+}
+
 // convertPriority maps Beads priority (0-4) to Cartographer priority
 // Beads: 0=lowest, 4=highest
 // Cartographer: low, medium, high, urgent
@@ -262,18 +371,10 @@ func ConvertTaskToBead(task *domain.Task) (*beads.Issue, error) {
 
 // convertStatusToBead maps Cartographer status back to Beads status
 func convertStatusToBead(status string) beads.Status {
-	switch status {
-	case "todo", "backlog":
-		return beads.StatusOpen
-	case "in_progress", "doing":
-		return beads.StatusInProgress
-	case "blocked":
-		return beads.StatusBlocked
-	case "done", "completed":
-		return beads.StatusClosed
-	default:
-		return beads.StatusOpen
+	if beadStatus, ok := ColumnStatus(status); ok {
+		return beadStatus
 	}
+	return beads.StatusOpen
 }
 
 // convertPriorityToBead maps Cartographer priority back to Beads priority
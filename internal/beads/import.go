@@ -0,0 +1,156 @@
+package beads
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rand/cartographer/internal/domain"
+	"github.com/steveyegge/beads"
+)
+
+// ImportReport lists what importing beads into a board did to each bead
+type ImportReport struct {
+	Created []string     `json:"created"`
+	Updated []string     `json:"updated"`
+	Skipped []ImportSkip `json:"skipped"`
+}
+
+// ImportSkip is a bead an import left alone, and why
+type ImportSkip struct {
+	BeadID string `json:"bead_id"`
+	TaskID string `json:"task_id,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// ImportedTask is a task an import created or updated, with the changes to
+// an updated task's fields
+type ImportedTask struct {
+	Task    *domain.Task
+	Created bool
+	Changes domain.Changes
+}
+
+// Reasons given for skipped beads
+const (
+	SkipUnchanged  = "unchanged"
+	SkipInvalid    = "invalid"
+	SkipOtherBoard = "linked to a task on another board"
+)
+
+// ImportIntoBoard converts issues into tasks in the board's columns and
+// upserts them through tasks, matching existing tasks by their bead link.
+// Blocks is filled in from the reverse dependency graph, and dependencies
+// refer to the tasks the beads became. The tasks' activity records their
+// creation or update by user. It returns the report along with the tasks it
+// created and updated. This is the only way beads become tasks: a Syncer
+// keeps them in step afterwards.
+func ImportIntoBoard(issues []*beads.Issue, board *domain.Board, tasks TaskStore, user string) (report *ImportReport, imported []ImportedTask, err error) {
+	report = &ImportReport{Created: []string{}, Updated: []string{}, Skipped: []ImportSkip{}}
+
+	graph, err := NewAnalyzer(issues).BuildDependencyGraph()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to build dependency graph: %w", err)
+	}
+
+	converter := NewBoardConverter(board)
+
+	type pending struct {
+		bead     *beads.Issue
+		task     *domain.Task
+		existing *domain.Task
+	}
+	var imports []pending
+	taskIDs := make(map[string]string) // bead ID to task ID
+
+	for _, issue := range issues {
+		task, err := converter.ConvertBeadToTask(issue)
+		if err != nil {
+			report.Skipped = append(report.Skipped, ImportSkip{BeadID: issue.ID, Reason: SkipInvalid + ": " + err.Error()})
+			continue
+		}
+
+		existing, err := tasks.FindByLinkedItem("bead", issue.ID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to look up task for bead %s: %w", issue.ID, err)
+		}
+		if existing != nil && existing.BoardID != board.ID {
+			report.Skipped = append(report.Skipped, ImportSkip{BeadID: issue.ID, TaskID: existing.ID, Reason: SkipOtherBoard})
+			continue
+		}
+
+		taskIDs[issue.ID] = task.ID
+		if existing != nil {
+			taskIDs[issue.ID] = existing.ID
+		}
+		imports = append(imports, pending{bead: issue, task: task, existing: existing})
+	}
+
+	now := time.Now()
+	for _, imp := range imports {
+		imp.task.Blocks = graph.Blocks[imp.bead.ID]
+		imp.task.Dependencies = mapIDs(imp.task.Dependencies, taskIDs)
+		imp.task.Blocks = mapIDs(imp.task.Blocks, taskIDs)
+		imp.task.Related = mapIDs(imp.task.Related, taskIDs)
+
+		if imp.existing == nil {
+			created := domain.ActivityEntry{Type: domain.ActivityCreated, User: user, Timestamp: now}
+			imp.task.Activity = append([]domain.ActivityEntry{created}, imp.task.Activity...)
+			if err := tasks.Create(imp.task); err != nil {
+				return nil, nil, fmt.Errorf("failed to create task for bead %s: %w", imp.bead.ID, err)
+			}
+			report.Created = append(report.Created, imp.bead.ID)
+			imported = append(imported, ImportedTask{Task: imp.task, Created: true})
+			continue
+		}
+
+		before := *imp.existing
+		mergeImportedTask(imp.existing, imp.task, imp.bead.Status)
+		changes := domain.RecordTaskUpdate(&before, imp.existing, user, now)
+		if len(changes) == 0 {
+			report.Skipped = append(report.Skipped, ImportSkip{BeadID: imp.bead.ID, TaskID: imp.existing.ID, Reason: SkipUnchanged})
+			continue
+		}
+		if err := tasks.Update(imp.existing); err != nil {
+			return nil, nil, fmt.Errorf("failed to update task %s: %w", imp.existing.ID, err)
+		}
+		report.Updated = append(report.Updated, imp.bead.ID)
+		imported = append(imported, ImportedTask{Task: imp.existing, Changes: changes})
+	}
+
+	return report, imported, nil
+}
+
+// mergeImportedTask copies the fields an import owns from a freshly
+// converted task onto the existing one, keeping its activity, checklist and
+// other links. A task already in a column for the bead's status stays put.
+func mergeImportedTask(existing, imported *domain.Task, status beads.Status) {
+	existing.Title = imported.Title
+	existing.Description = imported.Description
+	existing.Priority = imported.Priority
+	existing.Assignee = imported.Assignee
+	existing.Labels = imported.Labels
+	existing.Estimate = imported.Estimate
+	existing.Dependencies = imported.Dependencies
+	existing.Blocks = imported.Blocks
+	existing.Related = imported.Related
+
+	if current, ok := ColumnStatus(existing.Status); !ok || current != status {
+		existing.Status = imported.Status
+	}
+}
+
+// mapIDs translates bead IDs to task IDs, keeping IDs with no task
+func mapIDs(ids []string, taskIDs map[string]string) []string {
+	if len(ids) == 0 {
+		return nil
+	}
+	mapped := make([]string, len(ids))
+	for i, id := range ids {
+		if taskID, ok := taskIDs[id]; ok {
+			mapped[i] = taskID
+		} else {
+			mapped[i] = id
+		}
+	}
+	return mapped
+}
@@ -0,0 +1,113 @@
+package beads
+
+import (
+	"testing"
+
+	"github.com/rand/cartographer/internal/domain"
+	"github.com/steveyegge/beads"
+)
+
+func TestMapColumns(t *testing.T) {
+	columns := []domain.BoardColumn{
+		{ID: "done", Name: "Done", Order: 2},
+		{ID: "todo", Name: "To Do", Order: 0},
+		{ID: "col-2", Name: "In Progress", Order: 1},
+	}
+
+	mapping := MapColumns(columns)
+	expected := map[beads.Status]string{
+		beads.StatusOpen:       "todo",
+		beads.StatusInProgress: "col-2",
+		beads.StatusBlocked:    "col-2",
+		beads.StatusClosed:     "done",
+	}
+	for status, column := range expected {
+		if mapping[status] != column {
+			t.Errorf("Expected %s in column %s, got %s", status, column, mapping[status])
+		}
+	}
+
+	// Unrecognized columns fall back by position
+	mapping = MapColumns([]domain.BoardColumn{
+		{ID: "a", Name: "Later", Order: 0},
+		{ID: "b", Name: "Now", Order: 1},
+		{ID: "c", Name: "Shipped!", Order: 2},
+	})
+	if mapping[beads.StatusOpen] != "a" || mapping[beads.StatusInProgress] != "b" || mapping[beads.StatusClosed] != "c" {
+		t.Errorf("Unexpected fallback mapping %v", mapping)
+	}
+}
+
+func TestImportIntoBoard(t *testing.T) {
+	board := &domain.Board{
+		ID:        "board-1",
+		ProjectID: "project-1",
+		Columns: []domain.BoardColumn{
+			{ID: "todo", Name: "To Do", Order: 0},
+			{ID: "inprogress", Name: "In Progress", Order: 1},
+			{ID: "done", Name: "Done", Order: 2},
+		},
+	}
+	issues := createSampleBeads()
+	issues[1].Status = beads.StatusInProgress
+
+	tasks := &memoryTaskStore{tasks: make(map[string]*domain.Task)}
+
+	// bd-1 was linked earlier under a different task ID
+	tasks.Create(&domain.Task{
+		ID:          "task-1",
+		BoardID:     board.ID,
+		Title:       "Old title",
+		Status:      "todo",
+		LinkedItems: []domain.LinkedItem{{Type: "bead", ID: "bd-1"}},
+		Activity:    []domain.ActivityEntry{{Type: "commented", Comment: "keep me"}},
+	})
+	// bd-3 is already on another board
+	tasks.Create(&domain.Task{
+		ID:          "task-3",
+		BoardID:     "board-2",
+		LinkedItems: []domain.LinkedItem{{Type: "bead", ID: "bd-3"}},
+	})
+
+	report, imported, err := ImportIntoBoard(issues, board, tasks, "alice")
+	if err != nil {
+		t.Fatalf("ImportIntoBoard failed: %v", err)
+	}
+	if len(report.Created) != 1 || report.Created[0] != "bd-2" || len(imported) != 2 || !imported[1].Created {
+		t.Errorf("Expected bd-2 created, got %+v", report)
+	}
+	if len(report.Updated) != 1 || report.Updated[0] != "bd-1" || imported[0].Changes["title"].From != "Old title" {
+		t.Errorf("Expected bd-1 updated from its old title, got %+v", imported)
+	}
+	if len(report.Skipped) != 1 || report.Skipped[0].BeadID != "bd-3" || report.Skipped[0].Reason != SkipOtherBoard {
+		t.Errorf("Expected bd-3 skipped, got %+v", report.Skipped)
+	}
+
+	first, _ := tasks.GetByID("task-1")
+	if first.Title != issues[0].Title || len(first.Activity) != 2 || first.Activity[1].Type != domain.ActivityUpdated || first.Activity[1].User != "alice" {
+		t.Errorf("Expected bd-1 merged into existing task and the update recorded, got %+v", first)
+	}
+	// bd-2 depends on bd-1, so bd-1's task blocks bd-2's
+	if len(first.Blocks) != 1 || first.Blocks[0] != "bd-2" {
+		t.Errorf("Expected task-1 to block bd-2, got %v", first.Blocks)
+	}
+	second, _ := tasks.GetByID("bd-2")
+	if len(second.Dependencies) != 1 || second.Dependencies[0] != "task-1" {
+		t.Errorf("Expected bd-2 to depend on task-1, got %v", second.Dependencies)
+	}
+	if len(second.Activity) == 0 || second.Activity[0].Type != domain.ActivityCreated {
+		t.Errorf("Expected bd-2's activity to start with its creation, got %+v", second.Activity)
+	}
+	if second.Status != "inprogress" {
+		t.Errorf("Expected in_progress bead in the inprogress column, got %s", second.Status)
+	}
+
+	// A second import changes nothing
+	report, _, err = ImportIntoBoard(issues, board, tasks, "alice")
+	if err != nil {
+		t.Fatalf("ImportIntoBoard failed: %v", err)
+	}
+	if len(report.Created) != 0 || len(report.Updated) != 0 || len(report.Skipped) != 3 {
+		t.Errorf("Expected everything skipped on re-import, got %+v", report)
+	}
+}
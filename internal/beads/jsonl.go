@@ -0,0 +1,220 @@
+package beads
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/steveyegge/beads"
+)
+
+// errIssuesFileChanged is returned when the issues file was modified
+// between reading and writing it
+var errIssuesFileChanged = errors.New("beads file changed while syncing")
+
+// issuesFile is an issues file held line by line so that writing it back
+// changes only the lines of patched issues. Within a patched line, unknown
+// keys, comments and key order are kept as they were.
+type issuesFile struct {
+	path  string
+	lines [][]byte
+	index map[string]int // issue ID to line number
+	order []string       // issue IDs in file order
+	mode  os.FileMode
+	data  []byte // contents as read, to detect concurrent writes
+	dirty bool
+}
+
+// readIssuesFile reads the issues file at path
+func readIssuesFile(path string) (*issuesFile, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("beads file not found at %s", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read beads file: %w", err)
+	}
+
+	f := &issuesFile{
+		path:  path,
+		lines: bytes.Split(data, []byte("\n")),
+		index: make(map[string]int),
+		mode:  info.Mode().Perm(),
+		data:  data,
+	}
+
+	for i, line := range f.lines {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		var header struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal(line, &header); err != nil {
+			return nil, fmt.Errorf("error parsing line %d: %w", i+1, err)
+		}
+		if _, dup := f.index[header.ID]; !dup {
+			f.order = append(f.order, header.ID)
+		}
+		f.index[header.ID] = i
+	}
+
+	return f, nil
+}
+
+// ids returns the issue IDs in file order
+func (f *issuesFile) ids() []string {
+	return append([]string(nil), f.order...)
+}
+
+// issue parses an issue by ID, or returns nil if the file has no such issue
+func (f *issuesFile) issue(id string) (*beads.Issue, error) {
+	i, ok := f.index[id]
+	if !ok {
+		return nil, nil
+	}
+
+	var issue beads.Issue
+	if err := json.Unmarshal(bytes.TrimSpace(f.lines[i]), &issue); err != nil {
+		return nil, fmt.Errorf("error parsing line %d: %w", i+1, err)
+	}
+	if err := issue.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid issue on line %d: %w", i+1, err)
+	}
+	return &issue, nil
+}
+
+// patch sets keys on an issue's line. A nil value removes the key.
+func (f *issuesFile) patch(id string, values map[string]json.RawMessage) error {
+	i, ok := f.index[id]
+	if !ok {
+		return fmt.Errorf("bead not found: %s", id)
+	}
+
+	line := f.lines[i]
+	trimmed := bytes.TrimRight(line, "\r")
+	patched, err := patchObject(trimmed, values)
+	if err != nil {
+		return fmt.Errorf("error patching line %d: %w", i+1, err)
+	}
+
+	f.lines[i] = append(patched, line[len(trimmed):]...)
+	f.dirty = true
+	return nil
+}
+
+// save atomically replaces the issues file with the patched lines, failing
+// with errIssuesFileChanged if someone else wrote it since it was read
+func (f *issuesFile) save() error {
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), ".issues.jsonl.*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary beads file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(bytes.Join(f.lines, []byte("\n"))); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write beads file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync beads file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write beads file: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), f.mode); err != nil {
+		return fmt.Errorf("failed to set beads file mode: %w", err)
+	}
+
+	// Modification times are too coarse to catch a write made just after
+	// ours was read, so compare contents
+	current, err := os.ReadFile(f.path)
+	if err != nil || !bytes.Equal(current, f.data) {
+		return errIssuesFileChanged
+	}
+
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		return fmt.Errorf("failed to replace beads file: %w", err)
+	}
+	f.dirty = false
+	return nil
+}
+
+// patchObject rewrites a JSON object with the given keys replaced, added
+// (at the end) or, for nil values, removed. Other keys keep their order and
+// exact encoding.
+func patchObject(data []byte, values map[string]json.RawMessage) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return nil, fmt.Errorf("expected a JSON object")
+	}
+
+	var out bytes.Buffer
+	out.WriteByte('{')
+	written := make(map[string]bool, len(values))
+	first := true
+
+	writeField := func(key string, value json.RawMessage) {
+		if !first {
+			out.WriteByte(',')
+		}
+		first = false
+		name, _ := json.Marshal(key)
+		out.Write(name)
+		out.WriteByte(':')
+		out.Write(value)
+	}
+
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected an object key")
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+
+		if replacement, ok := values[key]; ok {
+			written[key] = true
+			if replacement == nil {
+				continue
+			}
+			value = replacement
+		}
+		writeField(key, value)
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !written[key] && values[key] != nil {
+			writeField(key, values[key])
+		}
+	}
+
+	out.WriteByte('}')
+	return out.Bytes(), nil
+}
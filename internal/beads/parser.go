@@ -24,11 +24,16 @@ func NewParser(projectPath string) *Parser {
 	}
 }
 
+// Path returns the path of the project's .beads/issues.jsonl file
+func (p *Parser) Path() string {
+	return filepath.Join(p.projectPath, ".beads", "issues.jsonl")
+}
+
 // ReadBeadsFromProject reads beads from the .beads/issues.jsonl file in a project
 // Returns a slice of beads and any error encountered
 func (p *Parser) ReadBeadsFromProject() ([]*beads.Issue, error) {
 	// Construct path to .beads/issues.jsonl
-	jsonlPath := filepath.Join(p.projectPath, ".beads", "issues.jsonl")
+	jsonlPath := p.Path()
 
 	// Check if file exists
 	if _, err := os.Stat(jsonlPath); os.IsNotExist(err) {
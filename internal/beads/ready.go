@@ -0,0 +1,108 @@
+package beads
+
+import (
+	"github.com/rand/cartographer/internal/domain"
+	"github.com/steveyegge/beads"
+)
+
+// ReadyTasks returns the tasks of a board that are ready to work on, by
+// analyzing them as beads issues: a task blocking another counts as a
+// dependency of it, and tasks in done columns are closed. Dependencies on
+// tasks of other boards are ignored.
+func ReadyTasks(tasks []*domain.Task) ([]*domain.Task, error) {
+	analyzer, err := taskAnalyzer(tasks)
+	if err != nil {
+		return nil, err
+	}
+	readyIssues, err := analyzer.GetReadyIssues()
+	if err != nil {
+		return nil, err
+	}
+	isReady := make(map[string]bool, len(readyIssues))
+	for _, issue := range readyIssues {
+		isReady[issue.ID] = true
+	}
+
+	var ready []*domain.Task
+	for _, task := range tasks {
+		if isReady[task.ID] {
+			ready = append(ready, task)
+		}
+	}
+	return ready, nil
+}
+
+// BlockedTask is a task with the open tasks of its board blocking it
+type BlockedTask struct {
+	Task      *domain.Task
+	BlockedBy []*domain.Task
+}
+
+// BlockedTasks returns the open tasks of a board that open tasks block, in
+// board order, analyzed as in ReadyTasks
+func BlockedTasks(tasks []*domain.Task) ([]BlockedTask, error) {
+	analyzer, err := taskAnalyzer(tasks)
+	if err != nil {
+		return nil, err
+	}
+	infos, err := analyzer.GetBlockedIssues()
+	if err != nil {
+		return nil, err
+	}
+	blockers := make(map[string][]string, len(infos))
+	for _, info := range infos {
+		blockers[info.IssueID] = info.BlockedBy
+	}
+	byID := make(map[string]*domain.Task, len(tasks))
+	for _, task := range tasks {
+		byID[task.ID] = task
+	}
+
+	var blocked []BlockedTask
+	for _, task := range tasks {
+		ids := blockers[task.ID]
+		if len(ids) == 0 {
+			continue
+		}
+		entry := BlockedTask{Task: task}
+		for _, id := range ids {
+			entry.BlockedBy = append(entry.BlockedBy, byID[id])
+		}
+		blocked = append(blocked, entry)
+	}
+	return blocked, nil
+}
+
+// taskAnalyzer returns an analyzer of a board's tasks as beads issues, with
+// its dependency graph built
+func taskAnalyzer(tasks []*domain.Task) (*Analyzer, error) {
+	issues := make([]*beads.Issue, 0, len(tasks))
+	byID := make(map[string]*beads.Issue, len(tasks))
+	for _, task := range tasks {
+		issue, err := ConvertTaskToBead(task)
+		if err != nil {
+			return nil, err
+		}
+		issues = append(issues, issue)
+		byID[task.ID] = issue
+	}
+	for _, task := range tasks {
+		for _, blocked := range task.Blocks {
+			if issue := byID[blocked]; issue != nil {
+				issue.Dependencies = append(issue.Dependencies, &beads.Dependency{IssueID: blocked, DependsOnID: task.ID, Type: beads.DepBlocks})
+			}
+		}
+	}
+
+	analyzer := NewAnalyzer(issues)
+	if _, err := analyzer.BuildDependencyGraph(); err != nil {
+		return nil, err
+	}
+	return analyzer, nil
+}
+
+// TaskPriority returns the task priority a beads issue priority maps to, so
+// issues and tasks can be ranked together
+func TaskPriority(beadPriority int) string {
+	return convertPriority(beadPriority)
+}
@@ -0,0 +1,291 @@
+package beads
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/rand/cartographer/internal/domain"
+	"github.com/steveyegge/beads"
+)
+
+// DefaultEstimateMinutes is the duration assumed for work with no estimate
+const DefaultEstimateMinutes = 60
+
+// ScheduleItem is a unit of work to schedule, either a bead or a task
+type ScheduleItem struct {
+	ID        string
+	Minutes   *int // nil when unestimated
+	DependsOn []string
+	Done      bool
+}
+
+// ScheduleEntry is the timing of one item. Times are minutes from the start
+// of the schedule, and slack is how long the item can slip without delaying
+// the whole schedule.
+type ScheduleEntry struct {
+	ID             string `json:"id"`
+	Minutes        int    `json:"minutes"`
+	Estimated      bool   `json:"estimated"`
+	EarliestStart  int    `json:"earliest_start"`
+	EarliestFinish int    `json:"earliest_finish"`
+	LatestStart    int    `json:"latest_start"`
+	LatestFinish   int    `json:"latest_finish"`
+	Slack          int    `json:"slack"`
+	Critical       bool   `json:"critical"`
+}
+
+// Schedule is the critical path analysis of a set of items. Done items are
+// left out and dependencies on them count as satisfied.
+type Schedule struct {
+	Order        []string        `json:"order"`
+	CriticalPath []string        `json:"critical_path"`
+	TotalMinutes int             `json:"total_minutes"`
+	Entries      []ScheduleEntry `json:"entries"` // in topological order
+	Unestimated  []string        `json:"unestimated"`
+}
+
+// CycleError is returned when items can't be ordered because their
+// dependencies form a cycle
+type CycleError struct {
+	IDs []string // items on or behind a cycle
+}
+
+func (e *CycleError) Error() string {
+	return fmt.Sprintf("dependency cycle among %s", strings.Join(e.IDs, ", "))
+}
+
+// TopologicalOrder returns the issue IDs ordered so that every issue comes
+// after the issues it depends on. Ties are broken by ID.
+func (a *Analyzer) TopologicalOrder() ([]string, error) {
+	graph, err := a.BuildDependencyGraph()
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]ScheduleItem, len(a.issues))
+	for i, issue := range a.issues {
+		items[i] = ScheduleItem{ID: issue.ID, DependsOn: graph.DependsOn[issue.ID]}
+	}
+	return topologicalOrder(items)
+}
+
+// CriticalPath schedules the issues that aren't closed by their blocking
+// dependencies, weighting each by EstimatedMinutes. Issues with no estimate
+// take defaultMinutes.
+func (a *Analyzer) CriticalPath(defaultMinutes int) (*Schedule, error) {
+	graph, err := a.BuildDependencyGraph()
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]ScheduleItem, len(a.issues))
+	for i, issue := range a.issues {
+		items[i] = ScheduleItem{
+			ID:        issue.ID,
+			Minutes:   issue.EstimatedMinutes,
+			DependsOn: graph.DependsOn[issue.ID],
+			Done:      issue.Status == beads.StatusClosed,
+		}
+	}
+	return BuildSchedule(items, defaultMinutes)
+}
+
+// TaskSchedule runs the same analysis over a board's tasks, using their
+// Dependencies, Blocks and Estimate. Tasks in a done column count as done.
+func TaskSchedule(tasks []*domain.Task, defaultMinutes int) (*Schedule, error) {
+	dependsOn := make(map[string][]string)
+	for _, task := range tasks {
+		dependsOn[task.ID] = append(dependsOn[task.ID], task.Dependencies...)
+		for _, blocked := range task.Blocks {
+			dependsOn[blocked] = append(dependsOn[blocked], task.ID)
+		}
+	}
+
+	items := make([]ScheduleItem, len(tasks))
+	for i, task := range tasks {
+		status, _ := ColumnStatus(task.Status)
+		items[i] = ScheduleItem{
+			ID:        task.ID,
+			DependsOn: dependsOn[task.ID],
+			Done:      status == beads.StatusClosed,
+		}
+		if task.Estimate != nil {
+			minutes := int(math.Round(*task.Estimate * 60))
+			items[i].Minutes = &minutes
+		}
+	}
+	return BuildSchedule(items, defaultMinutes)
+}
+
+// BuildSchedule computes earliest and latest start and finish times, slack
+// and the critical path for items. Dependencies on items not in the list
+// are ignored.
+func BuildSchedule(items []ScheduleItem, defaultMinutes int) (*Schedule, error) {
+	var open []ScheduleItem
+	for _, item := range items {
+		if !item.Done {
+			open = append(open, item)
+		}
+	}
+
+	order, err := topologicalOrder(open)
+	if err != nil {
+		return nil, err
+	}
+
+	schedule := &Schedule{
+		Order:        order,
+		CriticalPath: []string{},
+		Entries:      make([]ScheduleEntry, len(order)),
+		Unestimated:  []string{},
+	}
+
+	byID := make(map[string]ScheduleItem, len(open))
+	for _, item := range open {
+		byID[item.ID] = item
+	}
+	position := make(map[string]int, len(order))
+	for i, id := range order {
+		position[id] = i
+	}
+
+	// Forward pass: an item starts once everything it depends on finishes
+	deps := make([][]int, len(order))
+	successors := make([][]int, len(order))
+	for i, id := range order {
+		item := byID[id]
+		entry := &schedule.Entries[i]
+		entry.ID = id
+		entry.Minutes = defaultMinutes
+		if item.Minutes != nil {
+			entry.Minutes = *item.Minutes
+			entry.Estimated = true
+		} else {
+			schedule.Unestimated = append(schedule.Unestimated, id)
+		}
+
+		for _, depID := range item.DependsOn {
+			j, ok := position[depID]
+			if !ok {
+				continue
+			}
+			deps[i] = append(deps[i], j)
+			successors[j] = append(successors[j], i)
+			if finish := schedule.Entries[j].EarliestFinish; finish > entry.EarliestStart {
+				entry.EarliestStart = finish
+			}
+		}
+		entry.EarliestFinish = entry.EarliestStart + entry.Minutes
+		if entry.EarliestFinish > schedule.TotalMinutes {
+			schedule.TotalMinutes = entry.EarliestFinish
+		}
+	}
+
+	// Backward pass: an item must finish before anything depending on it
+	// has to start
+	for i := len(order) - 1; i >= 0; i-- {
+		entry := &schedule.Entries[i]
+		entry.LatestFinish = schedule.TotalMinutes
+		for _, j := range successors[i] {
+			if start := schedule.Entries[j].LatestStart; start < entry.LatestFinish {
+				entry.LatestFinish = start
+			}
+		}
+		entry.LatestStart = entry.LatestFinish - entry.Minutes
+		entry.Slack = entry.LatestStart - entry.EarliestStart
+		entry.Critical = entry.Slack == 0
+	}
+
+	// Walk back from the item finishing last through critical dependencies
+	// that finish exactly when it starts
+	current := -1
+	for i, entry := range schedule.Entries {
+		if entry.Critical && entry.EarliestFinish == schedule.TotalMinutes {
+			current = i
+			break
+		}
+	}
+	var path []string
+	for current >= 0 {
+		path = append(path, order[current])
+		next := -1
+		for _, j := range deps[current] {
+			dep := schedule.Entries[j]
+			if dep.Critical && dep.EarliestFinish == schedule.Entries[current].EarliestStart && (next < 0 || dep.ID < order[next]) {
+				next = j
+			}
+		}
+		current = next
+	}
+	for i := len(path) - 1; i >= 0; i-- {
+		schedule.CriticalPath = append(schedule.CriticalPath, path[i])
+	}
+
+	return schedule, nil
+}
+
+// topologicalOrder sorts items so that each follows the items it depends on,
+// taking the lowest ID whenever several are ready
+func topologicalOrder(items []ScheduleItem) ([]string, error) {
+	known := make(map[string]bool, len(items))
+	unique := items[:0:0]
+	for _, item := range items {
+		if !known[item.ID] {
+			known[item.ID] = true
+			unique = append(unique, item)
+		}
+	}
+	items = unique
+
+	pending := make(map[string]int, len(items))
+	dependents := make(map[string][]string)
+	for _, item := range items {
+		seen := make(map[string]bool)
+		for _, depID := range item.DependsOn {
+			if !known[depID] || seen[depID] {
+				continue
+			}
+			seen[depID] = true
+			pending[item.ID]++
+			dependents[depID] = append(dependents[depID], item.ID)
+		}
+	}
+
+	var ready []string
+	for _, item := range items {
+		if pending[item.ID] == 0 {
+			ready = append(ready, item.ID)
+		}
+	}
+	sort.Strings(ready)
+
+	order := make([]string, 0, len(items))
+	for len(ready) > 0 {
+		id := ready[0]
+		ready = ready[1:]
+		order = append(order, id)
+
+		for _, dependent := range dependents[id] {
+			pending[dependent]--
+			if pending[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+		sort.Strings(ready)
+	}
+
+	if len(order) < len(items) {
+		var stuck []string
+		for _, item := range items {
+			if pending[item.ID] > 0 {
+				stuck = append(stuck, item.ID)
+			}
+		}
+		sort.Strings(stuck)
+		return nil, &CycleError{IDs: stuck}
+	}
+
+	return order, nil
+}
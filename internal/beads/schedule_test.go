@@ -0,0 +1,113 @@
+package beads
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/rand/cartographer/internal/domain"
+	"github.com/steveyegge/beads"
+)
+
+func TestBuildSchedule(t *testing.T) {
+	minutes := func(m int) *int { return &m }
+
+	// a -> b -> d is 30+60+10, a -> c -> d is 30+20+10
+	items := []ScheduleItem{
+		{ID: "d", Minutes: minutes(10), DependsOn: []string{"b", "c"}},
+		{ID: "c", Minutes: minutes(20), DependsOn: []string{"a"}},
+		{ID: "b", DependsOn: []string{"a", "missing"}},
+		{ID: "a", Minutes: minutes(30), DependsOn: []string{"done"}},
+		{ID: "done", Minutes: minutes(500), Done: true},
+	}
+
+	schedule, err := BuildSchedule(items, 60)
+	if err != nil {
+		t.Fatalf("BuildSchedule failed: %v", err)
+	}
+
+	if want := []string{"a", "b", "c", "d"}; !reflect.DeepEqual(schedule.Order, want) {
+		t.Errorf("Expected order %v, got %v", want, schedule.Order)
+	}
+	if want := []string{"a", "b", "d"}; !reflect.DeepEqual(schedule.CriticalPath, want) {
+		t.Errorf("Expected critical path %v, got %v", want, schedule.CriticalPath)
+	}
+	if schedule.TotalMinutes != 100 {
+		t.Errorf("Expected 100 minutes, got %d", schedule.TotalMinutes)
+	}
+	if want := []string{"b"}; !reflect.DeepEqual(schedule.Unestimated, want) {
+		t.Errorf("Expected %v unestimated, got %v", want, schedule.Unestimated)
+	}
+
+	c := schedule.Entries[2]
+	if c.ID != "c" || c.EarliestStart != 30 || c.LatestStart != 70 || c.Slack != 40 || c.Critical {
+		t.Errorf("Unexpected entry for c: %+v", c)
+	}
+	d := schedule.Entries[3]
+	if d.EarliestStart != 90 || d.LatestFinish != 100 || !d.Critical {
+		t.Errorf("Unexpected entry for d: %+v", d)
+	}
+
+	items[3].DependsOn = []string{"d"}
+	_, err = BuildSchedule(items, 60)
+	var cycle *CycleError
+	if !errors.As(err, &cycle) || !reflect.DeepEqual(cycle.IDs, []string{"a", "b", "c", "d"}) {
+		t.Errorf("Expected a cycle through a, b, c and d, got %v", err)
+	}
+}
+
+func TestAnalyzerCriticalPath(t *testing.T) {
+	issues := createSampleBeads()
+	estimate := 90
+	issues[0].EstimatedMinutes = &estimate
+
+	analyzer := NewAnalyzer(issues)
+	order, err := analyzer.TopologicalOrder()
+	if err != nil {
+		t.Fatalf("TopologicalOrder failed: %v", err)
+	}
+	if len(order) != len(issues) || order[0] != "bd-1" {
+		t.Errorf("Expected bd-1 first, got %v", order)
+	}
+
+	schedule, err := analyzer.CriticalPath(DefaultEstimateMinutes)
+	if err != nil {
+		t.Fatalf("CriticalPath failed: %v", err)
+	}
+	if len(schedule.CriticalPath) < 2 || schedule.CriticalPath[0] != "bd-1" || schedule.CriticalPath[1] != "bd-2" {
+		t.Errorf("Expected critical path through bd-1 and bd-2, got %v", schedule.CriticalPath)
+	}
+	for _, entry := range schedule.Entries {
+		if entry.ID == "bd-1" && (entry.Minutes != 90 || !entry.Estimated) {
+			t.Errorf("Expected bd-1 to use its estimate, got %+v", entry)
+		}
+	}
+}
+
+func TestTaskSchedule(t *testing.T) {
+	hours := func(h float64) *float64 { return &h }
+	tasks := []*domain.Task{
+		{ID: "t1", Status: "todo", Estimate: hours(2), Blocks: []string{"t2"}},
+		{ID: "t2", Status: "in_progress", Estimate: hours(0.5), Dependencies: []string{"t0", "elsewhere"}},
+		{ID: "t0", Status: "done", Estimate: hours(8)},
+		{ID: "t3", Status: "todo"},
+	}
+
+	schedule, err := TaskSchedule(tasks, 30)
+	if err != nil {
+		t.Fatalf("TaskSchedule failed: %v", err)
+	}
+	if want := []string{"t1", "t2"}; !reflect.DeepEqual(schedule.CriticalPath, want) {
+		t.Errorf("Expected critical path %v, got %v", want, schedule.CriticalPath)
+	}
+	if schedule.TotalMinutes != 150 {
+		t.Errorf("Expected 150 minutes, got %d", schedule.TotalMinutes)
+	}
+	if len(schedule.Entries) != 3 || schedule.Entries[2].ID != "t3" || schedule.Entries[2].Slack != 120 {
+		t.Errorf("Expected t3 with 120 minutes of slack, got %+v", schedule.Entries)
+	}
+
+	if status, _ := ColumnStatus(tasks[2].Status); status != beads.StatusClosed {
+		t.Errorf("Expected done column to be closed, got %s", status)
+	}
+}
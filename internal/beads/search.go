@@ -0,0 +1,180 @@
+// Package beads provides integration with the Beads issue tracking framework.
+package beads
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rand/cartographer/internal/domain"
+	"github.com/steveyegge/beads"
+)
+
+// titleWeight boosts matches in an issue's title over its other text,
+// matching the weighting used for tasks and documents in storage
+const titleWeight = 10
+
+// SearchIndex is an in-memory inverted index over beads issues.
+// When created with a parser it re-reads the issues file whenever its
+// modification time or size changes; otherwise callers feed it via Index.
+type SearchIndex struct {
+	parser *Parser
+
+	mu       sync.RWMutex
+	issues   map[string]*beads.Issue
+	bodies   map[string]string         // searchable text other than the title
+	postings map[string]map[string]int // term -> issue ID -> weighted frequency
+	modTime  time.Time
+	size     int64
+}
+
+// NewSearchIndex creates a search index. The parser may be nil.
+func NewSearchIndex(parser *Parser) *SearchIndex {
+	idx := &SearchIndex{parser: parser}
+	idx.Index(nil)
+	return idx
+}
+
+// Index replaces the indexed issues
+func (idx *SearchIndex) Index(issues []*beads.Issue) {
+	byID := make(map[string]*beads.Issue, len(issues))
+	bodies := make(map[string]string, len(issues))
+	postings := make(map[string]map[string]int)
+
+	add := func(id, text string, weight int) {
+		for _, term := range domain.SearchTerms(text) {
+			if postings[term] == nil {
+				postings[term] = make(map[string]int)
+			}
+			postings[term][id] += weight
+		}
+	}
+
+	for _, issue := range issues {
+		body := strings.Join([]string{
+			issue.Description, issue.Design, issue.AcceptanceCriteria, issue.Notes,
+			strings.Join(issue.Labels, " "),
+		}, "\n")
+
+		byID[issue.ID] = issue
+		bodies[issue.ID] = body
+		add(issue.ID, issue.ID+" "+issue.Title, titleWeight)
+		add(issue.ID, body, 1)
+	}
+
+	idx.mu.Lock()
+	idx.issues = byID
+	idx.bodies = bodies
+	idx.postings = postings
+	idx.mu.Unlock()
+}
+
+// Search returns issues matching every term of the query (as a prefix of
+// some indexed word), ranked by weighted term frequency
+func (idx *SearchIndex) Search(query string, limit int) ([]domain.SearchHit, error) {
+	if err := idx.refresh(); err != nil {
+		return nil, err
+	}
+
+	terms := domain.SearchTerms(query)
+	if len(terms) == 0 {
+		return []domain.SearchHit{}, nil
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	var scores map[string]int
+	for _, term := range terms {
+		termScores := make(map[string]int)
+		for word, ids := range idx.postings {
+			if !strings.HasPrefix(word, term) {
+				continue
+			}
+			for id, weight := range ids {
+				termScores[id] += weight
+			}
+		}
+
+		// Every term must match: intersect with the previous terms
+		if scores == nil {
+			scores = termScores
+			continue
+		}
+		for id := range scores {
+			if s, ok := termScores[id]; ok {
+				scores[id] += s
+			} else {
+				delete(scores, id)
+			}
+		}
+	}
+
+	hits := make([]domain.SearchHit, 0, len(scores))
+	for id, score := range scores {
+		issue := idx.issues[id]
+		snippetSource := idx.bodies[id]
+		if domain.CountMatches(snippetSource, terms) == 0 {
+			snippetSource = issue.Title
+		}
+
+		hits = append(hits, domain.SearchHit{
+			Type:    domain.SearchTypeBead,
+			ID:      issue.ID,
+			Title:   issue.Title,
+			Snippet: domain.HighlightSnippet(snippetSource, terms, 160),
+			Score:   float64(score),
+		})
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+
+	return hits, nil
+}
+
+// refresh re-reads the issues file if it changed since it was last indexed.
+// A project without a beads file simply has nothing indexed.
+func (idx *SearchIndex) refresh() error {
+	if idx.parser == nil {
+		return nil
+	}
+
+	info, err := os.Stat(idx.parser.Path())
+	if os.IsNotExist(err) {
+		idx.Index(nil)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	idx.mu.RLock()
+	unchanged := info.ModTime().Equal(idx.modTime) && info.Size() == idx.size
+	idx.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	issues, err := idx.parser.ReadBeadsFromProject()
+	if err != nil {
+		return err
+	}
+	idx.Index(issues)
+
+	idx.mu.Lock()
+	idx.modTime = info.ModTime()
+	idx.size = info.Size()
+	idx.mu.Unlock()
+
+	return nil
+}
@@ -0,0 +1,693 @@
+package beads
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rand/cartographer/internal/domain"
+	"github.com/steveyegge/beads"
+)
+
+// Conflict resolution choices: keep the bead's values or the task's
+const (
+	KeepBead = "bead"
+	KeepTask = "task"
+)
+
+// Sync outcomes for a single bead
+const (
+	SyncUpdated   = "updated"
+	SyncUnchanged = "unchanged"
+	SyncConflict  = "conflict"
+)
+
+// ErrNoConflict is returned when resolving a bead that has no conflict
+var ErrNoConflict = errors.New("bead has no sync conflict")
+
+// syncAttempts bounds retries when the issues file changes underneath a sync
+const syncAttempts = 3
+
+// TaskStore is the task storage a Syncer reads and writes
+type TaskStore interface {
+	Create(task *domain.Task) error
+	GetByID(id string) (*domain.Task, error)
+	Update(task *domain.Task) error
+	FindByLinkedItem(itemType, itemID string) (*domain.Task, error)
+}
+
+// BoardStore looks up boards so synced tasks land in the board's columns
+type BoardStore interface {
+	GetByID(id string) (*domain.Board, error)
+}
+
+// SyncStateStore persists the last synced state of each bead
+type SyncStateStore interface {
+	Get(beadID string) (*domain.BeadSyncState, error)
+	GetByTask(taskID string) (*domain.BeadSyncState, error)
+	ListConflicts() ([]*domain.BeadSyncState, error)
+	Save(state *domain.BeadSyncState) error
+	Delete(beadID string) error
+}
+
+// TaskChange describes a task updated by a sync
+type TaskChange struct {
+	Task *domain.Task
+	// Changes maps each changed task JSON field to its new value
+	Changes map[string]interface{}
+}
+
+// SyncReport lists the beads a sync touched by outcome
+type SyncReport struct {
+	Updated   []string `json:"updated"`
+	Unchanged []string `json:"unchanged"`
+	Conflicts []string `json:"conflicts"`
+}
+
+func newSyncReport() *SyncReport {
+	return &SyncReport{Updated: []string{}, Unchanged: []string{}, Conflicts: []string{}}
+}
+
+func (r *SyncReport) add(beadID, outcome string) {
+	switch outcome {
+	case SyncUpdated:
+		r.Updated = append(r.Updated, beadID)
+	case SyncUnchanged:
+		r.Unchanged = append(r.Unchanged, beadID)
+	case SyncConflict:
+		r.Conflicts = append(r.Conflicts, beadID)
+	}
+}
+
+// Syncer keeps tasks imported from beads and the issues file in step.
+// Each side's edits since the last sync are applied to the other; fields
+// edited differently on both sides are recorded as a conflict and left
+// alone until resolved.
+type Syncer struct {
+	watcher *Watcher
+	tasks   TaskStore
+	boards  BoardStore
+	state   SyncStateStore
+	logger  *log.Logger
+
+	// Serializes syncs and writes to the issues file
+	mu sync.Mutex
+
+	listenerMu        sync.RWMutex
+	taskListeners     []func(TaskChange)
+	conflictListeners []func(*domain.BeadSyncState)
+}
+
+// NewSyncer creates a syncer for the watcher's issues file. Register
+// HandleBeadChanges with the watcher so edits to the file reach tasks.
+func NewSyncer(watcher *Watcher, tasks TaskStore, boards BoardStore, state SyncStateStore, logger *log.Logger) *Syncer {
+	if logger == nil {
+		logger = log.Default()
+	}
+
+	return &Syncer{
+		watcher: watcher,
+		tasks:   tasks,
+		boards:  boards,
+		state:   state,
+		logger:  logger,
+	}
+}
+
+// OnTaskChange registers a function called for each task a sync updates
+func (s *Syncer) OnTaskChange(fn func(change TaskChange)) {
+	s.listenerMu.Lock()
+	defer s.listenerMu.Unlock()
+	s.taskListeners = append(s.taskListeners, fn)
+}
+
+// OnConflict registers a function called when a sync finds a new conflict
+func (s *Syncer) OnConflict(fn func(state *domain.BeadSyncState)) {
+	s.listenerMu.Lock()
+	defer s.listenerMu.Unlock()
+	s.conflictListeners = append(s.conflictListeners, fn)
+}
+
+// Sync syncs every bead already linked to a task
+func (s *Syncer) Sync() (*SyncReport, error) {
+	return s.run(nil, nil)
+}
+
+// Path returns the path of the issues file the syncer writes
+func (s *Syncer) Path() string {
+	return s.watcher.parser.Path()
+}
+
+// PushTask syncs the bead a task was imported from after the task changed.
+// Tasks not linked to a bead are ignored.
+func (s *Syncer) PushTask(taskID string) (*SyncReport, error) {
+	state, err := s.state.GetByTask(taskID)
+	if err != nil {
+		return nil, err
+	}
+	if state == nil {
+		return newSyncReport(), nil
+	}
+	return s.run([]string{state.BeadID}, nil)
+}
+
+// HandleBeadChanges syncs linked tasks with changes read from the issues
+// file. New beads are left until a board imports them with ImportIntoBoard.
+func (s *Syncer) HandleBeadChanges(changes []IssueChange) {
+	var ids []string
+	for _, change := range changes {
+		if change.Action == ChangeDeleted {
+			if err := s.state.Delete(change.Issue.ID); err != nil {
+				s.logger.Printf("Error unlinking deleted bead %s: %v", change.Issue.ID, err)
+			}
+			continue
+		}
+		ids = append(ids, change.Issue.ID)
+	}
+	if len(ids) == 0 {
+		return
+	}
+
+	if _, err := s.run(ids, nil); err != nil {
+		s.logger.Printf("Error syncing bead changes: %v", err)
+	}
+}
+
+// Conflicts returns every bead with unresolved conflicting edits
+func (s *Syncer) Conflicts() ([]*domain.BeadSyncState, error) {
+	return s.state.ListConflicts()
+}
+
+// Resolve settles a bead's conflict by keeping either the bead's values
+// (KeepBead) or the task's (KeepTask) for the conflicting fields
+func (s *Syncer) Resolve(beadID, keep string) (*SyncReport, error) {
+	if keep != KeepBead && keep != KeepTask {
+		return nil, fmt.Errorf("keep must be %q or %q", KeepBead, KeepTask)
+	}
+
+	state, err := s.state.Get(beadID)
+	if err != nil {
+		return nil, err
+	}
+	if state == nil || state.Conflict == nil {
+		return nil, ErrNoConflict
+	}
+
+	return s.run([]string{beadID}, map[string]string{beadID: keep})
+}
+
+// run syncs the given beads (every bead when ids is nil), retrying if the
+// issues file changes while it is being written
+func (s *Syncer) run(ids []string, resolve map[string]string) (*SyncReport, error) {
+	s.mu.Lock()
+	var report *SyncReport
+	var applied []*syncPlan
+	var wrote bool
+	var err error
+	for attempt := 1; attempt <= syncAttempts; attempt++ {
+		report, applied, wrote, err = s.syncOnce(ids, resolve)
+		if !errors.Is(err, errIssuesFileChanged) {
+			break
+		}
+	}
+	s.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.notify(applied)
+
+	// Pick up our own write now so the watcher reports it to other
+	// listeners; the re-sync it triggers finds nothing left to do
+	if wrote {
+		s.watcher.invalidate()
+		if _, err := s.watcher.Refresh(); err != nil {
+			s.logger.Printf("Beads watcher: %v", err)
+		}
+	}
+
+	return report, nil
+}
+
+// syncPlan is the outcome of merging one bead with its task, applied only
+// once the issues file has been written
+type syncPlan struct {
+	outcome     string
+	task        *domain.Task
+	taskChanges map[string]interface{}
+	state       *domain.BeadSyncState
+	newConflict bool
+}
+
+// syncOnce reads the issues file, merges each bead with its task, writes
+// the beads that changed and then persists tasks and sync state
+func (s *Syncer) syncOnce(ids []string, resolve map[string]string) (*SyncReport, []*syncPlan, bool, error) {
+	file, err := readIssuesFile(s.watcher.parser.Path())
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	if ids == nil {
+		ids = file.ids()
+	}
+
+	report := newSyncReport()
+	var plans []*syncPlan
+	for _, id := range ids {
+		issue, err := file.issue(id)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		if issue == nil {
+			continue
+		}
+
+		plan, err := s.plan(file, issue, resolve[id])
+		if err != nil {
+			return nil, nil, false, fmt.Errorf("failed to sync bead %s: %w", id, err)
+		}
+		if plan == nil {
+			continue
+		}
+		plans = append(plans, plan)
+		report.add(id, plan.outcome)
+	}
+
+	wrote := file.dirty
+	if wrote {
+		if err := file.save(); err != nil {
+			return nil, nil, false, err
+		}
+	}
+
+	for _, plan := range plans {
+		if len(plan.taskChanges) > 0 {
+			if err := s.tasks.Update(plan.task); err != nil {
+				return nil, nil, wrote, fmt.Errorf("failed to update task %s: %w", plan.task.ID, err)
+			}
+		}
+		if err := s.state.Save(plan.state); err != nil {
+			return nil, nil, wrote, fmt.Errorf("failed to save sync state for bead %s: %w", plan.state.BeadID, err)
+		}
+	}
+
+	return report, plans, wrote, nil
+}
+
+// plan merges a bead with its task, patching the issues file in memory.
+// Beads not linked to a task are skipped.
+func (s *Syncer) plan(file *issuesFile, issue *beads.Issue, keep string) (*syncPlan, error) {
+	now := time.Now()
+
+	state, err := s.state.Get(issue.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var task *domain.Task
+	if state != nil {
+		// A task deleted since the last sync unlinks the bead
+		if task, err = s.tasks.GetByID(state.TaskID); err != nil {
+			task, state = nil, nil
+		}
+	}
+	if task == nil {
+		if task, err = s.tasks.FindByLinkedItem("bead", issue.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	if task == nil {
+		return nil, nil
+	}
+
+	beadSide := beadFields(issue)
+
+	// A task linked before sync state existed takes the bead's values
+	base := beadSide
+	if state != nil {
+		base = state.Base
+	}
+	taskSide := taskFields(task, base, issue)
+
+	baseMap := fieldMap(base)
+	if keep != "" && state != nil && state.Conflict != nil {
+		// Pretend the losing side never changed the conflicting fields
+		loser := fieldMap(beadSide)
+		if keep == KeepBead {
+			loser = fieldMap(taskSide)
+		}
+		for _, name := range state.Conflict.Fields {
+			baseMap[name] = loser[name]
+		}
+	}
+
+	merged, toTask, toBead, conflicts := mergeFields(baseMap, fieldMap(beadSide), fieldMap(taskSide))
+
+	plan := &syncPlan{
+		outcome: SyncUnchanged,
+		task:    task,
+		state:   &domain.BeadSyncState{BeadID: issue.ID, TaskID: task.ID, Base: merged, SyncedAt: now},
+	}
+
+	if len(toTask) > 0 {
+		before := *task
+		plan.taskChanges = applyToTask(task, withFields(taskSide, toTask), taskSide, issue, s.converter(task.BoardID))
+		domain.RecordTaskUpdate(&before, task, "beads", now)
+	}
+	if len(toBead) > 0 {
+		if err := file.patch(issue.ID, beadPatch(issue, toBead, now)); err != nil {
+			return nil, err
+		}
+	}
+	if len(plan.taskChanges) > 0 || len(toBead) > 0 {
+		plan.outcome = SyncUpdated
+	}
+
+	if len(conflicts) > 0 {
+		plan.outcome = SyncConflict
+		plan.state.Conflict = &domain.BeadSyncConflict{
+			Fields:     conflicts,
+			Bead:       beadSide,
+			Task:       taskSide,
+			DetectedAt: now,
+		}
+		if state != nil && state.Conflict != nil && sameStrings(state.Conflict.Fields, conflicts) {
+			plan.state.Conflict.DetectedAt = state.Conflict.DetectedAt
+		} else {
+			plan.newConflict = true
+		}
+	}
+
+	return plan, nil
+}
+
+// converter returns a converter for the board's columns
+func (s *Syncer) converter(boardID string) *Converter {
+	if s.boards != nil {
+		if board, err := s.boards.GetByID(boardID); err == nil {
+			return NewBoardConverter(board)
+		}
+	}
+	return NewConverter("", boardID)
+}
+
+// notify calls listeners for tasks and conflicts a sync changed
+func (s *Syncer) notify(plans []*syncPlan) {
+	s.listenerMu.RLock()
+	taskListeners := s.taskListeners
+	conflictListeners := s.conflictListeners
+	s.listenerMu.RUnlock()
+
+	for _, plan := range plans {
+		if len(plan.taskChanges) > 0 {
+			change := TaskChange{Task: plan.task, Changes: plan.taskChanges}
+			for _, fn := range taskListeners {
+				fn(change)
+			}
+		}
+		if plan.newConflict {
+			for _, fn := range conflictListeners {
+				fn(plan.state)
+			}
+		}
+	}
+}
+
+// mergeFields performs a three-way merge of bead and task fields against
+// their last synced base. It returns the new base, the fields to copy to
+// each side and the fields changed differently on both.
+func mergeFields(base, bead, task map[string]json.RawMessage) (merged domain.BeadFields, toTask, toBead map[string]json.RawMessage, conflicts []string) {
+	result := make(map[string]json.RawMessage, len(base))
+	toTask = make(map[string]json.RawMessage)
+	toBead = make(map[string]json.RawMessage)
+
+	names := make([]string, 0, len(base))
+	for name := range base {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		beadChanged := !bytes.Equal(bead[name], base[name])
+		taskChanged := !bytes.Equal(task[name], base[name])
+
+		switch {
+		case beadChanged && taskChanged:
+			if bytes.Equal(bead[name], task[name]) {
+				result[name] = bead[name]
+			} else {
+				result[name] = base[name]
+				conflicts = append(conflicts, name)
+			}
+		case beadChanged:
+			result[name] = bead[name]
+			toTask[name] = bead[name]
+		case taskChanged:
+			result[name] = task[name]
+			toBead[name] = task[name]
+		default:
+			result[name] = base[name]
+		}
+	}
+
+	merged = withFields(domain.BeadFields{}, result)
+	return merged, toTask, toBead, conflicts
+}
+
+// beadFields returns the synced fields of a bead
+func beadFields(issue *beads.Issue) domain.BeadFields {
+	return domain.BeadFields{
+		Title:              issue.Title,
+		Description:        issue.Description,
+		Design:             issue.Design,
+		AcceptanceCriteria: issue.AcceptanceCriteria,
+		Notes:              issue.Notes,
+		Status:             string(issue.Status),
+		Priority:           issue.Priority,
+		Assignee:           issue.Assignee,
+		Labels:             normalizeLabels(issue.Labels),
+		EstimatedMinutes:   issue.EstimatedMinutes,
+	}
+}
+
+// taskFields returns a task's synced fields in beads terms. Values the
+// conversion cannot represent exactly (columns with no known status, the two lowest
+// priorities, estimates in fractions of a minute) count as unchanged from
+// base unless the task really moved away from them.
+func taskFields(task *domain.Task, base domain.BeadFields, issue *beads.Issue) domain.BeadFields {
+	fields := domain.BeadFields{
+		Title:    task.Title,
+		Status:   base.Status,
+		Priority: base.Priority,
+		Labels:   normalizeLabels(task.Labels),
+	}
+
+	fields.Description, fields.Design, fields.AcceptanceCriteria, fields.Notes = splitDescription(task.Description, issue)
+
+	if status, ok := ColumnStatus(task.Status); ok {
+		fields.Status = string(status)
+	}
+	if convertPriority(base.Priority) != task.Priority {
+		fields.Priority = convertPriorityToBead(task.Priority)
+	}
+	if task.Assignee != nil {
+		fields.Assignee = task.Assignee.ID
+	}
+	if task.Estimate != nil {
+		minutes := int(math.Round(*task.Estimate * 60))
+		fields.EstimatedMinutes = &minutes
+	}
+
+	return fields
+}
+
+// applyToTask copies changed fields onto a task and returns the changed
+// task JSON fields
+func applyToTask(task *domain.Task, target, current domain.BeadFields, issue *beads.Issue, converter *Converter) map[string]interface{} {
+	changes := make(map[string]interface{})
+
+	if target.Title != current.Title {
+		task.Title = target.Title
+		changes["title"] = task.Title
+	}
+	if target.Description != current.Description || target.Design != current.Design ||
+		target.AcceptanceCriteria != current.AcceptanceCriteria || target.Notes != current.Notes {
+		rendered := *issue
+		rendered.Description = target.Description
+		rendered.Design = target.Design
+		rendered.AcceptanceCriteria = target.AcceptanceCriteria
+		rendered.Notes = target.Notes
+		task.Description = buildDescription(&rendered)
+		changes["description"] = task.Description
+	}
+	if target.Status != current.Status {
+		task.Status = converter.column(beads.Status(target.Status))
+		changes["status"] = task.Status
+	}
+	if target.Priority != current.Priority {
+		task.Priority = convertPriority(target.Priority)
+		changes["priority"] = task.Priority
+	}
+	if target.Assignee != current.Assignee {
+		task.Assignee = nil
+		if target.Assignee != "" {
+			task.Assignee = &domain.Assignee{Type: "human", ID: target.Assignee, Name: target.Assignee}
+		}
+		changes["assignee"] = task.Assignee
+	}
+	if !sameStrings(target.Labels, current.Labels) {
+		task.Labels = target.Labels
+		changes["labels"] = task.Labels
+	}
+	if !sameMinutes(target.EstimatedMinutes, current.EstimatedMinutes) {
+		task.Estimate = nil
+		if target.EstimatedMinutes != nil {
+			hours := float64(*target.EstimatedMinutes) / 60.0
+			task.Estimate = &hours
+		}
+		changes["estimate"] = task.Estimate
+	}
+
+	return changes
+}
+
+// beadPatch returns the JSON keys to set on a bead's line for the changed
+// fields, with empty optional fields removed. updated_at is always bumped
+// and closed_at follows the status.
+func beadPatch(issue *beads.Issue, changed map[string]json.RawMessage, now time.Time) map[string]json.RawMessage {
+	patch := make(map[string]json.RawMessage, len(changed)+2)
+	for name, value := range changed {
+		if isRequiredField(name) || !isEmptyJSON(value) {
+			patch[name] = value
+		} else {
+			patch[name] = nil
+		}
+	}
+
+	stamp, _ := json.Marshal(now.UTC())
+	patch["updated_at"] = stamp
+
+	if raw, ok := changed["status"]; ok {
+		var status beads.Status
+		json.Unmarshal(raw, &status)
+		switch {
+		case status == beads.StatusClosed && issue.Status != beads.StatusClosed:
+			patch["closed_at"] = stamp
+		case status != beads.StatusClosed && issue.Status == beads.StatusClosed:
+			patch["closed_at"] = nil
+		}
+	}
+
+	return patch
+}
+
+// isRequiredField reports whether a bead field is written even when empty
+func isRequiredField(name string) bool {
+	switch name {
+	case "title", "description", "status", "priority":
+		return true
+	default:
+		return false
+	}
+}
+
+// isEmptyJSON reports whether a JSON value is null, "" or []
+func isEmptyJSON(value json.RawMessage) bool {
+	switch string(bytes.TrimSpace(value)) {
+	case "null", `""`, "[]":
+		return true
+	default:
+		return false
+	}
+}
+
+// splitDescription reverses buildDescription, separating a task
+// description into the bead's description, design, acceptance criteria
+// and notes. The generated external reference and compaction lines are
+// dropped.
+func splitDescription(desc string, issue *beads.Issue) (description, design, acceptance, notes string) {
+	trailer := buildDescription(&beads.Issue{
+		ExternalRef:     issue.ExternalRef,
+		CompactionLevel: issue.CompactionLevel,
+		OriginalSize:    issue.OriginalSize,
+	})
+	desc = strings.TrimSuffix(desc, trailer)
+
+	sections := []struct {
+		heading string
+		value   *string
+	}{
+		{"Notes", &notes},
+		{"Acceptance Criteria", &acceptance},
+		{"Design", &design},
+	}
+	for _, section := range sections {
+		marker := "\n\n## " + section.heading + "\n\n"
+		if i := strings.LastIndex(desc, marker); i >= 0 {
+			*section.value = desc[i+len(marker):]
+			desc = desc[:i]
+		}
+	}
+
+	return desc, design, acceptance, notes
+}
+
+// fieldMap returns synced fields by JSON name
+func fieldMap(fields domain.BeadFields) map[string]json.RawMessage {
+	m := make(map[string]json.RawMessage)
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return m
+	}
+	json.Unmarshal(data, &m)
+	return m
+}
+
+// withFields returns fields with the given JSON values overlaid
+func withFields(fields domain.BeadFields, values map[string]json.RawMessage) domain.BeadFields {
+	m := fieldMap(fields)
+	for name, value := range values {
+		m[name] = value
+	}
+	data, _ := json.Marshal(m)
+	var result domain.BeadFields
+	json.Unmarshal(data, &result)
+	result.Labels = normalizeLabels(result.Labels)
+	return result
+}
+
+// normalizeLabels treats no labels and an empty list alike
+func normalizeLabels(labels []string) []string {
+	if len(labels) == 0 {
+		return nil
+	}
+	return labels
+}
+
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sameMinutes(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,254 @@
+package beads
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rand/cartographer/internal/domain"
+)
+
+// memoryTaskStore is an in-memory TaskStore
+type memoryTaskStore struct {
+	tasks map[string]*domain.Task
+}
+
+func (m *memoryTaskStore) Create(task *domain.Task) error {
+	if _, ok := m.tasks[task.ID]; ok {
+		return fmt.Errorf("duplicate task: %s", task.ID)
+	}
+	copied := *task
+	m.tasks[task.ID] = &copied
+	return nil
+}
+
+func (m *memoryTaskStore) GetByID(id string) (*domain.Task, error) {
+	task, ok := m.tasks[id]
+	if !ok {
+		return nil, fmt.Errorf("task not found: %s", id)
+	}
+	copied := *task
+	return &copied, nil
+}
+
+func (m *memoryTaskStore) Update(task *domain.Task) error {
+	if _, ok := m.tasks[task.ID]; !ok {
+		return fmt.Errorf("task not found: %s", task.ID)
+	}
+	copied := *task
+	m.tasks[task.ID] = &copied
+	return nil
+}
+
+func (m *memoryTaskStore) FindByLinkedItem(itemType, itemID string) (*domain.Task, error) {
+	for _, task := range m.tasks {
+		for _, item := range task.LinkedItems {
+			if item.Type == itemType && item.ID == itemID {
+				return m.GetByID(task.ID)
+			}
+		}
+	}
+	return nil, nil
+}
+
+// memorySyncStore is an in-memory SyncStateStore
+type memorySyncStore struct {
+	states map[string]*domain.BeadSyncState
+}
+
+func (m *memorySyncStore) Get(beadID string) (*domain.BeadSyncState, error) {
+	return m.states[beadID], nil
+}
+
+func (m *memorySyncStore) GetByTask(taskID string) (*domain.BeadSyncState, error) {
+	for _, state := range m.states {
+		if state.TaskID == taskID {
+			return state, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *memorySyncStore) ListConflicts() ([]*domain.BeadSyncState, error) {
+	var states []*domain.BeadSyncState
+	for _, state := range m.states {
+		if state.Conflict != nil {
+			states = append(states, state)
+		}
+	}
+	return states, nil
+}
+
+func (m *memorySyncStore) Save(state *domain.BeadSyncState) error {
+	copied := *state
+	m.states[state.BeadID] = &copied
+	return nil
+}
+
+func (m *memorySyncStore) Delete(beadID string) error {
+	delete(m.states, beadID)
+	return nil
+}
+
+func TestSyncer(t *testing.T) {
+	tmpDir := t.TempDir()
+	beadsDir := filepath.Join(tmpDir, ".beads")
+	if err := os.MkdirAll(beadsDir, 0755); err != nil {
+		t.Fatalf("Failed to create .beads directory: %v", err)
+	}
+	jsonlPath := filepath.Join(beadsDir, "issues.jsonl")
+
+	// bd-1 carries a field Cartographer doesn't know and a comment
+	first := `{"id":"bd-1","title":"Parse config","description":"Read the file","status":"open","priority":2,"issue_type":"task","created_at":"2025-01-01T00:00:00Z","updated_at":"2025-01-01T00:00:00Z","x_custom":{"keep":true},"comments":[{"id":1,"issue_id":"bd-1","author":"alice","text":"started","created_at":"2025-01-01T00:00:00Z"}]}`
+	second := `{"id":"bd-2","title":"Write docs","description":"","status":"open","priority":1,"issue_type":"chore","created_at":"2025-01-01T00:00:00Z","updated_at":"2025-01-01T00:00:00Z"}`
+	if err := os.WriteFile(jsonlPath, []byte(first+"\n\n"+second+"\n"), 0640); err != nil {
+		t.Fatalf("Failed to write beads: %v", err)
+	}
+
+	watcher := NewWatcher(NewParser(tmpDir), time.Hour, nil)
+	if _, err := watcher.Refresh(); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+
+	tasks := &memoryTaskStore{tasks: make(map[string]*domain.Task)}
+	states := &memorySyncStore{states: make(map[string]*domain.BeadSyncState)}
+	syncer := NewSyncer(watcher, tasks, nil, states, nil)
+	watcher.OnChange(syncer.HandleBeadChanges)
+
+	var taskChanges []TaskChange
+	syncer.OnTaskChange(func(change TaskChange) {
+		taskChanges = append(taskChanges, change)
+	})
+	var conflicts []*domain.BeadSyncState
+	syncer.OnConflict(func(state *domain.BeadSyncState) {
+		conflicts = append(conflicts, state)
+	})
+
+	issues, err := NewParser(tmpDir).ReadBeadsFromProject()
+	if err != nil {
+		t.Fatalf("ReadBeadsFromProject failed: %v", err)
+	}
+	imported, _, err := ImportIntoBoard(issues, &domain.Board{ID: "board-1"}, tasks, "")
+	if err != nil {
+		t.Fatalf("ImportIntoBoard failed: %v", err)
+	}
+	if len(imported.Created) != 2 || len(tasks.tasks) != 2 {
+		t.Fatalf("Expected 2 created tasks, got %+v", imported)
+	}
+
+	// The first sync starts tracking the imported tasks
+	report, err := syncer.Sync()
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if len(report.Unchanged) != 2 || len(states.states) != 2 {
+		t.Fatalf("Expected 2 unchanged beads, got %+v", report)
+	}
+
+	// A board edit is written back to the bead
+	task, _ := tasks.GetByID("bd-1")
+	task.Status = "in_progress"
+	task.Description = "Read and validate the file"
+	tasks.Update(task)
+	if _, err := syncer.PushTask(task.ID); err != nil {
+		t.Fatalf("PushTask failed: %v", err)
+	}
+
+	data, err := os.ReadFile(jsonlPath)
+	if err != nil {
+		t.Fatalf("Failed to read beads: %v", err)
+	}
+	lines := strings.Split(string(data), "\n")
+	if len(lines) != 4 || lines[1] != "" || lines[2] != second || lines[3] != "" {
+		t.Fatalf("Expected other lines untouched, got %q", lines)
+	}
+	for _, want := range []string{`"status":"in_progress"`, `"description":"Read and validate the file"`, `"x_custom":{"keep":true}`, `"text":"started"`} {
+		if !strings.Contains(lines[0], want) {
+			t.Errorf("Expected %s in written bead, got %s", want, lines[0])
+		}
+	}
+	if !strings.HasPrefix(lines[0], `{"id":"bd-1","title":"Parse config"`) {
+		t.Errorf("Expected key order preserved, got %s", lines[0])
+	}
+	if info, _ := os.Stat(jsonlPath); info.Mode().Perm() != 0640 {
+		t.Errorf("Expected file mode preserved, got %v", info.Mode().Perm())
+	}
+	if issue, _ := watcher.Issue("bd-1"); issue.Status != "in_progress" {
+		t.Errorf("Expected watcher to pick up the write, got %s", issue.Status)
+	}
+
+	// An edit to the file reaches the task
+	taskChanges = nil
+	lines[2] = strings.Replace(second, `"priority":1`, `"priority":4`, 1)
+	writeLines(t, jsonlPath, lines)
+	if _, err := watcher.Refresh(); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if task, _ := tasks.GetByID("bd-2"); task.Priority != "urgent" {
+		t.Errorf("Expected task priority urgent, got %s", task.Priority)
+	}
+	if len(taskChanges) != 1 || taskChanges[0].Changes["priority"] != "urgent" {
+		t.Errorf("Expected one priority change, got %+v", taskChanges)
+	}
+
+	// Both sides changing the title is a conflict and neither side wins
+	data, _ = os.ReadFile(jsonlPath)
+	lines = strings.Split(string(data), "\n")
+	lines[0] = strings.Replace(lines[0], `"title":"Parse config"`, `"title":"Parse YAML config"`, 1)
+	task, _ = tasks.GetByID("bd-1")
+	task.Title = "Parse TOML config"
+	tasks.Update(task)
+	writeLines(t, jsonlPath, lines)
+	if _, err := watcher.Refresh(); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+
+	if len(conflicts) != 1 || conflicts[0].Conflict.Fields[0] != "title" {
+		t.Fatalf("Expected a title conflict, got %+v", conflicts)
+	}
+	if task, _ := tasks.GetByID("bd-1"); task.Title != "Parse TOML config" {
+		t.Errorf("Expected task title left alone, got %s", task.Title)
+	}
+	if issue, _ := watcher.Issue("bd-1"); issue.Title != "Parse YAML config" {
+		t.Errorf("Expected bead title left alone, got %s", issue.Title)
+	}
+
+	if _, err := syncer.Resolve("bd-2", KeepTask); err != ErrNoConflict {
+		t.Errorf("Expected ErrNoConflict, got %v", err)
+	}
+	if _, err := syncer.Resolve("bd-1", KeepTask); err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if issue, _ := watcher.Issue("bd-1"); issue.Title != "Parse TOML config" {
+		t.Errorf("Expected task title written to bead, got %s", issue.Title)
+	}
+	if remaining, _ := syncer.Conflicts(); len(remaining) != 0 {
+		t.Errorf("Expected conflict resolved, got %+v", remaining)
+	}
+}
+
+func TestSplitDescription(t *testing.T) {
+	ref := "gh-12"
+	issue := createSampleBeads()[0]
+	issue.Description = "Summary"
+	issue.Design = "Use a parser"
+	issue.Notes = "See thread"
+	issue.ExternalRef = &ref
+
+	desc, design, acceptance, notes := splitDescription(buildDescription(issue), issue)
+	if desc != issue.Description || design != issue.Design || acceptance != "" || notes != issue.Notes {
+		t.Errorf("Expected description to round-trip, got %q %q %q %q", desc, design, acceptance, notes)
+	}
+}
+
+func writeLines(t *testing.T, path string, lines []string) {
+	t.Helper()
+	// Make sure the watcher sees a new modification time
+	time.Sleep(10 * time.Millisecond)
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0640); err != nil {
+		t.Fatalf("Failed to write beads: %v", err)
+	}
+}
@@ -0,0 +1,323 @@
+// Package beads provides integration with the Beads issue tracking framework.
+package beads
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rand/cartographer/internal/domain"
+	"github.com/steveyegge/beads"
+)
+
+// DefaultWatchInterval is how often a Watcher checks the issues file
+const DefaultWatchInterval = 2 * time.Second
+
+// Issue change actions
+const (
+	ChangeCreated = "created"
+	ChangeUpdated = "updated"
+	ChangeClosed  = "closed"
+	ChangeDeleted = "deleted"
+)
+
+// IssueChange describes how a single issue changed between two reads of
+// the issues file
+type IssueChange struct {
+	Action string       `json:"action"` // created, updated, closed, deleted
+	Issue  *beads.Issue `json:"issue"`  // new state, or last known state when deleted
+	// Changes maps each changed JSON field to its new value (nil when removed)
+	Changes map[string]interface{} `json:"changes,omitempty"`
+}
+
+// Watcher keeps the parsed issues file and its dependency graph in memory,
+// polling the file's modification time and size for changes
+type Watcher struct {
+	parser   *Parser
+	interval time.Duration
+	logger   *log.Logger
+
+	mu      sync.RWMutex
+	issues  []*beads.Issue
+	byID    map[string]*beads.Issue
+	graph   *DependencyGraph
+	err     error // error from the last read, e.g. the file is missing
+	modTime time.Time
+	size    int64
+	missing bool
+	loaded  bool
+
+	index     *SearchIndex
+	listeners []func([]IssueChange)
+
+	// Serializes refreshes so concurrent callers don't report a change twice
+	refreshMu sync.Mutex
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewWatcher creates a watcher for the parser's issues file. A zero
+// interval uses DefaultWatchInterval.
+func NewWatcher(parser *Parser, interval time.Duration, logger *log.Logger) *Watcher {
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+	if logger == nil {
+		logger = log.Default()
+	}
+
+	return &Watcher{
+		parser:   parser,
+		interval: interval,
+		logger:   logger,
+		byID:     make(map[string]*beads.Issue),
+		graph:    NewDependencyGraph(),
+		index:    NewSearchIndex(nil),
+		stop:     make(chan struct{}),
+	}
+}
+
+// OnChange registers a function called with the changed issues whenever the
+// file changes after the initial load. Register listeners before Start.
+func (w *Watcher) OnChange(fn func(changes []IssueChange)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.listeners = append(w.listeners, fn)
+}
+
+// Start loads the issues file and polls it for changes until Stop
+func (w *Watcher) Start() {
+	if _, err := w.Refresh(); err != nil {
+		w.logger.Printf("Beads watcher: %v", err)
+	}
+
+	go func() {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := w.Refresh(); err != nil {
+					w.logger.Printf("Beads watcher: %v", err)
+				}
+			case <-w.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops polling
+func (w *Watcher) Stop() {
+	w.stopOnce.Do(func() { close(w.stop) })
+}
+
+// Refresh re-reads the issues file if its modification time or size changed,
+// notifies listeners and returns the changes. Changes found by the first
+// load are not reported to listeners. A missing file counts as having no
+// issues; an unreadable one keeps the last good state.
+func (w *Watcher) Refresh() ([]IssueChange, error) {
+	w.refreshMu.Lock()
+	defer w.refreshMu.Unlock()
+
+	info, statErr := os.Stat(w.parser.Path())
+	missing := os.IsNotExist(statErr)
+
+	w.mu.RLock()
+	unchanged := w.loaded && missing == w.missing &&
+		(missing || statErr == nil && info.ModTime().Equal(w.modTime) && info.Size() == w.size)
+	w.mu.RUnlock()
+	if unchanged {
+		return nil, nil
+	}
+
+	var issues []*beads.Issue
+	var readErr error
+	if !missing {
+		issues, readErr = w.parser.ReadBeadsFromProject()
+	} else {
+		readErr = fmt.Errorf("beads file not found at %s", w.parser.Path())
+	}
+
+	if readErr != nil && !missing {
+		w.mu.Lock()
+		w.err = readErr
+		w.loaded = true
+		if statErr == nil {
+			// Don't re-read until the file changes again
+			w.modTime = info.ModTime()
+			w.size = info.Size()
+		}
+		w.mu.Unlock()
+		return nil, readErr
+	}
+
+	graph, err := NewAnalyzer(issues).BuildDependencyGraph()
+	if err != nil {
+		return nil, err
+	}
+
+	w.mu.Lock()
+	previous := w.byID
+	initial := !w.loaded
+	w.issues = issues
+	w.byID = make(map[string]*beads.Issue, len(issues))
+	for _, issue := range issues {
+		w.byID[issue.ID] = issue
+	}
+	w.graph = graph
+	w.err = readErr
+	w.loaded = true
+	w.missing = missing
+	if statErr == nil {
+		w.modTime = info.ModTime()
+		w.size = info.Size()
+	}
+	listeners := w.listeners
+	w.mu.Unlock()
+
+	w.index.Index(issues)
+
+	changes := DiffIssues(previous, issues)
+	if !initial && len(changes) > 0 {
+		for _, fn := range listeners {
+			fn(changes)
+		}
+	}
+
+	return changes, readErr
+}
+
+// invalidate makes the next Refresh re-read the file even if its
+// modification time and size look unchanged, e.g. after a write of the same
+// size within the file system's timestamp granularity
+func (w *Watcher) invalidate() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.modTime = time.Time{}
+	w.size = -1
+}
+
+// Issues returns the cached issues, or the error from the last read
+func (w *Watcher) Issues() ([]*beads.Issue, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.err != nil && len(w.issues) == 0 {
+		return nil, w.err
+	}
+	return w.issues, nil
+}
+
+// Missing reports whether the issues file didn't exist when last read
+func (w *Watcher) Missing() bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.missing
+}
+
+// Issue returns a cached issue by ID
+func (w *Watcher) Issue(id string) (*beads.Issue, bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	issue, ok := w.byID[id]
+	return issue, ok
+}
+
+// Graph returns the cached dependency graph, or the error from the last read
+func (w *Watcher) Graph() (*DependencyGraph, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.err != nil && len(w.issues) == 0 {
+		return nil, w.err
+	}
+	return w.graph, nil
+}
+
+// Search searches the cached issues
+func (w *Watcher) Search(query string, limit int) ([]domain.SearchHit, error) {
+	return w.index.Search(query, limit)
+}
+
+// DiffIssues compares the previous issues (by ID) with the current set and
+// returns the created, updated, closed and deleted issues, ordered by ID
+func DiffIssues(previous map[string]*beads.Issue, current []*beads.Issue) []IssueChange {
+	var changes []IssueChange
+	seen := make(map[string]bool, len(current))
+
+	for _, issue := range current {
+		seen[issue.ID] = true
+
+		old, existed := previous[issue.ID]
+		if !existed {
+			changes = append(changes, IssueChange{Action: ChangeCreated, Issue: issue})
+			continue
+		}
+
+		fields := diffFields(old, issue)
+		if len(fields) == 0 {
+			continue
+		}
+
+		action := ChangeUpdated
+		if issue.Status == beads.StatusClosed && old.Status != beads.StatusClosed {
+			action = ChangeClosed
+		}
+		changes = append(changes, IssueChange{Action: action, Issue: issue, Changes: fields})
+	}
+
+	for id, old := range previous {
+		if !seen[id] {
+			changes = append(changes, IssueChange{Action: ChangeDeleted, Issue: old})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Issue.ID < changes[j].Issue.ID
+	})
+
+	return changes
+}
+
+// diffFields compares two issues field by field through their JSON form
+func diffFields(old, current *beads.Issue) map[string]interface{} {
+	oldFields := issueFields(old)
+	newFields := issueFields(current)
+
+	changes := make(map[string]interface{})
+	for name, value := range newFields {
+		if !bytes.Equal(oldFields[name], value) {
+			changes[name] = value
+		}
+	}
+	for name := range oldFields {
+		if _, ok := newFields[name]; !ok {
+			changes[name] = nil
+		}
+	}
+
+	if len(changes) == 0 {
+		return nil
+	}
+	return changes
+}
+
+// issueFields returns an issue's JSON fields
+func issueFields(issue *beads.Issue) map[string]json.RawMessage {
+	fields := make(map[string]json.RawMessage)
+	data, err := json.Marshal(issue)
+	if err != nil {
+		return fields
+	}
+	json.Unmarshal(data, &fields)
+	return fields
+}
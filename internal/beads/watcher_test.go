@@ -0,0 +1,116 @@
+package beads
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/steveyegge/beads"
+)
+
+func TestWatcherRefresh(t *testing.T) {
+	tmpDir := t.TempDir()
+	beadsDir := filepath.Join(tmpDir, ".beads")
+	if err := os.MkdirAll(beadsDir, 0755); err != nil {
+		t.Fatalf("Failed to create .beads directory: %v", err)
+	}
+	jsonlPath := filepath.Join(beadsDir, "issues.jsonl")
+
+	watcher := NewWatcher(NewParser(tmpDir), time.Hour, nil)
+
+	var notified [][]IssueChange
+	watcher.OnChange(func(changes []IssueChange) {
+		notified = append(notified, changes)
+	})
+
+	// A missing file is an error but not a change
+	if _, err := watcher.Refresh(); err == nil {
+		t.Error("Expected error for missing beads file")
+	}
+	if _, err := watcher.Issues(); err == nil {
+		t.Error("Expected Issues to report the missing file")
+	}
+	if !watcher.Missing() {
+		t.Error("Expected Missing to report the missing file")
+	}
+
+	sample := createSampleBeads()
+	if err := writeBeadsToJSONL(jsonlPath, sample); err != nil {
+		t.Fatalf("Failed to write beads: %v", err)
+	}
+	changes, err := watcher.Refresh()
+	if err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if len(changes) != 3 || changes[0].Action != ChangeCreated {
+		t.Fatalf("Expected 3 created issues, got %+v", changes)
+	}
+
+	issues, err := watcher.Issues()
+	if err != nil || len(issues) != 3 {
+		t.Fatalf("Expected 3 cached issues, got %d (%v)", len(issues), err)
+	}
+	if watcher.Missing() {
+		t.Error("Expected Missing to be false once the file exists")
+	}
+	graph, _ := watcher.Graph()
+	if len(graph.DependsOn["bd-2"]) != 1 {
+		t.Errorf("Expected cached graph with bd-2 dependency, got %v", graph.DependsOn)
+	}
+
+	// Unchanged file is not re-read
+	if changes, _ := watcher.Refresh(); len(changes) != 0 {
+		t.Errorf("Expected no changes, got %+v", changes)
+	}
+
+	// Close bd-1, retitle bd-2, drop bd-3, add bd-4
+	sample[0].Status = beads.StatusClosed
+	sample[1].Title = "Add login and logout UI"
+	sample = append(sample[:2], &beads.Issue{
+		ID:        "bd-4",
+		Title:     "Write docs",
+		Status:    beads.StatusOpen,
+		Priority:  1,
+		IssueType: beads.TypeTask,
+	})
+	if err := writeBeadsToJSONL(jsonlPath, sample); err != nil {
+		t.Fatalf("Failed to write beads: %v", err)
+	}
+	// Ensure the change is visible even on coarse mtime filesystems
+	os.Chtimes(jsonlPath, time.Now().Add(time.Second), time.Now().Add(time.Second))
+
+	changes, err = watcher.Refresh()
+	if err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+
+	want := map[string]string{
+		"bd-1": ChangeClosed,
+		"bd-2": ChangeUpdated,
+		"bd-3": ChangeDeleted,
+		"bd-4": ChangeCreated,
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("Expected %d changes, got %+v", len(want), changes)
+	}
+	for _, change := range changes {
+		if want[change.Issue.ID] != change.Action {
+			t.Errorf("Issue %s: expected %s, got %s", change.Issue.ID, want[change.Issue.ID], change.Action)
+		}
+	}
+	if _, ok := changes[1].Changes["title"]; !ok || len(changes[1].Changes) != 1 {
+		t.Errorf("Expected only title to change for bd-2, got %v", changes[1].Changes)
+	}
+
+	// The initial load found no file; creating it and editing it both notify
+	if len(notified) != 2 {
+		t.Errorf("Expected listeners notified twice, got %d", len(notified))
+	}
+	if issue, ok := watcher.Issue("bd-1"); !ok || issue.Status != beads.StatusClosed {
+		t.Errorf("Expected cached bd-1 to be closed")
+	}
+	if hits, _ := watcher.Search("docs", 10); len(hits) != 1 || hits[0].ID != "bd-4" {
+		t.Errorf("Expected search to find bd-4, got %+v", hits)
+	}
+}
@@ -0,0 +1,372 @@
+package claude
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/rand/cartographer/internal/beads"
+	"github.com/rand/cartographer/internal/domain"
+	bd "github.com/steveyegge/beads"
+)
+
+// Caps on the sections that would otherwise grow with the project's age
+const (
+	maxActivity  = 20
+	maxDocuments = 10
+)
+
+// Briefing sections, highest priority first. Fitting a briefing to a token
+// budget drops items from the last sections first.
+const (
+	SectionInProgress = "in_progress"
+	SectionReady      = "ready"
+	SectionBlocked    = "blocked"
+	SectionActivity   = "activity"
+	SectionDocuments  = "documents"
+)
+
+// Briefing is what an agent starting a session needs to know about a
+// project: the work under way and up next, what is stuck, what happened
+// lately and the documents the tasks point to
+type Briefing struct {
+	Project    *BriefProject    `json:"project"`
+	InProgress []*BriefTask     `json:"in_progress"`
+	Ready      []*BriefTask     `json:"ready"`
+	Blocked    []*BriefTask     `json:"blocked"`
+	Activity   []*BriefActivity `json:"activity"`          // newest first
+	Documents  []*BriefDocument `json:"documents"`         // most linked first
+	Omitted    map[string]int   `json:"omitted,omitempty"` // items per section left out to fit the budget
+	Tokens     int              `json:"tokens"`            // estimate for the Markdown
+}
+
+// BriefProject is a project's metadata
+type BriefProject struct {
+	ID              string `json:"id"`
+	Name            string `json:"name"`
+	Description     string `json:"description,omitempty"`
+	Path            string `json:"path,omitempty"`
+	Type            string `json:"type,omitempty"`
+	PrimaryLanguage string `json:"primary_language,omitempty"`
+	Framework       string `json:"framework,omitempty"`
+	GitRemote       string `json:"git_remote,omitempty"`
+}
+
+// BriefTask is a task as a briefing lists it
+type BriefTask struct {
+	ID        string      `json:"id"`
+	Title     string      `json:"title"`
+	Board     string      `json:"board"`  // name
+	Status    string      `json:"status"` // column name
+	Priority  string      `json:"priority"`
+	Assignee  string      `json:"assignee,omitempty"`
+	BlockedBy []*TaskLink `json:"blocked_by,omitempty"`
+}
+
+// TaskLink names a task another one refers to
+type TaskLink struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+}
+
+// BriefActivity is an entry of a task's activity
+type BriefActivity struct {
+	Time    time.Time `json:"time"`
+	User    string    `json:"user,omitempty"`
+	Type    string    `json:"type"`
+	Task    *TaskLink `json:"task"`
+	Comment string    `json:"comment,omitempty"`
+}
+
+// BriefDocument is a document with the number of tasks linking to it
+type BriefDocument struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	Path  string `json:"path,omitempty"`
+	Links int    `json:"links"`
+}
+
+// Brief builds the briefing of a project from its boards, their tasks and
+// its documents. Lists of tasks are sorted by priority, then in board
+// order.
+func Brief(project *domain.Project, boards []*domain.Board, tasks []*domain.Task, documents []*domain.Document) (*Briefing, error) {
+	b := &Briefing{
+		Project:    briefProject(project),
+		InProgress: []*BriefTask{},
+		Ready:      []*BriefTask{},
+		Blocked:    []*BriefTask{},
+		Activity:   []*BriefActivity{},
+		Documents:  []*BriefDocument{},
+	}
+
+	byBoard := make(map[string][]*domain.Task)
+	for _, task := range tasks {
+		byBoard[task.BoardID] = append(byBoard[task.BoardID], task)
+	}
+
+	links := make(map[string]int)
+	for _, board := range boards {
+		boardTasks := byBoard[board.ID]
+		statuses := columnStatuses(board)
+		brief := func(task *domain.Task) *BriefTask {
+			return briefTask(board, task)
+		}
+
+		for _, task := range boardTasks {
+			if statuses[task.Status] == bd.StatusInProgress {
+				b.InProgress = append(b.InProgress, brief(task))
+			}
+			for _, entry := range task.Activity {
+				b.Activity = append(b.Activity, &BriefActivity{
+					Time:    entry.Timestamp,
+					User:    entry.User,
+					Type:    entry.Type,
+					Task:    &TaskLink{ID: task.ID, Title: task.Title},
+					Comment: entry.Comment,
+				})
+			}
+			for _, item := range task.LinkedItems {
+				if item.Type == "doc" {
+					links[item.ID]++
+				}
+			}
+		}
+
+		ready, err := beads.ReadyTasks(boardTasks)
+		if err != nil {
+			return nil, fmt.Errorf("finding ready tasks of board %s: %w", board.ID, err)
+		}
+		for _, task := range ready {
+			// ReadyTasks counts tasks under way as ready, and knows done
+			// columns by their ID only
+			if status := statuses[task.Status]; status != bd.StatusInProgress && status != bd.StatusClosed {
+				b.Ready = append(b.Ready, brief(task))
+			}
+		}
+
+		blocked, err := beads.BlockedTasks(boardTasks)
+		if err != nil {
+			return nil, fmt.Errorf("finding blocked tasks of board %s: %w", board.ID, err)
+		}
+		for _, entry := range blocked {
+			task := brief(entry.Task)
+			for _, blocker := range entry.BlockedBy {
+				task.BlockedBy = append(task.BlockedBy, &TaskLink{ID: blocker.ID, Title: blocker.Title})
+			}
+			b.Blocked = append(b.Blocked, task)
+		}
+	}
+
+	for _, list := range [][]*BriefTask{b.InProgress, b.Ready, b.Blocked} {
+		sort.SliceStable(list, func(i, j int) bool {
+			return domain.PriorityRank(list[i].Priority) > domain.PriorityRank(list[j].Priority)
+		})
+	}
+
+	sort.SliceStable(b.Activity, func(i, j int) bool {
+		return b.Activity[i].Time.After(b.Activity[j].Time)
+	})
+	if len(b.Activity) > maxActivity {
+		b.Activity = b.Activity[:maxActivity]
+	}
+
+	for _, doc := range documents {
+		if links[doc.ID] > 0 {
+			b.Documents = append(b.Documents, &BriefDocument{ID: doc.ID, Title: doc.Title, Path: doc.Path, Links: links[doc.ID]})
+		}
+	}
+	sort.SliceStable(b.Documents, func(i, j int) bool {
+		return b.Documents[i].Links > b.Documents[j].Links
+	})
+	if len(b.Documents) > maxDocuments {
+		b.Documents = b.Documents[:maxDocuments]
+	}
+
+	b.Tokens = EstimateTokens(b.Markdown())
+	return b, nil
+}
+
+// Fit drops items from the lowest priority sections until the Markdown
+// fits in a budget of tokens. The project's metadata always stays.
+func (b *Briefing) Fit(budget int) {
+	sections := []struct {
+		name  string
+		items func() int
+		drop  func()
+	}{
+		{SectionDocuments, func() int { return len(b.Documents) }, func() { b.Documents = b.Documents[:len(b.Documents)-1] }},
+		{SectionActivity, func() int { return len(b.Activity) }, func() { b.Activity = b.Activity[:len(b.Activity)-1] }},
+		{SectionBlocked, func() int { return len(b.Blocked) }, func() { b.Blocked = b.Blocked[:len(b.Blocked)-1] }},
+		{SectionReady, func() int { return len(b.Ready) }, func() { b.Ready = b.Ready[:len(b.Ready)-1] }},
+		{SectionInProgress, func() int { return len(b.InProgress) }, func() { b.InProgress = b.InProgress[:len(b.InProgress)-1] }},
+	}
+
+	for _, section := range sections {
+		for b.Tokens > budget && section.items() > 0 {
+			section.drop()
+			if b.Omitted == nil {
+				b.Omitted = make(map[string]int)
+			}
+			b.Omitted[section.name]++
+			b.Tokens = EstimateTokens(b.Markdown())
+		}
+	}
+}
+
+// Markdown renders the briefing
+func (b *Briefing) Markdown() string {
+	var s strings.Builder
+	p := b.Project
+
+	fmt.Fprintf(&s, "# %s\n", p.Name)
+	if p.Description != "" {
+		fmt.Fprintf(&s, "\n%s\n", strings.TrimSpace(p.Description))
+	}
+	var fields []string
+	for _, field := range []struct{ name, value string }{
+		{"Path", p.Path}, {"Type", p.Type}, {"Language", p.PrimaryLanguage},
+		{"Framework", p.Framework}, {"Git", p.GitRemote},
+	} {
+		if field.value != "" {
+			fields = append(fields, fmt.Sprintf("- %s: %s\n", field.name, field.value))
+		}
+	}
+	if len(fields) > 0 {
+		s.WriteString("\n" + strings.Join(fields, ""))
+	}
+
+	writeTasks := func(title, section string, tasks []*BriefTask) {
+		if len(tasks) == 0 {
+			return
+		}
+		fmt.Fprintf(&s, "\n## %s\n\n", title)
+		for _, task := range tasks {
+			fmt.Fprintf(&s, "- **%s** `%s` (%s, %s, %s", task.Title, task.ID, task.Priority, task.Board, task.Status)
+			if task.Assignee != "" {
+				fmt.Fprintf(&s, ", @%s", task.Assignee)
+			}
+			s.WriteString(")")
+			for i, blocker := range task.BlockedBy {
+				sep := ", "
+				if i == 0 {
+					sep = " blocked by "
+				}
+				fmt.Fprintf(&s, "%s**%s** `%s`", sep, blocker.Title, blocker.ID)
+			}
+			s.WriteString("\n")
+		}
+		b.writeOmitted(&s, section)
+	}
+	writeTasks("In progress", SectionInProgress, b.InProgress)
+	writeTasks("Ready", SectionReady, b.Ready)
+	writeTasks("Blocked", SectionBlocked, b.Blocked)
+
+	if len(b.Activity) > 0 {
+		s.WriteString("\n## Recent activity\n\n")
+		for _, entry := range b.Activity {
+			user := entry.User
+			if user == "" {
+				user = "someone"
+			}
+			fmt.Fprintf(&s, "- %s %s %s **%s**", entry.Time.UTC().Format("2006-01-02 15:04"), user, entry.Type, entry.Task.Title)
+			if entry.Comment != "" {
+				fmt.Fprintf(&s, ": %s", firstLine(entry.Comment))
+			}
+			s.WriteString("\n")
+		}
+		b.writeOmitted(&s, SectionActivity)
+	}
+
+	if len(b.Documents) > 0 {
+		s.WriteString("\n## Documents\n\n")
+		for _, doc := range b.Documents {
+			fmt.Fprintf(&s, "- **%s** `%s`", doc.Title, doc.ID)
+			if doc.Path != "" {
+				fmt.Fprintf(&s, " %s", doc.Path)
+			}
+			fmt.Fprintf(&s, " (linked from %d %s)\n", doc.Links, plural(doc.Links, "task"))
+		}
+		b.writeOmitted(&s, SectionDocuments)
+	}
+
+	return s.String()
+}
+
+// writeOmitted notes how many items of a section were left out, if any
+func (b *Briefing) writeOmitted(s *strings.Builder, section string) {
+	if n := b.Omitted[section]; n > 0 {
+		fmt.Fprintf(s, "- …and %d more\n", n)
+	}
+}
+
+// EstimateTokens roughly estimates the tokens of a text, at four
+// characters per token
+func EstimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+func briefProject(project *domain.Project) *BriefProject {
+	p := &BriefProject{
+		ID:          project.ID,
+		Name:        project.Name,
+		Description: project.Description,
+		Path:        project.Path,
+		Type:        project.Type,
+	}
+	if m := project.Metadata; m != nil {
+		p.PrimaryLanguage = m.PrimaryLanguage
+		p.Framework = m.Framework
+		p.GitRemote = m.GitRemote
+	}
+	return p
+}
+
+func briefTask(board *domain.Board, task *domain.Task) *BriefTask {
+	brief := &BriefTask{
+		ID:       task.ID,
+		Title:    task.Title,
+		Board:    board.Name,
+		Status:   task.Status,
+		Priority: task.Priority,
+	}
+	if brief.Priority == "" {
+		brief.Priority = domain.PriorityMedium
+	}
+	for _, column := range board.Columns {
+		if column.ID == task.Status {
+			brief.Status = column.Name
+		}
+	}
+	if task.Assignee != nil {
+		brief.Assignee = task.Assignee.ID
+	}
+	return brief
+}
+
+// columnStatuses maps a board's column IDs to the bead status each stands
+// for, judged by its ID or else its name
+func columnStatuses(board *domain.Board) map[string]bd.Status {
+	statuses := make(map[string]bd.Status, len(board.Columns))
+	for _, column := range board.Columns {
+		if status, ok := beads.ColumnStatus(column.ID); ok {
+			statuses[column.ID] = status
+		} else if status, ok := beads.ColumnStatus(column.Name); ok {
+			statuses[column.ID] = status
+		}
+	}
+	return statuses
+}
+
+func firstLine(text string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(text), "\n")
+	return line
+}
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return word
+	}
+	return word + "s"
+}
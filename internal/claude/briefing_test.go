@@ -0,0 +1,97 @@
+package claude
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rand/cartographer/internal/domain"
+)
+
+func TestBrief(t *testing.T) {
+	project := &domain.Project{ID: "p1", Name: "Atlas", Description: "Maps things", Path: "/tmp/atlas",
+		Metadata: &domain.ProjectMetadata{PrimaryLanguage: "Go"}}
+	board := &domain.Board{ID: "b1", ProjectID: "p1", Name: "Main", Columns: []domain.BoardColumn{
+		{ID: "c1", Name: "To Do"}, {ID: "c2", Name: "In Progress", Order: 1}, {ID: "c3", Name: "Done", Order: 2},
+	}}
+	now := time.Now()
+	tasks := []*domain.Task{
+		{ID: "lexer", BoardID: "b1", Title: "Lexer", Status: "c2", Priority: "high",
+			Assignee:    &domain.Assignee{Type: "agent", ID: "claude"},
+			Activity:    []domain.ActivityEntry{{Type: "commented", User: "alice", Timestamp: now, Comment: "Almost there\nmore"}},
+			LinkedItems: []domain.LinkedItem{{Type: "doc", ID: "d1"}, {Type: "doc", ID: "d2"}}},
+		{ID: "parser", BoardID: "b1", Title: "Parser", Status: "c1", Priority: "low",
+			Activity:    []domain.ActivityEntry{{Type: "created", Timestamp: now.Add(-time.Hour)}},
+			LinkedItems: []domain.LinkedItem{{Type: "doc", ID: "d2"}}},
+		{ID: "docs", BoardID: "b1", Title: "Docs", Status: "c1", Priority: "urgent"},
+		{ID: "ast", BoardID: "b1", Title: "AST", Status: "c1", Dependencies: []string{"lexer"}},
+		{ID: "old", BoardID: "b1", Title: "Old", Status: "c3"},
+	}
+	documents := []*domain.Document{
+		{ID: "d1", Title: "Design", Path: "design.md"},
+		{ID: "d2", Title: "Grammar", Path: "grammar.md"},
+		{ID: "d3", Title: "Unlinked"},
+	}
+
+	b, err := Brief(project, []*domain.Board{board}, tasks, documents)
+	if err != nil {
+		t.Fatalf("Brief failed: %v", err)
+	}
+
+	ids := func(list []*BriefTask) string {
+		var ids []string
+		for _, task := range list {
+			ids = append(ids, task.ID)
+		}
+		return strings.Join(ids, ",")
+	}
+	if got := ids(b.InProgress); got != "lexer" {
+		t.Errorf("in progress %s, want lexer", got)
+	}
+	if got := ids(b.Ready); got != "docs,parser" {
+		t.Errorf("ready %s, want docs,parser", got)
+	}
+	if got := ids(b.Blocked); got != "ast" || b.Blocked[0].BlockedBy[0].Title != "Lexer" {
+		t.Errorf("blocked %s, want ast blocked by the lexer", got)
+	}
+	if len(b.Activity) != 2 || b.Activity[0].Task.ID != "lexer" {
+		t.Errorf("expected the newest activity first, got %+v", b.Activity)
+	}
+	if len(b.Documents) != 2 || b.Documents[0].ID != "d2" || b.Documents[0].Links != 2 {
+		t.Errorf("expected the grammar linked twice first, got %+v", b.Documents)
+	}
+
+	md := b.Markdown()
+	for _, want := range []string{
+		"# Atlas\n\nMaps things\n\n- Path: /tmp/atlas\n- Language: Go\n",
+		"## In progress\n\n- **Lexer** `lexer` (high, Main, In Progress, @claude)\n",
+		"- **AST** `ast` (medium, Main, To Do) blocked by **Lexer** `lexer`\n",
+		"alice commented **Lexer**: Almost there\n",
+		"- **Grammar** `d2` grammar.md (linked from 2 tasks)\n",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("expected the Markdown to contain %q, got\n%s", want, md)
+		}
+	}
+	if b.Tokens != EstimateTokens(md) {
+		t.Errorf("tokens %d, want %d", b.Tokens, EstimateTokens(md))
+	}
+
+	// A tight budget drops documents and activity before tasks
+	b.Fit(b.Tokens - 40)
+	if len(b.Documents) != 0 || b.Omitted[SectionDocuments] != 2 || len(b.Ready) != 2 {
+		t.Errorf("expected only documents dropped, got %+v omitted %v", b, b.Omitted)
+	}
+	if b.Tokens > EstimateTokens(md)-40 {
+		t.Errorf("briefing of %d tokens doesn't fit", b.Tokens)
+	}
+
+	// The project's metadata stays whatever the budget
+	b.Fit(1)
+	if len(b.InProgress)+len(b.Ready)+len(b.Blocked)+len(b.Activity) != 0 || !strings.HasPrefix(b.Markdown(), "# Atlas\n") {
+		t.Errorf("expected only the project left, got\n%s", b.Markdown())
+	}
+	if b.Omitted[SectionInProgress] != 1 || b.Omitted[SectionReady] != 2 {
+		t.Errorf("omitted %v", b.Omitted)
+	}
+}
@@ -0,0 +1,331 @@
+// Package claude captures Claude Code sessions: what an agent did while
+// working on a project, the tasks it touched or left for later, and the
+// history the next session resumes from. It also briefs a new session on
+// the state of a project.
+package claude
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/rand/cartographer/internal/domain"
+)
+
+// maxTitleLength bounds the title of a task made from an event's first line
+const maxTitleLength = 200
+
+// SessionStore persists sessions and their events
+type SessionStore interface {
+	Create(session *domain.Session) error
+	GetByID(id string) (*domain.Session, error)
+	ListByProject(projectID string) ([]*domain.Session, error)
+	AppendEvent(event *domain.SessionEvent) error
+	AppendEventCreatingTask(event *domain.SessionEvent, task *domain.Task, limit int) error
+	Events(sessionID string) ([]*domain.SessionEvent, error)
+}
+
+// TaskStore is the task storage a Recorder links tasks in
+type TaskStore interface {
+	GetByID(id string) (*domain.Task, error)
+	UpdateFields(task *domain.Task, fields []string) error
+}
+
+// BoardStore looks up the boards tasks from tagged events go on
+type BoardStore interface {
+	GetByID(id string) (*domain.Board, error)
+	ListByProject(projectID string) ([]*domain.Board, error)
+}
+
+// Recorder starts sessions and records their events
+type Recorder struct {
+	sessions SessionStore
+	tasks    TaskStore
+	boards   BoardStore
+}
+
+// NewRecorder creates a new recorder
+func NewRecorder(sessions SessionStore, tasks TaskStore, boards BoardStore) *Recorder {
+	return &Recorder{sessions: sessions, tasks: tasks, boards: boards}
+}
+
+// Recorded is what recording an event did
+type Recorded struct {
+	Event *domain.SessionEvent `json:"event"`
+	// Task created from an event tagged as a task
+	Created *domain.Task `json:"created_task,omitempty"`
+	// Tasks the event links to that now link back to the session
+	Linked []*domain.Task `json:"-"`
+}
+
+// History is a session with everything that happened in it, for the next
+// session to resume from
+type History struct {
+	Session *domain.Session        `json:"session"`
+	Events  []*domain.SessionEvent `json:"events"`
+	Files   []string               `json:"files"` // every file touched, sorted
+	Tasks   []*domain.Task         `json:"tasks"` // linked tasks as they are now
+	Notes   []string               `json:"notes"` // text of the note events, in order
+}
+
+// Session returns a session by ID
+func (r *Recorder) Session(id string) (*domain.Session, error) {
+	return r.sessions.GetByID(id)
+}
+
+// Sessions returns a project's sessions, newest first
+func (r *Recorder) Sessions(projectID string) ([]*domain.Session, error) {
+	return r.sessions.ListByProject(projectID)
+}
+
+// Start starts a session. Unless it names the session it resumes, it
+// resumes the agent's latest session on the project, if any.
+func (r *Recorder) Start(session *domain.Session) error {
+	if err := session.Validate(); err != nil {
+		return err
+	}
+	if session.PreviousID != "" {
+		previous, err := r.sessions.GetByID(session.PreviousID)
+		if err != nil {
+			return err
+		}
+		if previous.ProjectID != session.ProjectID {
+			v := &domain.ValidationError{}
+			v.Add("previous_session_id", "is a session of another project")
+			return v
+		}
+	} else {
+		sessions, err := r.sessions.ListByProject(session.ProjectID)
+		if err != nil {
+			return err
+		}
+		for _, s := range sessions {
+			if s.Agent == session.Agent {
+				session.PreviousID = s.ID
+				break
+			}
+		}
+	}
+
+	if session.BoardID != "" {
+		board, err := r.boards.GetByID(session.BoardID)
+		if err != nil {
+			return err
+		}
+		if board.ProjectID != session.ProjectID {
+			v := &domain.ValidationError{}
+			v.Add("board_id", "is a board of another project")
+			return v
+		}
+	}
+
+	return r.sessions.Create(session)
+}
+
+// Record records an event of a session. Tasks the event links to get a
+// link back to the session, and an event tagged as a task becomes a task on
+// the session's board, or else the project's first board. The task is
+// created with the event, and not past its column's WIP limit if the board
+// enforces them.
+func (r *Recorder) Record(event *domain.SessionEvent) (*Recorded, error) {
+	session, err := r.sessions.GetByID(event.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	if err := event.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Linked tasks must exist and be in the session's project
+	var linked []*domain.Task
+	v := &domain.ValidationError{}
+	for i, link := range event.Links {
+		if link.Type != domain.EntityTask {
+			continue
+		}
+		task, err := r.tasks.GetByID(link.ID)
+		if err != nil || !r.inProject(task, session.ProjectID) {
+			v.Add(fmt.Sprintf("links[%d].id", i), "is not a task of the session's project")
+			continue
+		}
+		linked = append(linked, task)
+	}
+	if err := v.Err(); err != nil {
+		return nil, err
+	}
+
+	recorded := &Recorded{Event: event}
+	if event.HasTag(domain.SessionTagTask) {
+		task, limit, err := r.newTask(session, event)
+		if err != nil {
+			return nil, err
+		}
+		if err := r.sessions.AppendEventCreatingTask(event, task, limit); err != nil {
+			return nil, err
+		}
+		recorded.Created = task
+	} else if err := r.sessions.AppendEvent(event); err != nil {
+		return nil, err
+	}
+
+	for _, task := range linked {
+		updated, err := r.linkTask(task, session.ID)
+		if err != nil {
+			return nil, fmt.Errorf("linking task %s to session %s: %w", task.ID, session.ID, err)
+		}
+		if updated != nil {
+			recorded.Linked = append(recorded.Linked, updated)
+		}
+	}
+
+	return recorded, nil
+}
+
+// newTask makes the task of an event tagged as one, titled by the first line
+// of its text and labeled with its other tags. It returns the WIP limit of
+// the task's column if the board enforces one, or 0.
+func (r *Recorder) newTask(session *domain.Session, event *domain.SessionEvent) (*domain.Task, int, error) {
+	board, err := r.taskBoard(session)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	title, description, _ := strings.Cut(strings.TrimSpace(event.Text), "\n")
+	title = strings.TrimSpace(title)
+	if utf8.RuneCountInString(title) > maxTitleLength {
+		title = string([]rune(title)[:maxTitleLength-1]) + "…"
+	}
+
+	var labels []string
+	for _, tag := range event.Tags {
+		if tag != domain.SessionTagTask {
+			labels = append(labels, tag)
+		}
+	}
+	links := []domain.LinkedItem{{Type: domain.LinkSession, ID: session.ID}}
+	for _, file := range event.Files {
+		links = append(links, domain.LinkedItem{Type: "file", Path: file})
+	}
+
+	agent := session.Agent
+	task := &domain.Task{
+		BoardID:     board.ID,
+		Title:       title,
+		Description: strings.TrimSpace(description),
+		Priority:    domain.PriorityMedium,
+		Labels:      labels,
+		LinkedItems: links,
+		CreatedBy:   &agent,
+		Activity: []domain.ActivityEntry{{
+			Type:      domain.ActivityCreated,
+			User:      agent.ID,
+			Timestamp: time.Now(),
+			Comment:   "From session " + session.ID,
+		}},
+	}
+	if len(board.Columns) > 0 {
+		task.Status = firstColumn(board.Columns)
+	}
+	if err := task.Validate(board); err != nil {
+		return nil, 0, err
+	}
+
+	limit := 0
+	if column := board.Column(task.Status); column != nil && board.EnforcesWIPLimits() {
+		limit = column.WIPLimit
+	}
+	return task, limit, nil
+}
+
+// taskBoard returns the board tasks from a session's events go on
+func (r *Recorder) taskBoard(session *domain.Session) (*domain.Board, error) {
+	if session.BoardID != "" {
+		return r.boards.GetByID(session.BoardID)
+	}
+	boards, err := r.boards.ListByProject(session.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+	if len(boards) == 0 {
+		v := &domain.ValidationError{}
+		v.Add("tags", "can't make a task: the project has no board")
+		return nil, v
+	}
+	return boards[0], nil
+}
+
+// inProject reports whether a task is on a board of the project
+func (r *Recorder) inProject(task *domain.Task, projectID string) bool {
+	board, err := r.boards.GetByID(task.BoardID)
+	return err == nil && board.ProjectID == projectID
+}
+
+// linkTask adds a link to the session to a task, returning the updated task,
+// or nil if it already had one
+func (r *Recorder) linkTask(task *domain.Task, sessionID string) (*domain.Task, error) {
+	for _, item := range task.LinkedItems {
+		if item.Type == domain.LinkSession && item.ID == sessionID {
+			return nil, nil
+		}
+	}
+	task.LinkedItems = append(task.LinkedItems, domain.LinkedItem{Type: domain.LinkSession, ID: sessionID})
+	// Whatever else changed since the task was read, the link applies
+	task.Revision = 0
+	if err := r.tasks.UpdateFields(task, []string{"linked_items"}); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// History returns a session's events with the files and tasks they touched
+func (r *Recorder) History(sessionID string) (*History, error) {
+	session, err := r.sessions.GetByID(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	events, err := r.sessions.Events(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	history := &History{Session: session, Events: events, Files: []string{}, Tasks: []*domain.Task{}, Notes: []string{}}
+	files := make(map[string]bool)
+	tasks := make(map[string]bool)
+	for _, event := range events {
+		for _, file := range event.Files {
+			if !files[file] {
+				files[file] = true
+				history.Files = append(history.Files, file)
+			}
+		}
+		for _, link := range event.Links {
+			if link.Type != domain.EntityTask || tasks[link.ID] {
+				continue
+			}
+			tasks[link.ID] = true
+			// Tasks deleted since are left out
+			if task, err := r.tasks.GetByID(link.ID); err == nil {
+				history.Tasks = append(history.Tasks, task)
+			}
+		}
+		if event.Type == domain.SessionNote && event.Text != "" {
+			history.Notes = append(history.Notes, event.Text)
+		}
+	}
+	sort.Strings(history.Files)
+
+	return history, nil
+}
+
+// firstColumn returns the ID of the leftmost column
+func firstColumn(columns []domain.BoardColumn) string {
+	first := columns[0]
+	for _, column := range columns[1:] {
+		if column.Order < first.Order {
+			first = column
+		}
+	}
+	return first.ID
+}
@@ -0,0 +1,170 @@
+package claude
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/rand/cartographer/internal/domain"
+	"github.com/rand/cartographer/internal/storage"
+)
+
+func TestRecorder(t *testing.T) {
+	db, err := storage.New(t.TempDir())
+	if err != nil {
+		t.Fatalf("storage.New failed: %v", err)
+	}
+	defer db.Close()
+
+	projects := storage.NewProjectRepository(db)
+	boards := storage.NewBoardRepository(db)
+	tasks := storage.NewTaskRepository(db)
+	recorder := NewRecorder(storage.NewSessionRepository(db), tasks, boards)
+
+	project := &domain.Project{Name: "Atlas", Path: "/tmp/atlas"}
+	if err := projects.Create(project); err != nil {
+		t.Fatalf("failed to create project: %v", err)
+	}
+	agent := domain.User{Type: "agent", ID: "claude"}
+
+	// Without a board, tagged events can't become tasks
+	first := &domain.Session{ProjectID: project.ID, Agent: agent}
+	if err := recorder.Start(first); err != nil || first.PreviousID != "" {
+		t.Fatalf("Start failed: %+v (%v)", first, err)
+	}
+	_, err = recorder.Record(&domain.SessionEvent{SessionID: first.ID, Type: domain.SessionNote, Text: "Later", Tags: []string{"task"}})
+	if _, ok := err.(*domain.ValidationError); !ok {
+		t.Errorf("Expected a validation error without a board, got %v", err)
+	}
+
+	board := &domain.Board{ProjectID: project.ID, Name: "Main", Columns: []domain.BoardColumn{
+		{ID: "done", Name: "Done", Order: 1}, {ID: "todo", Name: "To Do", Order: 0},
+	}}
+	if err := boards.Create(board); err != nil {
+		t.Fatalf("failed to create board: %v", err)
+	}
+	existing := &domain.Task{BoardID: board.ID, Title: "Parser", Status: "todo"}
+	if err := tasks.Create(existing); err != nil {
+		t.Fatalf("failed to create task: %v", err)
+	}
+
+	// The next session resumes the agent's latest one
+	session := &domain.Session{ProjectID: project.ID, Agent: agent}
+	if err := recorder.Start(session); err != nil || session.PreviousID != first.ID {
+		t.Fatalf("Expected the session to resume %s, got %+v (%v)", first.ID, session, err)
+	}
+
+	recorded, err := recorder.Record(&domain.SessionEvent{
+		SessionID: session.ID, Type: domain.SessionToolCall, Tool: "Edit",
+		Files: []string{"parser.go"}, Links: []domain.LinkedItem{{Type: "task", ID: existing.ID}},
+	})
+	if err != nil || len(recorded.Linked) != 1 {
+		t.Fatalf("Record failed: %+v (%v)", recorded, err)
+	}
+	linked, _ := tasks.GetByID(existing.ID)
+	if want := []domain.LinkedItem{{Type: "session", ID: session.ID}}; !reflect.DeepEqual(linked.LinkedItems, want) {
+		t.Errorf("Expected the task linked to the session, got %+v", linked.LinkedItems)
+	}
+
+	// Linking again leaves the task alone
+	recorded, err = recorder.Record(&domain.SessionEvent{
+		SessionID: session.ID, Type: domain.SessionFile, Files: []string{"lexer.go", "parser.go"},
+		Links: []domain.LinkedItem{{Type: "task", ID: existing.ID}},
+	})
+	if err != nil || len(recorded.Linked) != 0 {
+		t.Errorf("Expected no task updated, got %+v (%v)", recorded, err)
+	}
+
+	recorded, err = recorder.Record(&domain.SessionEvent{
+		SessionID: session.ID, Type: domain.SessionNote, Text: "Handle comments\nThe lexer drops them.",
+		Tags: []string{"task", "parser"}, Files: []string{"lexer.go"},
+	})
+	if err != nil || recorded.Created == nil {
+		t.Fatalf("Expected a task from the tagged event, got %+v (%v)", recorded, err)
+	}
+	created := recorded.Created
+	if created.Title != "Handle comments" || created.Description != "The lexer drops them." || created.Status != "todo" ||
+		!reflect.DeepEqual(created.Labels, []string{"parser"}) || created.CreatedBy == nil || *created.CreatedBy != agent {
+		t.Errorf("Unexpected task %+v", created)
+	}
+	if recorded.Event.Seq != 3 || !reflect.DeepEqual(recorded.Event.Links, []domain.LinkedItem{{Type: "task", ID: created.ID}}) {
+		t.Errorf("Expected the event linked to the new task, got %+v", recorded.Event)
+	}
+
+	for _, event := range []*domain.SessionEvent{
+		{SessionID: session.ID, Type: "chat"},
+		{SessionID: session.ID, Type: domain.SessionToolCall},
+		{SessionID: session.ID, Type: domain.SessionNote, Links: []domain.LinkedItem{{Type: "task", ID: "missing"}}},
+	} {
+		if _, err := recorder.Record(event); err == nil {
+			t.Errorf("Expected %+v to be invalid", event)
+		}
+	}
+
+	history, err := recorder.History(session.ID)
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	if len(history.Events) != 3 || history.Session.EventCount != 3 {
+		t.Errorf("Expected 3 events, got %d (count %d)", len(history.Events), history.Session.EventCount)
+	}
+	if want := []string{"lexer.go", "parser.go"}; !reflect.DeepEqual(history.Files, want) {
+		t.Errorf("files = %v, want %v", history.Files, want)
+	}
+	if len(history.Tasks) != 2 || history.Tasks[0].ID != existing.ID || history.Tasks[1].ID != created.ID {
+		t.Errorf("Unexpected tasks %+v", history.Tasks)
+	}
+	if want := []string{"Handle comments\nThe lexer drops them."}; !reflect.DeepEqual(history.Notes, want) {
+		t.Errorf("notes = %q, want %q", history.Notes, want)
+	}
+
+	other := &domain.Project{Name: "Other", Path: "/tmp/other"}
+	projects.Create(other)
+	if err := recorder.Start(&domain.Session{ProjectID: other.ID, Agent: agent, PreviousID: session.ID}); err == nil {
+		t.Error("Expected an error resuming a session of another project")
+	}
+}
+
+func TestRecorderWIPLimit(t *testing.T) {
+	db, err := storage.New(t.TempDir())
+	if err != nil {
+		t.Fatalf("storage.New failed: %v", err)
+	}
+	defer db.Close()
+
+	boards := storage.NewBoardRepository(db)
+	tasks := storage.NewTaskRepository(db)
+	recorder := NewRecorder(storage.NewSessionRepository(db), tasks, boards)
+
+	project := &domain.Project{Name: "Atlas", Path: "/tmp/atlas"}
+	if err := storage.NewProjectRepository(db).Create(project); err != nil {
+		t.Fatalf("failed to create project: %v", err)
+	}
+	board := &domain.Board{ProjectID: project.ID, Name: "Main", WIPPolicy: domain.WIPPolicyEnforce,
+		Columns: []domain.BoardColumn{{ID: "todo", Name: "To Do", WIPLimit: 1}}}
+	if err := boards.Create(board); err != nil {
+		t.Fatalf("failed to create board: %v", err)
+	}
+	session := &domain.Session{ProjectID: project.ID, Agent: domain.User{Type: "agent", ID: "claude"}}
+	if err := recorder.Start(session); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	note := func(text string) (*Recorded, error) {
+		return recorder.Record(&domain.SessionEvent{SessionID: session.ID, Type: domain.SessionNote, Text: text, Tags: []string{"task"}})
+	}
+	if _, err := note("Lexer"); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	if _, err := note("Parser"); !errors.Is(err, storage.ErrWIPLimitExceeded) {
+		t.Errorf("Expected ErrWIPLimitExceeded past the column's limit, got %v", err)
+	}
+
+	// Neither the task nor the event of the rejected note is kept
+	if n, _ := tasks.CountInColumn(board.ID, "todo"); n != 1 {
+		t.Errorf("Expected 1 task, got %d", n)
+	}
+	if history, err := recorder.History(session.ID); err != nil || len(history.Events) != 1 {
+		t.Errorf("Expected 1 event, got %+v (%v)", history, err)
+	}
+}
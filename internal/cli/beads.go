@@ -0,0 +1,150 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/rand/cartographer/internal/beads"
+	"github.com/rand/cartographer/internal/domain"
+	bd "github.com/steveyegge/beads"
+)
+
+var beadsVerbs = map[string]verb{
+	"list":   beadsList,
+	"show":   beadsShow,
+	"import": beadsImport,
+	"export": beadsExport,
+}
+
+func beadsList(c *command, args []string) error {
+	status := c.flags("").String("status", "", "only issues with this status")
+	if _, err := c.parse(args, 0, 0); err != nil {
+		return err
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+
+	var issues []*bd.Issue
+	if err := api.get("/api/beads/issues", nil, &issues); err != nil {
+		return err
+	}
+	if *status != "" {
+		var matching []*bd.Issue
+		for _, issue := range issues {
+			if string(issue.Status) == *status {
+				matching = append(matching, issue)
+			}
+		}
+		issues = matching
+	}
+	return c.print(issues, func(w io.Writer) {
+		row(w, "ID", "STATUS", "PRIORITY", "TYPE", "TITLE")
+		for _, i := range issues {
+			row(w, i.ID, string(i.Status), "P"+strconv.Itoa(i.Priority), string(i.IssueType), i.Title)
+		}
+	})
+}
+
+func beadsShow(c *command, args []string) error {
+	c.flags("<issue-id>")
+	args, err := c.parse(args, 1, 1)
+	if err != nil {
+		return err
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+
+	var issue bd.Issue
+	if err := api.get("/api/beads/issues/"+url.PathEscape(args[0]), nil, &issue); err != nil {
+		return err
+	}
+	return c.print(&issue, func(w io.Writer) {
+		dependencies := make([]string, len(issue.Dependencies))
+		for i, dep := range issue.Dependencies {
+			dependencies[i] = fmt.Sprintf("%s (%s)", dep.DependsOnID, dep.Type)
+		}
+		fields(w,
+			field{"ID", issue.ID},
+			field{"Title", issue.Title},
+			field{"Status", string(issue.Status)},
+			field{"Priority", "P" + strconv.Itoa(issue.Priority)},
+			field{"Type", string(issue.IssueType)},
+			field{"Assignee", issue.Assignee},
+			field{"Labels", strings.Join(issue.Labels, ", ")},
+			field{"Depends on", strings.Join(dependencies, ", ")},
+			field{"Created", timestamp(issue.CreatedAt)},
+			field{"Updated", timestamp(issue.UpdatedAt)},
+		)
+		if issue.Description != "" {
+			fmt.Fprintf(w, "\n%s\n", issue.Description)
+		}
+	})
+}
+
+// beadsImport imports the issues of the board's project into the board
+func beadsImport(c *command, args []string) error {
+	c.flags("<board-id>")
+	args, err := c.parse(args, 1, 1)
+	if err != nil {
+		return err
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+
+	var report beads.ImportReport
+	if err := api.post("/api/boards/"+url.PathEscape(args[0])+"/import/beads", nil, &report); err != nil {
+		return err
+	}
+	return c.print(&report, func(w io.Writer) {
+		fmt.Fprintf(w, "Created %d, updated %d and skipped %d tasks\n", len(report.Created), len(report.Updated), len(report.Skipped))
+		for _, skip := range report.Skipped {
+			fmt.Fprintf(w, "Skipped %s: %s\n", skip.BeadID, skip.Reason)
+		}
+	})
+}
+
+// beadsExport writes a board's tasks as beads issues, one JSON object per
+// line as in .beads/issues.jsonl
+func beadsExport(c *command, args []string) error {
+	fs := c.flags("")
+	board := fs.String("board", "", "ID of the board to export tasks of (required)")
+	output := fs.String("output", "", "file to write the issues to (default stdout)")
+	if _, err := c.parse(args, 0, 0); err != nil {
+		return err
+	}
+	if *board == "" {
+		return usagef("--board is required")
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+
+	var tasks []*domain.Task
+	if err := api.get("/api/boards/"+url.PathEscape(*board)+"/tasks", nil, &tasks); err != nil {
+		return err
+	}
+	return c.writeOutput(*output, func(w io.Writer) error {
+		encoder := json.NewEncoder(w)
+		for _, task := range tasks {
+			issue, err := beads.ConvertTaskToBead(task)
+			if err != nil {
+				return err
+			}
+			if err := encoder.Encode(issue); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,271 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/rand/cartographer/internal/domain"
+)
+
+var boardVerbs = map[string]verb{
+	"list":   boardList,
+	"show":   boardShow,
+	"create": boardCreate,
+	"update": boardUpdate,
+	"export": boardExport,
+	"import": boardImport,
+}
+
+// defaultColumns are the columns of a board created without any, as in the
+// web UI
+var defaultColumns = []domain.BoardColumn{
+	{ID: "todo", Name: "To Do", Order: 0},
+	{ID: "inprogress", Name: "In Progress", Order: 1},
+	{ID: "done", Name: "Done", Order: 2},
+}
+
+// stringList is a flag that can be repeated
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+func boardList(c *command, args []string) error {
+	project := c.flags("").String("project", "", "ID of the project to list boards of (required)")
+	if _, err := c.parse(args, 0, 0); err != nil {
+		return err
+	}
+	if *project == "" {
+		return usagef("--project is required")
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+
+	var boards []*domain.Board
+	if err := api.get("/api/projects/"+url.PathEscape(*project)+"/boards", nil, &boards); err != nil {
+		return err
+	}
+	return c.print(boards, func(w io.Writer) {
+		row(w, "ID", "NAME", "COLUMNS")
+		for _, b := range boards {
+			row(w, b.ID, b.Name, columnIDs(b.Columns))
+		}
+	})
+}
+
+func columnIDs(columns []domain.BoardColumn) string {
+	ids := make([]string, len(columns))
+	for i, column := range columns {
+		ids[i] = column.ID
+	}
+	return strings.Join(ids, ",")
+}
+
+func boardShow(c *command, args []string) error {
+	c.flags("<board-id>")
+	args, err := c.parse(args, 1, 1)
+	if err != nil {
+		return err
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+
+	var board domain.Board
+	if err := api.get("/api/boards/"+url.PathEscape(args[0]), nil, &board); err != nil {
+		return err
+	}
+	return c.printBoard(&board)
+}
+
+func (c *command) printBoard(b *domain.Board) error {
+	return c.print(b, func(w io.Writer) {
+		columns := make([]string, len(b.Columns))
+		for i, column := range b.Columns {
+			columns[i] = column.ID + " (" + column.Name
+			if column.WIPLimit > 0 {
+				columns[i] += ", limit " + strconv.Itoa(column.WIPLimit)
+			}
+			columns[i] += ")"
+		}
+		fields(w,
+			field{"ID", b.ID},
+			field{"Project", b.ProjectID},
+			field{"Name", b.Name},
+			field{"Description", b.Description},
+			field{"Columns", strings.Join(columns, ", ")},
+			field{"WIP policy", b.WIPPolicy},
+			field{"Created", timestamp(b.CreatedAt)},
+			field{"Updated", timestamp(b.UpdatedAt)},
+			field{"Revision", strconv.FormatInt(b.Revision, 10)},
+		)
+	})
+}
+
+// boardFlags adds the flags for a board's fields
+func boardFlags(c *command, usage string) (map[string]*string, *stringList) {
+	fs := c.flags(usage)
+	values := map[string]*string{
+		"name":        fs.String("name", "", "board name"),
+		"description": fs.String("description", "", "board description"),
+		"wip-policy":  fs.String("wip-policy", "", "what exceeding a WIP limit does: warn or enforce"),
+	}
+	var columns stringList
+	fs.Var(&columns, "column", "a column as id[:name[:wip-limit]], repeated for each column in order")
+	return values, &columns
+}
+
+// parseColumns parses --column values
+func parseColumns(values []string) ([]domain.BoardColumn, error) {
+	columns := make([]domain.BoardColumn, len(values))
+	for i, value := range values {
+		parts := strings.SplitN(value, ":", 3)
+		column := domain.BoardColumn{ID: parts[0], Name: parts[0], Order: i}
+		if len(parts) > 1 && parts[1] != "" {
+			column.Name = parts[1]
+		}
+		if len(parts) > 2 {
+			limit, err := strconv.Atoi(parts[2])
+			if err != nil {
+				return nil, usagef("invalid WIP limit in --column %s", value)
+			}
+			column.WIPLimit = limit
+		}
+		columns[i] = column
+	}
+	return columns, nil
+}
+
+func boardCreate(c *command, args []string) error {
+	values, columnFlags := boardFlags(c, "")
+	project := c.fs.String("project", "", "ID of the project to add the board to (required)")
+	if _, err := c.parse(args, 0, 0); err != nil {
+		return err
+	}
+	if *project == "" {
+		return usagef("--project is required")
+	}
+	columns, err := parseColumns(*columnFlags)
+	if err != nil {
+		return err
+	}
+	if len(columns) == 0 {
+		columns = defaultColumns
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+
+	board := domain.Board{
+		ProjectID:   *project,
+		Name:        *values["name"],
+		Description: *values["description"],
+		WIPPolicy:   *values["wip-policy"],
+		Columns:     columns,
+	}
+	if err := api.post("/api/boards", &board, &board); err != nil {
+		return err
+	}
+	return c.printBoard(&board)
+}
+
+func boardUpdate(c *command, args []string) error {
+	values, columnFlags := boardFlags(c, "<board-id>")
+	args, err := c.parse(args, 1, 1)
+	if err != nil {
+		return err
+	}
+	patch := c.patch(values)
+	if len(*columnFlags) > 0 {
+		if patch["columns"], err = parseColumns(*columnFlags); err != nil {
+			return err
+		}
+	}
+	if len(patch) == 0 {
+		return usagef("nothing to update")
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+
+	var board domain.Board
+	if err := api.patch("/api/boards/"+url.PathEscape(args[0]), patch, &board); err != nil {
+		return err
+	}
+	return c.printBoard(&board)
+}
+
+func boardExport(c *command, args []string) error {
+	output := c.flags("<board-id>").String("output", "", "file to write the export to (default stdout)")
+	args, err := c.parse(args, 1, 1)
+	if err != nil {
+		return err
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+
+	bundle, err := exportBoard(api, args[0])
+	if err != nil {
+		return err
+	}
+	return c.writeOutput(*output, func(w io.Writer) error { return writeJSON(w, bundle) })
+}
+
+func boardImport(c *command, args []string) error {
+	c.flags("<project-id> <file|->")
+	args, err := c.parse(args, 2, 2)
+	if err != nil {
+		return err
+	}
+	data, err := c.readInput(args[1])
+	if err != nil {
+		return err
+	}
+	var bundle boardBundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return fmt.Errorf("invalid export: %w", err)
+	}
+	if bundle.Board == nil {
+		return fmt.Errorf("invalid export: no board")
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+
+	board := domain.Board{
+		ProjectID:   args[0],
+		Name:        bundle.Board.Name,
+		Description: bundle.Board.Description,
+		Columns:     bundle.Board.Columns,
+		WIPPolicy:   bundle.Board.WIPPolicy,
+	}
+	if err := api.post("/api/boards", &board, &board); err != nil {
+		return err
+	}
+	imp := newImporter(api)
+	if err := imp.addTasks(board.ID, bundle.Tasks); err != nil {
+		return err
+	}
+	if err := imp.linkTasks(); err != nil {
+		return err
+	}
+	return c.printBoard(&board)
+}
@@ -0,0 +1,177 @@
+// Package cli implements the `cartographer project|board|task|doc|beads`
+// subcommands for scripts and git hooks. Commands use the REST API of the
+// running server when one answers, so its WebSocket clients see the
+// changes, and otherwise serve the API in process on the database.
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Config holds the defaults commands connect with
+type Config struct {
+	DataDir   string // database directory, used when no server answers
+	ServerURL string // base URL of the running server
+	Token     string // API token for the server
+}
+
+// verb runs one subcommand with its arguments
+type verb func(c *command, args []string) error
+
+// resources maps each resource to its verbs
+var resources = map[string]map[string]verb{
+	"project": projectVerbs,
+	"board":   boardVerbs,
+	"task":    taskVerbs,
+	"doc":     docVerbs,
+	"beads":   beadsVerbs,
+}
+
+// Run runs `cartographer <resource> <verb> [flags] [args]` and returns the
+// exit code: 0 on success, 1 if the command failed and 2 on a usage error
+func Run(cfg Config, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 || resources[args[0]] == nil {
+		fmt.Fprintln(stderr, "Usage: cartographer project|board|task|doc|beads <verb> [flags] [args]")
+		return 2
+	}
+	verbs := resources[args[0]]
+	if len(args) < 2 || verbs[args[1]] == nil {
+		fmt.Fprintf(stderr, "Usage: cartographer %s %s [flags] [args]\n", args[0], strings.Join(verbNames(verbs), "|"))
+		return 2
+	}
+
+	c := &command{name: args[0] + " " + args[1], cfg: cfg, stdin: stdin, stdout: stdout, stderr: stderr}
+	defer c.close()
+
+	err := verbs[args[1]](c, args[2:])
+	var usage *usageError
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.As(err, &usage):
+		fmt.Fprintf(stderr, "cartographer %s: %s\n", c.name, usage.message)
+		if c.fs != nil {
+			c.fs.Usage()
+		}
+		return 2
+	case errors.Is(err, errParse):
+		return 2
+	case err != nil:
+		fmt.Fprintf(stderr, "cartographer %s: %v\n", c.name, err)
+		return 1
+	}
+	return 0
+}
+
+func verbNames(verbs map[string]verb) []string {
+	names := make([]string, 0, len(verbs))
+	for name := range verbs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// usageError is a command invoked wrongly
+type usageError struct {
+	message string
+}
+
+func (e *usageError) Error() string {
+	return e.message
+}
+
+func usagef(format string, args ...interface{}) error {
+	return &usageError{fmt.Sprintf(format, args...)}
+}
+
+// errParse is a flag parse error, which the flag set has already reported
+var errParse = errors.New("invalid flags")
+
+// command is one subcommand being run
+type command struct {
+	name   string
+	cfg    Config
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+	fs     *flag.FlagSet
+	json   bool
+	local  bool
+	client *client
+}
+
+// flags returns the command's flag set, with the flags every command has.
+// usage describes the arguments after the flags.
+func (c *command) flags(usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(c.stderr, strings.TrimSpace("Usage: cartographer "+c.name+" [flags] "+usage))
+		fs.PrintDefaults()
+	}
+	fs.BoolVar(&c.json, "json", false, "print JSON instead of a table")
+	fs.StringVar(&c.cfg.ServerURL, "server", c.cfg.ServerURL, "URL of the running server")
+	fs.StringVar(&c.cfg.Token, "token", c.cfg.Token, "API token (default $CARTOGRAPHER_TOKEN)")
+	fs.BoolVar(&c.local, "local", false, "use the database directly even if a server is running")
+	c.fs = fs
+	return fs
+}
+
+// parse parses flags, which may come before, between or after the
+// arguments, and returns between min and max arguments (max < 0 for any)
+func (c *command) parse(args []string, min, max int) ([]string, error) {
+	var positional []string
+	for {
+		if err := c.fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, err
+			}
+			return nil, errParse
+		}
+		args = c.fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+	if len(positional) < min {
+		return nil, usagef("missing arguments")
+	}
+	if max >= 0 && len(positional) > max {
+		return nil, usagef("unexpected arguments: %s", strings.Join(positional[max:], " "))
+	}
+	return positional, nil
+}
+
+// set returns the names of the flags given on the command line
+func (c *command) set() map[string]bool {
+	set := make(map[string]bool)
+	c.fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	return set
+}
+
+// api returns the client for the server, or the database if no server is
+// running
+func (c *command) api() (*client, error) {
+	if c.client == nil {
+		client, err := connect(c.cfg, c.local, c.stderr)
+		if err != nil {
+			return nil, err
+		}
+		c.client = client
+	}
+	return c.client, nil
+}
+
+func (c *command) close() {
+	if c.client != nil {
+		c.client.close()
+	}
+}
@@ -0,0 +1,273 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rand/cartographer/internal/api/rest"
+	"github.com/rand/cartographer/internal/beads"
+	"github.com/rand/cartographer/internal/domain"
+	"github.com/rand/cartographer/internal/storage"
+)
+
+// run runs a command, returning its exit code, stdout and stderr
+func run(t *testing.T, cfg Config, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := Run(cfg, args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+// runJSON runs a command with --json and decodes its output into v,
+// failing the test if it doesn't succeed
+func runJSON(t *testing.T, cfg Config, v interface{}, args ...string) {
+	t.Helper()
+	code, stdout, stderr := run(t, cfg, "", append(args, "--json")...)
+	if code != 0 {
+		t.Fatalf("%v exited with %d: %s", args, code, stderr)
+	}
+	if err := json.Unmarshal([]byte(stdout), v); err != nil {
+		t.Fatalf("%v printed invalid JSON: %v\n%s", args, err, stdout)
+	}
+}
+
+func TestLocalCommands(t *testing.T) {
+	cfg := Config{DataDir: filepath.Join(t.TempDir(), "data")}
+
+	var project domain.Project
+	runJSON(t, cfg, &project, "project", "create", "--name", "Atlas", "--type", "api")
+	var board domain.Board
+	runJSON(t, cfg, &board, "board", "create", "--project", project.ID, "--name", "Main", "--wip-policy", "enforce", "--column", "todo", "--column", "doing:Doing:1", "--column", "done")
+	want := []domain.BoardColumn{{ID: "todo", Name: "todo", Order: 0}, {ID: "doing", Name: "Doing", WIPLimit: 1, Order: 1}, {ID: "done", Name: "done", Order: 2}}
+	if !reflect.DeepEqual(board.Columns, want) {
+		t.Errorf("columns = %+v, want %+v", board.Columns, want)
+	}
+
+	// Tasks start in the first column
+	var first, second domain.Task
+	runJSON(t, cfg, &first, "task", "create", "--board", board.ID, "--title", "Parser", "--label", "core", "--assignee", "agent:bot", "--comment", "started")
+	if first.Status != "todo" || !reflect.DeepEqual(first.Labels, []string{"core"}) || first.Assignee == nil || first.Assignee.Type != domain.AssigneeAgent {
+		t.Errorf("created %+v", first)
+	}
+	runJSON(t, cfg, &second, "task", "create", "--board", board.ID, "--title", "Renderer", "--depends-on", first.ID)
+
+	// Flags may follow the arguments
+	var move domain.TaskMove
+	runJSON(t, cfg, &move, "task", "move", first.ID, "doing", "--position", "0")
+	if move.From != "todo" || move.To != "doing" {
+		t.Errorf("moved from %s to %s, want todo to doing", move.From, move.To)
+	}
+
+	var updated domain.Task
+	runJSON(t, cfg, &updated, "task", "update", second.ID, "--priority", "high", "--estimate", "2.5")
+	if updated.Priority != "high" || updated.Estimate == nil || *updated.Estimate != 2.5 || updated.Title != "Renderer" {
+		t.Errorf("updated %+v", updated)
+	}
+
+	code, stdout, _ := run(t, cfg, "", "task", "list", "--board", board.ID, "--status", "todo")
+	if code != 0 || !strings.Contains(stdout, "Renderer") || strings.Contains(stdout, "Parser") {
+		t.Errorf("task list exited with %d:\n%s", code, stdout)
+	}
+	if !strings.HasPrefix(stdout, "ID ") {
+		t.Errorf("task list has no header:\n%s", stdout)
+	}
+
+	// The REST API validates what commands send
+	code, _, stderr := run(t, cfg, "", "task", "create", "--board", board.ID, "--title", "WIP", "--status", "doing")
+	if code != 1 || !strings.Contains(stderr, "WIP limit") {
+		t.Errorf("task over the WIP limit exited with %d: %s", code, stderr)
+	}
+	code, _, stderr = run(t, cfg, "", "task", "create", "--board", board.ID)
+	if code != 1 || !strings.Contains(stderr, "title: is required") {
+		t.Errorf("task without a title exited with %d: %s", code, stderr)
+	}
+
+	for _, args := range [][]string{
+		{"task"},
+		{"task", "fly"},
+		{"task", "list"},
+		{"task", "update", first.ID},
+		{"task", "show"},
+		{"task", "show", first.ID, second.ID},
+		{"task", "show", "--bogus", first.ID},
+	} {
+		if code, _, _ := run(t, cfg, "", args...); code != 2 {
+			t.Errorf("%v exited with %d, want 2", args, code)
+		}
+	}
+	if code, _, _ := run(t, cfg, "", "task", "show", "missing"); code != 1 {
+		t.Errorf("showing a missing task exited with %d, want 1", code)
+	}
+}
+
+func TestProjectExportImport(t *testing.T) {
+	dir := t.TempDir()
+	cfg := Config{DataDir: filepath.Join(dir, "data")}
+
+	var project domain.Project
+	runJSON(t, cfg, &project, "project", "create", "--name", "Atlas")
+	var doc domain.Document
+	runJSON(t, cfg, &doc, "doc", "create", "--project", project.ID, "--title", "Design", "--content", "# Design")
+	var board domain.Board
+	runJSON(t, cfg, &board, "board", "create", "--project", project.ID, "--name", "Main")
+	var first, second domain.Task
+	runJSON(t, cfg, &first, "task", "create", "--board", board.ID, "--title", "Parser", "--comment", "started")
+	runJSON(t, cfg, &second, "task", "create", "--board", board.ID, "--title", "Renderer", "--depends-on", first.ID)
+
+	export := filepath.Join(dir, "atlas.json")
+	if code, _, stderr := run(t, cfg, "", "project", "export", project.ID, "--output", export); code != 0 {
+		t.Fatalf("export exited with %d: %s", code, stderr)
+	}
+	data, err := os.ReadFile(export)
+	if err != nil {
+		t.Fatalf("failed to read export: %v", err)
+	}
+
+	// Import from stdin
+	var imported domain.Project
+	code, stdout, stderr := run(t, cfg, string(data), "project", "import", "-", "--json")
+	if code != 0 {
+		t.Fatalf("import exited with %d: %s", code, stderr)
+	}
+	json.Unmarshal([]byte(stdout), &imported)
+	if imported.ID == project.ID || imported.Name != "Atlas" {
+		t.Fatalf("imported %+v", imported)
+	}
+
+	var bundle projectBundle
+	code, stdout, stderr = run(t, cfg, "", "project", "export", imported.ID)
+	if code != 0 {
+		t.Fatalf("export of the import exited with %d: %s", code, stderr)
+	}
+	if err := json.Unmarshal([]byte(stdout), &bundle); err != nil {
+		t.Fatalf("invalid export: %v", err)
+	}
+	if len(bundle.Documents) != 1 || bundle.Documents[0].Content != "# Design" || bundle.Documents[0].ID == doc.ID {
+		t.Errorf("imported documents %+v", bundle.Documents)
+	}
+	if len(bundle.Boards) != 1 || len(bundle.Boards[0].Tasks) != 2 {
+		t.Fatalf("imported boards %+v", bundle.Boards)
+	}
+	if got := bundle.Boards[0].Board.Columns; !reflect.DeepEqual(got, defaultColumns) {
+		t.Errorf("imported columns %+v", got)
+	}
+	parser, renderer := bundle.Boards[0].Tasks[0], bundle.Boards[0].Tasks[1]
+	if parser.Title != "Parser" || renderer.Title != "Renderer" {
+		t.Fatalf("imported tasks %q and %q", parser.Title, renderer.Title)
+	}
+	if parser.ID == first.ID || !reflect.DeepEqual(renderer.Dependencies, []string{parser.ID}) {
+		t.Errorf("renderer depends on %v, want [%s]", renderer.Dependencies, parser.ID)
+	}
+	var comments []string
+	for _, entry := range parser.Activity {
+		if entry.Type == domain.ActivityCommented {
+			comments = append(comments, entry.Comment)
+		}
+	}
+	if !reflect.DeepEqual(comments, []string{"started"}) {
+		t.Errorf("imported comments %v", comments)
+	}
+}
+
+func TestDocImport(t *testing.T) {
+	dir := t.TempDir()
+	cfg := Config{DataDir: filepath.Join(dir, "data")}
+	var project domain.Project
+	runJSON(t, cfg, &project, "project", "create", "--name", "Atlas")
+
+	titled := filepath.Join(dir, "design.md")
+	untitled := filepath.Join(dir, "notes.md")
+	os.WriteFile(titled, []byte("Intro\n\n# Design notes\n\nBody\n"), 0644)
+	os.WriteFile(untitled, []byte("No heading\n"), 0644)
+
+	var docs []*domain.Document
+	runJSON(t, cfg, &docs, "doc", "import", project.ID, titled, untitled)
+	if len(docs) != 2 || docs[0].Title != "Design notes" || docs[1].Title != "notes" {
+		t.Fatalf("imported %+v", docs)
+	}
+
+	// Importing a path again updates its document
+	os.WriteFile(titled, []byte("# Design\n"), 0644)
+	var again []*domain.Document
+	runJSON(t, cfg, &again, "doc", "import", project.ID, titled)
+	if len(again) != 1 || again[0].ID != docs[0].ID || again[0].Title != "Design" {
+		t.Errorf("reimported %+v, want document %s retitled", again, docs[0].ID)
+	}
+
+	code, stdout, _ := run(t, cfg, "", "doc", "export", docs[0].ID)
+	if code != 0 || stdout != "# Design\n" {
+		t.Errorf("export exited with %d: %q", code, stdout)
+	}
+}
+
+func TestRemoteCommands(t *testing.T) {
+	db, err := storage.New(filepath.Join(t.TempDir(), "data"))
+	if err != nil {
+		t.Fatalf("storage.New failed: %v", err)
+	}
+	defer db.Close()
+
+	logger := log.New(io.Discard, "", 0)
+	tokens := storage.NewAPITokenRepository(db)
+	audit := storage.NewAuditRepository(db)
+	secret, err := tokens.Create(&domain.APIToken{Name: "hook", Scope: domain.ScopeWrite})
+	if err != nil {
+		t.Fatalf("failed to create token: %v", err)
+	}
+
+	watcher := beads.NewWatcher(beads.NewParser(t.TempDir()), 0, logger)
+	watcher.Refresh()
+	api := rest.NewAPIHandler(rest.Config{
+		Projects:     storage.NewProjectRepository(db),
+		Boards:       storage.NewBoardRepository(db),
+		Tasks:        storage.NewTaskRepository(db),
+		Documents:    storage.NewDocumentRepository(db),
+		Diagrams:     storage.NewDiagramRepository(db),
+		Search:       storage.NewSearchRepository(db),
+		APITokens:    tokens,
+		Audit:        audit,
+		Leases:       storage.NewLeaseRepository(db),
+		BeadsWatcher: watcher,
+		Logger:       logger,
+	})
+	mux := http.NewServeMux()
+	api.Register(mux)
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"ok","service":"cartographer"}`))
+	})
+	server := httptest.NewServer(rest.AuthMiddleware(tokens, logger)(mux))
+	defer server.Close()
+
+	// The database directory is never opened while the server answers
+	cfg := Config{DataDir: filepath.Join(t.TempDir(), "unused"), ServerURL: server.URL, Token: secret}
+	var project domain.Project
+	runJSON(t, cfg, &project, "project", "create", "--name", "Atlas")
+
+	entries, err := audit.List(storage.AuditQuery{EntityID: project.ID})
+	if err != nil || len(entries) != 1 || entries[0].User == nil || entries[0].User.ID != "hook" {
+		t.Errorf("audit entries %+v (%v), want one by hook", entries, err)
+	}
+	if _, err := os.Stat(cfg.DataDir); !os.IsNotExist(err) {
+		t.Errorf("data directory was created")
+	}
+
+	cfg.Token = ""
+	code, _, stderr := run(t, cfg, "", "project", "list")
+	if code != 1 || !strings.Contains(stderr, "Authentication required") {
+		t.Errorf("unauthenticated list exited with %d: %s", code, stderr)
+	}
+
+	code, stdout, _ := run(t, cfg, "", "beads", "list", "--token", secret)
+	if code != 0 || strings.TrimSpace(stdout) != "ID  STATUS  PRIORITY  TYPE  TITLE" {
+		t.Errorf("beads list without an issues file exited with %d:\n%s", code, stdout)
+	}
+}
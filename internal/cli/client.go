@@ -0,0 +1,220 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rand/cartographer/internal/api/rest"
+	"github.com/rand/cartographer/internal/beads"
+	"github.com/rand/cartographer/internal/claude"
+	"github.com/rand/cartographer/internal/domain"
+	"github.com/rand/cartographer/internal/storage"
+)
+
+// client makes REST API requests, to a running server or to the API served
+// in process on the database
+type client struct {
+	base  string
+	token string
+	http  *http.Client
+	local bool
+	close func() error
+}
+
+// APIError is an error response from the API
+type APIError struct {
+	Status      int
+	Message     string
+	FieldErrors []domain.FieldError
+}
+
+func (e *APIError) Error() string {
+	if len(e.FieldErrors) == 0 {
+		return e.Message
+	}
+	fields := make([]string, len(e.FieldErrors))
+	for i, f := range e.FieldErrors {
+		fields[i] = f.Field + ": " + f.Message
+	}
+	return e.Message + " (" + strings.Join(fields, "; ") + ")"
+}
+
+// connect returns a client for the server at cfg.ServerURL if it answers
+// its health check, or else for the database in cfg.DataDir. local skips
+// the server.
+func connect(cfg Config, local bool, stderr io.Writer) (*client, error) {
+	if !local && cfg.ServerURL != "" && serverUp(cfg.ServerURL) {
+		return &client{
+			base:  strings.TrimSuffix(cfg.ServerURL, "/"),
+			token: cfg.Token,
+			http:  &http.Client{Timeout: 30 * time.Second},
+			close: func() error { return nil },
+		}, nil
+	}
+	return openLocal(cfg, stderr)
+}
+
+// serverUp reports whether a Cartographer server answers at serverURL
+func serverUp(serverURL string) bool {
+	c := &http.Client{Timeout: time.Second}
+	resp, err := c.Get(strings.TrimSuffix(serverURL, "/") + "/health")
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+
+	var health struct {
+		Service string `json:"service"`
+	}
+	json.NewDecoder(resp.Body).Decode(&health)
+	return health.Service == "cartographer"
+}
+
+// openLocal serves the REST API in process on the database, so commands
+// get the same validation, activity, audit log and beads sync as through a
+// server. There are no WebSocket clients to broadcast to. With a token,
+// requests are authenticated and attributed as the server would.
+func openLocal(cfg Config, stderr io.Writer) (*client, error) {
+	db, err := storage.Open(cfg.DataDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	if _, err := db.Migrate(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	logger := log.New(stderr, "[cartographer] ", 0)
+	boards := storage.NewBoardRepository(db)
+	tasks := storage.NewTaskRepository(db)
+	tokens := storage.NewAPITokenRepository(db)
+
+	// The issues file in the working directory, as the server syncs it
+	watcher := beads.NewWatcher(beads.NewParser("."), 0, logger)
+	watcher.Refresh()
+	syncer := beads.NewSyncer(watcher, tasks, boards, storage.NewBeadSyncRepository(db), logger)
+
+	api := rest.NewAPIHandler(rest.Config{
+		Projects:     storage.NewProjectRepository(db),
+		Boards:       boards,
+		Tasks:        tasks,
+		Documents:    storage.NewDocumentRepository(db),
+		Diagrams:     storage.NewDiagramRepository(db),
+		Search:       storage.NewSearchRepository(db),
+		APITokens:    tokens,
+		Audit:        storage.NewAuditRepository(db),
+		Leases:       storage.NewLeaseRepository(db),
+		Sessions:     claude.NewRecorder(storage.NewSessionRepository(db), tasks, boards),
+		BeadsWatcher: watcher,
+		BeadsSync:    syncer,
+		Logger:       logger,
+	})
+	mux := http.NewServeMux()
+	api.Register(mux)
+
+	var handler http.Handler = mux
+	if cfg.Token != "" {
+		handler = rest.AuthMiddleware(tokens, logger)(handler)
+	}
+	return &client{
+		base:  "http://local",
+		token: cfg.Token,
+		http:  &http.Client{Transport: handlerTransport{handler}},
+		local: true,
+		close: db.Close,
+	}, nil
+}
+
+// handlerTransport serves requests with a handler instead of the network
+type handlerTransport struct {
+	handler http.Handler
+}
+
+func (t handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rec := httptest.NewRecorder()
+	t.handler.ServeHTTP(rec, req)
+	return rec.Result(), nil
+}
+
+// do makes a request with body encoded as JSON, if not nil, and decodes
+// the response into v, if not nil. Error responses return an *APIError.
+func (c *client) do(method, path string, body, v interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, c.base+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		// A failed batch reports the operation that failed instead
+		var failure struct {
+			Message     string              `json:"message"`
+			FieldErrors []domain.FieldError `json:"field_errors"`
+			Results     []struct {
+				Index       int                 `json:"index"`
+				Error       string              `json:"error"`
+				FieldErrors []domain.FieldError `json:"field_errors"`
+			} `json:"results"`
+		}
+		json.NewDecoder(resp.Body).Decode(&failure)
+		if failure.Message == "" && len(failure.Results) > 0 {
+			result := failure.Results[0]
+			failure.Message = fmt.Sprintf("operation %d: %s", result.Index, result.Error)
+			failure.FieldErrors = result.FieldErrors
+		}
+		if failure.Message == "" {
+			failure.Message = resp.Status
+		}
+		return &APIError{Status: resp.StatusCode, Message: failure.Message, FieldErrors: failure.FieldErrors}
+	}
+	if v == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("invalid response to %s %s: %w", method, path, err)
+	}
+	return nil
+}
+
+func (c *client) get(path string, query url.Values, v interface{}) error {
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	return c.do(http.MethodGet, path, nil, v)
+}
+
+func (c *client) post(path string, body, v interface{}) error {
+	return c.do(http.MethodPost, path, body, v)
+}
+
+func (c *client) patch(path string, body, v interface{}) error {
+	return c.do(http.MethodPatch, path, body, v)
+}
@@ -0,0 +1,273 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/rand/cartographer/internal/domain"
+)
+
+var docVerbs = map[string]verb{
+	"list":   docList,
+	"show":   docShow,
+	"create": docCreate,
+	"update": docUpdate,
+	"export": docExport,
+	"import": docImport,
+}
+
+func docList(c *command, args []string) error {
+	fs := c.flags("")
+	project := fs.String("project", "", "ID of the project to list documents of (required)")
+	tag := fs.String("tag", "", "only documents with this tag")
+	q := fs.String("q", "", "only documents whose title or content contains this text")
+	if _, err := c.parse(args, 0, 0); err != nil {
+		return err
+	}
+	if *project == "" {
+		return usagef("--project is required")
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+
+	params := url.Values{}
+	if *tag != "" {
+		params.Set("tag", *tag)
+	}
+	if *q != "" {
+		params.Set("q", *q)
+	}
+	var docs []*domain.Document
+	if err := api.get("/api/projects/"+url.PathEscape(*project)+"/docs", params, &docs); err != nil {
+		return err
+	}
+	return c.print(docs, func(w io.Writer) {
+		row(w, "ID", "TITLE", "PATH")
+		for _, d := range docs {
+			row(w, d.ID, d.Title, d.Path)
+		}
+	})
+}
+
+func docShow(c *command, args []string) error {
+	c.flags("<document-id>")
+	args, err := c.parse(args, 1, 1)
+	if err != nil {
+		return err
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+
+	var doc domain.Document
+	if err := api.get("/api/documents/"+url.PathEscape(args[0]), nil, &doc); err != nil {
+		return err
+	}
+	return c.printDoc(&doc)
+}
+
+func (c *command) printDoc(d *domain.Document) error {
+	return c.print(d, func(w io.Writer) {
+		fields(w,
+			field{"ID", d.ID},
+			field{"Project", d.ProjectID},
+			field{"Title", d.Title},
+			field{"Path", d.Path},
+			field{"Tags", strings.Join(d.Tags, ", ")},
+			field{"Created", timestamp(d.CreatedAt)},
+			field{"Updated", timestamp(d.UpdatedAt)},
+			field{"Revision", strconv.FormatInt(d.Revision, 10)},
+		)
+	})
+}
+
+// docFlags holds the flags for a document's fields
+type docFlags struct {
+	values  map[string]*string
+	content *string
+	tags    stringList
+}
+
+func newDocFlags(c *command, usage string) *docFlags {
+	fs := c.flags(usage)
+	f := &docFlags{
+		values: map[string]*string{
+			"title": fs.String("title", "", "document title"),
+			"path":  fs.String("path", "", "path of the document in the project"),
+		},
+		content: fs.String("content", "", "markdown content, or @file to read it from a file (@- for stdin)"),
+	}
+	fs.Var(&f.tags, "tag", "a tag, repeated for each tag")
+	return f
+}
+
+// patch returns a merge patch of the document fields given on the command
+// line
+func (f *docFlags) patch(c *command) (map[string]interface{}, error) {
+	patch := c.patch(f.values)
+	set := c.set()
+	if set["content"] {
+		content, err := c.text(*f.content)
+		if err != nil {
+			return nil, err
+		}
+		patch["content"] = content
+	}
+	if set["tag"] {
+		patch["tags"] = []string(f.tags)
+	}
+	return patch, nil
+}
+
+func docCreate(c *command, args []string) error {
+	f := newDocFlags(c, "")
+	project := c.fs.String("project", "", "ID of the project to add the document to (required)")
+	if _, err := c.parse(args, 0, 0); err != nil {
+		return err
+	}
+	if *project == "" {
+		return usagef("--project is required")
+	}
+	patch, err := f.patch(c)
+	if err != nil {
+		return err
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+
+	var doc domain.Document
+	if err := api.post("/api/projects/"+url.PathEscape(*project)+"/docs", patch, &doc); err != nil {
+		return err
+	}
+	return c.printDoc(&doc)
+}
+
+func docUpdate(c *command, args []string) error {
+	f := newDocFlags(c, "<document-id>")
+	args, err := c.parse(args, 1, 1)
+	if err != nil {
+		return err
+	}
+	patch, err := f.patch(c)
+	if err != nil {
+		return err
+	}
+	if len(patch) == 0 {
+		return usagef("nothing to update")
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+
+	var doc domain.Document
+	if err := api.patch("/api/documents/"+url.PathEscape(args[0]), patch, &doc); err != nil {
+		return err
+	}
+	return c.printDoc(&doc)
+}
+
+// docExport writes a document's markdown, or the document as JSON with
+// --json
+func docExport(c *command, args []string) error {
+	output := c.flags("<document-id>").String("output", "", "file to write the document to (default stdout)")
+	args, err := c.parse(args, 1, 1)
+	if err != nil {
+		return err
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+
+	var doc domain.Document
+	if err := api.get("/api/documents/"+url.PathEscape(args[0]), nil, &doc); err != nil {
+		return err
+	}
+	return c.writeOutput(*output, func(w io.Writer) error {
+		if c.json {
+			return writeJSON(w, &doc)
+		}
+		_, err := io.WriteString(w, doc.Content)
+		return err
+	})
+}
+
+// docImport creates a document from each markdown file, or updates the
+// project's document with the file's path
+func docImport(c *command, args []string) error {
+	c.flags("<project-id> <file>...")
+	args, err := c.parse(args, 2, -1)
+	if err != nil {
+		return err
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+
+	var existing []*domain.Document
+	if err := api.get("/api/projects/"+url.PathEscape(args[0])+"/docs", nil, &existing); err != nil {
+		return err
+	}
+	byPath := make(map[string]*domain.Document)
+	for _, doc := range existing {
+		if doc.Path != "" {
+			byPath[doc.Path] = doc
+		}
+	}
+
+	var imported []*domain.Document
+	var actions []string
+	for _, file := range args[1:] {
+		data, err := c.readInput(file)
+		if err != nil {
+			return err
+		}
+		path := filepath.ToSlash(filepath.Clean(file))
+		doc := map[string]interface{}{"title": markdownTitle(string(data), file), "path": path, "content": string(data)}
+
+		var result domain.Document
+		if current, ok := byPath[path]; ok {
+			err = api.patch("/api/documents/"+url.PathEscape(current.ID), doc, &result)
+			actions = append(actions, "updated")
+		} else {
+			err = api.post("/api/projects/"+url.PathEscape(args[0])+"/docs", doc, &result)
+			actions = append(actions, "created")
+		}
+		if err != nil {
+			return fmt.Errorf("importing %s: %w", file, err)
+		}
+		imported = append(imported, &result)
+	}
+
+	return c.print(imported, func(w io.Writer) {
+		row(w, "ACTION", "ID", "TITLE", "PATH")
+		for i, d := range imported {
+			row(w, actions[i], d.ID, d.Title, d.Path)
+		}
+	})
+}
+
+// markdownTitle returns the text of a document's first level one heading,
+// or else its file name without the extension
+func markdownTitle(content, file string) string {
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		if title, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "# "); ok && strings.TrimSpace(title) != "" {
+			return strings.TrimSpace(title)
+		}
+	}
+	base := filepath.Base(file)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
@@ -0,0 +1,139 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/rand/cartographer/internal/domain"
+)
+
+// maxBatch is the most operations POST /api/tasks/batch accepts
+const maxBatch = 500
+
+// batchOperation is an operation of a task batch
+type batchOperation struct {
+	Op     string          `json:"op"`
+	TempID string          `json:"temp_id,omitempty"`
+	ID     string          `json:"id,omitempty"`
+	Task   json.RawMessage `json:"task,omitempty"`
+}
+
+// batchResult is the outcome of a batch operation
+type batchResult struct {
+	Index  int    `json:"index"`
+	ID     string `json:"id"`
+	TempID string `json:"temp_id"`
+}
+
+// importer recreates exported tasks under new IDs. Tasks are created
+// without the tasks they refer to, which may not exist yet, and linked once
+// they all do.
+type importer struct {
+	api     *client
+	docIDs  map[string]string // exported document ID to imported
+	taskIDs map[string]string // exported task ID to imported
+	links   []*domain.Task    // exported tasks that refer to others
+}
+
+func newImporter(api *client) *importer {
+	return &importer{api: api, docIDs: make(map[string]string), taskIDs: make(map[string]string)}
+}
+
+// addTasks creates exported tasks on a board, in order
+func (imp *importer) addTasks(boardID string, tasks []*domain.Task) error {
+	var ops []batchOperation
+	for _, task := range tasks {
+		created := domain.Task{
+			BoardID:     boardID,
+			Title:       task.Title,
+			Description: task.Description,
+			Status:      task.Status,
+			Priority:    task.Priority,
+			Assignee:    task.Assignee,
+			Labels:      task.Labels,
+			DueDate:     task.DueDate,
+			Estimate:    task.Estimate,
+			Actual:      task.Actual,
+			Checklist:   task.Checklist,
+		}
+		for _, item := range task.LinkedItems {
+			if id, ok := imp.docIDs[item.ID]; ok && item.Type == "doc" {
+				item.ID = id
+			}
+			created.LinkedItems = append(created.LinkedItems, item)
+		}
+		// The server records the rest of the activity anew
+		for _, entry := range task.Activity {
+			if entry.Type == domain.ActivityCommented {
+				created.Activity = append(created.Activity, entry)
+			}
+		}
+		data, err := json.Marshal(&created)
+		if err != nil {
+			return err
+		}
+		ops = append(ops, batchOperation{Op: "create", TempID: task.ID, Task: data})
+
+		if len(task.Dependencies)+len(task.Blocks)+len(task.Related) > 0 {
+			imp.links = append(imp.links, task)
+		}
+	}
+
+	return imp.batch(ops, func(op batchOperation, result batchResult) {
+		imp.taskIDs[op.TempID] = result.ID
+	})
+}
+
+// linkTasks sets the references between the imported tasks
+func (imp *importer) linkTasks() error {
+	var ops []batchOperation
+	for _, task := range imp.links {
+		patch := map[string][]string{
+			"dependencies": imp.mapTasks(task.Dependencies),
+			"blocks":       imp.mapTasks(task.Blocks),
+			"related":      imp.mapTasks(task.Related),
+		}
+		data, err := json.Marshal(patch)
+		if err != nil {
+			return err
+		}
+		ops = append(ops, batchOperation{Op: "update", ID: imp.taskIDs[task.ID], Task: data})
+	}
+	return imp.batch(ops, nil)
+}
+
+// mapTasks maps exported task IDs to the imported tasks, dropping those
+// that weren't exported
+func (imp *importer) mapTasks(ids []string) []string {
+	mapped := []string{}
+	for _, id := range ids {
+		if newID, ok := imp.taskIDs[id]; ok {
+			mapped = append(mapped, newID)
+		}
+	}
+	return mapped
+}
+
+// batch applies operations in atomic batches of at most maxBatch, calling
+// done with each operation's result
+func (imp *importer) batch(ops []batchOperation, done func(batchOperation, batchResult)) error {
+	for start := 0; start < len(ops); start += maxBatch {
+		chunk := ops[start:min(start+maxBatch, len(ops))]
+		var resp struct {
+			Results []batchResult `json:"results"`
+		}
+		req := map[string]interface{}{"mode": "atomic", "operations": chunk}
+		if err := imp.api.post("/api/tasks/batch", req, &resp); err != nil {
+			return fmt.Errorf("importing tasks: %w", err)
+		}
+		if done == nil {
+			continue
+		}
+		for _, result := range resp.Results {
+			if result.Index >= 0 && result.Index < len(chunk) {
+				done(chunk[result.Index], result)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// print writes v as indented JSON with --json, or else as written by table
+func (c *command) print(v interface{}, table func(w io.Writer)) error {
+	if c.json {
+		return writeJSON(c.stdout, v)
+	}
+	tw := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	table(tw)
+	return tw.Flush()
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// row writes tab-separated cells as a table row
+func row(w io.Writer, cells ...string) {
+	for i, cell := range cells {
+		// Keep multi-line values on their row
+		cells[i] = strings.Join(strings.Fields(cell), " ")
+		if cells[i] == "" {
+			cells[i] = "-"
+		}
+	}
+	fmt.Fprintln(w, strings.Join(cells, "\t"))
+}
+
+// field is a name and value shown by a show command
+type field struct {
+	name  string
+	value string
+}
+
+// fields writes name and value rows
+func fields(w io.Writer, fields ...field) {
+	for _, f := range fields {
+		if f.value != "" {
+			fmt.Fprintf(w, "%s:\t%s\n", f.name, f.value)
+		}
+	}
+}
+
+func timestamp(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// writeOutput writes data to the file at path, or stdout for "" or "-"
+func (c *command) writeOutput(path string, write func(w io.Writer) error) error {
+	if path == "" || path == "-" {
+		return write(c.stdout)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readInput reads the file at path, or stdin for "-"
+func (c *command) readInput(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(c.stdin)
+	}
+	return os.ReadFile(path)
+}
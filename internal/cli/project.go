@@ -0,0 +1,268 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/rand/cartographer/internal/domain"
+)
+
+var projectVerbs = map[string]verb{
+	"list":   projectList,
+	"show":   projectShow,
+	"create": projectCreate,
+	"update": projectUpdate,
+	"export": projectExport,
+	"import": projectImport,
+}
+
+func projectList(c *command, args []string) error {
+	c.flags("")
+	if _, err := c.parse(args, 0, 0); err != nil {
+		return err
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+
+	var projects []*domain.Project
+	if err := api.get("/api/projects", nil, &projects); err != nil {
+		return err
+	}
+	return c.print(projects, func(w io.Writer) {
+		row(w, "ID", "NAME", "TYPE", "PATH")
+		for _, p := range projects {
+			row(w, p.ID, p.Name, p.Type, p.Path)
+		}
+	})
+}
+
+func projectShow(c *command, args []string) error {
+	c.flags("<project-id>")
+	args, err := c.parse(args, 1, 1)
+	if err != nil {
+		return err
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+
+	var project domain.Project
+	if err := api.get("/api/projects/"+url.PathEscape(args[0]), nil, &project); err != nil {
+		return err
+	}
+	return c.printProject(&project)
+}
+
+func (c *command) printProject(p *domain.Project) error {
+	return c.print(p, func(w io.Writer) {
+		fields(w,
+			field{"ID", p.ID},
+			field{"Name", p.Name},
+			field{"Type", p.Type},
+			field{"Path", p.Path},
+			field{"Description", p.Description},
+			field{"Created", timestamp(p.CreatedAt)},
+			field{"Updated", timestamp(p.UpdatedAt)},
+			field{"Revision", strconv.FormatInt(p.Revision, 10)},
+		)
+	})
+}
+
+// projectFlags adds the flags for a project's fields
+func projectFlags(c *command, usage string) map[string]*string {
+	fs := c.flags(usage)
+	return map[string]*string{
+		"name":        fs.String("name", "", "project name"),
+		"path":        fs.String("path", "", "path of the project's repository"),
+		"description": fs.String("description", "", "project description"),
+		"type":        fs.String("type", "", "project type: web-app, api, library or custom"),
+	}
+}
+
+func projectCreate(c *command, args []string) error {
+	values := projectFlags(c, "")
+	if _, err := c.parse(args, 0, 0); err != nil {
+		return err
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+
+	project := domain.Project{
+		Name:        *values["name"],
+		Path:        *values["path"],
+		Description: *values["description"],
+		Type:        *values["type"],
+	}
+	if err := api.post("/api/projects", &project, &project); err != nil {
+		return err
+	}
+	return c.printProject(&project)
+}
+
+func projectUpdate(c *command, args []string) error {
+	values := projectFlags(c, "<project-id>")
+	args, err := c.parse(args, 1, 1)
+	if err != nil {
+		return err
+	}
+	patch := c.patch(values)
+	if len(patch) == 0 {
+		return usagef("nothing to update")
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+
+	var project domain.Project
+	if err := api.patch("/api/projects/"+url.PathEscape(args[0]), patch, &project); err != nil {
+		return err
+	}
+	return c.printProject(&project)
+}
+
+// patch returns a merge patch of the string flags given on the command
+// line, named as the fields they set
+func (c *command) patch(values map[string]*string) map[string]interface{} {
+	patch := make(map[string]interface{})
+	set := c.set()
+	for name, value := range values {
+		if set[name] {
+			patch[strings.ReplaceAll(name, "-", "_")] = *value
+		}
+	}
+	return patch
+}
+
+// projectBundle is a project exported with its documents, boards and tasks
+type projectBundle struct {
+	Project   *domain.Project    `json:"project"`
+	Documents []*domain.Document `json:"documents"`
+	Boards    []*boardBundle     `json:"boards"`
+}
+
+// boardBundle is a board exported with its tasks
+type boardBundle struct {
+	Board *domain.Board  `json:"board"`
+	Tasks []*domain.Task `json:"tasks"`
+}
+
+func projectExport(c *command, args []string) error {
+	output := c.flags("<project-id>").String("output", "", "file to write the export to (default stdout)")
+	args, err := c.parse(args, 1, 1)
+	if err != nil {
+		return err
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+
+	bundle := projectBundle{Project: &domain.Project{}}
+	if err := api.get("/api/projects/"+url.PathEscape(args[0]), nil, bundle.Project); err != nil {
+		return err
+	}
+	if err := api.get("/api/projects/"+url.PathEscape(args[0])+"/docs", nil, &bundle.Documents); err != nil {
+		return err
+	}
+	var boards []*domain.Board
+	if err := api.get("/api/projects/"+url.PathEscape(args[0])+"/boards", nil, &boards); err != nil {
+		return err
+	}
+	for _, board := range boards {
+		b, err := exportBoard(api, board.ID)
+		if err != nil {
+			return err
+		}
+		bundle.Boards = append(bundle.Boards, b)
+	}
+	return c.writeOutput(*output, func(w io.Writer) error { return writeJSON(w, &bundle) })
+}
+
+// exportBoard returns a board with its tasks
+func exportBoard(api *client, id string) (*boardBundle, error) {
+	b := &boardBundle{Board: &domain.Board{}}
+	if err := api.get("/api/boards/"+url.PathEscape(id), nil, b.Board); err != nil {
+		return nil, err
+	}
+	if err := api.get("/api/boards/"+url.PathEscape(id)+"/tasks", nil, &b.Tasks); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func projectImport(c *command, args []string) error {
+	c.flags("<file|->")
+	args, err := c.parse(args, 1, 1)
+	if err != nil {
+		return err
+	}
+	data, err := c.readInput(args[0])
+	if err != nil {
+		return err
+	}
+	var bundle projectBundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return fmt.Errorf("invalid export: %w", err)
+	}
+	if bundle.Project == nil {
+		return fmt.Errorf("invalid export: no project")
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+
+	project := domain.Project{
+		Name:        bundle.Project.Name,
+		Description: bundle.Project.Description,
+		Path:        bundle.Project.Path,
+		Type:        bundle.Project.Type,
+		Settings:    bundle.Project.Settings,
+		Metadata:    bundle.Project.Metadata,
+	}
+	if err := api.post("/api/projects", &project, &project); err != nil {
+		return err
+	}
+
+	// Documents first, so tasks' links to them can be remapped
+	imp := newImporter(api)
+	for _, doc := range bundle.Documents {
+		created := domain.Document{ProjectID: project.ID, Title: doc.Title, Content: doc.Content, Path: doc.Path, Tags: doc.Tags}
+		if err := api.post("/api/documents", &created, &created); err != nil {
+			return fmt.Errorf("importing document %q: %w", doc.Title, err)
+		}
+		imp.docIDs[doc.ID] = created.ID
+	}
+	for _, b := range bundle.Boards {
+		if b.Board == nil {
+			continue
+		}
+		board := domain.Board{
+			ProjectID:   project.ID,
+			Name:        b.Board.Name,
+			Description: b.Board.Description,
+			Columns:     b.Board.Columns,
+			WIPPolicy:   b.Board.WIPPolicy,
+		}
+		if err := api.post("/api/boards", &board, &board); err != nil {
+			return fmt.Errorf("importing board %q: %w", b.Board.Name, err)
+		}
+		if err := imp.addTasks(board.ID, b.Tasks); err != nil {
+			return err
+		}
+	}
+	if err := imp.linkTasks(); err != nil {
+		return err
+	}
+	return c.printProject(&project)
+}
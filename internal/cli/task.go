@@ -0,0 +1,317 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rand/cartographer/internal/domain"
+)
+
+var taskVerbs = map[string]verb{
+	"list":   taskList,
+	"show":   taskShow,
+	"create": taskCreate,
+	"update": taskUpdate,
+	"move":   taskMove,
+}
+
+func taskList(c *command, args []string) error {
+	fs := c.flags("")
+	board := fs.String("board", "", "ID of the board to list tasks of (required)")
+	query := map[string]*string{
+		"status":   fs.String("status", "", "only tasks in these columns, comma-separated"),
+		"priority": fs.String("priority", "", "only tasks with these priorities, comma-separated"),
+		"assignee": fs.String("assignee", "", "only tasks assigned to this ID or name"),
+		"label":    fs.String("label", "", "only tasks with this label"),
+		"q":        fs.String("q", "", "only tasks whose title or description contains this text"),
+	}
+	if _, err := c.parse(args, 0, 0); err != nil {
+		return err
+	}
+	if *board == "" {
+		return usagef("--board is required")
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+
+	params := url.Values{}
+	for name, value := range query {
+		if *value != "" {
+			params.Set(name, *value)
+		}
+	}
+	var tasks []*domain.Task
+	if err := api.get("/api/boards/"+url.PathEscape(*board)+"/tasks", params, &tasks); err != nil {
+		return err
+	}
+	return c.print(tasks, func(w io.Writer) {
+		row(w, "ID", "STATUS", "PRIORITY", "ASSIGNEE", "TITLE")
+		for _, t := range tasks {
+			row(w, t.ID, t.Status, t.Priority, assigneeName(t.Assignee), t.Title)
+		}
+	})
+}
+
+func assigneeName(a *domain.Assignee) string {
+	switch {
+	case a == nil:
+		return ""
+	case a.Name != "":
+		return a.Name
+	case a.Type == domain.AssigneeAgent:
+		return "agent:" + a.ID
+	}
+	return a.ID
+}
+
+func taskShow(c *command, args []string) error {
+	c.flags("<task-id>")
+	args, err := c.parse(args, 1, 1)
+	if err != nil {
+		return err
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+
+	var task domain.Task
+	if err := api.get("/api/tasks/"+url.PathEscape(args[0]), nil, &task); err != nil {
+		return err
+	}
+	return c.printTask(&task)
+}
+
+func (c *command) printTask(t *domain.Task) error {
+	return c.print(t, func(w io.Writer) {
+		var estimate, due string
+		if t.Estimate != nil {
+			estimate = strconv.FormatFloat(*t.Estimate, 'f', -1, 64) + "h"
+		}
+		if t.DueDate != nil {
+			due = timestamp(*t.DueDate)
+		}
+		fields(w,
+			field{"ID", t.ID},
+			field{"Board", t.BoardID},
+			field{"Title", t.Title},
+			field{"Status", t.Status},
+			field{"Priority", t.Priority},
+			field{"Assignee", assigneeName(t.Assignee)},
+			field{"Labels", strings.Join(t.Labels, ", ")},
+			field{"Estimate", estimate},
+			field{"Due", due},
+			field{"Depends on", strings.Join(t.Dependencies, ", ")},
+			field{"Blocks", strings.Join(t.Blocks, ", ")},
+			field{"Related", strings.Join(t.Related, ", ")},
+			field{"Created", timestamp(t.CreatedAt)},
+			field{"Updated", timestamp(t.UpdatedAt)},
+			field{"Revision", strconv.FormatInt(t.Revision, 10)},
+		)
+		if t.Description != "" {
+			fmt.Fprintf(w, "\n%s\n", t.Description)
+		}
+	})
+}
+
+// taskFlags holds the flags for a task's fields
+type taskFlags struct {
+	values      map[string]*string
+	labels      stringList
+	dependsOn   stringList
+	comment     *string
+	description *string
+}
+
+func newTaskFlags(c *command, usage string) *taskFlags {
+	fs := c.flags(usage)
+	f := &taskFlags{
+		values: map[string]*string{
+			"title":    fs.String("title", "", "task title"),
+			"status":   fs.String("status", "", "column the task is in"),
+			"priority": fs.String("priority", "", "priority: "+strings.Join(domain.Priorities, ", ")),
+			"assignee": fs.String("assignee", "", "assignee ID, prefixed with agent: for an agent; empty to unassign"),
+			"estimate": fs.String("estimate", "", "estimate in hours"),
+			"due":      fs.String("due", "", "due date, as YYYY-MM-DD or RFC 3339"),
+		},
+		description: fs.String("description", "", "task description, or @file to read it from a file"),
+		comment:     fs.String("comment", "", "comment to add to the task's activity"),
+	}
+	fs.Var(&f.labels, "label", "a label, repeated for each label")
+	fs.Var(&f.dependsOn, "depends-on", "ID of a task this one depends on, repeated for each")
+	return f
+}
+
+// patch returns a merge patch of the task fields given on the command line
+func (f *taskFlags) patch(c *command) (map[string]interface{}, error) {
+	set := c.set()
+	patch := make(map[string]interface{})
+	for _, name := range []string{"title", "status", "priority"} {
+		if set[name] {
+			patch[name] = *f.values[name]
+		}
+	}
+	if set["description"] {
+		description, err := c.text(*f.description)
+		if err != nil {
+			return nil, err
+		}
+		patch["description"] = description
+	}
+	if set["assignee"] {
+		patch["assignee"] = parseAssignee(*f.values["assignee"])
+	}
+	if set["estimate"] {
+		estimate, err := strconv.ParseFloat(*f.values["estimate"], 64)
+		if err != nil || estimate < 0 {
+			return nil, usagef("invalid --estimate %s", *f.values["estimate"])
+		}
+		patch["estimate"] = estimate
+	}
+	if set["due"] {
+		due, err := parseDate(*f.values["due"])
+		if err != nil {
+			return nil, err
+		}
+		patch["due_date"] = due
+	}
+	if set["label"] {
+		patch["labels"] = []string(f.labels)
+	}
+	if set["depends-on"] {
+		patch["dependencies"] = []string(f.dependsOn)
+	}
+	if set["comment"] {
+		patch["activity"] = []domain.ActivityEntry{{Type: domain.ActivityCommented, Comment: *f.comment}}
+	}
+	return patch, nil
+}
+
+// parseAssignee parses an --assignee value, returning nil for none
+func parseAssignee(value string) *domain.Assignee {
+	if value == "" {
+		return nil
+	}
+	if id, ok := strings.CutPrefix(value, domain.AssigneeAgent+":"); ok {
+		return &domain.Assignee{Type: domain.AssigneeAgent, ID: id}
+	}
+	id := strings.TrimPrefix(value, domain.AssigneeHuman+":")
+	return &domain.Assignee{Type: domain.AssigneeHuman, ID: id}
+}
+
+// parseDate parses a --due value, returning nil for none
+func parseDate(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	for _, layout := range []string{time.DateOnly, time.RFC3339} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, usagef("invalid date %s, use YYYY-MM-DD or RFC 3339", value)
+}
+
+// text returns a flag's text, read from a file if it starts with @
+func (c *command) text(value string) (string, error) {
+	path, ok := strings.CutPrefix(value, "@")
+	if !ok {
+		return value, nil
+	}
+	data, err := c.readInput(path)
+	return string(data), err
+}
+
+func taskCreate(c *command, args []string) error {
+	f := newTaskFlags(c, "")
+	board := c.fs.String("board", "", "ID of the board to add the task to (required)")
+	if _, err := c.parse(args, 0, 0); err != nil {
+		return err
+	}
+	if *board == "" {
+		return usagef("--board is required")
+	}
+	patch, err := f.patch(c)
+	if err != nil {
+		return err
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+
+	// Tasks start in the board's first column
+	if patch["status"] == nil {
+		var b domain.Board
+		if err := api.get("/api/boards/"+url.PathEscape(*board), nil, &b); err != nil {
+			return err
+		}
+		if len(b.Columns) > 0 {
+			patch["status"] = b.Columns[0].ID
+		}
+	}
+
+	var task domain.Task
+	if err := api.post("/api/boards/"+url.PathEscape(*board)+"/tasks", patch, &task); err != nil {
+		return err
+	}
+	return c.printTask(&task)
+}
+
+func taskUpdate(c *command, args []string) error {
+	f := newTaskFlags(c, "<task-id>")
+	args, err := c.parse(args, 1, 1)
+	if err != nil {
+		return err
+	}
+	patch, err := f.patch(c)
+	if err != nil {
+		return err
+	}
+	if len(patch) == 0 {
+		return usagef("nothing to update")
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+
+	var task domain.Task
+	if err := api.patch("/api/tasks/"+url.PathEscape(args[0]), patch, &task); err != nil {
+		return err
+	}
+	return c.printTask(&task)
+}
+
+func taskMove(c *command, args []string) error {
+	position := c.flags("<task-id> <column>").Int("position", -1, "position in the column, from 0 (default the end)")
+	args, err := c.parse(args, 2, 2)
+	if err != nil {
+		return err
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+
+	move := map[string]interface{}{"column": args[1]}
+	if *position >= 0 {
+		move["position"] = *position
+	}
+	var result domain.TaskMove
+	if err := api.patch("/api/tasks/"+url.PathEscape(args[0])+"/move", move, &result); err != nil {
+		return err
+	}
+	for _, warning := range result.Warnings {
+		fmt.Fprintf(c.stderr, "Warning: %s\n", warning)
+	}
+	return c.print(&result, func(w io.Writer) {
+		fmt.Fprintf(w, "Moved %s from %s to %s at position %d\n", result.Task.ID, result.From, result.To, result.Position)
+	})
+}
@@ -0,0 +1,141 @@
+package domain
+
+import (
+	"encoding/json"
+	"reflect"
+	"time"
+)
+
+// Activity entry types
+const (
+	ActivityCreated   = "created"
+	ActivityUpdated   = "updated"
+	ActivityCommented = "commented"
+	ActivityMoved     = "moved"
+)
+
+// Audited entity types
+const (
+	EntityProject  = "project"
+	EntityBoard    = "board"
+	EntityTask     = "task"
+	EntityDocument = "document"
+	EntityDiagram  = "diagram"
+)
+
+// AuditEntities lists the entity types recorded in the audit log
+var AuditEntities = []string{EntityProject, EntityBoard, EntityTask, EntityDocument, EntityDiagram}
+
+// Audit actions
+const (
+	AuditCreated = "created"
+	AuditUpdated = "updated"
+	AuditDeleted = "deleted"
+)
+
+// AuditEntry records one mutation of a project, board, task, document or
+// diagram
+type AuditEntry struct {
+	ID        int64     `json:"id"`
+	Entity    string    `json:"entity"`
+	EntityID  string    `json:"entity_id"`
+	ProjectID string    `json:"project_id,omitempty"`
+	Action    string    `json:"action"` // created, updated, deleted
+	User      *User     `json:"user,omitempty"`
+	Changes   Changes   `json:"changes,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// FieldChange is a field's value before and after a change
+type FieldChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// Changes maps JSON field names to how they changed
+type Changes map[string]FieldChange
+
+// Values returns each changed field's new value
+func (c Changes) Values() map[string]interface{} {
+	values := make(map[string]interface{}, len(c))
+	for name, change := range c {
+		values[name] = change.To
+	}
+	return values
+}
+
+// DiffFields compares the JSON encodings of two values field by field and returns
+// the fields that differ, skipping those named in ignore. A field missing
+// on one side is compared as null.
+func DiffFields(before, after interface{}, ignore ...string) Changes {
+	from := jsonFields(before)
+	to := jsonFields(after)
+	for _, name := range ignore {
+		delete(from, name)
+		delete(to, name)
+	}
+
+	changes := Changes{}
+	for name, value := range to {
+		if !reflect.DeepEqual(from[name], value) {
+			changes[name] = FieldChange{From: from[name], To: value}
+		}
+	}
+	for name, value := range from {
+		if _, ok := to[name]; !ok && value != nil {
+			changes[name] = FieldChange{From: value, To: nil}
+		}
+	}
+	return changes
+}
+
+// taskBookkeeping are task fields the server maintains, left out of diffs
+var taskBookkeeping = []string{"id", "board_id", "rank", "created_at", "updated_at", "revision", "created_by", "activity"}
+
+// DiffTasks returns the fields a user changed between two versions of a task
+func DiffTasks(before, after *Task) Changes {
+	return DiffFields(before, after, taskBookkeeping...)
+}
+
+// RecordTaskUpdate appends an activity entry to after describing how it
+// differs from before, attributed to user. A change to the status alone is
+// a move. It returns the changes, which are empty if nothing changed.
+func RecordTaskUpdate(before, after *Task, user string, at time.Time) Changes {
+	changes := DiffTasks(before, after)
+	if len(changes) == 0 {
+		return changes
+	}
+
+	entry := ActivityEntry{
+		Type:      ActivityUpdated,
+		User:      user,
+		Timestamp: at,
+		Changes:   make(map[string]interface{}, len(changes)),
+	}
+	for name, change := range changes {
+		entry.Changes[name] = change
+	}
+	if _, moved := changes["status"]; moved && len(changes) == 1 {
+		entry.Type = ActivityMoved
+	}
+
+	after.Activity = append(after.Activity, entry)
+	return changes
+}
+
+// jsonFields decodes a value's JSON encoding into a map of fields
+func jsonFields(v interface{}) map[string]interface{} {
+	fields := make(map[string]interface{})
+	if v == nil {
+		return fields
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return fields
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fields
+	}
+	json.Unmarshal(data, &fields)
+	return fields
+}
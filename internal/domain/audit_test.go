@@ -0,0 +1,60 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDiffTasks(t *testing.T) {
+	estimate := 2.0
+	before := &Task{ID: "t1", Title: "Write docs", Status: "todo", Priority: "low", Labels: []string{"docs"}}
+	after := &Task{ID: "t1", Title: "Write docs", Status: "todo", Priority: "high", Estimate: &estimate,
+		UpdatedAt: time.Now(), Activity: []ActivityEntry{{Type: ActivityCommented}}}
+
+	changes := DiffTasks(before, after)
+	if len(changes) != 3 {
+		t.Fatalf("Expected 3 changes, got %+v", changes)
+	}
+	if c := changes["priority"]; c.From != "low" || c.To != "high" {
+		t.Errorf("Unexpected priority change %+v", c)
+	}
+	if c := changes["estimate"]; c.From != nil || c.To != 2.0 {
+		t.Errorf("Unexpected estimate change %+v", c)
+	}
+	if c, ok := changes["labels"]; !ok || c.To != nil {
+		t.Errorf("Expected labels removed, got %+v", c)
+	}
+
+	if values := changes.Values(); values["priority"] != "high" {
+		t.Errorf("Expected new values, got %+v", values)
+	}
+}
+
+func TestRecordTaskUpdate(t *testing.T) {
+	now := time.Now()
+	before := &Task{Title: "Ship it", Status: "todo"}
+
+	unchanged := *before
+	if changes := RecordTaskUpdate(before, &unchanged, "alice", now); len(changes) != 0 || len(unchanged.Activity) != 0 {
+		t.Errorf("Expected no activity without changes, got %+v", unchanged.Activity)
+	}
+
+	moved := *before
+	moved.Status = "done"
+	RecordTaskUpdate(before, &moved, "alice", now)
+	if len(moved.Activity) != 1 || moved.Activity[0].Type != ActivityMoved || moved.Activity[0].User != "alice" {
+		t.Fatalf("Expected a move by alice, got %+v", moved.Activity)
+	}
+
+	edited := *before
+	edited.Status = "done"
+	edited.Title = "Shipped"
+	RecordTaskUpdate(before, &edited, "bob", now)
+	entry := edited.Activity[0]
+	if entry.Type != ActivityUpdated || !entry.Timestamp.Equal(now) || len(entry.Changes) != 2 {
+		t.Errorf("Expected an update with 2 changes, got %+v", entry)
+	}
+	if c, ok := entry.Changes["title"].(FieldChange); !ok || c.From != "Ship it" || c.To != "Shipped" {
+		t.Errorf("Unexpected title change %+v", entry.Changes["title"])
+	}
+}
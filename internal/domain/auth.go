@@ -0,0 +1,74 @@
+package domain
+
+import "time"
+
+// API token scopes, from least to most privileged. Each scope grants
+// everything the ones before it do.
+const (
+	ScopeRead  = "read"  // read anything the token can see
+	ScopeWrite = "write" // create, update and delete
+	ScopeAdmin = "admin" // manage tokens
+)
+
+// Scopes lists the valid token scopes in order of privilege
+var Scopes = []string{ScopeRead, ScopeWrite, ScopeAdmin}
+
+// APIToken grants a human or agent scoped access to the API. Only a hash of
+// the secret is stored; the secret itself is shown once when minted.
+type APIToken struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	User       User       `json:"user"`                  // identity requests are attributed to
+	Scope      string     `json:"scope"`                 // read, write, admin
+	ProjectIDs []string   `json:"project_ids,omitempty"` // empty for every project
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Allows reports whether the token's scope includes scope
+func (t *APIToken) Allows(scope string) bool {
+	return scopeRank(t.Scope) >= scopeRank(scope) && scopeRank(scope) > 0
+}
+
+// AllowsProject reports whether the token may access a project
+func (t *APIToken) AllowsProject(projectID string) bool {
+	if len(t.ProjectIDs) == 0 {
+		return true
+	}
+	for _, id := range t.ProjectIDs {
+		if id == projectID {
+			return true
+		}
+	}
+	return false
+}
+
+// Restricted reports whether the token is limited to some projects
+func (t *APIToken) Restricted() bool {
+	return len(t.ProjectIDs) > 0
+}
+
+// Active reports whether the token is neither revoked nor expired at now
+func (t *APIToken) Active(now time.Time) bool {
+	if t.RevokedAt != nil {
+		return false
+	}
+	return t.ExpiresAt == nil || now.Before(*t.ExpiresAt)
+}
+
+// ValidScope reports whether scope is a known token scope
+func ValidScope(scope string) bool {
+	return scopeRank(scope) > 0
+}
+
+// scopeRank orders scopes by privilege, with 0 for unknown scopes
+func scopeRank(scope string) int {
+	for i, s := range Scopes {
+		if s == scope {
+			return i + 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,21 @@
+package domain
+
+// CurrentVersion returns the version number of the diagram's current content.
+// Versions holds earlier snapshots numbered from 1, so the live content is
+// always one past the last recorded version.
+func (d *Diagram) CurrentVersion() int {
+	return len(d.Versions) + 1
+}
+
+// ContentAt returns the diagram content as of the given version
+func (d *Diagram) ContentAt(version int) (string, bool) {
+	if version == d.CurrentVersion() {
+		return d.Content, true
+	}
+	for _, v := range d.Versions {
+		if v.Version == version {
+			return v.Content, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,82 @@
+package domain
+
+import "strings"
+
+// DiffOp is the kind of change a diff line represents
+type DiffOp string
+
+const (
+	DiffEqual  DiffOp = "equal"
+	DiffInsert DiffOp = "insert"
+	DiffDelete DiffOp = "delete"
+)
+
+// DiffLine is a single line in a line-based diff
+type DiffLine struct {
+	Op      DiffOp `json:"op"`
+	Text    string `json:"text"`
+	OldLine int    `json:"old_line,omitempty"` // 1-based line in the old text
+	NewLine int    `json:"new_line,omitempty"` // 1-based line in the new text
+}
+
+// Diff is the result of comparing two texts line by line
+type Diff struct {
+	Lines     []DiffLine `json:"lines"`
+	Additions int        `json:"additions"`
+	Deletions int        `json:"deletions"`
+}
+
+// DiffLines computes a line-based diff from oldText to newText using the
+// longest common subsequence. It is quadratic in the number of lines, which
+// is fine for diagrams and documents but not for arbitrarily large files.
+func DiffLines(oldText, newText string) *Diff {
+	a := splitLines(oldText)
+	b := splitLines(newText)
+
+	// lcs[i][j] is the LCS length of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	diff := &Diff{Lines: []DiffLine{}}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			diff.Lines = append(diff.Lines, DiffLine{Op: DiffEqual, Text: a[i], OldLine: i + 1, NewLine: j + 1})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			// Prefer deletions before insertions, as unified diffs do
+			diff.Lines = append(diff.Lines, DiffLine{Op: DiffDelete, Text: a[i], OldLine: i + 1})
+			diff.Deletions++
+			i++
+		default:
+			diff.Lines = append(diff.Lines, DiffLine{Op: DiffInsert, Text: b[j], NewLine: j + 1})
+			diff.Additions++
+			j++
+		}
+	}
+
+	return diff
+}
+
+// splitLines splits text into lines, treating an empty string as no lines
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
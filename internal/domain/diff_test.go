@@ -0,0 +1,46 @@
+package domain
+
+import "testing"
+
+func TestDiffLines(t *testing.T) {
+	oldText := "graph TD\n  A --> B\n  B --> C\n"
+	newText := "graph TD\n  A --> B\n  B --> D\n  D --> C\n"
+
+	diff := DiffLines(oldText, newText)
+
+	if diff.Additions != 2 || diff.Deletions != 1 {
+		t.Fatalf("Expected +2 -1, got +%d -%d", diff.Additions, diff.Deletions)
+	}
+
+	want := []DiffOp{DiffEqual, DiffEqual, DiffDelete, DiffInsert, DiffInsert}
+	if len(diff.Lines) != len(want) {
+		t.Fatalf("Expected %d lines, got %d: %+v", len(want), len(diff.Lines), diff.Lines)
+	}
+	for i, op := range want {
+		if diff.Lines[i].Op != op {
+			t.Errorf("Line %d: expected %s, got %s (%q)", i, op, diff.Lines[i].Op, diff.Lines[i].Text)
+		}
+	}
+}
+
+func TestDiagramContentAt(t *testing.T) {
+	diagram := &Diagram{
+		Content: "v3",
+		Versions: []DiagramVersion{
+			{Version: 1, Content: "v1"},
+			{Version: 2, Content: "v2"},
+		},
+	}
+
+	if diagram.CurrentVersion() != 3 {
+		t.Errorf("Expected current version 3, got %d", diagram.CurrentVersion())
+	}
+	for version, want := range map[int]string{1: "v1", 2: "v2", 3: "v3"} {
+		if got, ok := diagram.ContentAt(version); !ok || got != want {
+			t.Errorf("ContentAt(%d) = %q, %v; want %q", version, got, ok, want)
+		}
+	}
+	if _, ok := diagram.ContentAt(4); ok {
+		t.Error("Expected version 4 to be missing")
+	}
+}
@@ -0,0 +1,13 @@
+package domain
+
+import "time"
+
+// Event is a broadcast real-time event recorded so that clients can
+// replay what they missed while disconnected
+type Event struct {
+	Seq       uint64    `json:"seq"`
+	Type      string    `json:"type"`
+	Resources []string  `json:"resources,omitempty"` // routing resources, e.g. "project:123"
+	Data      []byte    `json:"data"`                // the encoded message as sent to clients
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -11,6 +11,7 @@ type Project struct {
 	Type        string            `json:"type"` // web-app, api, library, custom
 	CreatedAt   time.Time         `json:"created_at"`
 	UpdatedAt   time.Time         `json:"updated_at"`
+	Revision    int64             `json:"revision"` // bumped on every write
 	Settings    *ProjectSettings  `json:"settings,omitempty"`
 	Metadata    *ProjectMetadata  `json:"metadata,omitempty"`
 }
@@ -35,8 +36,10 @@ type Board struct {
 	Name        string        `json:"name"`
 	Description string        `json:"description"`
 	Columns     []BoardColumn `json:"columns"`
+	WIPPolicy   string        `json:"wip_policy,omitempty"` // warn, enforce
 	CreatedAt   time.Time     `json:"created_at"`
 	UpdatedAt   time.Time     `json:"updated_at"`
+	Revision    int64         `json:"revision"`
 }
 
 // BoardColumn represents a column in a kanban board
@@ -65,8 +68,10 @@ type Task struct {
 	Related     []string       `json:"related,omitempty"`
 	LinkedItems []LinkedItem   `json:"linked_items,omitempty"`
 	Checklist   []ChecklistItem `json:"checklist,omitempty"`
+	Rank        float64        `json:"rank"` // position in its column, lowest first
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
+	Revision    int64          `json:"revision"`
 	CreatedBy   *User          `json:"created_by,omitempty"`
 	Activity    []ActivityEntry `json:"activity,omitempty"`
 }
@@ -119,6 +124,7 @@ type Document struct {
 	LinksTo    []string          `json:"links_to,omitempty"`
 	CreatedAt  time.Time         `json:"created_at"`
 	UpdatedAt  time.Time         `json:"updated_at"`
+	Revision   int64             `json:"revision"`
 	Versions   []DocumentVersion `json:"versions,omitempty"`
 }
 
@@ -139,6 +145,7 @@ type Diagram struct {
 	Content   string           `json:"content"`
 	CreatedAt time.Time        `json:"created_at"`
 	UpdatedAt time.Time        `json:"updated_at"`
+	Revision  int64            `json:"revision"`
 	Versions  []DiagramVersion `json:"versions,omitempty"`
 }
 
@@ -0,0 +1,69 @@
+package domain
+
+import "fmt"
+
+// Board WIP limit policies, deciding what happens when a task would take a
+// column past its WIP limit
+const (
+	WIPPolicyWarn    = "warn"    // allow the move but warn about it (the default)
+	WIPPolicyEnforce = "enforce" // reject the move
+)
+
+// ValidWIPPolicy reports whether p is a WIP limit policy, "" meaning the
+// default
+func ValidWIPPolicy(p string) bool {
+	return p == "" || p == WIPPolicyWarn || p == WIPPolicyEnforce
+}
+
+// EnforcesWIPLimits reports whether the board rejects moves past a WIP limit
+func (b *Board) EnforcesWIPLimits() bool {
+	return b.WIPPolicy == WIPPolicyEnforce
+}
+
+// Column returns the board's column with the given ID, or nil if it has none
+func (b *Board) Column(id string) *BoardColumn {
+	for i := range b.Columns {
+		if b.Columns[i].ID == id {
+			return &b.Columns[i]
+		}
+	}
+	return nil
+}
+
+// OverWIPLimit reports whether n tasks is more than the column's WIP limit
+func (c *BoardColumn) OverWIPLimit(n int) bool {
+	return c.WIPLimit > 0 && n > c.WIPLimit
+}
+
+// WIPWarning describes a column holding n tasks, over its WIP limit
+func (c *BoardColumn) WIPWarning(n int) string {
+	return fmt.Sprintf("Column %s has %d tasks, over its WIP limit of %d", c.Name, n, c.WIPLimit)
+}
+
+// TaskMove describes a task moved to a position in a column
+type TaskMove struct {
+	Task     *Task    `json:"task"`
+	From     string   `json:"from"`     // column the task left
+	To       string   `json:"to"`       // column the task is now in
+	Position int      `json:"position"` // index in the column, 0 at the top
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// RankAt returns the rank for an item inserted at position among items with
+// the given ranks, in ascending order. Ranks are fractional, so an insertion
+// only ranks the inserted item. ok is false if there is no room left between
+// its neighbours, and the items need reranking first.
+func RankAt(ranks []float64, position int) (rank float64, ok bool) {
+	switch {
+	case len(ranks) == 0:
+		return 1, true
+	case position <= 0:
+		return ranks[0] - 1, true
+	case position >= len(ranks):
+		return ranks[len(ranks)-1] + 1, true
+	}
+
+	before, after := ranks[position-1], ranks[position]
+	rank = before + (after-before)/2
+	return rank, rank > before && rank < after
+}
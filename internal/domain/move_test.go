@@ -0,0 +1,32 @@
+package domain
+
+import "testing"
+
+func TestRankAt(t *testing.T) {
+	ranks := []float64{1, 2, 4}
+
+	tests := []struct {
+		position int
+		want     float64
+	}{
+		{0, 0},
+		{-1, 0},
+		{1, 1.5},
+		{2, 3},
+		{3, 5},
+		{9, 5},
+	}
+	for _, tt := range tests {
+		got, ok := RankAt(ranks, tt.position)
+		if !ok || got != tt.want {
+			t.Errorf("RankAt(%v, %d) = %v, %v; want %v, true", ranks, tt.position, got, ok, tt.want)
+		}
+	}
+
+	if got, ok := RankAt(nil, 3); !ok || got != 1 {
+		t.Errorf("RankAt on an empty column = %v, %v; want 1, true", got, ok)
+	}
+	if _, ok := RankAt([]float64{1, 1}, 1); ok {
+		t.Error("Expected no room between equal ranks")
+	}
+}
@@ -0,0 +1,84 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+)
+
+// ErrPatchNotObject is returned for merge patches that aren't JSON objects,
+// which would replace a resource wholesale rather than patch it
+var ErrPatchNotObject = errors.New("merge patch must be a JSON object")
+
+// MergePatch applies an RFC 7396 JSON merge patch to a JSON document:
+// object members in the patch replace those in the document, null members
+// remove them, and nested objects are merged recursively.
+func MergePatch(doc, patch []byte) ([]byte, error) {
+	var target, changes interface{}
+	if len(doc) > 0 {
+		if err := json.Unmarshal(doc, &target); err != nil {
+			return nil, err
+		}
+	}
+	if err := json.Unmarshal(patch, &changes); err != nil {
+		return nil, err
+	}
+	return json.Marshal(mergeValue(target, changes))
+}
+
+// mergeValue implements the MergePatch algorithm from RFC 7396, section 2
+func mergeValue(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = make(map[string]interface{})
+	}
+	for name, value := range patchObject {
+		if value == nil {
+			delete(targetObject, name)
+		} else {
+			targetObject[name] = mergeValue(targetObject[name], value)
+		}
+	}
+	return targetObject
+}
+
+// ApplyMergePatch applies a merge patch object to the value target points
+// to, round-tripping it through JSON. Fields the patch removes are reset to
+// their zero value.
+func ApplyMergePatch(target interface{}, patch []byte) error {
+	if _, err := PatchFields(patch); err != nil {
+		return err
+	}
+
+	doc, err := json.Marshal(target)
+	if err != nil {
+		return err
+	}
+	merged, err := MergePatch(doc, patch)
+	if err != nil {
+		return err
+	}
+
+	value := reflect.ValueOf(target).Elem()
+	value.Set(reflect.Zero(value.Type()))
+	return json.Unmarshal(merged, target)
+}
+
+// PatchFields returns the top-level fields a merge patch sets or removes
+func PatchFields(patch []byte) ([]string, error) {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &members); err != nil || members == nil {
+		return nil, ErrPatchNotObject
+	}
+
+	fields := make([]string, 0, len(members))
+	for name := range members {
+		fields = append(fields, name)
+	}
+	return fields, nil
+}
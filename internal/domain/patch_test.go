@@ -0,0 +1,61 @@
+package domain
+
+import (
+	"testing"
+)
+
+func TestMergePatch(t *testing.T) {
+	// Examples from RFC 7396, appendix A
+	tests := []struct {
+		doc, patch, want string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+
+	for _, tt := range tests {
+		got, err := MergePatch([]byte(tt.doc), []byte(tt.patch))
+		if err != nil {
+			t.Errorf("MergePatch(%s, %s) failed: %v", tt.doc, tt.patch, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("MergePatch(%s, %s) = %s, want %s", tt.doc, tt.patch, got, tt.want)
+		}
+	}
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	estimate := 3.0
+	task := &Task{Title: "Draft", Status: "todo", Labels: []string{"docs"}, Estimate: &estimate,
+		Assignee: &Assignee{Type: "human", ID: "alice", Name: "Alice"}}
+
+	patch := `{"status":"done","labels":null,"assignee":{"name":"Alice B."}}`
+	if err := ApplyMergePatch(task, []byte(patch)); err != nil {
+		t.Fatalf("ApplyMergePatch failed: %v", err)
+	}
+	if task.Title != "Draft" || task.Status != "done" || task.Labels != nil || *task.Estimate != 3 {
+		t.Errorf("Unexpected task %+v", task)
+	}
+	if task.Assignee.ID != "alice" || task.Assignee.Name != "Alice B." {
+		t.Errorf("Expected the assignee merged, got %+v", task.Assignee)
+	}
+
+	for _, patch := range []string{`["status"]`, `null`, `"done"`, `{`} {
+		if err := ApplyMergePatch(task, []byte(patch)); err == nil {
+			t.Errorf("Expected %s to be rejected", patch)
+		}
+	}
+}
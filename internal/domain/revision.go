@@ -0,0 +1,16 @@
+package domain
+
+// CurrentRevision returns the project's revision
+func (p *Project) CurrentRevision() int64 { return p.Revision }
+
+// CurrentRevision returns the board's revision
+func (b *Board) CurrentRevision() int64 { return b.Revision }
+
+// CurrentRevision returns the task's revision
+func (t *Task) CurrentRevision() int64 { return t.Revision }
+
+// CurrentRevision returns the document's revision
+func (d *Document) CurrentRevision() int64 { return d.Revision }
+
+// CurrentRevision returns the diagram's revision
+func (d *Diagram) CurrentRevision() int64 { return d.Revision }
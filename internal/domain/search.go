@@ -0,0 +1,148 @@
+package domain
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Searchable entity types
+const (
+	SearchTypeTask     = "task"
+	SearchTypeDocument = "document"
+	SearchTypeDiagram  = "diagram"
+	SearchTypeBead     = "bead"
+)
+
+// SearchTypes lists every entity type that can be searched
+var SearchTypes = []string{SearchTypeTask, SearchTypeDocument, SearchTypeDiagram, SearchTypeBead}
+
+// SearchHit is a single ranked search result
+type SearchHit struct {
+	Type      string  `json:"type"` // task, document, diagram, bead
+	ID        string  `json:"id"`
+	ProjectID string  `json:"project_id,omitempty"`
+	BoardID   string  `json:"board_id,omitempty"`
+	Title     string  `json:"title"`
+	Snippet   string  `json:"snippet"` // matches wrapped in <mark></mark>; not HTML-escaped
+	Score     float64 `json:"score"`   // higher is more relevant; see MergeSearchHits
+}
+
+const (
+	highlightStart = "<mark>"
+	highlightEnd   = "</mark>"
+)
+
+// MergeSearchHits merges the hits of searches scored on different scales,
+// like bm25 and term counts, best first. Each source's scores are divided by
+// its best one, so every source's top hit scores 1.
+func MergeSearchHits(limit int, sources ...[]SearchHit) []SearchHit {
+	hits := []SearchHit{}
+	for _, source := range sources {
+		best := 0.0
+		for _, hit := range source {
+			if hit.Score > best {
+				best = hit.Score
+			}
+		}
+		for _, hit := range source {
+			if best > 0 {
+				hit.Score /= best
+			}
+			hits = append(hits, hit)
+		}
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].Score > hits[j].Score })
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
+
+// SearchTerms splits a free-text query into lowercase terms
+func SearchTerms(query string) []string {
+	return strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// CountMatches returns how many times any of the terms occurs in text
+func CountMatches(text string, terms []string) int {
+	lower := strings.ToLower(text)
+	count := 0
+	for _, term := range terms {
+		count += strings.Count(lower, term)
+	}
+	return count
+}
+
+// HighlightSnippet returns a window of roughly width runes around the first
+// match of any term, with every match wrapped in <mark></mark>. If nothing
+// matches, the start of the text is returned.
+func HighlightSnippet(text string, terms []string, width int) string {
+	runes := []rune(text)
+	lower := []rune(strings.ToLower(text))
+	if len(lower) != len(runes) {
+		// Lowercasing changed the length (rare non-ASCII case); give up on
+		// alignment rather than highlighting the wrong characters
+		lower = runes
+	}
+
+	first := -1
+	for _, term := range terms {
+		if i := indexRunes(lower, []rune(term), 0); i >= 0 && (first < 0 || i < first) {
+			first = i
+		}
+	}
+
+	start := 0
+	if first > width/3 {
+		start = first - width/3
+	}
+	end := start + width
+	if end > len(runes) {
+		end = len(runes)
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	for i := start; i < end; {
+		matched := 0
+		for _, term := range terms {
+			t := []rune(term)
+			if len(t) > matched && i+len(t) <= len(lower) && string(lower[i:i+len(t)]) == term {
+				matched = len(t)
+			}
+		}
+		if matched > 0 {
+			b.WriteString(highlightStart)
+			b.WriteString(string(runes[i : i+matched]))
+			b.WriteString(highlightEnd)
+			i += matched
+			continue
+		}
+		b.WriteRune(runes[i])
+		i++
+	}
+	if end < len(runes) {
+		b.WriteString("…")
+	}
+
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// indexRunes returns the index of needle in haystack at or after from, or -1
+func indexRunes(haystack, needle []rune, from int) int {
+	if len(needle) == 0 {
+		return -1
+	}
+	for i := from; i+len(needle) <= len(haystack); i++ {
+		if string(haystack[i:i+len(needle)]) == string(needle) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,20 @@
+package domain
+
+import "testing"
+
+func TestMergeSearchHits(t *testing.T) {
+	bm25 := []SearchHit{{ID: "task", Score: 4.2}, {ID: "doc", Score: 2.1}}
+	counts := []SearchHit{{ID: "bead", Score: 30}, {ID: "bead2", Score: 3}}
+
+	hits := MergeSearchHits(3, bm25, counts, nil)
+	if len(hits) != 3 {
+		t.Fatalf("Expected 3 hits, got %+v", hits)
+	}
+	// Each source's best hit scores 1; the doc at half its best ranks last
+	if hits[0].Score != 1 || hits[1].Score != 1 || hits[2].ID != "doc" || hits[2].Score != 0.5 {
+		t.Errorf("Expected scores relative to each source's best, got %+v", hits)
+	}
+	if bm25[0].Score != 4.2 {
+		t.Errorf("Expected the sources left alone, got %+v", bm25)
+	}
+}
@@ -0,0 +1,58 @@
+package domain
+
+import "time"
+
+// Session event types
+const (
+	SessionToolCall = "tool_call" // the agent ran a tool
+	SessionFile     = "file"      // the agent read or changed files
+	SessionNote     = "note"      // a note, bookmark or decision
+)
+
+// SessionEventTypes lists the valid session event types
+var SessionEventTypes = []string{SessionToolCall, SessionFile, SessionNote}
+
+// SessionTagTask tags a session event that should become a task, like a
+// TODO the agent left for later
+const SessionTagTask = "task"
+
+// LinkSession is the linked item type of a task's link to a session that
+// worked on it
+const LinkSession = "session"
+
+// Session is an agent's working session on a project, captured as it goes
+// so the next session can pick up where it left off
+type Session struct {
+	ID         string    `json:"id"`
+	ProjectID  string    `json:"project_id"`
+	BoardID    string    `json:"board_id,omitempty"` // where tasks from tagged events go
+	Agent      User      `json:"agent"`
+	Title      string    `json:"title,omitempty"`
+	PreviousID string    `json:"previous_session_id,omitempty"` // the session this one resumes
+	StartedAt  time.Time `json:"started_at"`
+	UpdatedAt  time.Time `json:"updated_at"` // when the last event was recorded
+	EventCount int       `json:"event_count"`
+}
+
+// SessionEvent is one thing that happened in a session, numbered in order
+type SessionEvent struct {
+	SessionID string       `json:"session_id"`
+	Seq       int          `json:"seq"` // from 1
+	Type      string       `json:"type"`
+	Tool      string       `json:"tool,omitempty"` // for a tool call
+	Text      string       `json:"text,omitempty"`
+	Files     []string     `json:"files,omitempty"` // paths the event touched
+	Tags      []string     `json:"tags,omitempty"`
+	Links     []LinkedItem `json:"links,omitempty"` // tasks and other items the event concerns
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+// HasTag reports whether the event is tagged with tag
+func (e *SessionEvent) HasTag(tag string) bool {
+	for _, t := range e.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+package domain
+
+import "time"
+
+// BeadFields are the bead fields kept in sync with a task, in beads terms
+type BeadFields struct {
+	Title              string   `json:"title"`
+	Description        string   `json:"description"`
+	Design             string   `json:"design"`
+	AcceptanceCriteria string   `json:"acceptance_criteria"`
+	Notes              string   `json:"notes"`
+	Status             string   `json:"status"`   // open, in_progress, blocked, closed
+	Priority           int      `json:"priority"` // 0-4
+	Assignee           string   `json:"assignee"`
+	Labels             []string `json:"labels"`
+	EstimatedMinutes   *int     `json:"estimated_minutes"`
+}
+
+// BeadSyncState links a bead to the task it was imported as. Base holds the
+// field values both sides agreed on at the last sync, so each side's edits
+// can be told apart from the other's.
+type BeadSyncState struct {
+	BeadID   string            `json:"bead_id"`
+	TaskID   string            `json:"task_id"`
+	Base     BeadFields        `json:"base"`
+	SyncedAt time.Time         `json:"synced_at"`
+	Conflict *BeadSyncConflict `json:"conflict,omitempty"`
+}
+
+// BeadSyncConflict records fields changed differently on both sides since
+// the last sync. Conflicting fields are left alone until resolved.
+type BeadSyncConflict struct {
+	Fields     []string   `json:"fields"`
+	Bead       BeadFields `json:"bead"`
+	Task       BeadFields `json:"task"`
+	DetectedAt time.Time  `json:"detected_at"`
+}
@@ -0,0 +1,223 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Task priorities, from least to most pressing
+const (
+	PriorityLow    = "low"
+	PriorityMedium = "medium"
+	PriorityHigh   = "high"
+	PriorityUrgent = "urgent"
+)
+
+// Priorities lists the valid task priorities in increasing order
+var Priorities = []string{PriorityLow, PriorityMedium, PriorityHigh, PriorityUrgent}
+
+// ValidPriority reports whether p is a task priority, "" meaning unset
+func ValidPriority(p string) bool {
+	if p == "" {
+		return true
+	}
+	for _, priority := range Priorities {
+		if p == priority {
+			return true
+		}
+	}
+	return false
+}
+
+// PriorityRank orders task priorities from 0 for low, counting unset ones
+// as medium
+func PriorityRank(priority string) int {
+	if priority == "" {
+		priority = PriorityMedium
+	}
+	for i, p := range Priorities {
+		if p == priority {
+			return i
+		}
+	}
+	return 0
+}
+
+// Assignee types
+const (
+	AssigneeHuman = "human"
+	AssigneeAgent = "agent"
+)
+
+// FieldError describes why a field of a resource is invalid. Field is the
+// JSON name, with an index or nested name for elements ("columns[1].id").
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists the invalid fields of a resource
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		messages[i] = f.Field + ": " + f.Message
+	}
+	return "invalid " + strings.Join(messages, "; ")
+}
+
+// Add records an invalid field
+func (e *ValidationError) Add(field, format string, args ...interface{}) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// Err returns e if any field is invalid, and nil otherwise
+func (e *ValidationError) Err() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+// required records field as missing if value is blank
+func (e *ValidationError) required(field, value string) {
+	if strings.TrimSpace(value) == "" {
+		e.Add(field, "is required")
+	}
+}
+
+// Validate checks the fields of a project
+func (p *Project) Validate() error {
+	v := &ValidationError{}
+	v.required("name", p.Name)
+	return v.Err()
+}
+
+// Validate checks the fields of a board and its columns
+func (b *Board) Validate() error {
+	v := &ValidationError{}
+	v.required("project_id", b.ProjectID)
+	v.required("name", b.Name)
+	if !ValidWIPPolicy(b.WIPPolicy) {
+		v.Add("wip_policy", "must be %s or %s", WIPPolicyWarn, WIPPolicyEnforce)
+	}
+
+	seen := make(map[string]bool)
+	for i, column := range b.Columns {
+		field := fmt.Sprintf("columns[%d]", i)
+		v.required(field+".id", column.ID)
+		v.required(field+".name", column.Name)
+		if column.ID != "" && seen[column.ID] {
+			v.Add(field+".id", "duplicates column %s", column.ID)
+		}
+		seen[column.ID] = true
+		if column.WIPLimit < 0 {
+			v.Add(field+".wip_limit", "must not be negative")
+		}
+	}
+	return v.Err()
+}
+
+// Validate checks the fields of a task on board. A task's status is the
+// column it is in, so it must be one of the board's columns if the board
+// has any. References to other tasks are checked by the caller, which can
+// tell whether they exist.
+func (t *Task) Validate(board *Board) error {
+	v := &ValidationError{}
+	v.required("board_id", t.BoardID)
+	v.required("title", t.Title)
+	if board != nil && len(board.Columns) > 0 && board.Column(t.Status) == nil {
+		ids := make([]string, len(board.Columns))
+		for i, column := range board.Columns {
+			ids[i] = column.ID
+		}
+		v.Add("status", "must be a column of the board (%s)", strings.Join(ids, ", "))
+	}
+	if !ValidPriority(t.Priority) {
+		v.Add("priority", "must be one of %s", strings.Join(Priorities, ", "))
+	}
+	if t.Assignee != nil && t.Assignee.Type != "" && t.Assignee.Type != AssigneeHuman && t.Assignee.Type != AssigneeAgent {
+		v.Add("assignee.type", "must be %s or %s", AssigneeHuman, AssigneeAgent)
+	}
+	if t.Estimate != nil && *t.Estimate < 0 {
+		v.Add("estimate", "must not be negative")
+	}
+	if t.Actual != nil && *t.Actual < 0 {
+		v.Add("actual", "must not be negative")
+	}
+	t.EachReference(func(field, id string) {
+		if t.ID != "" && id == t.ID {
+			v.Add(field, "a task can't refer to itself")
+		}
+	})
+	return v.Err()
+}
+
+// EachReference calls fn with each task ID the task depends on, blocks or
+// is related to, and the field naming it ("dependencies[0]")
+func (t *Task) EachReference(fn func(field, id string)) {
+	for _, refs := range []struct {
+		name string
+		ids  []string
+	}{{"dependencies", t.Dependencies}, {"blocks", t.Blocks}, {"related", t.Related}} {
+		for i, id := range refs.ids {
+			fn(fmt.Sprintf("%s[%d]", refs.name, i), id)
+		}
+	}
+}
+
+// Validate checks the fields of a document
+func (d *Document) Validate() error {
+	v := &ValidationError{}
+	v.required("project_id", d.ProjectID)
+	v.required("title", d.Title)
+	return v.Err()
+}
+
+// Validate checks the fields of a diagram
+func (d *Diagram) Validate() error {
+	v := &ValidationError{}
+	v.required("project_id", d.ProjectID)
+	v.required("name", d.Name)
+	v.required("type", d.Type)
+	v.required("content", d.Content)
+	return v.Err()
+}
+
+// Validate checks the fields of a session
+func (s *Session) Validate() error {
+	v := &ValidationError{}
+	v.required("project_id", s.ProjectID)
+	v.required("agent.id", s.Agent.ID)
+	return v.Err()
+}
+
+// Validate checks the fields of a session event. Tagging an event as a task
+// makes its text the task's title, so it needs some.
+func (e *SessionEvent) Validate() error {
+	v := &ValidationError{}
+	valid := false
+	for _, t := range SessionEventTypes {
+		valid = valid || e.Type == t
+	}
+	if !valid {
+		v.Add("type", "must be one of %s", strings.Join(SessionEventTypes, ", "))
+	}
+	if e.Type == SessionToolCall {
+		v.required("tool", e.Tool)
+	}
+	if e.HasTag(SessionTagTask) {
+		v.required("text", e.Text)
+	}
+	for i, link := range e.Links {
+		field := fmt.Sprintf("links[%d]", i)
+		v.required(field+".type", link.Type)
+		if link.ID == "" && link.Path == "" {
+			v.Add(field, "needs an id or a path")
+		}
+	}
+	return v.Err()
+}
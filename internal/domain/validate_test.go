@@ -0,0 +1,46 @@
+package domain
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestTaskValidate(t *testing.T) {
+	board := &Board{Columns: []BoardColumn{{ID: "todo", Name: "To Do"}, {ID: "done", Name: "Done"}}}
+	negative := -1.0
+
+	tests := []struct {
+		name string
+		task Task
+		want []string
+	}{
+		{"valid", Task{ID: "t-1", BoardID: "b-1", Title: "Ship", Status: "todo", Priority: PriorityHigh, Dependencies: []string{"t-2"}}, nil},
+		{"no priority", Task{BoardID: "b-1", Title: "Ship", Status: "done"}, nil},
+		{"missing fields", Task{Status: "todo"}, []string{"board_id", "title"}},
+		{"unknown column", Task{BoardID: "b-1", Title: "Ship", Status: "doing"}, []string{"status"}},
+		{"bad values", Task{BoardID: "b-1", Title: "Ship", Status: "todo", Priority: "asap", Assignee: &Assignee{Type: "robot"}, Estimate: &negative}, []string{"priority", "assignee.type", "estimate"}},
+		{"self reference", Task{ID: "t-1", BoardID: "b-1", Title: "Ship", Status: "todo", Dependencies: []string{"t-2"}, Blocks: []string{"t-1"}}, []string{"blocks[0]"}},
+	}
+	for _, tt := range tests {
+		err := tt.task.Validate(board)
+		var fields []string
+		var verr *ValidationError
+		if errors.As(err, &verr) {
+			for _, f := range verr.Fields {
+				fields = append(fields, f.Field)
+			}
+		} else if err != nil {
+			t.Fatalf("%s: unexpected error %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(fields, tt.want) {
+			t.Errorf("%s: expected invalid fields %v, got %v", tt.name, tt.want, fields)
+		}
+	}
+
+	// Boards without columns take any status
+	task := Task{BoardID: "b-1", Title: "Ship", Status: "anything"}
+	if err := task.Validate(&Board{}); err != nil {
+		t.Errorf("Expected any status on a board without columns, got %v", err)
+	}
+}
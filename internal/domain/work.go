@@ -0,0 +1,36 @@
+package domain
+
+import "time"
+
+// Kinds of work an agent can claim
+const (
+	WorkTask = "task"
+	WorkBead = "bead"
+)
+
+// Reasons a lease ended
+const (
+	LeaseReleased = "released" // by its agent
+	LeaseExpired  = "expired"  // its agent stopped heartbeating
+)
+
+// Lease is an agent's time-boxed claim on a task or beads issue, so agents
+// working in parallel don't pick the same work. It lasts until it expires
+// unless the agent heartbeats to extend it.
+type Lease struct {
+	ID          string     `json:"id"`
+	Kind        string     `json:"kind"`               // task, bead
+	ItemID      string     `json:"item_id"`            // task or beads issue ID
+	BoardID     string     `json:"board_id,omitempty"` // of a task
+	Agent       User       `json:"agent"`
+	ClaimedAt   time.Time  `json:"claimed_at"`
+	HeartbeatAt time.Time  `json:"heartbeat_at"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	ReleasedAt  *time.Time `json:"released_at,omitempty"`
+	Reason      string     `json:"release_reason,omitempty"` // released, expired
+}
+
+// Active reports whether the lease is neither released nor expired at now
+func (l *Lease) Active(now time.Time) bool {
+	return l.ReleasedAt == nil && now.Before(l.ExpiresAt)
+}
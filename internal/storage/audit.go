@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rand/cartographer/internal/domain"
+)
+
+// Audit log query limits
+const (
+	DefaultAuditLimit = 100
+	MaxAuditLimit     = 1000
+)
+
+// AuditQuery filters the audit log. Zero values match everything.
+type AuditQuery struct {
+	Entity     string
+	EntityID   string
+	ProjectIDs []string // entries in any of these projects
+	Since      time.Time
+	Limit      int
+}
+
+// AuditRepository records and queries the audit log
+type AuditRepository struct {
+	db *DB
+}
+
+// NewAuditRepository creates a new audit repository
+func NewAuditRepository(db *DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+// Record appends an entry to the audit log
+func (r *AuditRepository) Record(entry *domain.AuditEntry) error {
+	// Stored in UTC so times compare as text
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	entry.CreatedAt = entry.CreatedAt.UTC()
+
+	var user, changes sql.NullString
+	if entry.User != nil {
+		data, err := json.Marshal(entry.User)
+		if err != nil {
+			return fmt.Errorf("failed to marshal audit user: %w", err)
+		}
+		user = sql.NullString{String: string(data), Valid: true}
+	}
+	if len(entry.Changes) > 0 {
+		data, err := json.Marshal(entry.Changes)
+		if err != nil {
+			return fmt.Errorf("failed to marshal audit changes: %w", err)
+		}
+		changes = sql.NullString{String: string(data), Valid: true}
+	}
+
+	query := `
+		INSERT INTO audit_log (entity, entity_id, project_id, action, user, changes, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+
+	result, err := r.db.Conn().Exec(query,
+		entry.Entity, entry.EntityID, entry.ProjectID, entry.Action, user, changes, entry.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	entry.ID, err = result.LastInsertId()
+	return err
+}
+
+// List returns matching entries, oldest first
+func (r *AuditRepository) List(q AuditQuery) ([]*domain.AuditEntry, error) {
+	var where []string
+	var args []interface{}
+
+	if q.Entity != "" {
+		where = append(where, "entity = ?")
+		args = append(args, q.Entity)
+	}
+	if q.EntityID != "" {
+		where = append(where, "entity_id = ?")
+		args = append(args, q.EntityID)
+	}
+	if len(q.ProjectIDs) > 0 {
+		where = append(where, "project_id IN (?"+strings.Repeat(", ?", len(q.ProjectIDs)-1)+")")
+		for _, id := range q.ProjectIDs {
+			args = append(args, id)
+		}
+	}
+	if !q.Since.IsZero() {
+		where = append(where, "created_at >= ?")
+		args = append(args, q.Since.UTC())
+	}
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultAuditLimit
+	}
+	if limit > MaxAuditLimit {
+		limit = MaxAuditLimit
+	}
+
+	query := `
+		SELECT id, entity, entity_id, project_id, action, user, changes, created_at
+		FROM audit_log
+	`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY id LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := r.db.Conn().Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []*domain.AuditEntry{}
+	for rows.Next() {
+		entry := &domain.AuditEntry{}
+		var projectID, user, changes sql.NullString
+		if err := rows.Scan(&entry.ID, &entry.Entity, &entry.EntityID, &projectID,
+			&entry.Action, &user, &changes, &entry.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		entry.ProjectID = projectID.String
+		if user.Valid {
+			entry.User = &domain.User{}
+			if err := json.Unmarshal([]byte(user.String), entry.User); err != nil {
+				return nil, fmt.Errorf("invalid user for audit entry %d: %w", entry.ID, err)
+			}
+		}
+		if changes.Valid {
+			if err := json.Unmarshal([]byte(changes.String), &entry.Changes); err != nil {
+				return nil, fmt.Errorf("invalid changes for audit entry %d: %w", entry.ID, err)
+			}
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
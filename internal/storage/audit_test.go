@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rand/cartographer/internal/domain"
+)
+
+func TestAuditRepository(t *testing.T) {
+	db, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewAuditRepository(db)
+	start := time.Now().Add(-time.Hour)
+
+	entries := []*domain.AuditEntry{
+		{Entity: domain.EntityProject, EntityID: "p1", ProjectID: "p1", Action: domain.AuditCreated, CreatedAt: start},
+		{Entity: domain.EntityTask, EntityID: "t1", ProjectID: "p1", Action: domain.AuditUpdated,
+			User:    &domain.User{Type: "agent", ID: "bot"},
+			Changes: domain.Changes{"status": {From: "todo", To: "done"}}},
+		{Entity: domain.EntityTask, EntityID: "t2", ProjectID: "p2", Action: domain.AuditDeleted},
+	}
+	for _, entry := range entries {
+		if err := repo.Record(entry); err != nil {
+			t.Fatalf("Record failed: %v", err)
+		}
+	}
+	if entries[0].ID == 0 || entries[1].ID <= entries[0].ID {
+		t.Errorf("Expected increasing IDs, got %d and %d", entries[0].ID, entries[1].ID)
+	}
+
+	all, err := repo.List(AuditQuery{})
+	if err != nil || len(all) != 3 {
+		t.Fatalf("Expected 3 entries, got %d (%v)", len(all), err)
+	}
+
+	tasks, _ := repo.List(AuditQuery{Entity: domain.EntityTask, EntityID: "t1"})
+	if len(tasks) != 1 {
+		t.Fatalf("Expected 1 entry for t1, got %d", len(tasks))
+	}
+	if tasks[0].User == nil || tasks[0].User.ID != "bot" || tasks[0].Changes["status"].To != "done" {
+		t.Errorf("Unexpected entry %+v", tasks[0])
+	}
+
+	recent, _ := repo.List(AuditQuery{Since: start.Add(time.Minute)})
+	if len(recent) != 2 {
+		t.Errorf("Expected 2 recent entries, got %d", len(recent))
+	}
+
+	scoped, _ := repo.List(AuditQuery{ProjectIDs: []string{"p2"}})
+	if len(scoped) != 1 || scoped[0].EntityID != "t2" {
+		t.Errorf("Expected only p2's entry, got %+v", scoped)
+	}
+
+	limited, _ := repo.List(AuditQuery{Limit: 1})
+	if len(limited) != 1 || limited[0].ID != entries[0].ID {
+		t.Errorf("Expected the oldest entry, got %+v", limited)
+	}
+}
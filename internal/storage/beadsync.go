@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/rand/cartographer/internal/domain"
+)
+
+// BeadSyncRepository stores the sync state between beads and tasks
+type BeadSyncRepository struct {
+	db *DB
+}
+
+// NewBeadSyncRepository creates a new bead sync repository
+func NewBeadSyncRepository(db *DB) *BeadSyncRepository {
+	return &BeadSyncRepository{db: db}
+}
+
+// Get returns the sync state for a bead, or nil if it has never been synced
+func (r *BeadSyncRepository) Get(beadID string) (*domain.BeadSyncState, error) {
+	return r.getOne(`WHERE bead_id = ?`, beadID)
+}
+
+// GetByTask returns the sync state for a task, or nil if it is not linked
+// to a bead
+func (r *BeadSyncRepository) GetByTask(taskID string) (*domain.BeadSyncState, error) {
+	return r.getOne(`WHERE task_id = ?`, taskID)
+}
+
+// ListConflicts returns every bead with unresolved conflicting edits
+func (r *BeadSyncRepository) ListConflicts() ([]*domain.BeadSyncState, error) {
+	query := `
+		SELECT bead_id, task_id, base, conflict, synced_at
+		FROM bead_sync_state
+		WHERE conflict IS NOT NULL
+		ORDER BY bead_id
+	`
+
+	rows, err := r.db.Conn().Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	states := []*domain.BeadSyncState{}
+	for rows.Next() {
+		state, err := scanBeadSyncState(rows)
+		if err != nil {
+			return nil, err
+		}
+		states = append(states, state)
+	}
+
+	return states, rows.Err()
+}
+
+// Save inserts or replaces the sync state for a bead
+func (r *BeadSyncRepository) Save(state *domain.BeadSyncState) error {
+	base, err := json.Marshal(state.Base)
+	if err != nil {
+		return fmt.Errorf("failed to marshal sync base: %w", err)
+	}
+
+	var conflict sql.NullString
+	if state.Conflict != nil {
+		data, err := json.Marshal(state.Conflict)
+		if err != nil {
+			return fmt.Errorf("failed to marshal sync conflict: %w", err)
+		}
+		conflict = sql.NullString{String: string(data), Valid: true}
+	}
+
+	query := `
+		INSERT INTO bead_sync_state (bead_id, task_id, base, conflict, synced_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(bead_id) DO UPDATE SET
+			task_id = excluded.task_id,
+			base = excluded.base,
+			conflict = excluded.conflict,
+			synced_at = excluded.synced_at
+	`
+
+	_, err = r.db.Conn().Exec(query, state.BeadID, state.TaskID, string(base), conflict, state.SyncedAt)
+	return err
+}
+
+// Delete removes the sync state for a bead
+func (r *BeadSyncRepository) Delete(beadID string) error {
+	_, err := r.db.Conn().Exec(`DELETE FROM bead_sync_state WHERE bead_id = ?`, beadID)
+	return err
+}
+
+func (r *BeadSyncRepository) getOne(where string, arg string) (*domain.BeadSyncState, error) {
+	query := `
+		SELECT bead_id, task_id, base, conflict, synced_at
+		FROM bead_sync_state
+	` + where
+
+	state, err := scanBeadSyncState(r.db.Conn().QueryRow(query, arg))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return state, err
+}
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanBeadSyncState(row rowScanner) (*domain.BeadSyncState, error) {
+	state := &domain.BeadSyncState{}
+	var base string
+	var conflict sql.NullString
+
+	if err := row.Scan(&state.BeadID, &state.TaskID, &base, &conflict, &state.SyncedAt); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(base), &state.Base); err != nil {
+		return nil, fmt.Errorf("invalid sync base for bead %s: %w", state.BeadID, err)
+	}
+	if conflict.Valid {
+		state.Conflict = &domain.BeadSyncConflict{}
+		if err := json.Unmarshal([]byte(conflict.String), state.Conflict); err != nil {
+			return nil, fmt.Errorf("invalid sync conflict for bead %s: %w", state.BeadID, err)
+		}
+	}
+
+	return state, nil
+}
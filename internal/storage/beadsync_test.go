@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rand/cartographer/internal/domain"
+)
+
+func TestBeadSyncRepository(t *testing.T) {
+	db, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer db.Close()
+
+	project := &domain.Project{Name: "Sync", Path: "/tmp/sync"}
+	if err := NewProjectRepository(db).Create(project); err != nil {
+		t.Fatalf("failed to create project: %v", err)
+	}
+	board := &domain.Board{ProjectID: project.ID, Name: "Main"}
+	if err := NewBoardRepository(db).Create(board); err != nil {
+		t.Fatalf("failed to create board: %v", err)
+	}
+
+	tasks := NewTaskRepository(db)
+	task := &domain.Task{
+		BoardID:     board.ID,
+		Title:       "Parse config",
+		Status:      "todo",
+		Priority:    "medium",
+		LinkedItems: []domain.LinkedItem{{Type: "doc", ID: "doc-1"}, {Type: "bead", ID: "bd-1"}},
+	}
+	if err := tasks.Create(task); err != nil {
+		t.Fatalf("failed to create task: %v", err)
+	}
+
+	found, err := tasks.FindByLinkedItem("bead", "bd-1")
+	if err != nil || found == nil || found.ID != task.ID {
+		t.Fatalf("Expected task linked to bd-1, got %+v (%v)", found, err)
+	}
+	if found, err := tasks.FindByLinkedItem("bead", "bd-2"); err != nil || found != nil {
+		t.Errorf("Expected no task linked to bd-2, got %+v (%v)", found, err)
+	}
+
+	repo := NewBeadSyncRepository(db)
+	if state, err := repo.Get("bd-1"); err != nil || state != nil {
+		t.Fatalf("Expected no sync state, got %+v (%v)", state, err)
+	}
+
+	state := &domain.BeadSyncState{
+		BeadID:   "bd-1",
+		TaskID:   task.ID,
+		Base:     domain.BeadFields{Title: "Parse config", Status: "open", Priority: 2},
+		SyncedAt: time.Now(),
+	}
+	if err := repo.Save(state); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	state.Conflict = &domain.BeadSyncConflict{Fields: []string{"title"}, DetectedAt: time.Now()}
+	if err := repo.Save(state); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	got, err := repo.GetByTask(task.ID)
+	if err != nil || got == nil {
+		t.Fatalf("GetByTask failed: %+v (%v)", got, err)
+	}
+	if got.Base.Title != "Parse config" || got.Conflict == nil || got.Conflict.Fields[0] != "title" {
+		t.Errorf("Unexpected sync state %+v", got)
+	}
+
+	conflicts, err := repo.ListConflicts()
+	if err != nil || len(conflicts) != 1 {
+		t.Errorf("Expected 1 conflict, got %d (%v)", len(conflicts), err)
+	}
+
+	if err := repo.Delete("bd-1"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if state, _ := repo.Get("bd-1"); state != nil {
+		t.Errorf("Expected sync state deleted, got %+v", state)
+	}
+}
@@ -26,9 +26,10 @@ func (r *BoardRepository) Create(board *domain.Board) error {
 		board.ID = uuid.New().String()
 	}
 
-	now := time.Now()
+	now := time.Now().UTC()
 	board.CreatedAt = now
 	board.UpdatedAt = now
+	board.Revision = 1
 
 	columns, err := json.Marshal(board.Columns)
 	if err != nil {
@@ -36,8 +37,8 @@ func (r *BoardRepository) Create(board *domain.Board) error {
 	}
 
 	query := `
-		INSERT INTO boards (id, project_id, name, description, columns, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO boards (id, project_id, name, description, columns, wip_policy, created_at, updated_at, revision)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
 	_, err = r.db.Conn().Exec(query,
@@ -46,8 +47,10 @@ func (r *BoardRepository) Create(board *domain.Board) error {
 		board.Name,
 		board.Description,
 		string(columns),
+		board.WIPPolicy,
 		board.CreatedAt,
 		board.UpdatedAt,
+		board.Revision,
 	)
 
 	return err
@@ -56,7 +59,7 @@ func (r *BoardRepository) Create(board *domain.Board) error {
 // GetByID retrieves a board by ID
 func (r *BoardRepository) GetByID(id string) (*domain.Board, error) {
 	query := `
-		SELECT id, project_id, name, description, columns, created_at, updated_at
+		SELECT id, project_id, name, description, columns, wip_policy, created_at, updated_at, revision
 		FROM boards
 		WHERE id = ?
 	`
@@ -70,12 +73,14 @@ func (r *BoardRepository) GetByID(id string) (*domain.Board, error) {
 		&board.Name,
 		&board.Description,
 		&columnsJSON,
+		&board.WIPPolicy,
 		&board.CreatedAt,
 		&board.UpdatedAt,
+		&board.Revision,
 	)
 
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("board not found: %s", id)
+		return nil, fmt.Errorf("board %w: %s", ErrNotFound, id)
 	}
 	if err != nil {
 		return nil, err
@@ -94,7 +99,7 @@ func (r *BoardRepository) GetByID(id string) (*domain.Board, error) {
 // ListByProject retrieves all boards for a project
 func (r *BoardRepository) ListByProject(projectID string) ([]*domain.Board, error) {
 	query := `
-		SELECT id, project_id, name, description, columns, created_at, updated_at
+		SELECT id, project_id, name, description, columns, wip_policy, created_at, updated_at, revision
 		FROM boards
 		WHERE project_id = ?
 		ORDER BY updated_at DESC
@@ -117,8 +122,10 @@ func (r *BoardRepository) ListByProject(projectID string) ([]*domain.Board, erro
 			&board.Name,
 			&board.Description,
 			&columnsJSON,
+			&board.WIPPolicy,
 			&board.CreatedAt,
 			&board.UpdatedAt,
+			&board.Revision,
 		)
 		if err != nil {
 			return nil, err
@@ -137,9 +144,11 @@ func (r *BoardRepository) ListByProject(projectID string) ([]*domain.Board, erro
 	return boards, rows.Err()
 }
 
-// Update updates an existing board
+// Update updates an existing board and bumps its revision. If
+// board.Revision is set, the update only applies at that revision and
+// otherwise fails with ErrRevisionConflict.
 func (r *BoardRepository) Update(board *domain.Board) error {
-	board.UpdatedAt = time.Now()
+	board.UpdatedAt = time.Now().UTC()
 
 	columns, err := json.Marshal(board.Columns)
 	if err != nil {
@@ -148,29 +157,41 @@ func (r *BoardRepository) Update(board *domain.Board) error {
 
 	query := `
 		UPDATE boards
-		SET name = ?, description = ?, columns = ?
-		WHERE id = ?
+		SET name = ?, description = ?, columns = ?, wip_policy = ?, updated_at = ?, revision = revision + 1
+		WHERE id = ?` + revisionCondition + `
+		RETURNING revision
 	`
 
-	result, err := r.db.Conn().Exec(query,
+	row := r.db.Conn().QueryRow(query,
 		board.Name,
 		board.Description,
 		string(columns),
+		board.WIPPolicy,
+		board.UpdatedAt,
 		board.ID,
+		board.Revision,
+		board.Revision,
 	)
-	if err != nil {
-		return err
-	}
-
-	rows, err := result.RowsAffected()
-	if err != nil {
-		return err
-	}
-	if rows == 0 {
-		return fmt.Errorf("board not found: %s", board.ID)
-	}
+	return scanRevision(r.db.Conn(), row, "boards", "board", board.ID, &board.Revision)
+}
 
-	return nil
+// UpdateFields writes only the named fields of a board. Revisions work as
+// for Update.
+func (r *BoardRepository) UpdateFields(board *domain.Board, fields []string) error {
+	board.UpdatedAt = time.Now().UTC()
+	return updateColumns(r.db.Conn(), "boards", board.ID, fields, func(field string) (interface{}, error) {
+		switch field {
+		case "name":
+			return board.Name, nil
+		case "description":
+			return board.Description, nil
+		case "columns":
+			return jsonColumn(board.Columns)
+		case "wip_policy":
+			return board.WIPPolicy, nil
+		}
+		return nil, fieldError("board", field)
+	}, board.UpdatedAt, &board.Revision)
 }
 
 // Delete deletes a board by ID
@@ -187,7 +208,7 @@ func (r *BoardRepository) Delete(id string) error {
 		return err
 	}
 	if rows == 0 {
-		return fmt.Errorf("board not found: %s", id)
+		return fmt.Errorf("board %w: %s", ErrNotFound, id)
 	}
 
 	return nil
@@ -0,0 +1,220 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rand/cartographer/internal/domain"
+)
+
+// DiagramRepository handles diagram CRUD operations
+type DiagramRepository struct {
+	db *DB
+}
+
+// NewDiagramRepository creates a new diagram repository
+func NewDiagramRepository(db *DB) *DiagramRepository {
+	return &DiagramRepository{db: db}
+}
+
+// Create creates a new diagram
+func (r *DiagramRepository) Create(diagram *domain.Diagram) error {
+	if diagram.ID == "" {
+		diagram.ID = uuid.New().String()
+	}
+
+	now := time.Now().UTC()
+	diagram.CreatedAt = now
+	diagram.UpdatedAt = now
+	diagram.Revision = 1
+
+	// History starts empty; it is owned by the repository, not the caller
+	diagram.Versions = nil
+
+	versions, err := json.Marshal(diagram.Versions)
+	if err != nil {
+		return fmt.Errorf("failed to marshal versions: %w", err)
+	}
+
+	query := `
+		INSERT INTO diagrams (id, project_id, name, type, content, created_at, updated_at, versions, revision)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	_, err = r.db.Conn().Exec(query,
+		diagram.ID,
+		diagram.ProjectID,
+		diagram.Name,
+		diagram.Type,
+		diagram.Content,
+		diagram.CreatedAt,
+		diagram.UpdatedAt,
+		string(versions),
+		diagram.Revision,
+	)
+
+	return err
+}
+
+// GetByID retrieves a diagram by ID
+func (r *DiagramRepository) GetByID(id string) (*domain.Diagram, error) {
+	query := `
+		SELECT id, project_id, name, type, content, created_at, updated_at, versions, revision
+		FROM diagrams
+		WHERE id = ?
+	`
+
+	diagram := &domain.Diagram{}
+	var versionsJSON sql.NullString
+
+	err := r.db.Conn().QueryRow(query, id).Scan(
+		&diagram.ID,
+		&diagram.ProjectID,
+		&diagram.Name,
+		&diagram.Type,
+		&diagram.Content,
+		&diagram.CreatedAt,
+		&diagram.UpdatedAt,
+		&versionsJSON,
+		&diagram.Revision,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("diagram %w: %s", ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if versionsJSON.Valid {
+		if err := json.Unmarshal([]byte(versionsJSON.String), &diagram.Versions); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal versions: %w", err)
+		}
+	}
+
+	return diagram, nil
+}
+
+// ListByProject retrieves all diagrams for a project
+func (r *DiagramRepository) ListByProject(projectID string) ([]*domain.Diagram, error) {
+	query := `
+		SELECT id, project_id, name, type, content, created_at, updated_at, versions, revision
+		FROM diagrams
+		WHERE project_id = ?
+		ORDER BY updated_at DESC
+	`
+
+	rows, err := r.db.Conn().Query(query, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var diagrams []*domain.Diagram
+	for rows.Next() {
+		diagram := &domain.Diagram{}
+		var versionsJSON sql.NullString
+
+		err := rows.Scan(
+			&diagram.ID,
+			&diagram.ProjectID,
+			&diagram.Name,
+			&diagram.Type,
+			&diagram.Content,
+			&diagram.CreatedAt,
+			&diagram.UpdatedAt,
+			&versionsJSON,
+			&diagram.Revision,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if versionsJSON.Valid {
+			if err := json.Unmarshal([]byte(versionsJSON.String), &diagram.Versions); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal versions: %w", err)
+			}
+		}
+
+		diagrams = append(diagrams, diagram)
+	}
+
+	return diagrams, rows.Err()
+}
+
+// Update updates an existing diagram. When the content changes, the
+// previous content is appended to Versions before it is overwritten.
+// Any Versions supplied by the caller are ignored. The revision is bumped;
+// if diagram.Revision is set, the update only applies at that revision and
+// otherwise fails with ErrRevisionConflict.
+func (r *DiagramRepository) Update(diagram *domain.Diagram) error {
+	existing, err := r.GetByID(diagram.ID)
+	if err != nil {
+		return err
+	}
+	if diagram.Revision != 0 && diagram.Revision != existing.Revision {
+		return ErrRevisionConflict
+	}
+
+	diagram.ProjectID = existing.ProjectID
+	diagram.CreatedAt = existing.CreatedAt
+	diagram.Versions = existing.Versions
+	diagram.UpdatedAt = time.Now().UTC()
+
+	if diagram.Content != existing.Content {
+		diagram.Versions = append(diagram.Versions, domain.DiagramVersion{
+			Version:   len(existing.Versions) + 1,
+			Timestamp: existing.UpdatedAt,
+			Content:   existing.Content,
+		})
+	}
+
+	versions, err := json.Marshal(diagram.Versions)
+	if err != nil {
+		return fmt.Errorf("failed to marshal versions: %w", err)
+	}
+
+	query := `
+		UPDATE diagrams
+		SET name = ?, type = ?, content = ?, versions = ?, updated_at = ?, revision = revision + 1
+		WHERE id = ?` + revisionCondition + `
+		RETURNING revision
+	`
+
+	// Conditional on the revision read above, so the version history can't
+	// lose a concurrent edit
+	row := r.db.Conn().QueryRow(query,
+		diagram.Name,
+		diagram.Type,
+		diagram.Content,
+		string(versions),
+		diagram.UpdatedAt,
+		diagram.ID,
+		existing.Revision,
+		existing.Revision,
+	)
+	return scanRevision(r.db.Conn(), row, "diagrams", "diagram", diagram.ID, &diagram.Revision)
+}
+
+// Delete deletes a diagram by ID
+func (r *DiagramRepository) Delete(id string) error {
+	query := `DELETE FROM diagrams WHERE id = ?`
+
+	result, err := r.db.Conn().Exec(query, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("diagram %w: %s", ErrNotFound, id)
+	}
+
+	return nil
+}
@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/rand/cartographer/internal/domain"
+)
+
+func TestDiagramRepository(t *testing.T) {
+	db, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer db.Close()
+
+	project := &domain.Project{Name: "Diagrams", Path: "/tmp/diagrams"}
+	if err := NewProjectRepository(db).Create(project); err != nil {
+		t.Fatalf("failed to create project: %v", err)
+	}
+
+	repo := NewDiagramRepository(db)
+	diagram := &domain.Diagram{
+		ProjectID: project.ID, Name: "Flow", Type: "mermaid", Content: "graph TD; A-->B",
+		// History belongs to the repository
+		Versions: []domain.DiagramVersion{{Version: 1, Content: "forged"}},
+	}
+	if err := repo.Create(diagram); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if len(diagram.Versions) != 0 || diagram.Revision != 1 {
+		t.Errorf("Expected a new diagram without history at revision 1, got %+v", diagram)
+	}
+	created, err := repo.GetByID(diagram.ID)
+	if err != nil {
+		t.Fatalf("GetByID failed: %v", err)
+	}
+
+	// Renaming keeps the content, so it adds no version
+	update := &domain.Diagram{ID: diagram.ID, Name: "Flow chart", Type: "mermaid", Content: "graph TD; A-->B"}
+	if err := repo.Update(update); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if len(update.Versions) != 0 || update.Revision != 2 {
+		t.Errorf("Expected no version from a rename at revision 2, got %+v", update)
+	}
+	if !update.CreatedAt.Equal(created.CreatedAt) || update.ProjectID != project.ID {
+		t.Errorf("Expected the updated diagram to keep its creation time and project, got %+v", update)
+	}
+
+	for _, content := range []string{"graph TD; A-->C", "graph TD; A-->D"} {
+		update := &domain.Diagram{ID: diagram.ID, Name: "Flow chart", Type: "mermaid", Content: content}
+		if err := repo.Update(update); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+	}
+
+	found, err := repo.GetByID(diagram.ID)
+	if err != nil {
+		t.Fatalf("GetByID failed: %v", err)
+	}
+	if !found.CreatedAt.Equal(created.CreatedAt) || found.ProjectID != project.ID {
+		t.Errorf("Expected the creation time and project kept, got %v %s, want %v %s",
+			found.CreatedAt, found.ProjectID, created.CreatedAt, project.ID)
+	}
+	if found.Revision != 4 || found.CurrentVersion() != 3 || found.Content != "graph TD; A-->D" {
+		t.Fatalf("Unexpected diagram %+v", found)
+	}
+	for i, want := range []string{"graph TD; A-->B", "graph TD; A-->C"} {
+		v := found.Versions[i]
+		if v.Version != i+1 || v.Content != want {
+			t.Errorf("Version %d: got %+v, want content %q", i+1, v, want)
+		}
+	}
+	if content, ok := found.ContentAt(1); !ok || content != "graph TD; A-->B" {
+		t.Errorf("ContentAt(1) = %q, %v", content, ok)
+	}
+
+	// A stale revision loses to the edits since
+	stale := &domain.Diagram{ID: diagram.ID, Name: "Flow chart", Type: "mermaid", Content: "graph TD; A-->E", Revision: 2}
+	if err := repo.Update(stale); !errors.Is(err, ErrRevisionConflict) {
+		t.Errorf("Expected ErrRevisionConflict, got %v", err)
+	}
+	if err := repo.Update(&domain.Diagram{ID: "missing", Content: "graph TD"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
@@ -26,9 +26,10 @@ func (r *DocumentRepository) Create(doc *domain.Document) error {
 		doc.ID = uuid.New().String()
 	}
 
-	now := time.Now()
+	now := time.Now().UTC()
 	doc.CreatedAt = now
 	doc.UpdatedAt = now
+	doc.Revision = 1
 
 	tags, err := json.Marshal(doc.Tags)
 	if err != nil {
@@ -51,8 +52,8 @@ func (r *DocumentRepository) Create(doc *domain.Document) error {
 	}
 
 	query := `
-		INSERT INTO documents (id, project_id, title, content, path, tags, linked_from, links_to, created_at, updated_at, versions)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO documents (id, project_id, title, content, path, tags, linked_from, links_to, created_at, updated_at, versions, revision)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
 	_, err = r.db.Conn().Exec(query,
@@ -67,23 +68,35 @@ func (r *DocumentRepository) Create(doc *domain.Document) error {
 		doc.CreatedAt,
 		doc.UpdatedAt,
 		string(versions),
+		doc.Revision,
 	)
 
 	return err
 }
 
+// documentColumns are the columns scanned by scanDocument
+const documentColumns = `id, project_id, title, content, path, tags, linked_from, links_to, created_at, updated_at, versions, revision`
+
 // GetByID retrieves a document by ID
 func (r *DocumentRepository) GetByID(id string) (*domain.Document, error) {
-	query := `
-		SELECT id, project_id, title, content, path, tags, linked_from, links_to, created_at, updated_at, versions
-		FROM documents
-		WHERE id = ?
-	`
+	query := `SELECT ` + documentColumns + ` FROM documents WHERE id = ?`
+
+	doc, err := scanDocument(r.db.Conn().QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("document %w: %s", ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
 
+// scanDocument scans a row of documentColumns, followed by extra
+func scanDocument(row rowScanner, extra ...interface{}) (*domain.Document, error) {
 	doc := &domain.Document{}
 	var tagsJSON, linkedFromJSON, linksToJSON, versionsJSON string
 
-	err := r.db.Conn().QueryRow(query, id).Scan(
+	err := row.Scan(append([]interface{}{
 		&doc.ID,
 		&doc.ProjectID,
 		&doc.Title,
@@ -95,11 +108,8 @@ func (r *DocumentRepository) GetByID(id string) (*domain.Document, error) {
 		&doc.CreatedAt,
 		&doc.UpdatedAt,
 		&versionsJSON,
-	)
-
-	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("document not found")
-	}
+		&doc.Revision,
+	}, extra...)...)
 	if err != nil {
 		return nil, err
 	}
@@ -122,62 +132,85 @@ func (r *DocumentRepository) GetByID(id string) (*domain.Document, error) {
 
 // ListByProject retrieves all documents for a project
 func (r *DocumentRepository) ListByProject(projectID string) ([]*domain.Document, error) {
-	query := `
-		SELECT id, project_id, title, content, path, tags, linked_from, links_to, created_at, updated_at, versions
-		FROM documents
-		WHERE project_id = ?
-		ORDER BY updated_at DESC
-	`
+	documents, _, err := r.Query(DocumentQuery{ProjectID: projectID})
+	return documents, err
+}
+
+// DocumentQuery filters, sorts and pages the documents of a project. Empty
+// fields match every document.
+type DocumentQuery struct {
+	ProjectID    string
+	Tag          string
+	UpdatedSince *time.Time
+	Text         string // in the title or content
+	ListOptions
+}
+
+// DefaultDocumentSort is the order of documents when a query doesn't sort
+// them: most recently updated first
+const DefaultDocumentSort = "-updated_at"
+
+// documentSortKeys are the fields documents can be sorted by
+var documentSortKeys = map[string]sortKey{
+	"title":      {expr: "title"},
+	"path":       {expr: "path"},
+	"created_at": timeSortKey("created_at"),
+	"updated_at": timeSortKey("updated_at"),
+}
+
+// Query retrieves the documents matching q and, if q.Limit cuts them
+// short, the cursor of the next page
+func (r *DocumentRepository) Query(q DocumentQuery) ([]*domain.Document, string, error) {
+	lq := &listQuery{table: "documents", columns: documentColumns}
+	lq.filter("project_id = ?", q.ProjectID)
+	if q.Tag != "" {
+		lq.filter("EXISTS (SELECT 1 FROM json_each(documents.tags) WHERE value = ?)", q.Tag)
+	}
+	if q.UpdatedSince != nil {
+		lq.filter(timeFilter("updated_at", ">="), dbTime(*q.UpdatedSince))
+	}
+	if q.Text != "" {
+		lq.filterText(q.Text, "title", "content")
+	}
 
-	rows, err := r.db.Conn().Query(query, projectID)
+	query, args, err := lq.build(documentSortKeys, DefaultDocumentSort, q.ListOptions)
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+	rows, err := r.db.Conn().Query(query, args...)
+	if err != nil {
+		return nil, "", err
 	}
 	defer rows.Close()
 
 	var documents []*domain.Document
+	var keys []pageKey
 	for rows.Next() {
-		doc := &domain.Document{}
-		var tagsJSON, linkedFromJSON, linksToJSON, versionsJSON string
-
-		err := rows.Scan(
-			&doc.ID,
-			&doc.ProjectID,
-			&doc.Title,
-			&doc.Content,
-			&doc.Path,
-			&tagsJSON,
-			&linkedFromJSON,
-			&linksToJSON,
-			&doc.CreatedAt,
-			&doc.UpdatedAt,
-			&versionsJSON,
-		)
+		var key pageKey
+		doc, err := scanDocument(rows, key.dest()...)
 		if err != nil {
-			return nil, err
-		}
-
-		if err := json.Unmarshal([]byte(tagsJSON), &doc.Tags); err != nil {
-			doc.Tags = []string{}
-		}
-		if err := json.Unmarshal([]byte(linkedFromJSON), &doc.LinkedFrom); err != nil {
-			doc.LinkedFrom = []string{}
+			return nil, "", err
 		}
-		if err := json.Unmarshal([]byte(linksToJSON), &doc.LinksTo); err != nil {
-			doc.LinksTo = []string{}
-		}
-		if err := json.Unmarshal([]byte(versionsJSON), &doc.Versions); err != nil {
-			doc.Versions = []domain.DocumentVersion{}
-		}
-
 		documents = append(documents, doc)
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
 	}
 
-	return documents, rows.Err()
+	next := nextCursor(q.ListOptions, DefaultDocumentSort, keys)
+	if next != "" {
+		documents = documents[:q.Limit]
+	}
+	return documents, next, nil
 }
 
-// Update updates an existing document
+// Update updates an existing document and bumps its revision. If
+// doc.Revision is set, the update only applies at that revision and
+// otherwise fails with ErrRevisionConflict.
 func (r *DocumentRepository) Update(doc *domain.Document) error {
+	doc.UpdatedAt = time.Now().UTC()
+
 	tags, err := json.Marshal(doc.Tags)
 	if err != nil {
 		return fmt.Errorf("failed to marshal tags: %w", err)
@@ -200,11 +233,13 @@ func (r *DocumentRepository) Update(doc *domain.Document) error {
 
 	query := `
 		UPDATE documents
-		SET title = ?, content = ?, path = ?, tags = ?, linked_from = ?, links_to = ?, versions = ?
-		WHERE id = ?
+		SET title = ?, content = ?, path = ?, tags = ?, linked_from = ?, links_to = ?, versions = ?,
+			updated_at = ?, revision = revision + 1
+		WHERE id = ?` + revisionCondition + `
+		RETURNING revision
 	`
 
-	_, err = r.db.Conn().Exec(query,
+	row := r.db.Conn().QueryRow(query,
 		doc.Title,
 		doc.Content,
 		doc.Path,
@@ -212,10 +247,35 @@ func (r *DocumentRepository) Update(doc *domain.Document) error {
 		string(linkedFrom),
 		string(linksTo),
 		string(versions),
+		doc.UpdatedAt,
 		doc.ID,
+		doc.Revision,
+		doc.Revision,
 	)
+	return scanRevision(r.db.Conn(), row, "documents", "document", doc.ID, &doc.Revision)
+}
 
-	return err
+// UpdateFields writes only the named fields of a document. Revisions work
+// as for Update.
+func (r *DocumentRepository) UpdateFields(doc *domain.Document, fields []string) error {
+	doc.UpdatedAt = time.Now().UTC()
+	return updateColumns(r.db.Conn(), "documents", doc.ID, fields, func(field string) (interface{}, error) {
+		switch field {
+		case "title":
+			return doc.Title, nil
+		case "content":
+			return doc.Content, nil
+		case "path":
+			return doc.Path, nil
+		case "tags":
+			return jsonColumn(doc.Tags)
+		case "linked_from":
+			return jsonColumn(doc.LinkedFrom)
+		case "links_to":
+			return jsonColumn(doc.LinksTo)
+		}
+		return nil, fieldError("document", field)
+	}, doc.UpdatedAt, &doc.Revision)
 }
 
 // Delete deletes a document
@@ -0,0 +1,36 @@
+package storage
+
+import (
+	"errors"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// ErrNotFound is wrapped by the errors of lookups and writes of rows that
+// don't exist
+var ErrNotFound = errors.New("not found")
+
+// IsDuplicate reports whether err comes from a write that violated a
+// uniqueness constraint
+func IsDuplicate(err error) bool {
+	return isConstraint(err, sqlite3.ErrConstraintUnique, sqlite3.ErrConstraintPrimaryKey)
+}
+
+// IsMissingReference reports whether err comes from a write referring to a
+// row that doesn't exist, violating a foreign key constraint
+func IsMissingReference(err error) bool {
+	return isConstraint(err, sqlite3.ErrConstraintForeignKey)
+}
+
+func isConstraint(err error, codes ...sqlite3.ErrNoExtended) bool {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) || sqliteErr.Code != sqlite3.ErrConstraint {
+		return false
+	}
+	for _, code := range codes {
+		if sqliteErr.ExtendedCode == code {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rand/cartographer/internal/domain"
+)
+
+// EventRepository persists broadcast events for replay
+type EventRepository struct {
+	db *DB
+}
+
+// NewEventRepository creates a new event repository
+func NewEventRepository(db *DB) *EventRepository {
+	return &EventRepository{db: db}
+}
+
+// EventStreamID returns the identifier of this database's event sequence,
+// creating it on first use
+func (r *EventRepository) EventStreamID() (string, error) {
+	var id string
+	err := r.db.Conn().QueryRow(`SELECT id FROM event_stream ORDER BY created_at LIMIT 1`).Scan(&id)
+	if err == nil {
+		return id, nil
+	}
+	if err != sql.ErrNoRows {
+		return "", err
+	}
+
+	id = uuid.New().String()
+	if _, err := r.db.Conn().Exec(`INSERT INTO event_stream (id, created_at) VALUES (?, ?)`, id, time.Now().UTC()); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// AppendEvent records an event
+func (r *EventRepository) AppendEvent(event *domain.Event) error {
+	resources, err := json.Marshal(event.Resources)
+	if err != nil {
+		return fmt.Errorf("failed to marshal resources: %w", err)
+	}
+
+	query := `
+		INSERT INTO event_log (seq, type, resources, data, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`
+
+	_, err = r.db.Conn().Exec(query,
+		int64(event.Seq),
+		event.Type,
+		string(resources),
+		string(event.Data),
+		event.CreatedAt,
+	)
+
+	return err
+}
+
+// RecentEvents returns up to limit of the newest events, oldest first
+func (r *EventRepository) RecentEvents(limit int) ([]*domain.Event, error) {
+	query := `
+		SELECT seq, type, resources, data, created_at
+		FROM event_log
+		ORDER BY seq DESC
+		LIMIT ?
+	`
+
+	rows, err := r.db.Conn().Query(query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*domain.Event
+	for rows.Next() {
+		event := &domain.Event{}
+		var seq int64
+		var resourcesJSON sql.NullString
+		var data string
+
+		if err := rows.Scan(&seq, &event.Type, &resourcesJSON, &data, &event.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		event.Seq = uint64(seq)
+		event.Data = []byte(data)
+		if resourcesJSON.Valid {
+			json.Unmarshal([]byte(resourcesJSON.String), &event.Resources)
+		}
+
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Reverse into ascending sequence order
+	for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+		events[i], events[j] = events[j], events[i]
+	}
+
+	return events, nil
+}
+
+// TrimEvents deletes all but the newest keep events
+func (r *EventRepository) TrimEvents(keep int) error {
+	_, err := r.db.Conn().Exec(
+		`DELETE FROM event_log WHERE seq <= (SELECT MAX(seq) FROM event_log) - ?`,
+		keep,
+	)
+	return err
+}
@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rand/cartographer/internal/domain"
+)
+
+func TestEventRepository(t *testing.T) {
+	db, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewEventRepository(db)
+
+	stream, err := repo.EventStreamID()
+	if err != nil {
+		t.Fatalf("EventStreamID failed: %v", err)
+	}
+	if again, _ := repo.EventStreamID(); again != stream {
+		t.Errorf("Expected stable stream ID %s, got %s", stream, again)
+	}
+
+	for seq := uint64(1); seq <= 5; seq++ {
+		event := &domain.Event{
+			Seq:       seq,
+			Type:      "task.updated",
+			Resources: []string{"board:b1", "project:p1"},
+			Data:      []byte(`{"type":"task.updated"}`),
+			CreatedAt: time.Now(),
+		}
+		if err := repo.AppendEvent(event); err != nil {
+			t.Fatalf("AppendEvent failed: %v", err)
+		}
+	}
+
+	events, err := repo.RecentEvents(3)
+	if err != nil {
+		t.Fatalf("RecentEvents failed: %v", err)
+	}
+	if len(events) != 3 || events[0].Seq != 3 || events[2].Seq != 5 {
+		t.Fatalf("Expected events 3-5 in order, got %d events", len(events))
+	}
+	if len(events[0].Resources) != 2 || string(events[0].Data) != `{"type":"task.updated"}` {
+		t.Errorf("Event not round-tripped: %+v", events[0])
+	}
+
+	if err := repo.TrimEvents(2); err != nil {
+		t.Fatalf("TrimEvents failed: %v", err)
+	}
+	events, _ = repo.RecentEvents(10)
+	if len(events) != 2 || events[0].Seq != 4 {
+		t.Errorf("Expected events 4-5 after trim, got %d events", len(events))
+	}
+}
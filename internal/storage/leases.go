@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rand/cartographer/internal/domain"
+)
+
+// ErrLeaseEnded is returned when extending or releasing a lease that has
+// already expired or been released
+var ErrLeaseEnded = errors.New("lease has ended")
+
+const leaseColumns = `id, kind, item_id, board_id, agent, claimed_at, heartbeat_at, expires_at, released_at, release_reason`
+
+// LeaseRepository handles agents' work leases. Times are stored in UTC so
+// they compare as text.
+type LeaseRepository struct {
+	db *DB
+}
+
+// NewLeaseRepository creates a new lease repository
+func NewLeaseRepository(db *DB) *LeaseRepository {
+	return &LeaseRepository{db: db}
+}
+
+// Create records a lease claimed at now for the given duration. It fails
+// with a duplicate error, see IsDuplicate, if the item already has an
+// unreleased lease.
+func (r *LeaseRepository) Create(lease *domain.Lease, now time.Time, duration time.Duration) error {
+	if lease.ID == "" {
+		lease.ID = uuid.New().String()
+	}
+	lease.ClaimedAt = now.UTC()
+	lease.HeartbeatAt = lease.ClaimedAt
+	lease.ExpiresAt = lease.ClaimedAt.Add(duration)
+
+	agent, err := json.Marshal(lease.Agent)
+	if err != nil {
+		return fmt.Errorf("failed to marshal lease agent: %w", err)
+	}
+
+	query := `
+		INSERT INTO work_leases (id, kind, item_id, board_id, agent, claimed_at, heartbeat_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	_, err = r.db.Conn().Exec(query,
+		lease.ID, lease.Kind, lease.ItemID, sql.NullString{String: lease.BoardID, Valid: lease.BoardID != ""}, string(agent),
+		lease.ClaimedAt, lease.HeartbeatAt, lease.ExpiresAt,
+	)
+	return err
+}
+
+// Get retrieves a lease by ID, whether or not it is active
+func (r *LeaseRepository) Get(id string) (*domain.Lease, error) {
+	lease, err := scanLease(r.db.Conn().QueryRow(`SELECT `+leaseColumns+` FROM work_leases WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("lease %w: %s", ErrNotFound, id)
+	}
+	return lease, err
+}
+
+// ListActive returns the leases active at now, oldest first
+func (r *LeaseRepository) ListActive(now time.Time) ([]*domain.Lease, error) {
+	query := `SELECT ` + leaseColumns + ` FROM work_leases
+		WHERE released_at IS NULL AND expires_at > ?
+		ORDER BY claimed_at`
+	return r.list(query, now.UTC())
+}
+
+// Heartbeat extends an active lease to expire the given duration after now
+func (r *LeaseRepository) Heartbeat(id string, now time.Time, duration time.Duration) (*domain.Lease, error) {
+	now = now.UTC()
+	query := `UPDATE work_leases SET heartbeat_at = ?, expires_at = ?
+		WHERE id = ? AND released_at IS NULL AND expires_at > ?
+		RETURNING ` + leaseColumns
+	return r.end(id, r.db.Conn().QueryRow(query, now, now.Add(duration), id, now))
+}
+
+// Release ends a lease at now for the given reason. Leases that have expired
+// but not yet been swept by ExpireLeases can still be released.
+func (r *LeaseRepository) Release(id, reason string, now time.Time) (*domain.Lease, error) {
+	query := `UPDATE work_leases SET released_at = ?, release_reason = ?
+		WHERE id = ? AND released_at IS NULL
+		RETURNING ` + leaseColumns
+	return r.end(id, r.db.Conn().QueryRow(query, now.UTC(), reason, id))
+}
+
+// ExpireLeases releases every lease that expired by now and returns them
+func (r *LeaseRepository) ExpireLeases(now time.Time) ([]*domain.Lease, error) {
+	now = now.UTC()
+	query := `UPDATE work_leases SET released_at = ?, release_reason = ?
+		WHERE released_at IS NULL AND expires_at <= ?
+		RETURNING ` + leaseColumns
+	return r.list(query, now, domain.LeaseExpired, now)
+}
+
+// end scans the lease returned by a conditional update of the lease with
+// the given ID, telling a missing lease apart from one that has ended
+func (r *LeaseRepository) end(id string, row *sql.Row) (*domain.Lease, error) {
+	lease, err := scanLease(row)
+	if err != sql.ErrNoRows {
+		return lease, err
+	}
+	if _, err := r.Get(id); err != nil {
+		return nil, err
+	}
+	return nil, ErrLeaseEnded
+}
+
+func (r *LeaseRepository) list(query string, args ...interface{}) ([]*domain.Lease, error) {
+	rows, err := r.db.Conn().Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	leases := []*domain.Lease{}
+	for rows.Next() {
+		lease, err := scanLease(rows)
+		if err != nil {
+			return nil, err
+		}
+		leases = append(leases, lease)
+	}
+
+	return leases, rows.Err()
+}
+
+func scanLease(row rowScanner) (*domain.Lease, error) {
+	lease := &domain.Lease{}
+	var agent string
+	var boardID, reason sql.NullString
+	var releasedAt sql.NullTime
+
+	err := row.Scan(&lease.ID, &lease.Kind, &lease.ItemID, &boardID, &agent,
+		&lease.ClaimedAt, &lease.HeartbeatAt, &lease.ExpiresAt, &releasedAt, &reason)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(agent), &lease.Agent); err != nil {
+		return nil, fmt.Errorf("invalid agent for lease %s: %w", lease.ID, err)
+	}
+	lease.BoardID = boardID.String
+	lease.Reason = reason.String
+	if releasedAt.Valid {
+		lease.ReleasedAt = &releasedAt.Time
+	}
+
+	return lease, nil
+}
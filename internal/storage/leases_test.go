@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rand/cartographer/internal/domain"
+)
+
+func TestLeaseRepository(t *testing.T) {
+	db, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewLeaseRepository(db)
+	now := time.Now()
+	agent := domain.User{ID: "bot", Type: "agent"}
+
+	lease := &domain.Lease{Kind: domain.WorkTask, ItemID: "task-1", BoardID: "board-1", Agent: agent}
+	if err := repo.Create(lease, now, time.Minute); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if !lease.Active(now) || lease.Active(now.Add(time.Minute)) {
+		t.Errorf("Expected the lease to last a minute, got %+v", lease)
+	}
+
+	// An item can only be claimed once at a time
+	err = repo.Create(&domain.Lease{Kind: domain.WorkTask, ItemID: "task-1", Agent: agent}, now, time.Minute)
+	if !IsDuplicate(err) {
+		t.Errorf("Expected a duplicate error claiming a leased task, got %v", err)
+	}
+	bead := &domain.Lease{Kind: domain.WorkBead, ItemID: "task-1", Agent: agent}
+	if err := repo.Create(bead, now, time.Second); err != nil {
+		t.Errorf("Expected a beads issue with the same ID to be claimable, got %v", err)
+	}
+
+	found, err := repo.Get(lease.ID)
+	if err != nil || found.BoardID != "board-1" || found.Agent != agent || !found.ExpiresAt.Equal(lease.ExpiresAt) {
+		t.Errorf("Unexpected lease %+v (%v)", found, err)
+	}
+	if _, err := repo.Get("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	extended, err := repo.Heartbeat(lease.ID, now.Add(30*time.Second), time.Minute)
+	if err != nil || !extended.ExpiresAt.Equal(now.Add(90*time.Second).UTC()) {
+		t.Errorf("Unexpected heartbeat result %+v (%v)", extended, err)
+	}
+
+	// Only the bead's lease has expired a second later
+	expired, err := repo.ExpireLeases(now.Add(time.Second))
+	if err != nil || len(expired) != 1 || expired[0].ID != bead.ID || expired[0].Reason != domain.LeaseExpired {
+		t.Fatalf("Unexpected expired leases %+v (%v)", expired, err)
+	}
+	if _, err := repo.Heartbeat(bead.ID, now.Add(time.Second), time.Minute); !errors.Is(err, ErrLeaseEnded) {
+		t.Errorf("Expected ErrLeaseEnded extending an expired lease, got %v", err)
+	}
+	active, err := repo.ListActive(now.Add(time.Second))
+	if err != nil || len(active) != 1 || active[0].ID != lease.ID {
+		t.Errorf("Unexpected active leases %+v (%v)", active, err)
+	}
+
+	released, err := repo.Release(lease.ID, domain.LeaseReleased, now.Add(time.Minute))
+	if err != nil || released.ReleasedAt == nil || released.Reason != domain.LeaseReleased {
+		t.Errorf("Unexpected released lease %+v (%v)", released, err)
+	}
+	if _, err := repo.Release(lease.ID, domain.LeaseReleased, now); !errors.Is(err, ErrLeaseEnded) {
+		t.Errorf("Expected ErrLeaseEnded releasing twice, got %v", err)
+	}
+	if _, err := repo.Release("missing", domain.LeaseReleased, now); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound releasing a missing lease, got %v", err)
+	}
+
+	// Released items can be claimed again
+	if err := repo.Create(&domain.Lease{Kind: domain.WorkTask, ItemID: "task-1", Agent: agent}, now, time.Minute); err != nil {
+		t.Errorf("Expected a released task to be claimable, got %v", err)
+	}
+}
@@ -0,0 +1,199 @@
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Errors returned for invalid list options
+var (
+	ErrInvalidSort   = errors.New("invalid sort")
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// MaxListLimit bounds the page size of list queries
+const MaxListLimit = 500
+
+// ListOptions sorts and pages a list query
+type ListOptions struct {
+	Sort   string // a sort field, prefixed with "-" for descending order
+	Limit  int    // page size, 0 for every row
+	Cursor string // NextCursor of the previous page
+}
+
+// listQuery builds a filtered, sorted and paged SELECT. Rows are ordered by
+// a sort key and then by ID, so a cursor holding both picks up exactly
+// where the previous page ended.
+type listQuery struct {
+	table   string
+	columns string
+	where   []string
+	args    []interface{}
+}
+
+// filter adds a condition on the rows
+func (q *listQuery) filter(condition string, args ...interface{}) {
+	q.where = append(q.where, condition)
+	q.args = append(q.args, args...)
+}
+
+// filterIn adds a condition that column is one of values
+func (q *listQuery) filterIn(column string, values []string) {
+	q.filter(column+" IN (?"+strings.Repeat(", ?", len(values)-1)+")", stringArgs(values)...)
+}
+
+// filterText adds a condition that any of the columns contains text
+func (q *listQuery) filterText(text string, columns ...string) {
+	pattern := "%" + likeEscaper.Replace(text) + "%"
+	conditions := make([]string, len(columns))
+	args := make([]interface{}, len(columns))
+	for i, column := range columns {
+		conditions[i] = column + ` LIKE ? ESCAPE '\'`
+		args[i] = pattern
+	}
+	q.filter("("+strings.Join(conditions, " OR ")+")", args...)
+}
+
+// likeEscaper escapes the LIKE wildcards in a search term
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// sortKey is what a list can be sorted by
+type sortKey struct {
+	expr   string // ordered and compared on
+	cursor string // selected for the cursor, if not expr itself
+}
+
+// timeSortKey sorts by a timestamp column. Timestamps are compared as
+// julian days, as older rows may have been stored with a local offset.
+func timeSortKey(column string) sortKey {
+	return sortKey{expr: "julianday(" + column + ")"}
+}
+
+// cursor is the decoded form of a page cursor
+type cursor struct {
+	Sort string      `json:"s"`
+	Key  interface{} `json:"k"`
+	ID   string      `json:"id"`
+}
+
+// build returns the SQL and arguments selecting a page of rows sorted by
+// opts.Sort, one of sortKeys or defaultSort if unset. The sort key and ID
+// are selected after the columns, for the cursor, and one row more than
+// the limit is asked for to tell whether there is a next page.
+func (q *listQuery) build(sortKeys map[string]sortKey, defaultSort string, opts ListOptions) (string, []interface{}, error) {
+	sort := opts.Sort
+	if sort == "" {
+		sort = defaultSort
+	}
+	field, desc := strings.TrimPrefix(sort, "-"), strings.HasPrefix(sort, "-")
+	key, ok := sortKeys[field]
+	if !ok {
+		return "", nil, fmt.Errorf("%w: %s", ErrInvalidSort, field)
+	}
+	if key.cursor == "" {
+		key.cursor = key.expr
+	}
+
+	where, args := append([]string{}, q.where...), append([]interface{}{}, q.args...)
+	if opts.Cursor != "" {
+		c, err := decodeCursor(opts.Cursor)
+		if err != nil || c.Sort != sort {
+			return "", nil, ErrInvalidCursor
+		}
+		op := ">"
+		if desc {
+			op = "<"
+		}
+		where = append(where, fmt.Sprintf("(%s %s ? OR (%s = ? AND id %s ?))", key.expr, op, key.expr, op))
+		args = append(args, c.Key, c.Key, c.ID)
+	}
+
+	direction := "ASC"
+	if desc {
+		direction = "DESC"
+	}
+	query := "SELECT " + q.columns + ", " + key.cursor + ", id FROM " + q.table
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += fmt.Sprintf(" ORDER BY %s %s, id %s", key.expr, direction, direction)
+	if opts.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, opts.Limit+1)
+	}
+	return query, args, nil
+}
+
+// pageKey is the sort key and ID of a row, scanned after its columns
+type pageKey struct {
+	key interface{}
+	id  string
+}
+
+// dest returns the scan destinations of the key
+func (k *pageKey) dest() []interface{} {
+	return []interface{}{&k.key, &k.id}
+}
+
+// nextCursor returns the cursor of the page after one whose rows had the
+// given keys, or "" if there are no more rows. Only the first opts.Limit
+// rows belong to the page; build asks for one more to tell.
+func nextCursor(opts ListOptions, defaultSort string, keys []pageKey) string {
+	if opts.Limit <= 0 || len(keys) <= opts.Limit {
+		return ""
+	}
+	sort := opts.Sort
+	if sort == "" {
+		sort = defaultSort
+	}
+	last := keys[opts.Limit-1]
+	key := last.key
+	if b, ok := key.([]byte); ok {
+		key = string(b)
+	}
+	data, err := json.Marshal(cursor{Sort: sort, Key: key, ID: last.id})
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor parses a cursor returned by nextCursor
+func decodeCursor(s string) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// dbTime formats a time for comparison with stored timestamps, which are
+// written both by the driver and by SQLite's CURRENT_TIMESTAMP. Compare with
+// julianday(), as in timeFilter, since rows written before timestamps were
+// stored in UTC carry a local offset.
+func dbTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04:05.000")
+}
+
+// timeFilter returns a condition comparing a timestamp column to a time,
+// with op one of <, <=, >, >=
+func timeFilter(column, op string) string {
+	return "julianday(" + column + ") " + op + " julianday(?)"
+}
+
+// stringArgs converts strings to query arguments
+func stringArgs(values []string) []interface{} {
+	args := make([]interface{}, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return args
+}
@@ -0,0 +1,192 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rand/cartographer/internal/domain"
+)
+
+func TestTaskRepositoryQuery(t *testing.T) {
+	db, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer db.Close()
+
+	project := &domain.Project{Name: "Query", Path: "/tmp/query"}
+	if err := NewProjectRepository(db).Create(project); err != nil {
+		t.Fatalf("failed to create project: %v", err)
+	}
+	board := &domain.Board{ProjectID: project.ID, Name: "Main"}
+	if err := NewBoardRepository(db).Create(board); err != nil {
+		t.Fatalf("failed to create board: %v", err)
+	}
+
+	repo := NewTaskRepository(db)
+	due := time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC)
+	priorities := []string{"low", "medium", "high", "urgent"}
+	for i := 0; i < 12; i++ {
+		task := &domain.Task{
+			ID:       fmt.Sprintf("t-%02d", i),
+			BoardID:  board.ID,
+			Title:    fmt.Sprintf("Task %d", i),
+			Status:   []string{"todo", "doing"}[i%2],
+			Priority: priorities[i%4],
+		}
+		if i%3 == 0 {
+			task.Labels = []string{"bug"}
+			task.Assignee = &domain.Assignee{Type: "human", ID: "u-1", Name: "Ada"}
+		}
+		if i < 4 {
+			d := due.AddDate(0, 0, i)
+			task.DueDate = &d
+		}
+		if i == 5 {
+			task.Title = "Fix 100%_done"
+		}
+		if err := repo.Create(task); err != nil {
+			t.Fatalf("failed to create task: %v", err)
+		}
+	}
+
+	ids := func(tasks []*domain.Task) []string {
+		var ids []string
+		for _, task := range tasks {
+			ids = append(ids, task.ID)
+		}
+		return ids
+	}
+
+	// Filters
+	tests := []struct {
+		name  string
+		query TaskQuery
+		want  []string
+	}{
+		{"status", TaskQuery{Status: []string{"doing"}, ListOptions: ListOptions{Sort: "-rank"}}, []string{"t-11", "t-09", "t-07", "t-05", "t-03", "t-01"}},
+		{"priority", TaskQuery{Priority: []string{"urgent", "high"}}, []string{"t-02", "t-03", "t-06", "t-07", "t-10", "t-11"}},
+		{"assignee", TaskQuery{Assignee: "Ada"}, []string{"t-00", "t-03", "t-06", "t-09"}},
+		{"label", TaskQuery{Label: "bug", Status: []string{"todo"}}, []string{"t-00", "t-06"}},
+		{"due before", TaskQuery{DueBefore: &[]time.Time{due.AddDate(0, 0, 2)}[0]}, []string{"t-00", "t-01"}},
+		{"text", TaskQuery{Text: "0%_"}, []string{"t-05"}},
+		{"text wildcard", TaskQuery{Text: "_"}, []string{"t-05"}},
+		{"due date sort", TaskQuery{Priority: []string{"high"}, ListOptions: ListOptions{Sort: "due_date"}}, []string{"t-02", "t-06", "t-10"}},
+	}
+	for _, tt := range tests {
+		tt.query.BoardID = board.ID
+		tasks, next, err := repo.Query(tt.query)
+		if err != nil {
+			t.Fatalf("%s: Query failed: %v", tt.name, err)
+		}
+		if got := ids(tasks); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+		if next != "" {
+			t.Errorf("%s: expected no next page, got %q", tt.name, next)
+		}
+	}
+
+	// Paging through any sort returns every task once, in the same order as
+	// a single query
+	for _, sort := range []string{"rank", "-priority", "due_date", "-updated_at", "status"} {
+		all, _, err := repo.Query(TaskQuery{BoardID: board.ID, ListOptions: ListOptions{Sort: sort}})
+		if err != nil {
+			t.Fatalf("%s: Query failed: %v", sort, err)
+		}
+
+		var paged []*domain.Task
+		opts := ListOptions{Sort: sort, Limit: 5}
+		for pages := 0; ; pages++ {
+			if pages > 3 {
+				t.Fatalf("%s: too many pages", sort)
+			}
+			page, next, err := repo.Query(TaskQuery{BoardID: board.ID, ListOptions: opts})
+			if err != nil {
+				t.Fatalf("%s: Query failed: %v", sort, err)
+			}
+			paged = append(paged, page...)
+			if next == "" {
+				break
+			}
+			opts.Cursor = next
+		}
+		if got, want := ids(paged), ids(all); len(want) != 12 || !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: expected pages %v, got %v", sort, want, got)
+		}
+	}
+
+	// A cursor only continues the sort it came from
+	_, next, err := repo.Query(TaskQuery{BoardID: board.ID, ListOptions: ListOptions{Limit: 2}})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if _, _, err := repo.Query(TaskQuery{BoardID: board.ID, ListOptions: ListOptions{Sort: "status", Cursor: next}}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("Expected ErrInvalidCursor, got %v", err)
+	}
+	if _, _, err := repo.Query(TaskQuery{BoardID: board.ID, ListOptions: ListOptions{Sort: "title; DROP TABLE tasks"}}); !errors.Is(err, ErrInvalidSort) {
+		t.Errorf("Expected ErrInvalidSort, got %v", err)
+	}
+}
+
+// TestTimeFiltersOutsideUTC filters by time on a host whose local time
+// isn't UTC, with a due date given in yet another zone
+func TestTimeFiltersOutsideUTC(t *testing.T) {
+	local := time.Local
+	time.Local = time.FixedZone("EST", -5*60*60)
+	defer func() { time.Local = local }()
+
+	db, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer db.Close()
+
+	projects := NewProjectRepository(db)
+	project := &domain.Project{Name: "Zones", Path: "/tmp/zones"}
+	if err := projects.Create(project); err != nil {
+		t.Fatalf("failed to create project: %v", err)
+	}
+	board := &domain.Board{ProjectID: project.ID, Name: "Main"}
+	if err := NewBoardRepository(db).Create(board); err != nil {
+		t.Fatalf("failed to create board: %v", err)
+	}
+	tasks := NewTaskRepository(db)
+	due := time.Date(2026, 1, 11, 2, 0, 0, 0, time.FixedZone("CET", 60*60)) // 01:00 UTC
+	if err := tasks.Create(&domain.Task{BoardID: board.ID, Title: "Zoned", Status: "todo", DueDate: &due}); err != nil {
+		t.Fatalf("failed to create task: %v", err)
+	}
+
+	at := func(d time.Duration) *time.Time {
+		t := time.Now().Add(d)
+		return &t
+	}
+	utc := func(hour, min int) *time.Time {
+		t := time.Date(2026, 1, 11, hour, min, 0, 0, time.UTC)
+		return &t
+	}
+	for _, tt := range []struct {
+		name string
+		q    TaskQuery
+		want int
+	}{
+		{"updated in the last minute", TaskQuery{UpdatedSince: at(-time.Minute)}, 1},
+		{"updated in the next minute", TaskQuery{UpdatedSince: at(time.Minute)}, 0},
+		{"due before 00:30 UTC", TaskQuery{DueBefore: utc(0, 30)}, 0},
+		{"due before 01:30 UTC", TaskQuery{DueBefore: utc(1, 30)}, 1},
+	} {
+		tt.q.BoardID = board.ID
+		found, _, err := tasks.Query(tt.q)
+		if err != nil || len(found) != tt.want {
+			t.Errorf("%s: expected %d tasks, got %d (%v)", tt.name, tt.want, len(found), err)
+		}
+	}
+
+	found, _, err := projects.Query(ProjectQuery{UpdatedSince: at(-time.Minute)})
+	if err != nil || len(found) != 1 {
+		t.Errorf("Expected the project updated in the last minute, got %d (%v)", len(found), err)
+	}
+}
@@ -0,0 +1,485 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrSchemaTooNew is returned when the database has migrations applied that
+// this binary does not know about (i.e. it was written by a newer build)
+var ErrSchemaTooNew = errors.New("database schema is newer than this binary")
+
+// Migration is a single numbered, forward-only schema change
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// migrations lists every schema change in the order it must be applied.
+// Append new entries with the next version number; never edit, reorder or
+// renumber a migration that has shipped, since existing databases have
+// already recorded it as applied.
+var migrations = []Migration{
+	{Version: 1, Name: "initial_schema", SQL: initialSchema},
+	{Version: 2, Name: "event_log", SQL: eventLogSchema},
+	{Version: 3, Name: "bead_sync_state", SQL: beadSyncSchema},
+	{Version: 4, Name: "api_tokens", SQL: apiTokensSchema},
+	{Version: 5, Name: "audit_log", SQL: auditLogSchema},
+	{Version: 6, Name: "revisions", SQL: revisionsSchema},
+	{Version: 7, Name: "task_ranks", SQL: taskRanksSchema},
+	{Version: 8, Name: "work_leases", SQL: workLeasesSchema},
+	{Version: 9, Name: "claude_sessions", SQL: claudeSessionsSchema},
+}
+
+// AppliedMigration records a migration that has been applied to the database
+type AppliedMigration struct {
+	Version   int       `json:"version"`
+	Name      string    `json:"name"`
+	AppliedAt time.Time `json:"applied_at"`
+}
+
+// MigrationReport describes the migration state of a database
+type MigrationReport struct {
+	CurrentVersion int                `json:"current_version"`
+	LatestVersion  int                `json:"latest_version"`
+	Applied        []AppliedMigration `json:"applied"`
+	Pending        []Migration        `json:"pending"`
+	// Unknown holds applied versions this binary has no migration for
+	Unknown []AppliedMigration `json:"unknown,omitempty"`
+}
+
+// UpToDate reports whether there is nothing left to apply
+func (r *MigrationReport) UpToDate() bool {
+	return len(r.Pending) == 0 && len(r.Unknown) == 0
+}
+
+// LatestSchemaVersion returns the highest migration version known to this binary
+func LatestSchemaVersion() int {
+	if len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].Version
+}
+
+// MigrationStatus reports applied and pending migrations without changing
+// the database. It is safe to call against a database of any version.
+func (db *DB) MigrationStatus() (*MigrationReport, error) {
+	applied, err := db.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &MigrationReport{
+		LatestVersion: LatestSchemaVersion(),
+		Applied:       []AppliedMigration{},
+		Pending:       []Migration{},
+	}
+
+	known := make(map[int]bool, len(migrations))
+	for _, m := range migrations {
+		known[m.Version] = true
+		if a, ok := applied[m.Version]; ok {
+			report.Applied = append(report.Applied, a)
+		} else {
+			report.Pending = append(report.Pending, m)
+		}
+	}
+
+	for version, a := range applied {
+		if version > report.CurrentVersion {
+			report.CurrentVersion = version
+		}
+		if !known[version] {
+			report.Unknown = append(report.Unknown, a)
+		}
+	}
+
+	return report, nil
+}
+
+// Migrate applies all pending migrations in version order, each in its own
+// transaction. It refuses to run against a database that has migrations
+// newer than this binary. It returns the migrations that were applied.
+func (db *DB) Migrate() ([]Migration, error) {
+	if _, err := db.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	report, err := db.MigrationStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	if report.CurrentVersion > report.LatestVersion || len(report.Unknown) > 0 {
+		return nil, fmt.Errorf("%w: database is at version %d, binary supports up to %d",
+			ErrSchemaTooNew, report.CurrentVersion, report.LatestVersion)
+	}
+
+	var applied []Migration
+	for _, m := range report.Pending {
+		if err := db.applyMigration(m); err != nil {
+			return applied, err
+		}
+		applied = append(applied, m)
+	}
+
+	if err := db.initSearchIndex(); err != nil {
+		return applied, err
+	}
+
+	return applied, nil
+}
+
+// applyMigration runs a single migration and records it atomically
+func (db *DB) applyMigration(m Migration) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin migration %d: %w", m.Version, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(m.SQL); err != nil {
+		return fmt.Errorf("migration %d (%s) failed: %w", m.Version, m.Name, err)
+	}
+
+	if _, err := tx.Exec(
+		`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+		m.Version, m.Name, time.Now().UTC(),
+	); err != nil {
+		return fmt.Errorf("failed to record migration %d: %w", m.Version, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %d: %w", m.Version, err)
+	}
+
+	return nil
+}
+
+// appliedMigrations returns applied migrations keyed by version. A database
+// without a schema_migrations table is treated as having none applied.
+func (db *DB) appliedMigrations() (map[int]AppliedMigration, error) {
+	applied := make(map[int]AppliedMigration)
+
+	var exists int
+	err := db.conn.QueryRow(
+		`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'`,
+	).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if exists == 0 {
+		return applied, nil
+	}
+
+	rows, err := db.conn.Query(`SELECT version, name, applied_at FROM schema_migrations ORDER BY version`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var a AppliedMigration
+		var appliedAt sql.NullTime
+		if err := rows.Scan(&a.Version, &a.Name, &appliedAt); err != nil {
+			return nil, err
+		}
+		if appliedAt.Valid {
+			a.AppliedAt = appliedAt.Time
+		}
+		applied[a.Version] = a
+	}
+
+	return applied, rows.Err()
+}
+
+// initialSchema is the schema that predates versioned migrations. Every
+// statement is idempotent so that databases created before the migration
+// runner existed adopt version 1 without changes.
+const initialSchema = `
+	-- Projects table
+	CREATE TABLE IF NOT EXISTS projects (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		description TEXT,
+		path TEXT NOT NULL,
+		type TEXT NOT NULL DEFAULT 'custom',
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		settings TEXT, -- JSON
+		metadata TEXT  -- JSON
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_projects_path ON projects(path);
+	CREATE INDEX IF NOT EXISTS idx_projects_type ON projects(type);
+	CREATE INDEX IF NOT EXISTS idx_projects_updated_at ON projects(updated_at DESC);
+
+	-- Boards table
+	CREATE TABLE IF NOT EXISTS boards (
+		id TEXT PRIMARY KEY,
+		project_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		description TEXT,
+		columns TEXT, -- JSON array of columns
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (project_id) REFERENCES projects(id) ON DELETE CASCADE
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_boards_project_id ON boards(project_id);
+	CREATE INDEX IF NOT EXISTS idx_boards_updated_at ON boards(updated_at DESC);
+
+	-- Tasks table
+	CREATE TABLE IF NOT EXISTS tasks (
+		id TEXT PRIMARY KEY,
+		board_id TEXT NOT NULL,
+		title TEXT NOT NULL,
+		description TEXT,
+		status TEXT NOT NULL DEFAULT 'open',
+		priority TEXT NOT NULL DEFAULT 'medium',
+		assignee TEXT, -- JSON
+		labels TEXT,   -- JSON array
+		due_date DATETIME,
+		estimate REAL,
+		actual REAL,
+		dependencies TEXT, -- JSON array of task IDs
+		blocks TEXT,       -- JSON array of task IDs
+		related TEXT,      -- JSON array of task IDs
+		linked_items TEXT, -- JSON array
+		checklist TEXT,    -- JSON array
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		created_by TEXT,   -- JSON
+		activity TEXT,     -- JSON array
+		FOREIGN KEY (board_id) REFERENCES boards(id) ON DELETE CASCADE
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_tasks_board_id ON tasks(board_id);
+	CREATE INDEX IF NOT EXISTS idx_tasks_status ON tasks(status);
+	CREATE INDEX IF NOT EXISTS idx_tasks_priority ON tasks(priority);
+	CREATE INDEX IF NOT EXISTS idx_tasks_due_date ON tasks(due_date);
+	CREATE INDEX IF NOT EXISTS idx_tasks_updated_at ON tasks(updated_at DESC);
+
+	-- Documents table
+	CREATE TABLE IF NOT EXISTS documents (
+		id TEXT PRIMARY KEY,
+		project_id TEXT NOT NULL,
+		title TEXT NOT NULL,
+		content TEXT,
+		path TEXT NOT NULL,
+		tags TEXT, -- JSON array
+		linked_from TEXT, -- JSON array of doc IDs
+		links_to TEXT,    -- JSON array of doc IDs
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		versions TEXT, -- JSON array
+		FOREIGN KEY (project_id) REFERENCES projects(id) ON DELETE CASCADE
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_documents_project_id ON documents(project_id);
+	CREATE INDEX IF NOT EXISTS idx_documents_path ON documents(path);
+	CREATE INDEX IF NOT EXISTS idx_documents_updated_at ON documents(updated_at DESC);
+	CREATE INDEX IF NOT EXISTS idx_documents_title ON documents(title);
+
+	-- Diagrams table
+	CREATE TABLE IF NOT EXISTS diagrams (
+		id TEXT PRIMARY KEY,
+		project_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		type TEXT NOT NULL,
+		content TEXT NOT NULL,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		versions TEXT, -- JSON array
+		FOREIGN KEY (project_id) REFERENCES projects(id) ON DELETE CASCADE
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_diagrams_project_id ON diagrams(project_id);
+	CREATE INDEX IF NOT EXISTS idx_diagrams_type ON diagrams(type);
+	CREATE INDEX IF NOT EXISTS idx_diagrams_updated_at ON diagrams(updated_at DESC);
+
+	-- Update triggers for updated_at
+	CREATE TRIGGER IF NOT EXISTS update_projects_timestamp
+	AFTER UPDATE ON projects
+	BEGIN
+		UPDATE projects SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS update_boards_timestamp
+	AFTER UPDATE ON boards
+	BEGIN
+		UPDATE boards SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS update_tasks_timestamp
+	AFTER UPDATE ON tasks
+	BEGIN
+		UPDATE tasks SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS update_documents_timestamp
+	AFTER UPDATE ON documents
+	BEGIN
+		UPDATE documents SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS update_diagrams_timestamp
+	AFTER UPDATE ON diagrams
+	BEGIN
+		UPDATE diagrams SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+	END;
+`
+
+// eventLogSchema stores recent real-time events so WebSocket clients can
+// resume after reconnecting, even across server restarts
+const eventLogSchema = `
+	CREATE TABLE event_log (
+		seq INTEGER PRIMARY KEY,
+		type TEXT NOT NULL,
+		resources TEXT, -- JSON array
+		data TEXT NOT NULL, -- encoded message
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	-- Identifies the sequence numbering in event_log; clients resuming
+	-- against a different stream must resync
+	CREATE TABLE event_stream (
+		id TEXT PRIMARY KEY,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+`
+
+// beadSyncSchema links beads to the tasks they were imported as, with the
+// field values last agreed on by both sides
+const beadSyncSchema = `
+	CREATE TABLE bead_sync_state (
+		bead_id TEXT PRIMARY KEY,
+		task_id TEXT NOT NULL UNIQUE,
+		base TEXT NOT NULL, -- JSON object
+		conflict TEXT, -- JSON object, NULL when in sync
+		synced_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE
+	);
+`
+
+// apiTokensSchema stores bearer tokens by the SHA-256 hash of their secret
+const apiTokensSchema = `
+	CREATE TABLE api_tokens (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		user TEXT NOT NULL, -- JSON
+		scope TEXT NOT NULL,
+		project_ids TEXT NOT NULL DEFAULT '[]', -- JSON array, empty for every project
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		expires_at DATETIME,
+		last_used_at DATETIME,
+		revoked_at DATETIME
+	);
+`
+
+// auditLogSchema records every mutation made through the API. Entries
+// outlive the entities they describe, so there are no foreign keys.
+const auditLogSchema = `
+	CREATE TABLE audit_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		entity TEXT NOT NULL,
+		entity_id TEXT NOT NULL,
+		project_id TEXT,
+		action TEXT NOT NULL,
+		user TEXT, -- JSON
+		changes TEXT, -- JSON object
+		created_at DATETIME NOT NULL
+	);
+
+	CREATE INDEX idx_audit_log_entity ON audit_log(entity, entity_id);
+	CREATE INDEX idx_audit_log_created_at ON audit_log(created_at);
+`
+
+// revisionsSchema adds the optimistic concurrency revision, bumped on every
+// write, to each editable entity
+const revisionsSchema = `
+	ALTER TABLE projects ADD COLUMN revision INTEGER NOT NULL DEFAULT 1;
+	ALTER TABLE boards ADD COLUMN revision INTEGER NOT NULL DEFAULT 1;
+	ALTER TABLE tasks ADD COLUMN revision INTEGER NOT NULL DEFAULT 1;
+	ALTER TABLE documents ADD COLUMN revision INTEGER NOT NULL DEFAULT 1;
+	ALTER TABLE diagrams ADD COLUMN revision INTEGER NOT NULL DEFAULT 1;
+`
+
+// taskRanksSchema orders tasks within their column by a fractional rank,
+// numbering existing tasks oldest first, and adds the board's WIP limit
+// policy
+const taskRanksSchema = `
+	ALTER TABLE tasks ADD COLUMN rank REAL NOT NULL DEFAULT 0;
+	UPDATE tasks SET rank = (
+		SELECT COUNT(*) FROM tasks AS t
+		WHERE t.board_id = tasks.board_id AND t.status = tasks.status
+		  AND (t.created_at < tasks.created_at OR (t.created_at = tasks.created_at AND t.id <= tasks.id))
+	);
+	CREATE INDEX idx_tasks_rank ON tasks(board_id, status, rank);
+
+	ALTER TABLE boards ADD COLUMN wip_policy TEXT NOT NULL DEFAULT '';
+`
+
+// workLeasesSchema records agents' claims on tasks and beads issues. An
+// item can have only one unreleased lease; expired leases are released by
+// a sweep, so the index also rules out claiming them twice before it runs.
+const workLeasesSchema = `
+	CREATE TABLE work_leases (
+		id TEXT PRIMARY KEY,
+		kind TEXT NOT NULL,
+		item_id TEXT NOT NULL,
+		board_id TEXT,
+		agent TEXT NOT NULL, -- JSON
+		claimed_at DATETIME NOT NULL,
+		heartbeat_at DATETIME NOT NULL,
+		expires_at DATETIME NOT NULL,
+		released_at DATETIME,
+		release_reason TEXT
+	);
+
+	CREATE UNIQUE INDEX idx_work_leases_item ON work_leases(kind, item_id) WHERE released_at IS NULL;
+	CREATE INDEX idx_work_leases_expires_at ON work_leases(expires_at) WHERE released_at IS NULL;
+`
+
+// claudeSessionsSchema records agents' working sessions and what happened in
+// them, in order
+const claudeSessionsSchema = `
+	CREATE TABLE claude_sessions (
+		id TEXT PRIMARY KEY,
+		project_id TEXT NOT NULL,
+		board_id TEXT,
+		agent TEXT NOT NULL, -- JSON
+		title TEXT,
+		previous_id TEXT,
+		started_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL,
+		event_count INTEGER NOT NULL DEFAULT 0,
+		FOREIGN KEY (project_id) REFERENCES projects(id) ON DELETE CASCADE
+	);
+
+	CREATE INDEX idx_claude_sessions_project ON claude_sessions(project_id, started_at);
+
+	CREATE TABLE claude_session_events (
+		session_id TEXT NOT NULL,
+		seq INTEGER NOT NULL,
+		type TEXT NOT NULL,
+		tool TEXT,
+		text TEXT,
+		files TEXT, -- JSON array
+		tags TEXT, -- JSON array
+		links TEXT, -- JSON array of linked items
+		created_at DATETIME NOT NULL,
+		PRIMARY KEY (session_id, seq),
+		FOREIGN KEY (session_id) REFERENCES claude_sessions(id) ON DELETE CASCADE
+	);
+`
@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMigrateFreshDatabase(t *testing.T) {
+	db, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer db.Close()
+
+	report, err := db.MigrationStatus()
+	if err != nil {
+		t.Fatalf("MigrationStatus failed: %v", err)
+	}
+
+	if report.CurrentVersion != LatestSchemaVersion() {
+		t.Errorf("Expected version %d, got %d", LatestSchemaVersion(), report.CurrentVersion)
+	}
+	if !report.UpToDate() {
+		t.Errorf("Expected no pending migrations, got %d", len(report.Pending))
+	}
+
+	// Running again must be a no-op
+	applied, err := db.Migrate()
+	if err != nil {
+		t.Fatalf("second Migrate failed: %v", err)
+	}
+	if len(applied) != 0 {
+		t.Errorf("Expected no migrations on second run, got %d", len(applied))
+	}
+}
+
+func TestMigrateAdoptsLegacyDatabase(t *testing.T) {
+	dir := t.TempDir()
+
+	// Simulate a database created before the migration runner existed
+	db, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if _, err := db.Conn().Exec(initialSchema); err != nil {
+		t.Fatalf("failed to create legacy schema: %v", err)
+	}
+	if _, err := db.Conn().Exec(
+		`INSERT INTO projects (id, name, description, path, settings, metadata)
+		 VALUES ('p1', 'Legacy', '', '/tmp/legacy', 'null', 'null')`,
+	); err != nil {
+		t.Fatalf("failed to insert legacy row: %v", err)
+	}
+
+	report, err := db.MigrationStatus()
+	if err != nil {
+		t.Fatalf("MigrationStatus failed: %v", err)
+	}
+	if report.CurrentVersion != 0 || len(report.Pending) != len(migrations) {
+		t.Errorf("Expected all migrations pending, got current=%d pending=%d",
+			report.CurrentVersion, len(report.Pending))
+	}
+	db.Close()
+
+	db, err = New(dir)
+	if err != nil {
+		t.Fatalf("New on legacy database failed: %v", err)
+	}
+	defer db.Close()
+
+	project, err := NewProjectRepository(db).GetByID("p1")
+	if err != nil {
+		t.Fatalf("legacy row lost after migration: %v", err)
+	}
+	if project.Name != "Legacy" {
+		t.Errorf("Expected project name Legacy, got %s", project.Name)
+	}
+}
+
+func TestMigrateRefusesNewerSchema(t *testing.T) {
+	dir := t.TempDir()
+
+	db, err := New(dir)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if _, err := db.Conn().Exec(
+		`INSERT INTO schema_migrations (version, name) VALUES (?, 'from_the_future')`,
+		LatestSchemaVersion()+1,
+	); err != nil {
+		t.Fatalf("failed to record future migration: %v", err)
+	}
+	db.Close()
+
+	_, err = New(dir)
+	if !errors.Is(err, ErrSchemaTooNew) {
+		t.Fatalf("Expected ErrSchemaTooNew, got %v", err)
+	}
+
+	// Status must still be readable so the operator can see what happened
+	db, err = Open(dir)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	report, err := db.MigrationStatus()
+	if err != nil {
+		t.Fatalf("MigrationStatus failed: %v", err)
+	}
+	if len(report.Unknown) != 1 {
+		t.Errorf("Expected 1 unknown migration, got %d", len(report.Unknown))
+	}
+}
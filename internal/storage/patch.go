@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// columnValue returns the value to store for a field, or an error if the
+// field has no updatable column
+type columnValue func(field string) (interface{}, error)
+
+// updateColumns sets only the columns named by fields, plus updated_at, on
+// the row of table with the given ID and bumps its revision. Field names are
+// the JSON names, which match the column names. revision holds the expected
+// revision, 0 for any, and is set to the new one.
+func updateColumns(q querier, table, id string, fields []string, value columnValue, updatedAt time.Time, revision *int64) error {
+	assignments := make([]string, 0, len(fields)+2)
+	args := make([]interface{}, 0, len(fields)+4)
+	for _, field := range fields {
+		v, err := value(field)
+		if err != nil {
+			return err
+		}
+		if e, ok := v.(columnExpr); ok {
+			assignments = append(assignments, field+" = "+e.expr)
+			args = append(args, e.args...)
+			continue
+		}
+		assignments = append(assignments, field+" = ?")
+		args = append(args, v)
+	}
+	assignments = append(assignments, "updated_at = ?", "revision = revision + 1")
+	args = append(args, updatedAt, id, *revision, *revision)
+
+	query := "UPDATE " + table + " SET " + strings.Join(assignments, ", ") +
+		" WHERE id = ?" + revisionCondition + " RETURNING revision"
+	return scanRevision(q, q.QueryRow(query, args...), table, strings.TrimSuffix(table, "s"), id, revision)
+}
+
+// columnExpr is a column value computed in SQL from the stored row
+type columnExpr struct {
+	expr string
+	args []interface{}
+}
+
+// jsonAppend returns the value of a JSON array column with values appended
+// to what is stored, so that concurrent appends don't overwrite each other
+func jsonAppend(column string, values ...interface{}) (columnExpr, error) {
+	expr := "json_insert(CASE json_type(" + column + ") WHEN 'array' THEN " + column + " ELSE '[]' END"
+	args := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return columnExpr{}, err
+		}
+		expr += ", '$[#]', json(?)"
+		args = append(args, string(data))
+	}
+	return columnExpr{expr: expr + ")", args: args}, nil
+}
+
+// jsonColumn encodes a value stored as JSON text
+func jsonColumn(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// hasField reports whether fields includes field
+func hasField(fields []string, field string) bool {
+	for _, f := range fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldError reports a field that can't be updated
+func fieldError(entity, field string) error {
+	return fmt.Errorf("%s field %s can't be updated", entity, field)
+}
@@ -26,9 +26,10 @@ func (r *ProjectRepository) Create(project *domain.Project) error {
 		project.ID = uuid.New().String()
 	}
 
-	now := time.Now()
+	now := time.Now().UTC()
 	project.CreatedAt = now
 	project.UpdatedAt = now
+	project.Revision = 1
 
 	settings, err := json.Marshal(project.Settings)
 	if err != nil {
@@ -41,8 +42,8 @@ func (r *ProjectRepository) Create(project *domain.Project) error {
 	}
 
 	query := `
-		INSERT INTO projects (id, name, description, path, type, created_at, updated_at, settings, metadata)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO projects (id, name, description, path, type, created_at, updated_at, settings, metadata, revision)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
 	_, err = r.db.Conn().Exec(query,
@@ -55,73 +56,49 @@ func (r *ProjectRepository) Create(project *domain.Project) error {
 		project.UpdatedAt,
 		string(settings),
 		string(metadata),
+		project.Revision,
 	)
 
 	return err
 }
 
+// projectColumns are the columns scanned by scanProject
+const projectColumns = `id, name, description, path, type, created_at, updated_at, settings, metadata, revision`
+
 // GetByID retrieves a project by ID
 func (r *ProjectRepository) GetByID(id string) (*domain.Project, error) {
-	query := `
-		SELECT id, name, description, path, type, created_at, updated_at, settings, metadata
-		FROM projects
-		WHERE id = ?
-	`
-
-	project := &domain.Project{}
-	var settingsJSON, metadataJSON string
-
-	err := r.db.Conn().QueryRow(query, id).Scan(
-		&project.ID,
-		&project.Name,
-		&project.Description,
-		&project.Path,
-		&project.Type,
-		&project.CreatedAt,
-		&project.UpdatedAt,
-		&settingsJSON,
-		&metadataJSON,
-	)
+	query := `SELECT ` + projectColumns + ` FROM projects WHERE id = ?`
 
+	project, err := scanProject(r.db.Conn().QueryRow(query, id))
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("project not found: %s", id)
+		return nil, fmt.Errorf("project %w: %s", ErrNotFound, id)
 	}
 	if err != nil {
 		return nil, err
 	}
-
-	// Unmarshal JSON fields
-	if settingsJSON != "" {
-		var settings domain.ProjectSettings
-		if err := json.Unmarshal([]byte(settingsJSON), &settings); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal settings: %w", err)
-		}
-		project.Settings = &settings
-	}
-
-	if metadataJSON != "" {
-		var metadata domain.ProjectMetadata
-		if err := json.Unmarshal([]byte(metadataJSON), &metadata); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
-		}
-		project.Metadata = &metadata
-	}
-
 	return project, nil
 }
 
 // GetByPath retrieves a project by its path
 func (r *ProjectRepository) GetByPath(path string) (*domain.Project, error) {
-	query := `
-		SELECT id, name, description, path, type, created_at, updated_at, settings, metadata
-		FROM projects
-		WHERE path = ?
-	`
+	query := `SELECT ` + projectColumns + ` FROM projects WHERE path = ?`
 
+	project, err := scanProject(r.db.Conn().QueryRow(query, path))
+	if err == sql.ErrNoRows {
+		return nil, nil // Not found, but not an error
+	}
+	if err != nil {
+		return nil, err
+	}
+	return project, nil
+}
+
+// scanProject scans a row of projectColumns, followed by extra
+func scanProject(row rowScanner, extra ...interface{}) (*domain.Project, error) {
 	project := &domain.Project{}
 	var settingsJSON, metadataJSON string
 
-	err := r.db.Conn().QueryRow(query, path).Scan(
+	err := row.Scan(append([]interface{}{
 		&project.ID,
 		&project.Name,
 		&project.Description,
@@ -131,11 +108,8 @@ func (r *ProjectRepository) GetByPath(path string) (*domain.Project, error) {
 		&project.UpdatedAt,
 		&settingsJSON,
 		&metadataJSON,
-	)
-
-	if err == sql.ErrNoRows {
-		return nil, nil // Not found, but not an error
-	}
+		&project.Revision,
+	}, extra...)...)
 	if err != nil {
 		return nil, err
 	}
@@ -162,64 +136,87 @@ func (r *ProjectRepository) GetByPath(path string) (*domain.Project, error) {
 
 // List retrieves all projects
 func (r *ProjectRepository) List() ([]*domain.Project, error) {
-	query := `
-		SELECT id, name, description, path, type, created_at, updated_at, settings, metadata
-		FROM projects
-		ORDER BY updated_at DESC
-	`
+	projects, _, err := r.Query(ProjectQuery{})
+	return projects, err
+}
+
+// ProjectQuery filters, sorts and pages projects. Empty fields match every
+// project.
+type ProjectQuery struct {
+	IDs          []string // restricts the projects to these
+	Type         string
+	UpdatedSince *time.Time
+	Text         string // in the name, description or path
+	ListOptions
+}
+
+// DefaultProjectSort is the order of projects when a query doesn't sort
+// them: most recently updated first
+const DefaultProjectSort = "-updated_at"
+
+// projectSortKeys are the fields projects can be sorted by
+var projectSortKeys = map[string]sortKey{
+	"name":       {expr: "name"},
+	"path":       {expr: "path"},
+	"type":       {expr: "type"},
+	"created_at": timeSortKey("created_at"),
+	"updated_at": timeSortKey("updated_at"),
+}
 
-	rows, err := r.db.Conn().Query(query)
+// Query retrieves the projects matching q and, if q.Limit cuts them short,
+// the cursor of the next page
+func (r *ProjectRepository) Query(q ProjectQuery) ([]*domain.Project, string, error) {
+	lq := &listQuery{table: "projects", columns: projectColumns}
+	if len(q.IDs) > 0 {
+		lq.filterIn("id", q.IDs)
+	}
+	if q.Type != "" {
+		lq.filter("type = ?", q.Type)
+	}
+	if q.UpdatedSince != nil {
+		lq.filter(timeFilter("updated_at", ">="), dbTime(*q.UpdatedSince))
+	}
+	if q.Text != "" {
+		lq.filterText(q.Text, "name", "description", "path")
+	}
+
+	query, args, err := lq.build(projectSortKeys, DefaultProjectSort, q.ListOptions)
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+	rows, err := r.db.Conn().Query(query, args...)
+	if err != nil {
+		return nil, "", err
 	}
 	defer rows.Close()
 
 	var projects []*domain.Project
+	var keys []pageKey
 	for rows.Next() {
-		project := &domain.Project{}
-		var settingsJSON, metadataJSON string
-
-		err := rows.Scan(
-			&project.ID,
-			&project.Name,
-			&project.Description,
-			&project.Path,
-			&project.Type,
-			&project.CreatedAt,
-			&project.UpdatedAt,
-			&settingsJSON,
-			&metadataJSON,
-		)
+		var key pageKey
+		project, err := scanProject(rows, key.dest()...)
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
-
-		// Unmarshal JSON fields
-		if settingsJSON != "" {
-			var settings domain.ProjectSettings
-			if err := json.Unmarshal([]byte(settingsJSON), &settings); err != nil {
-				return nil, fmt.Errorf("failed to unmarshal settings: %w", err)
-			}
-			project.Settings = &settings
-		}
-
-		if metadataJSON != "" {
-			var metadata domain.ProjectMetadata
-			if err := json.Unmarshal([]byte(metadataJSON), &metadata); err != nil {
-				return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
-			}
-			project.Metadata = &metadata
-		}
-
 		projects = append(projects, project)
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
 	}
 
-	return projects, rows.Err()
+	next := nextCursor(q.ListOptions, DefaultProjectSort, keys)
+	if next != "" {
+		projects = projects[:q.Limit]
+	}
+	return projects, next, nil
 }
 
-// Update updates an existing project
+// Update updates an existing project and bumps its revision. If
+// project.Revision is set, the update only applies at that revision and
+// otherwise fails with ErrRevisionConflict.
 func (r *ProjectRepository) Update(project *domain.Project) error {
-	project.UpdatedAt = time.Now()
+	project.UpdatedAt = time.Now().UTC()
 
 	settings, err := json.Marshal(project.Settings)
 	if err != nil {
@@ -233,32 +230,48 @@ func (r *ProjectRepository) Update(project *domain.Project) error {
 
 	query := `
 		UPDATE projects
-		SET name = ?, description = ?, path = ?, type = ?, settings = ?, metadata = ?
-		WHERE id = ?
+		SET name = ?, description = ?, path = ?, type = ?, settings = ?, metadata = ?,
+			updated_at = ?, revision = revision + 1
+		WHERE id = ?` + revisionCondition + `
+		RETURNING revision
 	`
 
-	result, err := r.db.Conn().Exec(query,
+	row := r.db.Conn().QueryRow(query,
 		project.Name,
 		project.Description,
 		project.Path,
 		project.Type,
 		string(settings),
 		string(metadata),
+		project.UpdatedAt,
 		project.ID,
+		project.Revision,
+		project.Revision,
 	)
-	if err != nil {
-		return err
-	}
-
-	rows, err := result.RowsAffected()
-	if err != nil {
-		return err
-	}
-	if rows == 0 {
-		return fmt.Errorf("project not found: %s", project.ID)
-	}
+	return scanRevision(r.db.Conn(), row, "projects", "project", project.ID, &project.Revision)
+}
 
-	return nil
+// UpdateFields writes only the named fields of a project. Revisions work as
+// for Update.
+func (r *ProjectRepository) UpdateFields(project *domain.Project, fields []string) error {
+	project.UpdatedAt = time.Now().UTC()
+	return updateColumns(r.db.Conn(), "projects", project.ID, fields, func(field string) (interface{}, error) {
+		switch field {
+		case "name":
+			return project.Name, nil
+		case "description":
+			return project.Description, nil
+		case "path":
+			return project.Path, nil
+		case "type":
+			return project.Type, nil
+		case "settings":
+			return jsonColumn(project.Settings)
+		case "metadata":
+			return jsonColumn(project.Metadata)
+		}
+		return nil, fieldError("project", field)
+	}, project.UpdatedAt, &project.Revision)
 }
 
 // Delete deletes a project by ID
@@ -275,7 +288,7 @@ func (r *ProjectRepository) Delete(id string) error {
 		return err
 	}
 	if rows == 0 {
-		return fmt.Errorf("project not found: %s", id)
+		return fmt.Errorf("project %w: %s", ErrNotFound, id)
 	}
 
 	return nil
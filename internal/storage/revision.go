@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrRevisionConflict is returned when a write expects a different revision
+// than the one stored, meaning someone else changed the row first
+var ErrRevisionConflict = errors.New("revision conflict")
+
+// revisionCondition is appended to the WHERE clause of an update so that it
+// only applies at the expected revision. Its arguments are the expected
+// revision twice; 0 matches any revision.
+const revisionCondition = " AND (? = 0 OR revision = ?)"
+
+// scanRevision reads the new revision returned by a conditional update of
+// the row with the given ID, telling a missing row apart from a conflict
+func scanRevision(q querier, row *sql.Row, table, entity, id string, revision *int64) error {
+	err := row.Scan(revision)
+	if err != sql.ErrNoRows {
+		return err
+	}
+
+	var current int64
+	err = q.QueryRow("SELECT revision FROM "+table+" WHERE id = ?", id).Scan(&current)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("%s %w: %s", entity, ErrNotFound, id)
+	}
+	if err != nil {
+		return err
+	}
+	return ErrRevisionConflict
+}
@@ -0,0 +1,385 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rand/cartographer/internal/domain"
+)
+
+// snippetWidth is the approximate snippet length in characters (fallback)
+// and tokens (FTS5) returned with each hit
+const (
+	snippetWidth  = 160
+	snippetTokens = 24
+)
+
+// searchIndexSchema creates FTS5 indexes over tasks, documents and diagrams
+// using external content tables, so the text is stored once and the indexes
+// are kept in sync by triggers. The UPDATE triggers are limited to indexed
+// columns so the updated_at triggers don't cause needless reindexing.
+const searchIndexSchema = `
+	CREATE VIRTUAL TABLE IF NOT EXISTS tasks_fts USING fts5(
+		title, description, labels,
+		content='tasks', content_rowid='rowid', tokenize='porter unicode61'
+	);
+
+	CREATE TRIGGER IF NOT EXISTS tasks_fts_insert AFTER INSERT ON tasks BEGIN
+		INSERT INTO tasks_fts(rowid, title, description, labels)
+		VALUES (new.rowid, new.title, new.description, new.labels);
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS tasks_fts_delete AFTER DELETE ON tasks BEGIN
+		INSERT INTO tasks_fts(tasks_fts, rowid, title, description, labels)
+		VALUES ('delete', old.rowid, old.title, old.description, old.labels);
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS tasks_fts_update AFTER UPDATE OF title, description, labels ON tasks BEGIN
+		INSERT INTO tasks_fts(tasks_fts, rowid, title, description, labels)
+		VALUES ('delete', old.rowid, old.title, old.description, old.labels);
+		INSERT INTO tasks_fts(rowid, title, description, labels)
+		VALUES (new.rowid, new.title, new.description, new.labels);
+	END;
+
+	CREATE VIRTUAL TABLE IF NOT EXISTS documents_fts USING fts5(
+		title, content, tags,
+		content='documents', content_rowid='rowid', tokenize='porter unicode61'
+	);
+
+	CREATE TRIGGER IF NOT EXISTS documents_fts_insert AFTER INSERT ON documents BEGIN
+		INSERT INTO documents_fts(rowid, title, content, tags)
+		VALUES (new.rowid, new.title, new.content, new.tags);
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS documents_fts_delete AFTER DELETE ON documents BEGIN
+		INSERT INTO documents_fts(documents_fts, rowid, title, content, tags)
+		VALUES ('delete', old.rowid, old.title, old.content, old.tags);
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS documents_fts_update AFTER UPDATE OF title, content, tags ON documents BEGIN
+		INSERT INTO documents_fts(documents_fts, rowid, title, content, tags)
+		VALUES ('delete', old.rowid, old.title, old.content, old.tags);
+		INSERT INTO documents_fts(rowid, title, content, tags)
+		VALUES (new.rowid, new.title, new.content, new.tags);
+	END;
+
+	CREATE VIRTUAL TABLE IF NOT EXISTS diagrams_fts USING fts5(
+		name, content,
+		content='diagrams', content_rowid='rowid', tokenize='porter unicode61'
+	);
+
+	CREATE TRIGGER IF NOT EXISTS diagrams_fts_insert AFTER INSERT ON diagrams BEGIN
+		INSERT INTO diagrams_fts(rowid, name, content)
+		VALUES (new.rowid, new.name, new.content);
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS diagrams_fts_delete AFTER DELETE ON diagrams BEGIN
+		INSERT INTO diagrams_fts(diagrams_fts, rowid, name, content)
+		VALUES ('delete', old.rowid, old.name, old.content);
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS diagrams_fts_update AFTER UPDATE OF name, content ON diagrams BEGIN
+		INSERT INTO diagrams_fts(diagrams_fts, rowid, name, content)
+		VALUES ('delete', old.rowid, old.name, old.content);
+		INSERT INTO diagrams_fts(rowid, name, content)
+		VALUES (new.rowid, new.name, new.content);
+	END;
+`
+
+// searchIndexTriggers are the triggers searchIndexSchema creates
+var searchIndexTriggers = []string{
+	"tasks_fts_insert", "tasks_fts_delete", "tasks_fts_update",
+	"documents_fts_insert", "documents_fts_delete", "documents_fts_update",
+	"diagrams_fts_insert", "diagrams_fts_delete", "diagrams_fts_update",
+}
+
+// initSearchIndex installs the FTS5 indexes if the SQLite driver was built
+// with FTS5 (go build -tags sqlite_fts5). This is deliberately not a numbered
+// migration: whether it can run depends on the build, not the schema version,
+// and it must be picked up the first time an FTS5-enabled binary starts.
+//
+// Without FTS5, the triggers an FTS5 build installed are dropped, as every
+// write they fire on would fail with "no such module: fts5". The index
+// tables can't be dropped without the module; they are left unused and
+// rebuilt when an FTS5 build reinstalls the triggers.
+func (db *DB) initSearchIndex() error {
+	var enabled int
+	if err := db.conn.QueryRow(`SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&enabled); err != nil {
+		return fmt.Errorf("failed to detect FTS5 support: %w", err)
+	}
+	if enabled == 0 {
+		db.fts = false
+		for _, trigger := range searchIndexTriggers {
+			if _, err := db.conn.Exec(`DROP TRIGGER IF EXISTS ` + trigger); err != nil {
+				return fmt.Errorf("failed to drop search index trigger %s: %w", trigger, err)
+			}
+		}
+		return nil
+	}
+
+	// Rows written while the triggers were missing aren't indexed
+	var existing int
+	if err := db.conn.QueryRow(
+		`SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name IN (?`+strings.Repeat(", ?", len(searchIndexTriggers)-1)+`)`,
+		stringArgs(searchIndexTriggers)...,
+	).Scan(&existing); err != nil {
+		return err
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(searchIndexSchema); err != nil {
+		return fmt.Errorf("failed to create search index: %w", err)
+	}
+
+	// Index rows written before the index existed
+	if existing < len(searchIndexTriggers) {
+		for _, table := range []string{"tasks_fts", "documents_fts", "diagrams_fts"} {
+			if _, err := tx.Exec(fmt.Sprintf(`INSERT INTO %s(%s) VALUES ('rebuild')`, table, table)); err != nil {
+				return fmt.Errorf("failed to rebuild %s: %w", table, err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	db.fts = true
+	return nil
+}
+
+// FullTextSearch reports whether FTS5 indexes are available
+func (db *DB) FullTextSearch() bool {
+	return db.fts
+}
+
+// SearchOptions narrows a search
+type SearchOptions struct {
+	Types     []string // task, document, diagram; empty means all
+	ProjectID string
+	Limit     int
+}
+
+// SearchRepository runs ranked full-text queries over tasks, documents and diagrams
+type SearchRepository struct {
+	db *DB
+}
+
+// NewSearchRepository creates a new search repository
+func NewSearchRepository(db *DB) *SearchRepository {
+	return &SearchRepository{db: db}
+}
+
+// Search returns hits across the requested entity types, best first. With
+// FTS5 it ranks by bm25; otherwise it falls back to substring matching
+// ranked by the number of occurrences.
+func (r *SearchRepository) Search(query string, opts SearchOptions) ([]domain.SearchHit, error) {
+	terms := domain.SearchTerms(query)
+	if len(terms) == 0 {
+		return []domain.SearchHit{}, nil
+	}
+	if opts.Limit <= 0 {
+		opts.Limit = 20
+	}
+
+	wanted := make(map[string]bool)
+	for _, t := range opts.Types {
+		wanted[t] = true
+	}
+	include := func(t string) bool { return len(wanted) == 0 || wanted[t] }
+
+	var hits []domain.SearchHit
+	for _, source := range []struct {
+		entity string
+		fts    string
+		like   string
+	}{
+		{domain.SearchTypeTask, taskFTSQuery, taskLikeQuery},
+		{domain.SearchTypeDocument, documentFTSQuery, documentLikeQuery},
+		{domain.SearchTypeDiagram, diagramFTSQuery, diagramLikeQuery},
+	} {
+		if !include(source.entity) {
+			continue
+		}
+
+		var found []domain.SearchHit
+		var err error
+		if r.db.fts {
+			found, err = r.searchFTS(source.entity, source.fts, terms, opts)
+		} else {
+			found, err = r.searchLike(source.entity, source.like, terms, opts)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to search %ss: %w", source.entity, err)
+		}
+		hits = append(hits, found...)
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].Score > hits[j].Score })
+	if len(hits) > opts.Limit {
+		hits = hits[:opts.Limit]
+	}
+	if hits == nil {
+		hits = []domain.SearchHit{}
+	}
+
+	return hits, nil
+}
+
+// Each FTS query selects: id, project_id, board_id, title, snippet, rank.
+// Parameters: snippet tokens, match expression, project_id (twice), limit.
+const (
+	taskFTSQuery = `
+		SELECT t.id, b.project_id, t.board_id, t.title,
+			snippet(tasks_fts, -1, '<mark>', '</mark>', '…', ?), bm25(tasks_fts, 10.0, 1.0, 2.0)
+		FROM tasks_fts
+		JOIN tasks t ON t.rowid = tasks_fts.rowid
+		JOIN boards b ON b.id = t.board_id
+		WHERE tasks_fts MATCH ? AND (? = '' OR b.project_id = ?)
+		ORDER BY bm25(tasks_fts, 10.0, 1.0, 2.0)
+		LIMIT ?
+	`
+	documentFTSQuery = `
+		SELECT d.id, d.project_id, '', d.title,
+			snippet(documents_fts, -1, '<mark>', '</mark>', '…', ?), bm25(documents_fts, 10.0, 1.0, 2.0)
+		FROM documents_fts
+		JOIN documents d ON d.rowid = documents_fts.rowid
+		WHERE documents_fts MATCH ? AND (? = '' OR d.project_id = ?)
+		ORDER BY bm25(documents_fts, 10.0, 1.0, 2.0)
+		LIMIT ?
+	`
+	diagramFTSQuery = `
+		SELECT g.id, g.project_id, '', g.name,
+			snippet(diagrams_fts, -1, '<mark>', '</mark>', '…', ?), bm25(diagrams_fts, 10.0, 1.0)
+		FROM diagrams_fts
+		JOIN diagrams g ON g.rowid = diagrams_fts.rowid
+		WHERE diagrams_fts MATCH ? AND (? = '' OR g.project_id = ?)
+		ORDER BY bm25(diagrams_fts, 10.0, 1.0)
+		LIMIT ?
+	`
+)
+
+// searchFTS runs an FTS5 query; bm25 is negated so higher scores rank first
+func (r *SearchRepository) searchFTS(entity, query string, terms []string, opts SearchOptions) ([]domain.SearchHit, error) {
+	rows, err := r.db.Conn().Query(query, snippetTokens, ftsMatchExpression(terms), opts.ProjectID, opts.ProjectID, opts.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hits []domain.SearchHit
+	for rows.Next() {
+		hit := domain.SearchHit{Type: entity}
+		var snippet sql.NullString
+		var rank float64
+		if err := rows.Scan(&hit.ID, &hit.ProjectID, &hit.BoardID, &hit.Title, &snippet, &rank); err != nil {
+			return nil, err
+		}
+		hit.Snippet = snippet.String
+		hit.Score = -rank
+		hits = append(hits, hit)
+	}
+
+	return hits, rows.Err()
+}
+
+// ftsMatchExpression turns search terms into an FTS5 query that ANDs
+// prefix matches, quoting each term so user input can't inject FTS syntax
+func ftsMatchExpression(terms []string) string {
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = `"` + strings.ReplaceAll(term, `"`, `""`) + `"*`
+	}
+	return strings.Join(quoted, " ")
+}
+
+// Each LIKE query selects id, project_id, board_id, title_col and body_col,
+// where body_col is the concatenated searchable text. Parameters: project_id
+// (twice); searchLike appends one condition per term.
+const (
+	taskLikeQuery = `
+		SELECT t.id AS id, b.project_id AS project_id, t.board_id AS board_id, t.title AS title_col,
+			COALESCE(t.description, '') || ' ' || COALESCE(t.labels, '') AS body_col
+		FROM tasks t
+		JOIN boards b ON b.id = t.board_id
+		WHERE (? = '' OR b.project_id = ?)
+	`
+	documentLikeQuery = `
+		SELECT d.id AS id, d.project_id AS project_id, '' AS board_id, d.title AS title_col,
+			COALESCE(d.content, '') || ' ' || COALESCE(d.tags, '') AS body_col
+		FROM documents d
+		WHERE (? = '' OR d.project_id = ?)
+	`
+	diagramLikeQuery = `
+		SELECT g.id AS id, g.project_id AS project_id, '' AS board_id, g.name AS title_col,
+			g.content AS body_col
+		FROM diagrams g
+		WHERE (? = '' OR g.project_id = ?)
+	`
+)
+
+// searchLike is the fallback when FTS5 isn't compiled in. Every term must
+// appear in the title or body; hits are ranked by weighted occurrence count.
+func (r *SearchRepository) searchLike(entity, query string, terms []string, opts SearchOptions) ([]domain.SearchHit, error) {
+	args := []interface{}{opts.ProjectID, opts.ProjectID}
+	var conditions strings.Builder
+	for _, term := range terms {
+		conditions.WriteString(` AND (LOWER(title_col) LIKE ? ESCAPE '\' OR LOWER(body_col) LIKE ? ESCAPE '\')`)
+		pattern := "%" + escapeLike(term) + "%"
+		args = append(args, pattern, pattern)
+	}
+
+	// Wrap so the conditions can refer to the selected columns by alias
+	wrapped := `SELECT id, project_id, board_id, title_col, body_col FROM (` + query + `) WHERE 1 = 1` + conditions.String()
+
+	rows, err := r.db.Conn().Query(wrapped, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hits []domain.SearchHit
+	for rows.Next() {
+		hit := domain.SearchHit{Type: entity}
+		var body string
+		if err := rows.Scan(&hit.ID, &hit.ProjectID, &hit.BoardID, &hit.Title, &body); err != nil {
+			return nil, err
+		}
+
+		titleMatches := domain.CountMatches(hit.Title, terms)
+		bodyMatches := domain.CountMatches(body, terms)
+		hit.Score = float64(10*titleMatches + bodyMatches)
+
+		if bodyMatches > 0 {
+			hit.Snippet = domain.HighlightSnippet(body, terms, snippetWidth)
+		} else {
+			hit.Snippet = domain.HighlightSnippet(hit.Title, terms, snippetWidth)
+		}
+
+		hits = append(hits, hit)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].Score > hits[j].Score })
+	if len(hits) > opts.Limit {
+		hits = hits[:opts.Limit]
+	}
+
+	return hits, nil
+}
+
+// escapeLike escapes LIKE wildcards so terms match literally
+func escapeLike(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `%`, `\%`)
+	return strings.ReplaceAll(s, `_`, `\_`)
+}
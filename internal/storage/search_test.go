@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rand/cartographer/internal/domain"
+)
+
+// TestSearch runs against whichever backend the driver supports:
+// substring fallback by default, FTS5 with -tags sqlite_fts5
+func TestSearch(t *testing.T) {
+	db, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer db.Close()
+	t.Logf("FTS5 enabled: %v", db.FullTextSearch())
+
+	project := &domain.Project{Name: "Search", Path: "/tmp/search"}
+	if err := NewProjectRepository(db).Create(project); err != nil {
+		t.Fatalf("failed to create project: %v", err)
+	}
+	other := &domain.Project{Name: "Other", Path: "/tmp/other"}
+	if err := NewProjectRepository(db).Create(other); err != nil {
+		t.Fatalf("failed to create project: %v", err)
+	}
+	board := &domain.Board{ProjectID: project.ID, Name: "Main"}
+	if err := NewBoardRepository(db).Create(board); err != nil {
+		t.Fatalf("failed to create board: %v", err)
+	}
+
+	tasks := NewTaskRepository(db)
+	task := &domain.Task{BoardID: board.ID, Title: "Implement websocket reconnect", Description: "Resume after laptop sleep", Status: "todo", Priority: "high"}
+	if err := tasks.Create(task); err != nil {
+		t.Fatalf("failed to create task: %v", err)
+	}
+
+	docs := NewDocumentRepository(db)
+	if err := docs.Create(&domain.Document{ProjectID: project.ID, Title: "Protocol", Path: "protocol.md", Content: "The websocket protocol supports resume."}); err != nil {
+		t.Fatalf("failed to create document: %v", err)
+	}
+	if err := docs.Create(&domain.Document{ProjectID: other.ID, Title: "Elsewhere", Path: "other.md", Content: "Another websocket note."}); err != nil {
+		t.Fatalf("failed to create document: %v", err)
+	}
+
+	if err := NewDiagramRepository(db).Create(&domain.Diagram{ProjectID: project.ID, Name: "Sequence", Type: "mermaid", Content: "sequenceDiagram\n  Client->>Server: websocket"}); err != nil {
+		t.Fatalf("failed to create diagram: %v", err)
+	}
+
+	search := NewSearchRepository(db)
+
+	hits, err := search.Search("websocket", SearchOptions{ProjectID: project.ID})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(hits) != 3 {
+		t.Fatalf("Expected 3 hits in project, got %d: %+v", len(hits), hits)
+	}
+	if hits[0].Type != domain.SearchTypeTask || hits[0].ID != task.ID {
+		t.Errorf("Expected title match to rank first, got %s %s", hits[0].Type, hits[0].Title)
+	}
+	for _, hit := range hits {
+		if !strings.Contains(strings.ToLower(hit.Snippet), "<mark>websocket</mark>") {
+			t.Errorf("Expected highlighted snippet for %s, got %q", hit.Type, hit.Snippet)
+		}
+	}
+
+	hits, err = search.Search("websocket", SearchOptions{Types: []string{domain.SearchTypeDocument}})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(hits) != 2 {
+		t.Errorf("Expected 2 document hits across projects, got %d", len(hits))
+	}
+
+	// Updates must be reflected in the index
+	task.Title = "Implement event replay"
+	if err := tasks.Update(task); err != nil {
+		t.Fatalf("failed to update task: %v", err)
+	}
+	hits, err = search.Search("replay", SearchOptions{Types: []string{domain.SearchTypeTask}})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(hits) != 1 || hits[0].ID != task.ID {
+		t.Errorf("Expected updated task to match, got %+v", hits)
+	}
+
+	// Deletes must be reflected too
+	if err := tasks.Delete(task.ID); err != nil {
+		t.Fatalf("failed to delete task: %v", err)
+	}
+	hits, err = search.Search("replay", SearchOptions{Types: []string{domain.SearchTypeTask}})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(hits) != 0 {
+		t.Errorf("Expected no hits after delete, got %+v", hits)
+	}
+
+	// FTS syntax in user input must not cause errors
+	if _, err := search.Search(`"unbalanced AND (`, SearchOptions{}); err != nil {
+		t.Errorf("Search with FTS syntax failed: %v", err)
+	}
+}
+
+// TestSearchIndexWithoutFTS5 opens a database an FTS5 build indexed with a
+// build without FTS5, whose writes would fail on the leftover triggers
+func TestSearchIndexWithoutFTS5(t *testing.T) {
+	dir := t.TempDir()
+	db, err := New(dir)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if db.FullTextSearch() {
+		db.Close()
+		t.Skip("FTS5 is compiled in")
+	}
+
+	// A trigger as an FTS5 build leaves it, with the index out of reach
+	if _, err := db.Conn().Exec(`CREATE TRIGGER documents_fts_insert AFTER INSERT ON documents BEGIN
+		INSERT INTO documents_fts(rowid, title, content, tags) VALUES (new.rowid, new.title, new.content, new.tags);
+	END`); err != nil {
+		t.Fatalf("failed to create trigger: %v", err)
+	}
+	project := &domain.Project{Name: "Search", Path: "/tmp/search"}
+	if err := NewProjectRepository(db).Create(project); err != nil {
+		t.Fatalf("failed to create project: %v", err)
+	}
+	if err := NewDocumentRepository(db).Create(&domain.Document{ProjectID: project.ID, Title: "Before", Path: "before.md"}); err == nil {
+		t.Fatal("Expected the leftover trigger to fail the write")
+	}
+	db.Close()
+
+	db, err = New(dir)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer db.Close()
+	if err := NewDocumentRepository(db).Create(&domain.Document{ProjectID: project.ID, Title: "After", Path: "after.md"}); err != nil {
+		t.Errorf("Expected the trigger dropped on open, got %v", err)
+	}
+}
@@ -0,0 +1,219 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rand/cartographer/internal/domain"
+)
+
+const sessionColumns = `id, project_id, board_id, agent, title, previous_id, started_at, updated_at, event_count`
+
+// SessionRepository handles agents' sessions and their events. Times are
+// stored in UTC so they compare as text.
+type SessionRepository struct {
+	db *DB
+}
+
+// NewSessionRepository creates a new session repository
+func NewSessionRepository(db *DB) *SessionRepository {
+	return &SessionRepository{db: db}
+}
+
+// Create starts a new session
+func (r *SessionRepository) Create(session *domain.Session) error {
+	if session.ID == "" {
+		session.ID = uuid.New().String()
+	}
+	session.StartedAt = time.Now().UTC()
+	session.UpdatedAt = session.StartedAt
+	session.EventCount = 0
+
+	agent, err := json.Marshal(session.Agent)
+	if err != nil {
+		return fmt.Errorf("failed to marshal session agent: %w", err)
+	}
+
+	query := `
+		INSERT INTO claude_sessions (id, project_id, board_id, agent, title, previous_id, started_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	_, err = r.db.Conn().Exec(query,
+		session.ID, session.ProjectID, session.BoardID, string(agent), session.Title, session.PreviousID,
+		session.StartedAt, session.UpdatedAt,
+	)
+	return err
+}
+
+// GetByID retrieves a session by ID
+func (r *SessionRepository) GetByID(id string) (*domain.Session, error) {
+	session, err := scanSession(r.db.Conn().QueryRow(`SELECT `+sessionColumns+` FROM claude_sessions WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("session %w: %s", ErrNotFound, id)
+	}
+	return session, err
+}
+
+// ListByProject returns a project's sessions, newest first
+func (r *SessionRepository) ListByProject(projectID string) ([]*domain.Session, error) {
+	query := `SELECT ` + sessionColumns + ` FROM claude_sessions
+		WHERE project_id = ?
+		ORDER BY started_at DESC, rowid DESC`
+
+	rows, err := r.db.Conn().Query(query, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []*domain.Session{}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// AppendEvent records an event as the next one of its session, setting its
+// sequence number and time
+func (r *SessionRepository) AppendEvent(event *domain.SessionEvent) error {
+	return r.db.InTx(func(tx *sql.Tx) error {
+		return appendEvent(tx, event)
+	})
+}
+
+// AppendEventCreatingTask creates task and appends event, linked to it, in
+// one transaction, so neither is kept without the other. If limit is
+// positive, it fails with ErrWIPLimitExceeded when the task's column
+// already holds limit tasks.
+func (r *SessionRepository) AppendEventCreatingTask(event *domain.SessionEvent, task *domain.Task, limit int) error {
+	return r.db.InTx(func(tx *sql.Tx) error {
+		tasks := &TaskRepository{db: r.db, tx: tx}
+		if limit > 0 {
+			n, err := tasks.CountInColumn(task.BoardID, task.Status)
+			if err != nil {
+				return err
+			}
+			if n >= limit {
+				return ErrWIPLimitExceeded
+			}
+		}
+		if err := tasks.Create(task); err != nil {
+			return err
+		}
+		event.Links = append(event.Links, domain.LinkedItem{Type: domain.EntityTask, ID: task.ID})
+		return appendEvent(tx, event)
+	})
+}
+
+// appendEvent records an event in a transaction
+func appendEvent(tx *sql.Tx, event *domain.SessionEvent) error {
+	event.CreatedAt = time.Now().UTC()
+
+	files, _ := json.Marshal(nonNil(event.Files))
+	tags, _ := json.Marshal(nonNil(event.Tags))
+	links, err := json.Marshal(event.Links)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event links: %w", err)
+	}
+
+	err = tx.QueryRow(
+		`UPDATE claude_sessions SET event_count = event_count + 1, updated_at = ? WHERE id = ? RETURNING event_count`,
+		event.CreatedAt, event.SessionID,
+	).Scan(&event.Seq)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("session %w: %s", ErrNotFound, event.SessionID)
+	}
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO claude_session_events (session_id, seq, type, tool, text, files, tags, links, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	_, err = tx.Exec(query,
+		event.SessionID, event.Seq, event.Type, event.Tool, event.Text,
+		string(files), string(tags), string(links), event.CreatedAt,
+	)
+	return err
+}
+
+// Events returns a session's events in order
+func (r *SessionRepository) Events(sessionID string) ([]*domain.SessionEvent, error) {
+	query := `
+		SELECT session_id, seq, type, tool, text, files, tags, links, created_at
+		FROM claude_session_events
+		WHERE session_id = ?
+		ORDER BY seq
+	`
+
+	rows, err := r.db.Conn().Query(query, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*domain.SessionEvent{}
+	for rows.Next() {
+		event := &domain.SessionEvent{}
+		var tool, text, files, tags, links sql.NullString
+		err := rows.Scan(&event.SessionID, &event.Seq, &event.Type, &tool, &text,
+			&files, &tags, &links, &event.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		event.Tool = tool.String
+		event.Text = text.String
+		for _, field := range []struct {
+			data string
+			dest interface{}
+		}{{files.String, &event.Files}, {tags.String, &event.Tags}, {links.String, &event.Links}} {
+			if field.data == "" {
+				continue
+			}
+			if err := json.Unmarshal([]byte(field.data), field.dest); err != nil {
+				return nil, fmt.Errorf("invalid event %d of session %s: %w", event.Seq, sessionID, err)
+			}
+		}
+		if len(event.Files) == 0 {
+			event.Files = nil
+		}
+		if len(event.Tags) == 0 {
+			event.Tags = nil
+		}
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+func scanSession(row rowScanner) (*domain.Session, error) {
+	session := &domain.Session{}
+	var agent string
+	var boardID, title, previousID sql.NullString
+
+	err := row.Scan(&session.ID, &session.ProjectID, &boardID, &agent, &title, &previousID,
+		&session.StartedAt, &session.UpdatedAt, &session.EventCount)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(agent), &session.Agent); err != nil {
+		return nil, fmt.Errorf("invalid agent for session %s: %w", session.ID, err)
+	}
+	session.BoardID = boardID.String
+	session.Title = title.String
+	session.PreviousID = previousID.String
+
+	return session, nil
+}
@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/rand/cartographer/internal/domain"
+)
+
+func TestSessionRepository(t *testing.T) {
+	db, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer db.Close()
+
+	project := &domain.Project{Name: "Sessions", Path: "/tmp/sessions"}
+	if err := NewProjectRepository(db).Create(project); err != nil {
+		t.Fatalf("failed to create project: %v", err)
+	}
+
+	repo := NewSessionRepository(db)
+	agent := domain.User{Type: "agent", ID: "claude"}
+	first := &domain.Session{ProjectID: project.ID, Agent: agent, Title: "Parser"}
+	if err := repo.Create(first); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	second := &domain.Session{ProjectID: project.ID, Agent: agent, PreviousID: first.ID}
+	if err := repo.Create(second); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := repo.Create(&domain.Session{ProjectID: "missing", Agent: agent}); !IsMissingReference(err) {
+		t.Errorf("Expected a missing reference error for an unknown project, got %v", err)
+	}
+
+	sessions, err := repo.ListByProject(project.ID)
+	if err != nil || len(sessions) != 2 || sessions[0].ID != second.ID || sessions[0].PreviousID != first.ID {
+		t.Fatalf("Unexpected sessions %+v (%v)", sessions, err)
+	}
+
+	events := []*domain.SessionEvent{
+		{SessionID: first.ID, Type: domain.SessionToolCall, Tool: "Edit", Files: []string{"parser.go"}},
+		{SessionID: first.ID, Type: domain.SessionNote, Text: "Handle comments", Tags: []string{"task"},
+			Links: []domain.LinkedItem{{Type: "task", ID: "task-1"}}},
+	}
+	for _, event := range events {
+		if err := repo.AppendEvent(event); err != nil {
+			t.Fatalf("AppendEvent failed: %v", err)
+		}
+	}
+	if events[1].Seq != 2 {
+		t.Errorf("Expected the second event to be numbered 2, got %d", events[1].Seq)
+	}
+	err = repo.AppendEvent(&domain.SessionEvent{SessionID: "missing", Type: domain.SessionNote})
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for an unknown session, got %v", err)
+	}
+
+	stored, err := repo.Events(first.ID)
+	if err != nil || len(stored) != 2 {
+		t.Fatalf("Unexpected events %+v (%v)", stored, err)
+	}
+	for i, event := range stored {
+		event.CreatedAt = events[i].CreatedAt
+		if !reflect.DeepEqual(event, events[i]) {
+			t.Errorf("Event %d: got %+v, want %+v", i, event, events[i])
+		}
+	}
+
+	session, err := repo.GetByID(first.ID)
+	if err != nil || session.EventCount != 2 || session.Title != "Parser" || session.Agent != agent {
+		t.Errorf("Unexpected session %+v (%v)", session, err)
+	}
+	if _, err := repo.GetByID("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestAppendEventCreatingTask(t *testing.T) {
+	db, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer db.Close()
+
+	project := &domain.Project{Name: "Sessions", Path: "/tmp/sessions"}
+	if err := NewProjectRepository(db).Create(project); err != nil {
+		t.Fatalf("failed to create project: %v", err)
+	}
+	board := &domain.Board{ProjectID: project.ID, Name: "Main", Columns: []domain.BoardColumn{{ID: "todo", Name: "To Do"}}}
+	if err := NewBoardRepository(db).Create(board); err != nil {
+		t.Fatalf("failed to create board: %v", err)
+	}
+	repo := NewSessionRepository(db)
+	session := &domain.Session{ProjectID: project.ID, Agent: domain.User{Type: "agent", ID: "claude"}}
+	if err := repo.Create(session); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	tasks := NewTaskRepository(db)
+
+	// A failed append leaves no task behind
+	event := &domain.SessionEvent{SessionID: "missing", Type: domain.SessionNote, Text: "Lexer"}
+	err = repo.AppendEventCreatingTask(event, &domain.Task{BoardID: board.ID, Title: "Lexer", Status: "todo"}, 0)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for an unknown session, got %v", err)
+	}
+	if n, _ := tasks.CountInColumn(board.ID, "todo"); n != 0 {
+		t.Errorf("Expected the task rolled back, got %d tasks", n)
+	}
+
+	event = &domain.SessionEvent{SessionID: session.ID, Type: domain.SessionNote, Text: "Parser"}
+	task := &domain.Task{BoardID: board.ID, Title: "Parser", Status: "todo"}
+	if err := repo.AppendEventCreatingTask(event, task, 1); err != nil {
+		t.Fatalf("AppendEventCreatingTask failed: %v", err)
+	}
+	if want := []domain.LinkedItem{{Type: "task", ID: task.ID}}; event.Seq != 1 || !reflect.DeepEqual(event.Links, want) {
+		t.Errorf("Expected the event linked to the task, got %+v", event)
+	}
+
+	event = &domain.SessionEvent{SessionID: session.ID, Type: domain.SessionNote, Text: "Printer"}
+	err = repo.AppendEventCreatingTask(event, &domain.Task{BoardID: board.ID, Title: "Printer", Status: "todo"}, 1)
+	if !errors.Is(err, ErrWIPLimitExceeded) {
+		t.Errorf("Expected ErrWIPLimitExceeded for a full column, got %v", err)
+	}
+	if n, _ := tasks.CountInColumn(board.ID, "todo"); n != 1 {
+		t.Errorf("Expected 1 task, got %d", n)
+	}
+	if events, _ := repo.Events(session.ID); len(events) != 1 {
+		t.Errorf("Expected only the first event kept, got %d", len(events))
+	}
+}
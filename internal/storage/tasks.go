@@ -3,6 +3,7 @@ package storage
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
@@ -13,6 +14,7 @@ import (
 // TaskRepository handles task CRUD operations
 type TaskRepository struct {
 	db *DB
+	tx *sql.Tx // set for repositories bound to a transaction
 }
 
 // NewTaskRepository creates a new task repository
@@ -20,15 +22,38 @@ func NewTaskRepository(db *DB) *TaskRepository {
 	return &TaskRepository{db: db}
 }
 
+// Transaction runs fn with a repository whose operations all happen in one
+// transaction, committed only if fn returns nil
+func (r *TaskRepository) Transaction(fn func(tasks *TaskRepository) error) error {
+	return r.db.InTx(func(tx *sql.Tx) error {
+		return fn(&TaskRepository{db: r.db, tx: tx})
+	})
+}
+
+// conn returns the transaction the repository is bound to, if any, or the
+// database connection
+func (r *TaskRepository) conn() querier {
+	if r.tx != nil {
+		return r.tx
+	}
+	return r.db.Conn()
+}
+
 // Create creates a new task
 func (r *TaskRepository) Create(task *domain.Task) error {
 	if task.ID == "" {
 		task.ID = uuid.New().String()
 	}
 
-	now := time.Now()
+	now := time.Now().UTC()
 	task.CreatedAt = now
 	task.UpdatedAt = now
+	task.Revision = 1
+
+	// New tasks go to the bottom of their column
+	if err := r.conn().QueryRow(columnEndQuery, task.BoardID, task.Status).Scan(&task.Rank); err != nil {
+		return err
+	}
 
 	// Marshal JSON fields
 	assignee, _ := json.Marshal(task.Assignee)
@@ -46,48 +71,55 @@ func (r *TaskRepository) Create(task *domain.Task) error {
 			id, board_id, title, description, status, priority,
 			assignee, labels, due_date, estimate, actual,
 			dependencies, blocks, related, linked_items, checklist,
-			created_at, updated_at, created_by, activity
+			rank, created_at, updated_at, created_by, activity, revision
 		)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
-	_, err := r.db.Conn().Exec(query,
+	_, err := r.conn().Exec(query,
 		task.ID, task.BoardID, task.Title, task.Description, task.Status, task.Priority,
 		string(assignee), string(labels), task.DueDate, task.Estimate, task.Actual,
 		string(dependencies), string(blocks), string(related), string(linkedItems), string(checklist),
-		task.CreatedAt, task.UpdatedAt, string(createdBy), string(activity),
+		task.Rank, task.CreatedAt, task.UpdatedAt, string(createdBy), string(activity), task.Revision,
 	)
 
 	return err
 }
 
+// taskColumns are the columns scanned by scanTask
+const taskColumns = `id, board_id, title, description, status, priority,
+	assignee, labels, due_date, estimate, actual,
+	dependencies, blocks, related, linked_items, checklist,
+	rank, created_at, updated_at, created_by, activity, revision`
+
 // GetByID retrieves a task by ID
 func (r *TaskRepository) GetByID(id string) (*domain.Task, error) {
-	query := `
-		SELECT id, board_id, title, description, status, priority,
-			   assignee, labels, due_date, estimate, actual,
-			   dependencies, blocks, related, linked_items, checklist,
-			   created_at, updated_at, created_by, activity
-		FROM tasks
-		WHERE id = ?
-	`
+	query := `SELECT ` + taskColumns + ` FROM tasks WHERE id = ?`
 
+	task, err := scanTask(r.conn().QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("task %w: %s", ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// scanTask scans a row of taskColumns, followed by extra
+func scanTask(row rowScanner, extra ...interface{}) (*domain.Task, error) {
 	task := &domain.Task{}
 	var assigneeJSON, labelsJSON, dependenciesJSON, blocksJSON, relatedJSON,
 		linkedItemsJSON, checklistJSON, createdByJSON, activityJSON sql.NullString
 	var dueDate sql.NullTime
 	var estimate, actual sql.NullFloat64
 
-	err := r.db.Conn().QueryRow(query, id).Scan(
+	err := row.Scan(append([]interface{}{
 		&task.ID, &task.BoardID, &task.Title, &task.Description, &task.Status, &task.Priority,
 		&assigneeJSON, &labelsJSON, &dueDate, &estimate, &actual,
 		&dependenciesJSON, &blocksJSON, &relatedJSON, &linkedItemsJSON, &checklistJSON,
-		&task.CreatedAt, &task.UpdatedAt, &createdByJSON, &activityJSON,
-	)
-
-	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("task not found: %s", id)
-	}
+		&task.Rank, &task.CreatedAt, &task.UpdatedAt, &createdByJSON, &activityJSON, &task.Revision,
+	}, extra...)...)
 	if err != nil {
 		return nil, err
 	}
@@ -133,89 +165,132 @@ func (r *TaskRepository) GetByID(id string) (*domain.Task, error) {
 	return task, nil
 }
 
-// ListByBoard retrieves all tasks for a board
+// ListByBoard retrieves all tasks for a board, in column order
 func (r *TaskRepository) ListByBoard(boardID string) ([]*domain.Task, error) {
-	query := `
-		SELECT id, board_id, title, description, status, priority,
-			   assignee, labels, due_date, estimate, actual,
-			   dependencies, blocks, related, linked_items, checklist,
-			   created_at, updated_at, created_by, activity
-		FROM tasks
-		WHERE board_id = ?
-		ORDER BY updated_at DESC
-	`
+	tasks, _, err := r.Query(TaskQuery{BoardID: boardID})
+	return tasks, err
+}
+
+// TaskQuery filters, sorts and pages the tasks of a board. Empty fields
+// match every task.
+type TaskQuery struct {
+	BoardID      string
+	Status       []string
+	Priority     []string
+	Assignee     string // assignee ID or name
+	Label        string
+	DueBefore    *time.Time
+	UpdatedSince *time.Time
+	Text         string // in the title or description
+	ListOptions
+}
+
+// DefaultTaskSort is the order of tasks when a query doesn't sort them
+const DefaultTaskSort = "rank"
+
+// taskSortKeys are the fields tasks can be sorted by
+var taskSortKeys = map[string]sortKey{
+	"rank":       {expr: "rank"},
+	"status":     {expr: "status"},
+	"priority":   {expr: "CASE priority WHEN 'urgent' THEN 0 WHEN 'high' THEN 1 WHEN 'medium' THEN 2 WHEN 'low' THEN 3 ELSE 4 END"},
+	"due_date":   {expr: "IFNULL(julianday(due_date), 1e9)"}, // tasks without one last
+	"created_at": timeSortKey("created_at"),
+	"updated_at": timeSortKey("updated_at"),
+}
+
+// Query retrieves the tasks matching q and, if q.Limit cuts them short,
+// the cursor of the next page
+func (r *TaskRepository) Query(q TaskQuery) ([]*domain.Task, string, error) {
+	lq := &listQuery{table: "tasks", columns: taskColumns}
+	lq.filter("board_id = ?", q.BoardID)
+	if len(q.Status) > 0 {
+		lq.filterIn("status", q.Status)
+	}
+	if len(q.Priority) > 0 {
+		lq.filterIn("priority", q.Priority)
+	}
+	if q.Assignee != "" {
+		lq.filter("(json_extract(assignee, '$.id') = ? OR json_extract(assignee, '$.name') = ?)", q.Assignee, q.Assignee)
+	}
+	if q.Label != "" {
+		lq.filter("EXISTS (SELECT 1 FROM json_each(tasks.labels) WHERE value = ?)", q.Label)
+	}
+	if q.DueBefore != nil {
+		lq.filter(timeFilter("due_date", "<"), dbTime(*q.DueBefore))
+	}
+	if q.UpdatedSince != nil {
+		lq.filter(timeFilter("updated_at", ">="), dbTime(*q.UpdatedSince))
+	}
+	if q.Text != "" {
+		lq.filterText(q.Text, "title", "description")
+	}
 
-	rows, err := r.db.Conn().Query(query, boardID)
+	query, args, err := lq.build(taskSortKeys, DefaultTaskSort, q.ListOptions)
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+	rows, err := r.conn().Query(query, args...)
+	if err != nil {
+		return nil, "", err
 	}
 	defer rows.Close()
 
 	var tasks []*domain.Task
+	var keys []pageKey
 	for rows.Next() {
-		task := &domain.Task{}
-		var assigneeJSON, labelsJSON, dependenciesJSON, blocksJSON, relatedJSON,
-			linkedItemsJSON, checklistJSON, createdByJSON, activityJSON sql.NullString
-		var dueDate sql.NullTime
-		var estimate, actual sql.NullFloat64
-
-		err := rows.Scan(
-			&task.ID, &task.BoardID, &task.Title, &task.Description, &task.Status, &task.Priority,
-			&assigneeJSON, &labelsJSON, &dueDate, &estimate, &actual,
-			&dependenciesJSON, &blocksJSON, &relatedJSON, &linkedItemsJSON, &checklistJSON,
-			&task.CreatedAt, &task.UpdatedAt, &createdByJSON, &activityJSON,
-		)
+		var key pageKey
+		task, err := scanTask(rows, key.dest()...)
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
+		tasks = append(tasks, task)
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
 
-		// Unmarshal JSON fields
-		if assigneeJSON.Valid {
-			json.Unmarshal([]byte(assigneeJSON.String), &task.Assignee)
-		}
-		if labelsJSON.Valid {
-			json.Unmarshal([]byte(labelsJSON.String), &task.Labels)
-		}
-		if dueDate.Valid {
-			task.DueDate = &dueDate.Time
-		}
-		if estimate.Valid {
-			task.Estimate = &estimate.Float64
-		}
-		if actual.Valid {
-			task.Actual = &actual.Float64
-		}
-		if dependenciesJSON.Valid {
-			json.Unmarshal([]byte(dependenciesJSON.String), &task.Dependencies)
-		}
-		if blocksJSON.Valid {
-			json.Unmarshal([]byte(blocksJSON.String), &task.Blocks)
-		}
-		if relatedJSON.Valid {
-			json.Unmarshal([]byte(relatedJSON.String), &task.Related)
-		}
-		if linkedItemsJSON.Valid {
-			json.Unmarshal([]byte(linkedItemsJSON.String), &task.LinkedItems)
-		}
-		if checklistJSON.Valid {
-			json.Unmarshal([]byte(checklistJSON.String), &task.Checklist)
-		}
-		if createdByJSON.Valid {
-			json.Unmarshal([]byte(createdByJSON.String), &task.CreatedBy)
-		}
-		if activityJSON.Valid {
-			json.Unmarshal([]byte(activityJSON.String), &task.Activity)
-		}
+	next := nextCursor(q.ListOptions, DefaultTaskSort, keys)
+	if next != "" {
+		tasks = tasks[:q.Limit]
+	}
+	return tasks, next, nil
+}
 
-		tasks = append(tasks, task)
+// FindByLinkedItem returns the task linked to the given item, e.g. the task
+// imported from a bead, or nil if no task links to it
+func (r *TaskRepository) FindByLinkedItem(itemType, itemID string) (*domain.Task, error) {
+	query := `
+		SELECT tasks.id
+		FROM tasks, json_each(tasks.linked_items) AS item
+		WHERE json_valid(tasks.linked_items)
+		  AND json_extract(item.value, '$.type') = ?
+		  AND json_extract(item.value, '$.id') = ?
+		ORDER BY tasks.created_at
+		LIMIT 1
+	`
+
+	var id string
+	err := r.conn().QueryRow(query, itemType, itemID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	return tasks, rows.Err()
+	return r.GetByID(id)
 }
 
-// Update updates an existing task
+// Update updates an existing task and bumps its revision. If task.Revision
+// is set, the update only applies at that revision and otherwise fails with
+// ErrRevisionConflict. A task whose status changes goes to the bottom of its
+// new column; otherwise it keeps its rank.
 func (r *TaskRepository) Update(task *domain.Task) error {
-	task.UpdatedAt = time.Now()
+	task.UpdatedAt = time.Now().UTC()
+	if err := r.placeInColumn(task); err != nil {
+		return err
+	}
 
 	// Marshal JSON fields
 	assignee, _ := json.Marshal(task.Assignee)
@@ -233,37 +308,235 @@ func (r *TaskRepository) Update(task *domain.Task) error {
 		SET title = ?, description = ?, status = ?, priority = ?,
 			assignee = ?, labels = ?, due_date = ?, estimate = ?, actual = ?,
 			dependencies = ?, blocks = ?, related = ?, linked_items = ?, checklist = ?,
-			created_by = ?, activity = ?
-		WHERE id = ?
+			rank = ?, created_by = ?, activity = ?, updated_at = ?, revision = revision + 1
+		WHERE id = ?` + revisionCondition + `
+		RETURNING revision
 	`
 
-	result, err := r.db.Conn().Exec(query,
+	row := r.conn().QueryRow(query,
 		task.Title, task.Description, task.Status, task.Priority,
 		string(assignee), string(labels), task.DueDate, task.Estimate, task.Actual,
 		string(dependencies), string(blocks), string(related), string(linkedItems), string(checklist),
-		string(createdBy), string(activity),
-		task.ID,
+		task.Rank, string(createdBy), string(activity), task.UpdatedAt,
+		task.ID, task.Revision, task.Revision,
 	)
+	return scanRevision(r.conn(), row, "tasks", "task", task.ID, &task.Revision)
+}
+
+// UpdateFields writes only the named fields of a task, leaving concurrent
+// edits to its other fields intact. Revisions and status changes work as for
+// Update, unless the fields include the rank.
+func (r *TaskRepository) UpdateFields(task *domain.Task, fields []string) error {
+	return r.updateFields(task, fields, func() (interface{}, error) {
+		return jsonColumn(task.Activity)
+	})
+}
+
+// UpdateFieldsAppending is UpdateFields for an update adding entries to the
+// task's activity. Rather than writing the task's copy of the activity it
+// appends added to the stored one, so that updates without an expected
+// revision keep each other's entries, and sets task.Activity to the result.
+func (r *TaskRepository) UpdateFieldsAppending(task *domain.Task, fields []string, added []domain.ActivityEntry) error {
+	if r.tx == nil {
+		return r.Transaction(func(tasks *TaskRepository) error {
+			return tasks.UpdateFieldsAppending(task, fields, added)
+		})
+	}
+
+	if len(added) > 0 && !hasField(fields, "activity") {
+		fields = append(fields, "activity")
+	}
+	entries := make([]interface{}, len(added))
+	for i, entry := range added {
+		entries[i] = entry
+	}
+	err := r.updateFields(task, fields, func() (interface{}, error) {
+		return jsonAppend("activity", entries...)
+	})
 	if err != nil {
 		return err
 	}
 
-	rows, err := result.RowsAffected()
-	if err != nil {
+	var activity sql.NullString
+	if err := r.tx.QueryRow(`SELECT activity FROM tasks WHERE id = ?`, task.ID).Scan(&activity); err != nil {
 		return err
 	}
-	if rows == 0 {
-		return fmt.Errorf("task not found: %s", task.ID)
+	task.Activity = nil
+	if activity.Valid {
+		json.Unmarshal([]byte(activity.String), &task.Activity)
 	}
-
 	return nil
 }
 
+// updateFields writes the named fields of a task, taking the activity's
+// value from activity
+func (r *TaskRepository) updateFields(task *domain.Task, fields []string, activity func() (interface{}, error)) error {
+	task.UpdatedAt = time.Now().UTC()
+	if hasField(fields, "status") && !hasField(fields, "rank") {
+		if err := r.placeInColumn(task); err != nil {
+			return err
+		}
+		fields = append(fields, "rank")
+	}
+	return updateColumns(r.conn(), "tasks", task.ID, fields, func(field string) (interface{}, error) {
+		switch field {
+		case "title":
+			return task.Title, nil
+		case "description":
+			return task.Description, nil
+		case "status":
+			return task.Status, nil
+		case "priority":
+			return task.Priority, nil
+		case "assignee":
+			return jsonColumn(task.Assignee)
+		case "labels":
+			return jsonColumn(task.Labels)
+		case "due_date":
+			return task.DueDate, nil
+		case "estimate":
+			return task.Estimate, nil
+		case "actual":
+			return task.Actual, nil
+		case "dependencies":
+			return jsonColumn(task.Dependencies)
+		case "blocks":
+			return jsonColumn(task.Blocks)
+		case "related":
+			return jsonColumn(task.Related)
+		case "linked_items":
+			return jsonColumn(task.LinkedItems)
+		case "checklist":
+			return jsonColumn(task.Checklist)
+		case "rank":
+			return task.Rank, nil
+		case "activity":
+			return activity()
+		}
+		return nil, fieldError("task", field)
+	}, task.UpdatedAt, &task.Revision)
+}
+
+// ErrWIPLimitExceeded is returned when a move or a new task would put more
+// tasks in a column than its WIP limit
+var ErrWIPLimitExceeded = errors.New("column is at its WIP limit")
+
+// columnEndQuery returns the rank that puts a task at the bottom of the
+// column of a board with the given status
+const columnEndQuery = `SELECT COALESCE(MAX(rank), 0) + 1 FROM tasks WHERE board_id = ? AND status = ?`
+
+// placeInColumn ranks a task at the bottom of its column if its status
+// differs from the stored one, and at its stored rank otherwise
+func (r *TaskRepository) placeInColumn(task *domain.Task) error {
+	var status string
+	err := r.conn().QueryRow(`SELECT status, rank FROM tasks WHERE id = ?`, task.ID).Scan(&status, &task.Rank)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("task %w: %s", ErrNotFound, task.ID)
+	}
+	if err != nil || status == task.Status {
+		return err
+	}
+	return r.conn().QueryRow(columnEndQuery, task.BoardID, task.Status).Scan(&task.Rank)
+}
+
+// CountInColumn returns the number of tasks on a board with the given status
+func (r *TaskRepository) CountInColumn(boardID, status string) (int, error) {
+	var n int
+	err := r.conn().QueryRow(`SELECT COUNT(*) FROM tasks WHERE board_id = ? AND status = ?`, boardID, status).Scan(&n)
+	return n, err
+}
+
+// Move places a task at position in the column given by its status, 0 being
+// the top and anything out of range the bottom, writes its status and rank
+// and appends added to its activity as UpdateFieldsAppending does. If limit
+// is positive and the task is entering the column, the move fails with
+// ErrWIPLimitExceeded when the column already holds limit tasks. It returns
+// the task's position and the number of tasks in the column afterwards.
+// Revisions work as for Update.
+func (r *TaskRepository) Move(task *domain.Task, added []domain.ActivityEntry, position, limit int) (int, int, error) {
+	if r.tx == nil {
+		var index, size int
+		err := r.Transaction(func(tasks *TaskRepository) error {
+			var err error
+			index, size, err = tasks.Move(task, added, position, limit)
+			return err
+		})
+		return index, size, err
+	}
+
+	var current string
+	err := r.tx.QueryRow(`SELECT status FROM tasks WHERE id = ?`, task.ID).Scan(&current)
+	if err == sql.ErrNoRows {
+		return 0, 0, fmt.Errorf("task %w: %s", ErrNotFound, task.ID)
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+
+	ids, ranks, err := r.columnRanks(task.BoardID, task.Status, task.ID)
+	if err != nil {
+		return 0, 0, err
+	}
+	if limit > 0 && current != task.Status && len(ids) >= limit {
+		return 0, 0, ErrWIPLimitExceeded
+	}
+	if position < 0 || position > len(ids) {
+		position = len(ids)
+	}
+
+	rank, ok := domain.RankAt(ranks, position)
+	if !ok {
+		// Ranks have run out of precision between the neighbours, so
+		// space the column out again. This isn't an edit of the other
+		// tasks, so it leaves their revisions alone.
+		for i, id := range ids {
+			ranks[i] = float64(i + 1)
+			if _, err := r.tx.Exec(`UPDATE tasks SET rank = ? WHERE id = ?`, ranks[i], id); err != nil {
+				return 0, 0, err
+			}
+		}
+		rank, _ = domain.RankAt(ranks, position)
+	}
+
+	task.Rank = rank
+	if err := r.UpdateFieldsAppending(task, []string{"status", "rank"}, added); err != nil {
+		return 0, 0, err
+	}
+	return position, len(ids) + 1, nil
+}
+
+// columnRanks returns the IDs and ranks of the tasks in a column in order,
+// leaving out the task with ID except
+func (r *TaskRepository) columnRanks(boardID, status, except string) ([]string, []float64, error) {
+	rows, err := r.conn().Query(`
+		SELECT id, rank FROM tasks
+		WHERE board_id = ? AND status = ? AND id != ?
+		ORDER BY rank, created_at
+	`, boardID, status, except)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	var ranks []float64
+	for rows.Next() {
+		var id string
+		var rank float64
+		if err := rows.Scan(&id, &rank); err != nil {
+			return nil, nil, err
+		}
+		ids = append(ids, id)
+		ranks = append(ranks, rank)
+	}
+	return ids, ranks, rows.Err()
+}
+
 // Delete deletes a task by ID
 func (r *TaskRepository) Delete(id string) error {
 	query := `DELETE FROM tasks WHERE id = ?`
 
-	result, err := r.db.Conn().Exec(query, id)
+	result, err := r.conn().Exec(query, id)
 	if err != nil {
 		return err
 	}
@@ -273,7 +546,7 @@ func (r *TaskRepository) Delete(id string) error {
 		return err
 	}
 	if rows == 0 {
-		return fmt.Errorf("task not found: %s", id)
+		return fmt.Errorf("task %w: %s", ErrNotFound, id)
 	}
 
 	return nil
@@ -0,0 +1,217 @@
+package storage
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/rand/cartographer/internal/domain"
+)
+
+func TestTaskRepositoryTransaction(t *testing.T) {
+	db, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer db.Close()
+
+	project := &domain.Project{Name: "Tx", Path: "/tmp/tx"}
+	if err := NewProjectRepository(db).Create(project); err != nil {
+		t.Fatalf("failed to create project: %v", err)
+	}
+	board := &domain.Board{ProjectID: project.ID, Name: "Main"}
+	if err := NewBoardRepository(db).Create(board); err != nil {
+		t.Fatalf("failed to create board: %v", err)
+	}
+
+	repo := NewTaskRepository(db)
+
+	// A failed transaction leaves nothing behind
+	failure := errors.New("stop")
+	err = repo.Transaction(func(tasks *TaskRepository) error {
+		if err := tasks.Create(&domain.Task{ID: "t-1", BoardID: board.ID, Title: "One", Status: "todo"}); err != nil {
+			return err
+		}
+		if _, err := tasks.GetByID("t-1"); err != nil {
+			t.Errorf("Expected task visible inside transaction: %v", err)
+		}
+		return failure
+	})
+	if err != failure {
+		t.Fatalf("Expected transaction error, got %v", err)
+	}
+	if _, err := repo.GetByID("t-1"); err == nil {
+		t.Error("Expected rolled back task to be gone")
+	}
+
+	err = repo.Transaction(func(tasks *TaskRepository) error {
+		return tasks.Create(&domain.Task{ID: "t-2", BoardID: board.ID, Title: "Two", Status: "todo"})
+	})
+	if err != nil {
+		t.Fatalf("Transaction failed: %v", err)
+	}
+	if _, err := repo.GetByID("t-2"); err != nil {
+		t.Errorf("Expected committed task: %v", err)
+	}
+}
+
+func TestTaskRepositoryUpdateFields(t *testing.T) {
+	db, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer db.Close()
+
+	project := &domain.Project{Name: "Patch", Path: "/tmp/patch"}
+	if err := NewProjectRepository(db).Create(project); err != nil {
+		t.Fatalf("failed to create project: %v", err)
+	}
+	board := &domain.Board{ProjectID: project.ID, Name: "Main"}
+	if err := NewBoardRepository(db).Create(board); err != nil {
+		t.Fatalf("failed to create board: %v", err)
+	}
+
+	repo := NewTaskRepository(db)
+	task := &domain.Task{BoardID: board.ID, Title: "Original", Status: "todo", Priority: "low"}
+	if err := repo.Create(task); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	// Two writers working from the same copy each change one field,
+	// without asking for a particular revision
+	first, second := *task, *task
+	first.Revision, second.Revision = 0, 0
+	first.Title = "Renamed"
+	second.Priority = "high"
+	second.Labels = []string{"urgent"}
+	if err := repo.UpdateFields(&first, []string{"title"}); err != nil {
+		t.Fatalf("UpdateFields failed: %v", err)
+	}
+	if err := repo.UpdateFields(&second, []string{"priority", "labels"}); err != nil {
+		t.Fatalf("UpdateFields failed: %v", err)
+	}
+
+	found, err := repo.GetByID(task.ID)
+	if err != nil {
+		t.Fatalf("GetByID failed: %v", err)
+	}
+	if found.Title != "Renamed" || found.Priority != "high" || len(found.Labels) != 1 {
+		t.Errorf("Expected both edits kept, got %+v", found)
+	}
+	if found.Revision != 3 || second.Revision != 3 {
+		t.Errorf("Expected revision 3, got %d (writer saw %d)", found.Revision, second.Revision)
+	}
+
+	// A writer expecting an older revision is refused
+	stale := *task
+	stale.Title = "Stale"
+	if err := repo.UpdateFields(&stale, []string{"title"}); err != ErrRevisionConflict {
+		t.Errorf("Expected a revision conflict, got %v", err)
+	}
+	if err := repo.Update(&stale); err != ErrRevisionConflict {
+		t.Errorf("Expected a revision conflict, got %v", err)
+	}
+	found.Title = "Current"
+	if err := repo.Update(found); err != nil || found.Revision != 4 {
+		t.Errorf("Expected an update at the current revision to succeed, got %v (revision %d)", err, found.Revision)
+	}
+
+	if err := repo.UpdateFields(&first, []string{"board_id"}); err == nil {
+		t.Error("Expected an error for a read-only field")
+	}
+	missing := domain.Task{ID: "missing"}
+	if err := repo.UpdateFields(&missing, []string{"title"}); err == nil {
+		t.Error("Expected an error for a missing task")
+	}
+}
+
+func TestTaskRepositoryMove(t *testing.T) {
+	db, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer db.Close()
+
+	project := &domain.Project{Name: "Move", Path: "/tmp/move"}
+	if err := NewProjectRepository(db).Create(project); err != nil {
+		t.Fatalf("failed to create project: %v", err)
+	}
+	board := &domain.Board{ProjectID: project.ID, Name: "Main"}
+	if err := NewBoardRepository(db).Create(board); err != nil {
+		t.Fatalf("failed to create board: %v", err)
+	}
+
+	repo := NewTaskRepository(db)
+	for _, id := range []string{"a", "b", "c", "d"} {
+		status := "todo"
+		if id == "d" {
+			status = "doing"
+		}
+		if err := repo.Create(&domain.Task{ID: id, BoardID: board.ID, Title: id, Status: status}); err != nil {
+			t.Fatalf("failed to create task %s: %v", id, err)
+		}
+	}
+
+	column := func(status string) []string {
+		tasks, err := repo.ListByBoard(board.ID)
+		if err != nil {
+			t.Fatalf("ListByBoard failed: %v", err)
+		}
+		var ids []string
+		for _, task := range tasks {
+			if task.Status == status {
+				ids = append(ids, task.ID)
+			}
+		}
+		return ids
+	}
+	move := func(id, status string, position, limit int) (int, int, error) {
+		task, err := repo.GetByID(id)
+		if err != nil {
+			t.Fatalf("GetByID failed: %v", err)
+		}
+		task.Status = status
+		return repo.Move(task, nil, position, limit)
+	}
+
+	if got := column("todo"); !reflect.DeepEqual(got, []string{"a", "b", "c"}) {
+		t.Fatalf("Expected creation order, got %v", got)
+	}
+
+	// Repeatedly moving to the same spot exhausts the gap between the
+	// neighbours and forces the column to be reranked
+	for i := 0; i < 60; i++ {
+		from := "c"
+		if i%2 == 1 {
+			from = "b"
+		}
+		if _, _, err := move(from, "todo", 1, 0); err != nil {
+			t.Fatalf("Move %d failed: %v", i, err)
+		}
+	}
+	if got := column("todo"); !reflect.DeepEqual(got, []string{"a", "b", "c"}) {
+		t.Errorf("Expected a, b, c after reordering, got %v", got)
+	}
+
+	position, size, err := move("a", "doing", 0, 2)
+	if err != nil {
+		t.Fatalf("Move across columns failed: %v", err)
+	}
+	if position != 0 || size != 2 {
+		t.Errorf("Expected position 0 of 2, got %d of %d", position, size)
+	}
+	if got := column("doing"); !reflect.DeepEqual(got, []string{"a", "d"}) {
+		t.Errorf("Expected a, d, got %v", got)
+	}
+
+	// The column is now full, but reordering within it is still allowed
+	if _, _, err := move("b", "doing", 0, 2); !errors.Is(err, ErrWIPLimitExceeded) {
+		t.Errorf("Expected ErrWIPLimitExceeded, got %v", err)
+	}
+	if _, _, err := move("a", "doing", 99, 2); err != nil {
+		t.Errorf("Expected reorder within a full column to succeed: %v", err)
+	}
+	if got := column("doing"); !reflect.DeepEqual(got, []string{"d", "a"}) {
+		t.Errorf("Expected d, a, got %v", got)
+	}
+}
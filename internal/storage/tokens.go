@@ -0,0 +1,206 @@
+package storage
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rand/cartographer/internal/domain"
+)
+
+// tokenPrefix marks Cartographer secrets so they are easy to spot in logs
+// and config files
+const tokenPrefix = "carto_"
+
+// touchInterval limits how often a token's last use is written
+const touchInterval = time.Minute
+
+// APITokenRepository handles API token storage
+type APITokenRepository struct {
+	db *DB
+}
+
+// NewAPITokenRepository creates a new API token repository
+func NewAPITokenRepository(db *DB) *APITokenRepository {
+	return &APITokenRepository{db: db}
+}
+
+// Create mints a token and returns its secret. Only the secret's hash is
+// stored, so this is the one chance to see it.
+func (r *APITokenRepository) Create(token *domain.APIToken) (string, error) {
+	if !domain.ValidScope(token.Scope) {
+		return "", fmt.Errorf("invalid scope: %q", token.Scope)
+	}
+	if token.ID == "" {
+		token.ID = uuid.New().String()
+	}
+	if token.User.ID == "" {
+		token.User.ID = token.Name
+	}
+	if token.User.Type == "" {
+		token.User.Type = "human"
+	}
+	token.CreatedAt = time.Now().UTC()
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	secret := tokenPrefix + base64.RawURLEncoding.EncodeToString(raw)
+
+	user, _ := json.Marshal(token.User)
+	projectIDs, _ := json.Marshal(nonNil(token.ProjectIDs))
+
+	query := `
+		INSERT INTO api_tokens (id, name, token_hash, user, scope, project_ids, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	_, err := r.db.Conn().Exec(query,
+		token.ID, token.Name, hashToken(secret), string(user), token.Scope,
+		string(projectIDs), token.CreatedAt, token.ExpiresAt,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	return secret, nil
+}
+
+// GetBySecret returns the token with the given secret, or nil if there is
+// none. Revoked and expired tokens are returned too; check Active.
+func (r *APITokenRepository) GetBySecret(secret string) (*domain.APIToken, error) {
+	return r.getOne(`WHERE token_hash = ?`, hashToken(secret))
+}
+
+// GetByID retrieves a token by ID
+func (r *APITokenRepository) GetByID(id string) (*domain.APIToken, error) {
+	token, err := r.getOne(`WHERE id = ?`, id)
+	if err == nil && token == nil {
+		return nil, fmt.Errorf("token %w: %s", ErrNotFound, id)
+	}
+	return token, err
+}
+
+// List returns every token, oldest first
+func (r *APITokenRepository) List() ([]*domain.APIToken, error) {
+	query := `
+		SELECT id, name, user, scope, project_ids, created_at, expires_at, last_used_at, revoked_at
+		FROM api_tokens
+		ORDER BY created_at
+	`
+
+	rows, err := r.db.Conn().Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []*domain.APIToken{}
+	for rows.Next() {
+		token, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+
+	return tokens, rows.Err()
+}
+
+// Revoke revokes a token so it no longer authenticates
+func (r *APITokenRepository) Revoke(id string) error {
+	result, err := r.db.Conn().Exec(
+		`UPDATE api_tokens SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL`,
+		time.Now().UTC(), id,
+	)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("token %w: %s", ErrNotFound, id)
+	}
+
+	return nil
+}
+
+// Touch records that a token was used at the given time. Writes are skipped
+// if the recorded use is less than a minute old.
+func (r *APITokenRepository) Touch(id string, at time.Time) error {
+	_, err := r.db.Conn().Exec(
+		`UPDATE api_tokens SET last_used_at = ? WHERE id = ? AND (last_used_at IS NULL OR last_used_at < ?)`,
+		at.UTC(), id, at.UTC().Add(-touchInterval),
+	)
+	return err
+}
+
+func (r *APITokenRepository) getOne(where string, arg string) (*domain.APIToken, error) {
+	query := `
+		SELECT id, name, user, scope, project_ids, created_at, expires_at, last_used_at, revoked_at
+		FROM api_tokens
+	` + where
+
+	token, err := scanAPIToken(r.db.Conn().QueryRow(query, arg))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return token, err
+}
+
+func scanAPIToken(row rowScanner) (*domain.APIToken, error) {
+	token := &domain.APIToken{}
+	var user, projectIDs string
+	var expiresAt, lastUsedAt, revokedAt sql.NullTime
+
+	err := row.Scan(&token.ID, &token.Name, &user, &token.Scope, &projectIDs,
+		&token.CreatedAt, &expiresAt, &lastUsedAt, &revokedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(user), &token.User); err != nil {
+		return nil, fmt.Errorf("invalid user for token %s: %w", token.ID, err)
+	}
+	if err := json.Unmarshal([]byte(projectIDs), &token.ProjectIDs); err != nil {
+		return nil, fmt.Errorf("invalid project IDs for token %s: %w", token.ID, err)
+	}
+	if len(token.ProjectIDs) == 0 {
+		token.ProjectIDs = nil
+	}
+	if expiresAt.Valid {
+		token.ExpiresAt = &expiresAt.Time
+	}
+	if lastUsedAt.Valid {
+		token.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		token.RevokedAt = &revokedAt.Time
+	}
+
+	return token, nil
+}
+
+// hashToken returns the hex SHA-256 of a secret
+func hashToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// nonNil returns an empty slice in place of nil, so it encodes as []
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rand/cartographer/internal/domain"
+)
+
+func TestAPITokenRepository(t *testing.T) {
+	db, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewAPITokenRepository(db)
+
+	if _, err := repo.Create(&domain.APIToken{Name: "bad", Scope: "root"}); err == nil {
+		t.Error("Expected an error for an unknown scope")
+	}
+
+	token := &domain.APIToken{
+		Name:       "triage-bot",
+		User:       domain.User{Type: "agent"},
+		Scope:      domain.ScopeWrite,
+		ProjectIDs: []string{"project-1"},
+	}
+	secret, err := repo.Create(token)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if !strings.HasPrefix(secret, tokenPrefix) || len(secret) < 40 {
+		t.Errorf("Unexpected secret %q", secret)
+	}
+	if token.User.ID != "triage-bot" {
+		t.Errorf("Expected the user to default to the name, got %+v", token.User)
+	}
+
+	found, err := repo.GetBySecret(secret)
+	if err != nil || found == nil {
+		t.Fatalf("GetBySecret failed: %+v (%v)", found, err)
+	}
+	if found.ID != token.ID || found.User.Type != "agent" || !found.Active(time.Now()) {
+		t.Errorf("Unexpected token %+v", found)
+	}
+	if !found.Allows(domain.ScopeRead) || found.Allows(domain.ScopeAdmin) {
+		t.Errorf("Expected write to include read but not admin")
+	}
+	if !found.AllowsProject("project-1") || found.AllowsProject("project-2") {
+		t.Errorf("Expected access to project-1 only, got %v", found.ProjectIDs)
+	}
+
+	if found, err := repo.GetBySecret(secret + "x"); err != nil || found != nil {
+		t.Errorf("Expected no token for a wrong secret, got %+v (%v)", found, err)
+	}
+
+	// Uses are recorded at most once a minute
+	now := time.Now()
+	if err := repo.Touch(token.ID, now); err != nil {
+		t.Fatalf("Touch failed: %v", err)
+	}
+	if err := repo.Touch(token.ID, now.Add(time.Second)); err != nil {
+		t.Fatalf("Touch failed: %v", err)
+	}
+	found, _ = repo.GetByID(token.ID)
+	if found.LastUsedAt == nil || !found.LastUsedAt.Equal(now) {
+		t.Errorf("Expected last use at %v, got %v", now, found.LastUsedAt)
+	}
+
+	if err := repo.Revoke(token.ID); err != nil {
+		t.Fatalf("Revoke failed: %v", err)
+	}
+	if err := repo.Revoke(token.ID); err == nil {
+		t.Error("Expected revoking twice to fail")
+	}
+	found, _ = repo.GetBySecret(secret)
+	if found == nil || found.Active(time.Now()) {
+		t.Errorf("Expected revoked token to be inactive, got %+v", found)
+	}
+
+	tokens, err := repo.List()
+	if err != nil || len(tokens) != 1 {
+		t.Errorf("Expected 1 token, got %d (%v)", len(tokens), err)
+	}
+}
@@ -0,0 +1,48 @@
+// Package testutil sets up the databases and beads issues files the API
+// packages' tests share
+package testutil
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rand/cartographer/internal/storage"
+	bd "github.com/steveyegge/beads"
+)
+
+// NewDB creates a database under dir, closed when the test ends
+func NewDB(t testing.TB, dir string) *storage.DB {
+	t.Helper()
+	db, err := storage.New(filepath.Join(dir, "data"))
+	if err != nil {
+		t.Fatalf("storage.New failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// WriteBeads writes two open beads issues to dir/.beads/issues.jsonl: bd-1,
+// "Parser", and bd-2, "Renderer", blocked by bd-1
+func WriteBeads(t testing.TB, dir string) {
+	t.Helper()
+	now := time.Now()
+	var lines []string
+	for _, issue := range []*bd.Issue{
+		{ID: "bd-1", Title: "Parser", Status: bd.StatusOpen, Priority: 1, IssueType: bd.TypeTask, CreatedAt: now, UpdatedAt: now},
+		{ID: "bd-2", Title: "Renderer", Status: bd.StatusOpen, Priority: 2, IssueType: bd.TypeTask, CreatedAt: now, UpdatedAt: now,
+			Dependencies: []*bd.Dependency{{IssueID: "bd-2", DependsOnID: "bd-1", Type: bd.DepBlocks, CreatedAt: now}}},
+	} {
+		line, _ := json.Marshal(issue)
+		lines = append(lines, string(line))
+	}
+	if err := os.MkdirAll(filepath.Join(dir, ".beads"), 0755); err != nil {
+		t.Fatalf("failed to create .beads: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".beads", "issues.jsonl"), []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatalf("failed to write issues: %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+// API token handling for the web UI
+//
+// The API requires a bearer token. The UI keeps one in localStorage, adds it
+// to every same-origin API request and asks for it the first time a request
+// is rejected. WebSockets can't carry headers, so their URLs carry it as a
+// query parameter instead.
+
+(function() {
+	'use strict';
+
+	const STORAGE_KEY = 'cartographer_token';
+	const nativeFetch = window.fetch.bind(window);
+
+	function getToken() {
+		return localStorage.getItem(STORAGE_KEY) || '';
+	}
+
+	function promptForToken(message) {
+		const token = window.prompt(message);
+		if (token) {
+			localStorage.setItem(STORAGE_KEY, token.trim());
+			return true;
+		}
+		return false;
+	}
+
+	function isAPIRequest(url) {
+		const parsed = new URL(url, window.location.href);
+		return parsed.origin === window.location.origin && parsed.pathname.startsWith('/api/');
+	}
+
+	function withToken(init) {
+		const headers = new Headers((init && init.headers) || {});
+		const token = getToken();
+		if (token) {
+			headers.set('Authorization', `Bearer ${token}`);
+		}
+		return { ...init, headers };
+	}
+
+	window.fetch = async (input, init) => {
+		const url = typeof input === 'string' ? input : input.url;
+		if (!isAPIRequest(url)) {
+			return nativeFetch(input, init);
+		}
+
+		const response = await nativeFetch(input, withToken(init));
+		if (response.status !== 401) {
+			return response;
+		}
+
+		const message = getToken()
+			? 'Your Cartographer API token was rejected. Enter a new token:'
+			: 'Enter a Cartographer API token (create one with `cartographer token create --name <you> --scope write`):';
+		if (!promptForToken(message)) {
+			return response;
+		}
+		return nativeFetch(input, withToken(init));
+	};
+
+	window.cartographerAuth = {
+		token: getToken,
+
+		// wsURL returns the WebSocket URL for path with the token attached
+		wsURL(path) {
+			const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
+			const url = new URL(`${protocol}//${window.location.host}${path}`);
+			const token = getToken();
+			if (token) {
+				url.searchParams.set('access_token', token);
+			}
+			return url.toString();
+		},
+
+		signOut() {
+			localStorage.removeItem(STORAGE_KEY);
+		}
+	};
+})();
@@ -25,6 +25,58 @@ class BeadsViewer {
     init() {
         this.attachEventListeners();
         this.loadIssues();
+        this.connectLiveUpdates();
+    }
+
+    // Follow changes to .beads/issues.jsonl as the server detects them
+    connectLiveUpdates() {
+        const ws = new WebSocket(window.cartographerAuth.wsURL('/ws'));
+        const reconnected = this.liveUpdatesConnected;
+
+        ws.onopen = () => {
+            this.liveUpdatesConnected = true;
+            ws.send(JSON.stringify({ type: 'subscribe', resource: 'bead:*' }));
+            if (reconnected) {
+                // Changes may have been missed while disconnected
+                this.loadIssues();
+            }
+        };
+
+        ws.onmessage = (event) => {
+            // The server may batch several messages into one frame
+            event.data.split('\n').forEach(line => {
+                try {
+                    const message = JSON.parse(line);
+                    if (message.type && message.type.startsWith('bead.')) {
+                        this.applyBeadEvent(message);
+                    }
+                } catch (error) {
+                    console.error('Failed to parse WebSocket message:', error);
+                }
+            });
+        };
+
+        ws.onclose = () => {
+            setTimeout(() => this.connectLiveUpdates(), 2000);
+        };
+    }
+
+    applyBeadEvent(message) {
+        const { issue_id: issueId, issue } = message.data;
+        const index = this.issues.findIndex(i => i.id === issueId);
+
+        if (message.type === 'bead.deleted') {
+            if (index !== -1) {
+                this.issues.splice(index, 1);
+            }
+        } else if (index !== -1) {
+            this.issues[index] = issue;
+        } else {
+            this.issues.push(issue);
+        }
+
+        this.applyFilters();
+        this.updateStats();
     }
 
     attachEventListeners() {
@@ -334,88 +386,78 @@ class BeadsViewer {
                 <svg width="16" height="16" viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2" style="margin-right: 4px;">
                     <path d="M12 5v14M5 12h14"></path>
                 </svg>
-                Create Task from Issue
+                Import Beads to Board
             </button>
         `;
 
         // Attach create task handler
         document.getElementById('createTaskBtn').addEventListener('click', () => {
-            this.createTaskFromIssue(issue);
+            this.importIssuesToBoard(issue);
         });
 
         this.openModal();
     }
 
-    async createTaskFromIssue(issue) {
+    async importIssuesToBoard(issue) {
         try {
-            // First, get list of available boards
-            const boardsResponse = await fetch('/api/boards');
-            if (!boardsResponse.ok) {
-                throw new Error('Failed to fetch boards');
-            }
-            const boards = await boardsResponse.json();
-
-            if (!boards || boards.length === 0) {
+            const board = await this.findImportBoard();
+            if (!board) {
                 alert('No boards available. Please create a project and board first.');
                 return;
             }
 
-            // For now, use the first available board
-            // In a future enhancement, we could show a board selector
-            const board = boards[0];
-
-            // Map beads priority to task priority
-            const priorityMap = {
-                0: 'urgent',
-                1: 'high',
-                2: 'medium',
-                3: 'low'
-            };
-
-            // Create task with linked beads issue
-            const taskData = {
-                board_id: board.id,
-                title: issue.title,
-                description: issue.description || `Linked from beads issue ${issue.id}`,
-                status: issue.status === 'closed' ? 'Done' : (issue.status === 'in_progress' ? 'In Progress' : 'To Do'),
-                priority: priorityMap[issue.priority] || 'medium',
-                labels: issue.labels || [],
-                linked_items: [
-                    {
-                        type: 'bead',
-                        id: issue.id
-                    }
-                ]
-            };
-
-            const response = await fetch('/api/tasks', {
-                method: 'POST',
-                headers: {
-                    'Content-Type': 'application/json'
-                },
-                body: JSON.stringify(taskData)
+            // The server imports every bead in one transaction, upserting
+            // tasks already linked to a bead instead of duplicating them
+            const response = await fetch(`/api/boards/${board.id}/import/beads`, {
+                method: 'POST'
             });
 
             if (!response.ok) {
-                throw new Error(`Failed to create task: ${response.statusText}`);
+                const body = await response.json().catch(() => ({}));
+                throw new Error(body.message || response.statusText);
             }
 
-            const createdTask = await response.json();
+            const report = await response.json();
+            const skipped = report.skipped.filter(s => s.bead_id === issue.id);
+            const status = skipped.length > 0 && skipped[0].reason !== 'unchanged'
+                ? `Issue ${issue.id} was skipped: ${skipped[0].reason}`
+                : `Imported into board "${board.name}": ${report.created.length} created, ${report.updated.length} updated, ${report.skipped.length} skipped`;
 
-            // Show success message
+            // Show result
             const modalFooter = document.getElementById('modalFooter');
             modalFooter.innerHTML = `
                 <div class="success-message" style="flex: 1; color: var(--color-success); font-size: var(--font-size-sm);">
-                    ✓ Task created successfully on board "${this.escapeHtml(board.name)}"
+                    ✓ ${this.escapeHtml(status)}
                 </div>
                 <a href="/static/board.html?id=${board.id}" class="btn btn-primary" target="_blank">
                     View Board
                 </a>
             `;
         } catch (error) {
-            console.error('Error creating task from issue:', error);
-            alert('Failed to create task. Error: ' + error.message);
+            console.error('Error importing beads:', error);
+            alert('Failed to import beads. Error: ' + error.message);
+        }
+    }
+
+    // findImportBoard returns the first board of the first project that has one
+    async findImportBoard() {
+        const projectsResponse = await fetch('/api/projects');
+        if (!projectsResponse.ok) {
+            throw new Error('Failed to fetch projects');
+        }
+        const projects = await projectsResponse.json() || [];
+
+        for (const project of projects) {
+            const boardsResponse = await fetch(`/api/boards?project_id=${encodeURIComponent(project.id)}`);
+            if (!boardsResponse.ok) {
+                throw new Error('Failed to fetch boards');
+            }
+            const boards = await boardsResponse.json();
+            if (boards && boards.length > 0) {
+                return boards[0];
+            }
         }
+        return null;
     }
 
     openModal() {
@@ -8,16 +8,23 @@ const API = {
 	async fetch(endpoint, options = {}) {
 		const url = `${this.baseURL}${endpoint}`;
 		const response = await fetch(url, {
+			...options,
 			headers: {
 				'Content-Type': 'application/json',
 				...options.headers
-			},
-			...options
+			}
 		});
 
 		if (!response.ok) {
-			const error = await response.text();
-			throw new Error(`API Error: ${response.statusText} - ${error}`);
+			// Errors come as {code, message, field_errors}
+			const body = await response.json().catch(() => ({}));
+			const fieldErrors = body.field_errors || [];
+			const details = fieldErrors.map(e => `${e.field} ${e.message}`).join(', ');
+			const err = new Error(`API Error: ${body.message || response.statusText}${details ? ` (${details})` : ''}`);
+			err.status = response.status;
+			err.code = body.code;
+			err.fieldErrors = fieldErrors;
+			throw err;
 		}
 
 		if (response.status === 204) {
@@ -46,8 +53,15 @@ const API = {
 		});
 	},
 
-	async getTasks(boardId) {
-		return this.fetch(`/api/tasks?board_id=${boardId}`);
+	// filters: { q, priority }, applied by the server
+	async getTasks(boardId, filters = {}) {
+		const params = new URLSearchParams({ board_id: boardId });
+		Object.entries(filters).forEach(([name, value]) => {
+			if (value) {
+				params.set(name, value);
+			}
+		});
+		return this.fetch(`/api/tasks?${params}`);
 	},
 
 	async createTask(task) {
@@ -58,12 +72,22 @@ const API = {
 	},
 
 	async updateTask(taskId, task) {
+		// Refuse to overwrite edits made since the task was loaded
+		const headers = task.revision ? { 'If-Match': `"${task.revision}"` } : {};
 		return this.fetch(`/api/tasks/${taskId}`, {
 			method: 'PUT',
+			headers,
 			body: JSON.stringify(task)
 		});
 	},
 
+	async moveTask(taskId, column, position) {
+		return this.fetch(`/api/tasks/${taskId}/move`, {
+			method: 'PATCH',
+			body: JSON.stringify({ column, position })
+		});
+	},
+
 	async deleteTask(taskId) {
 		return this.fetch(`/api/tasks/${taskId}`, {
 			method: 'DELETE'
@@ -79,11 +103,17 @@ class WebSocketManager {
 		this.maxReconnectAttempts = 5;
 		this.reconnectDelay = 1000;
 		this.listeners = new Map();
+		this.subscriptions = new Map();
+
+		// Event stream position, used to resume after reconnecting
+		this.stream = null;
+		this.lastSeq = 0;
+		this.resuming = false;
+		this.pending = [];
 	}
 
 	connect() {
-		const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
-		const wsURL = `${protocol}//${window.location.host}/ws`;
+		const wsURL = window.cartographerAuth.wsURL('/ws');
 
 		this.ws = new WebSocket(wsURL);
 
@@ -91,15 +121,23 @@ class WebSocketManager {
 			console.log('WebSocket connected');
 			this.reconnectAttempts = 0;
 			this.updateConnectionStatus(true);
+
+			// Restore subscriptions after (re)connecting
+			this.subscriptions.forEach((events, resource) => {
+				this.sendSubscription('subscribe', resource, events);
+			});
 		};
 
 		this.ws.onmessage = (event) => {
-			try {
-				const message = JSON.parse(event.data);
-				this.handleMessage(message);
-			} catch (error) {
-				console.error('Failed to parse WebSocket message:', error);
-			}
+			// The server may batch several messages into one frame
+			event.data.split('\n').forEach(line => {
+				try {
+					const message = JSON.parse(line);
+					this.handleMessage(message);
+				} catch (error) {
+					console.error('Failed to parse WebSocket message:', error);
+				}
+			});
 		};
 
 		this.ws.onerror = (error) => {
@@ -125,11 +163,73 @@ class WebSocketManager {
 		}
 	}
 
+	// Subscribe to events on a resource, e.g. subscribe('board:123', ['task.*'])
+	subscribe(resource, events = []) {
+		this.subscriptions.set(resource, events);
+		this.sendSubscription('subscribe', resource, events);
+	}
+
+	unsubscribe(resource) {
+		this.subscriptions.delete(resource);
+		this.sendSubscription('unsubscribe', resource);
+	}
+
+	sendSubscription(type, resource, events = []) {
+		if (this.ws && this.ws.readyState === WebSocket.OPEN) {
+			this.ws.send(JSON.stringify({ type, resource, events }));
+		}
+	}
+
 	handleMessage(message) {
+		switch (message.type) {
+			case 'connected':
+				if (this.stream && this.lastSeq > 0) {
+					// Reconnected: ask for everything missed while disconnected,
+					// holding live events until the replay arrives
+					this.resuming = true;
+					this.ws.send(JSON.stringify({ type: 'resume', stream: this.stream, after: this.lastSeq }));
+				} else {
+					this.stream = message.data.stream;
+					this.lastSeq = message.data.latest_seq;
+				}
+				break;
+
+			case 'resumed':
+				this.resuming = false;
+				(message.data.events || []).forEach(event => this.handleMessage(event));
+				this.flushPending();
+				return;
+
+			case 'resync_required':
+				// Missed events are gone; listeners reload their state instead
+				this.resuming = false;
+				this.pending = [];
+				this.stream = message.data.stream;
+				this.lastSeq = message.data.latest_seq;
+				break;
+		}
+
+		if (message.seq) {
+			if (this.resuming) {
+				this.pending.push(message);
+				return;
+			}
+			if (message.seq <= this.lastSeq) {
+				return; // already seen
+			}
+			this.lastSeq = message.seq;
+		}
+
 		const listeners = this.listeners.get(message.type) || [];
 		listeners.forEach(listener => listener(message));
 	}
 
+	flushPending() {
+		const pending = this.pending.sort((a, b) => a.seq - b.seq);
+		this.pending = [];
+		pending.forEach(message => this.handleMessage(message));
+	}
+
 	on(type, listener) {
 		if (!this.listeners.has(type)) {
 			this.listeners.set(type, []);
@@ -178,12 +278,18 @@ class KanbanBoard {
 			search: '',
 			priorities: new Set()
 		};
+		// IDs of the tasks the server found matching the filters, or null
+		// when there are none
+		this.matchingIds = null;
+		this.filterRequest = 0;
+		this.searchTimer = null;
 
 		this.wsManager = new WebSocketManager();
 		this.setupWebSocket();
 	}
 
 	setupWebSocket() {
+		this.wsManager.subscribe(`board:${this.boardId}`, ['task.*', 'board.*']);
 		this.wsManager.connect();
 
 		// Listen for task updates
@@ -204,6 +310,30 @@ class KanbanBoard {
 			}
 		});
 
+		this.wsManager.on('task.moved', (message) => {
+			if (message.data.board_id === this.boardId) {
+				const index = this.tasks.findIndex(t => t.id === message.data.task_id);
+				if (index !== -1 && message.data.task) {
+					this.tasks[index] = message.data.task;
+					this.render();
+				}
+			}
+		});
+
+		this.wsManager.on('task.batch', async (message) => {
+			const { created = [], updated = [], moved = [], deleted = [] } = message.data;
+			const events = [...created, ...updated, ...moved, ...deleted];
+			if (events.some(event => event.board_id === this.boardId)) {
+				this.tasks = await API.getTasks(this.boardId);
+				this.render();
+			}
+		});
+
+		this.wsManager.on('resync_required', async () => {
+			this.tasks = await API.getTasks(this.boardId);
+			this.render();
+		});
+
 		this.wsManager.on('task.deleted', (message) => {
 			const index = this.tasks.findIndex(t => t.id === message.task_id);
 			if (index !== -1) {
@@ -211,6 +341,15 @@ class KanbanBoard {
 				this.render();
 			}
 		});
+
+		// Changed tasks may now match the filters, or no longer
+		['task.created', 'task.updated', 'task.moved', 'task.batch', 'resync_required'].forEach(type => {
+			this.wsManager.on(type, () => {
+				if (this.hasFilters()) {
+					this.applyFilters();
+				}
+			});
+		});
 	}
 
 	async init() {
@@ -277,8 +416,10 @@ class KanbanBoard {
 			url.searchParams.set('id', boardId);
 			window.history.pushState({}, '', url);
 
-			// Update board ID
+			// Update board ID and event subscription
+			this.wsManager.unsubscribe(`board:${this.boardId}`);
 			this.boardId = boardId;
+			this.wsManager.subscribe(`board:${this.boardId}`, ['task.*', 'board.*']);
 
 			// Reload board data
 			this.board = await API.getBoard(this.boardId);
@@ -390,28 +531,40 @@ class KanbanBoard {
 		clearFiltersBtn.style.display = hasActiveFilters ? 'flex' : 'none';
 	}
 
-	getFilteredTasks() {
-		return this.tasks.filter(task => {
-			// Search filter
-			if (this.filters.search) {
-				const searchLower = this.filters.search;
-				const titleMatch = (task.title || '').toLowerCase().includes(searchLower);
-				const descMatch = (task.description || '').toLowerCase().includes(searchLower);
-				if (!titleMatch && !descMatch) {
-					return false;
-				}
-			}
+	hasFilters() {
+		return this.filters.search !== '' || this.filters.priorities.size > 0;
+	}
 
-			// Priority filter
-			if (this.filters.priorities.size > 0) {
-				const taskPriority = task.priority || 'medium';
-				if (!this.filters.priorities.has(taskPriority)) {
-					return false;
-				}
+	// Ask the server which tasks match the filters, then render them
+	async applyFilters() {
+		const request = ++this.filterRequest;
+		let matchingIds = null;
+		if (this.hasFilters()) {
+			try {
+				const tasks = await API.getTasks(this.boardId, {
+					q: this.filters.search,
+					priority: [...this.filters.priorities].join(',')
+				});
+				matchingIds = new Set(tasks.map(task => task.id));
+			} catch (error) {
+				console.error('Failed to filter tasks:', error);
+				this.showError('Failed to filter tasks');
+				return;
 			}
+		}
 
-			return true;
-		});
+		// A later change of filters wins
+		if (request === this.filterRequest) {
+			this.matchingIds = matchingIds;
+			this.render();
+		}
+	}
+
+	getFilteredTasks() {
+		if (this.matchingIds === null) {
+			return this.tasks;
+		}
+		return this.tasks.filter(task => this.matchingIds.has(task.id));
 	}
 
 	render() {
@@ -433,7 +586,9 @@ class KanbanBoard {
 
 	renderColumn(column) {
 		const filteredTasks = this.getFilteredTasks();
-		const tasks = filteredTasks.filter(t => t.status === column.id);
+		const tasks = filteredTasks
+			.filter(t => t.status === column.id)
+			.sort((a, b) => a.rank - b.rank);
 
 		return `
 			<div class="kanban-column" data-column-id="${column.id}">
@@ -583,33 +738,48 @@ class KanbanBoard {
 		e.currentTarget.classList.remove('drag-over');
 
 		const newColumnId = e.currentTarget.dataset.columnId;
-		const oldColumnId = this.draggedTask.status;
-
-		if (newColumnId !== oldColumnId) {
-			// Update task status
-			try {
-				const updatedTask = {
-					...this.draggedTask,
-					status: newColumnId
-				};
+		const position = this.dropPosition(e.currentTarget, newColumnId, e.clientY);
 
-				await API.updateTask(this.draggedTask.id, updatedTask);
+		try {
+			const move = await API.moveTask(this.draggedTask.id, newColumnId, position);
 
-				// Update local state
-				const index = this.tasks.findIndex(t => t.id === this.draggedTask.id);
-				if (index !== -1) {
-					this.tasks[index].status = newColumnId;
-					this.render();
-				}
-			} catch (error) {
-				console.error('Failed to update task:', error);
-				this.showError('Failed to update task');
+			// Update local state
+			const index = this.tasks.findIndex(t => t.id === move.task.id);
+			if (index !== -1) {
+				this.tasks[index] = move.task;
+				this.render();
+			}
+			(move.warnings || []).forEach(warning => this.showError(warning));
+		} catch (error) {
+			console.error('Failed to move task:', error);
+			if (error.status === 409 && error.message.includes('WIP limit')) {
+				this.showError(error.message);
+			} else {
+				await this.handleUpdateError(error);
 			}
 		}
 
 		return false;
 	}
 
+	dropPosition(columnBody, columnId, y) {
+		// The dragged card goes before the first card whose middle is
+		// below the drop point, counting every task in the column, since
+		// filters may hide some of them
+		const column = this.tasks
+			.filter(t => t.status === columnId && t.id !== this.draggedTask.id)
+			.sort((a, b) => a.rank - b.rank);
+		const cards = [...columnBody.querySelectorAll('.kanban-card')]
+			.filter(card => card.dataset.taskId !== this.draggedTask.id);
+		for (const card of cards) {
+			const box = card.getBoundingClientRect();
+			if (y < box.top + box.height / 2) {
+				return column.findIndex(t => t.id === card.dataset.taskId);
+			}
+		}
+		return column.length;
+	}
+
 	setupEventListeners() {
 		// Board selector
 		const boardSelectorBtn = document.getElementById('boardSelectorBtn');
@@ -638,16 +808,18 @@ class KanbanBoard {
 		const clearSearchBtn = document.getElementById('clearSearchBtn');
 
 		searchInput.addEventListener('input', (e) => {
-			this.filters.search = e.target.value.toLowerCase();
+			this.filters.search = e.target.value.trim();
 			clearSearchBtn.style.display = this.filters.search ? 'flex' : 'none';
-			this.render();
+			clearTimeout(this.searchTimer);
+			this.searchTimer = setTimeout(() => this.applyFilters(), 200);
 		});
 
 		clearSearchBtn.addEventListener('click', () => {
 			searchInput.value = '';
 			this.filters.search = '';
 			clearSearchBtn.style.display = 'none';
-			this.render();
+			clearTimeout(this.searchTimer);
+			this.applyFilters();
 		});
 
 		// Priority filter buttons
@@ -665,7 +837,7 @@ class KanbanBoard {
 				}
 
 				this.updateClearFiltersButton();
-				this.render();
+				this.applyFilters();
 			});
 		});
 
@@ -677,7 +849,7 @@ class KanbanBoard {
 				btn.classList.remove('active');
 			});
 			this.updateClearFiltersButton();
-			this.render();
+			this.applyFilters();
 		});
 
 		// New task button
@@ -1021,7 +1193,7 @@ class KanbanBoard {
 					modal.style.display = 'none';
 				} catch (error) {
 					console.error('Failed to update task:', error);
-					this.showError('Failed to update task');
+					await this.handleUpdateError(error);
 				}
 			});
 
@@ -1063,6 +1235,18 @@ class KanbanBoard {
 		// Simple error display - could be enhanced with a toast notification
 		alert(message);
 	}
+
+	async handleUpdateError(error) {
+		// 412 means the task was changed since it was loaded, so reload it
+		// rather than overwrite the newer edit
+		if (error.status === 412 || error.status === 409) {
+			this.tasks = await API.getTasks(this.boardId);
+			this.render();
+			this.showError('Task was changed by someone else and has been reloaded');
+			return;
+		}
+		this.showError('Failed to update task');
+	}
 }
 
 // Initialize board on page load
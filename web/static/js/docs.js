@@ -15,8 +15,10 @@ const API = {
 		});
 
 		if (!response.ok) {
-			const error = await response.text();
-			throw new Error(`API Error: ${response.statusText} - ${error}`);
+			// Errors come as {code, message, field_errors}
+			const body = await response.json().catch(() => ({}));
+			const details = (body.field_errors || []).map(e => `${e.field} ${e.message}`).join(', ');
+			throw new Error(`API Error: ${body.message || response.statusText}${details ? ` (${details})` : ''}`);
 		}
 
 		if (response.status === 204) {
@@ -4,11 +4,13 @@ class DependencyGraph {
     constructor() {
         this.issues = [];
         this.graph = null;
+        this.schedule = null;
         this.nodes = [];
         this.edges = [];
         this.selectedNode = null;
         this.showClosed = false;
         this.showOrphans = true;
+        this.showCriticalPath = true;
         this.layoutType = 'hierarchical';
 
         // SVG elements
@@ -44,6 +46,11 @@ class DependencyGraph {
             this.render();
         });
 
+        document.getElementById('showCriticalPath').addEventListener('change', (e) => {
+            this.showCriticalPath = e.target.checked;
+            this.render();
+        });
+
         document.getElementById('layoutType').addEventListener('change', (e) => {
             this.layoutType = e.target.value;
             this.render();
@@ -75,6 +82,8 @@ class DependencyGraph {
             this.issues = await issuesResponse.json();
             this.graph = await graphResponse.json();
 
+            await this.loadSchedule();
+
             this.loadingEl.classList.add('hidden');
         } catch (error) {
             console.error('Error loading graph data:', error);
@@ -82,6 +91,42 @@ class DependencyGraph {
         }
     }
 
+    async loadSchedule() {
+        // The graph still renders without a schedule, e.g. when dependencies form a cycle
+        try {
+            const response = await fetch('/api/beads/critical-path');
+            if (!response.ok) {
+                throw new Error(await response.text());
+            }
+            this.schedule = await response.json();
+        } catch (error) {
+            console.warn('Critical path unavailable:', error);
+            this.schedule = null;
+        }
+    }
+
+    getScheduleEntry(id) {
+        if (!this.schedule) return null;
+        return this.schedule.entries.find(entry => entry.id === id) || null;
+    }
+
+    isCriticalNode(id) {
+        return this.showCriticalPath && this.schedule !== null &&
+            this.schedule.critical_path.includes(id);
+    }
+
+    isCriticalEdge(edge) {
+        if (!this.showCriticalPath || !this.schedule) return false;
+        const path = this.schedule.critical_path;
+        for (let i = 1; i < path.length; i++) {
+            if ((edge.from === path[i - 1] && edge.to === path[i]) ||
+                (edge.from === path[i] && edge.to === path[i - 1])) {
+                return true;
+            }
+        }
+        return false;
+    }
+
     render() {
         // Filter issues
         const filteredIssues = this.getFilteredIssues();
@@ -428,7 +473,7 @@ class DependencyGraph {
                 : edge.type === 'related' ? 'url(#arrowhead-related)'
                 : 'url(#arrowhead)';
 
-            return `<line class="graph-edge ${edge.type}"
+            return `<line class="graph-edge ${edge.type} ${this.isCriticalEdge(edge) ? 'critical' : ''}"
                 x1="${x1}" y1="${y1}" x2="${x2}" y2="${y2}"
                 marker-end="${markerEnd}"
                 data-from="${edge.from}" data-to="${edge.to}" />`;
@@ -441,7 +486,7 @@ class DependencyGraph {
             const shortId = issue.id.split('-').pop();
 
             return `
-                <g class="graph-node" data-node-id="${node.id}" transform="translate(${node.x}, ${node.y})">
+                <g class="graph-node ${this.isCriticalNode(node.id) ? 'critical' : ''}" data-node-id="${node.id}" transform="translate(${node.x}, ${node.y})">
                     <circle class="node-circle status-${issue.status} ${issue.issue_type === 'epic' ? 'type-epic' : ''}"
                         r="${node.radius}" />
                     <text class="node-text" y="4">${shortId}</text>
@@ -593,6 +638,20 @@ class DependencyGraph {
             </div>
         `;
 
+        const entry = this.getScheduleEntry(issue.id);
+        if (entry) {
+            html += `
+                <div class="detail-section">
+                    <div class="detail-label">Schedule</div>
+                    <div class="detail-value">
+                        ${entry.minutes} min${entry.estimated ? '' : ' (default)'},
+                        starts at ${entry.earliest_start}–${entry.latest_start} min,
+                        ${entry.critical ? '<strong>on the critical path</strong>' : `${entry.slack} min slack`}
+                    </div>
+                </div>
+            `;
+        }
+
         if (issue.description) {
             html += `
                 <div class="detail-section">